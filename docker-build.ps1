@@ -90,7 +90,9 @@ if ($Clean) {
 Write-Status "Building Docker image: ${ImageName}:${Tag}"
 Write-Status "This may take several minutes..."
 
-$buildResult = docker build -t "${ImageName}:${Tag}" .
+$commit = git rev-parse --short HEAD 2>$null
+if (-not $commit) { $commit = "unknown" }
+$buildResult = docker build --build-arg VERSION="$Tag" --build-arg COMMIT="$commit" -t "${ImageName}:${Tag}" .
 if ($LASTEXITCODE -eq 0) {
     Write-Success "Docker image built successfully: ${ImageName}:${Tag}"
 } else {
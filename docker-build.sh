@@ -102,7 +102,10 @@ fi
 print_status "Building Docker image: $IMAGE_NAME:$TAG"
 print_status "This may take several minutes..."
 
-if docker build -t "$IMAGE_NAME:$TAG" .; then
+if docker build \
+    --build-arg VERSION="$TAG" \
+    --build-arg COMMIT="$(git rev-parse --short HEAD 2>/dev/null || echo unknown)" \
+    -t "$IMAGE_NAME:$TAG" .; then
     print_success "Docker image built successfully: $IMAGE_NAME:$TAG"
 else
     print_error "Failed to build Docker image"
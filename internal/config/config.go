@@ -1,27 +1,90 @@
 package config
 
 import (
+	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
 	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
 )
 
-// Config holds the application configuration
+// ConfigFileEnv names the environment variable pointing to the config file
+const ConfigFileEnv = "CONFIG_FILE"
+
+// Config holds the application configuration. Every setting has a key in the
+// config file, an environment variable overriding it and a default. Settings
+// tagged reload are applied on SIGHUP, the others need a restart.
 type Config struct {
 	// Server configuration
-	Host        string
-	Port        string
-	Debug       bool
-	FrontendURL string
+	Host        string   `key:"server.host" env:"HOST" default:"127.0.0.1"`
+	Port        string   `key:"server.port" env:"PORT" default:"5001"`
+	Debug       bool     `key:"server.debug" env:"DEBUG" default:"true" reload:"true"`
+	FrontendURL string   `key:"server.frontend_url" env:"FRONTEND_URL" default:"http://localhost:3000" reload:"true"`
+	CORSOrigins []string `key:"server.cors_origins" env:"CORS_ORIGINS" default:"http://localhost:3000,http://127.0.0.1:3000" reload:"true"` // allowed in addition to FrontendURL
+
+	// HTTP server timeouts and shutdown, in seconds
+	ReadTimeout     int `key:"server.read_timeout" env:"SERVER_READ_TIMEOUT" default:"30"`
+	WriteTimeout    int `key:"server.write_timeout" env:"SERVER_WRITE_TIMEOUT" default:"90"`
+	IdleTimeout     int `key:"server.idle_timeout" env:"SERVER_IDLE_TIMEOUT" default:"120"`
+	ShutdownDelay   int `key:"server.shutdown_delay" env:"SHUTDOWN_DELAY" default:"5"`      // time between reporting not ready and closing the listener
+	ShutdownTimeout int `key:"server.shutdown_timeout" env:"SHUTDOWN_TIMEOUT" default:"20"` // deadline for draining requests and stopping background workers
+
+	// Readiness checks
+	ReadinessCacheTTL int `key:"server.readiness_cache_ttl" env:"READINESS_CACHE_TTL" default:"10"` // seconds readiness results are reused
 
 	// File upload configuration
-	MaxFileSize int64 // in bytes
+	MaxFileSize int64  `key:"upload.max_file_size" env:"MAX_FILE_SIZE" default:"16777216" reload:"true"` // in bytes
+	TempDir     string `key:"upload.temp_dir" env:"TEMP_DIR" default:"/app/temp" reload:"true"`          // where uploads are stored while being parsed
 
 	// API configuration
-	APIEndpoint string
-	APIKey      string
-	APIUsername string
-	APIPassword string
-	APITimeout  int
+	APIEndpoint string `key:"ceisa.endpoint" env:"API_ENDPOINT" reload:"true"`
+	APIKey      string `key:"ceisa.api_key" env:"API_KEY" reload:"true"`
+	APIUsername string `key:"ceisa.username" env:"API_USERNAME" reload:"true"`
+	APIPassword string `key:"ceisa.password" env:"API_PASSWORD" reload:"true"`
+	APITimeout  int    `key:"ceisa.timeout" env:"API_TIMEOUT" default:"30" reload:"true"` // in seconds
+
+	// CEISA OAuth endpoints
+	OAuthTokenURL   string `key:"oauth.token_url" env:"OAUTH_TOKEN_URL" default:"https://apis-gw.beacukai.go.id/nle-oauth/v1/user/login" reload:"true"`
+	OAuthRefreshURL string `key:"oauth.refresh_url" env:"OAUTH_REFRESH_URL" default:"https://apis-gw.beacukai.go.id/nle-oauth/v1/user/update-token" reload:"true"`
+
+	// Token store configuration
+	TokenStorePath    string `key:"oauth.token_store.path" env:"TOKEN_STORE_PATH"`         // empty disables persistence
+	TokenStoreKeys    string `key:"oauth.token_store.keys" env:"TOKEN_STORE_KEYS"`         // comma separated base64 AES-256 keys, primary first
+	TokenStoreKeyFile string `key:"oauth.token_store.key_file" env:"TOKEN_STORE_KEY_FILE"` // file with one base64 key per line, primary first
+
+	// OAuth token refresh configuration
+	OAuthRefreshFraction float64 `key:"oauth.refresh_fraction" env:"OAUTH_REFRESH_FRACTION" default:"0.75"` // share of the token lifetime after which it is renewed
+	OAuthRefreshInterval int     `key:"oauth.refresh_interval" env:"OAUTH_REFRESH_INTERVAL" default:"15"`   // in seconds
+	OAuthJWKSFile        string  `key:"oauth.jwks_file" env:"OAUTH_JWKS_FILE"`                              // optional JWKS file used to verify CEISA tokens
+
+	// Authentication of this service's own API
+	AuthUsersFile string `key:"auth.users_file" env:"AUTH_USERS_FILE"` // JSON file with users and API keys, empty disables authentication
+
+	// Approval workflow configuration
+	DocumentStorePath string `key:"documents.store_path" env:"DOCUMENT_STORE_PATH"`                  // empty keeps documents in memory only
+	RequireApproval   bool   `key:"documents.require_approval" env:"REQUIRE_APPROVAL" reload:"true"` // only approved documents may be sent to CEISA, needs authentication; on by default when auth.users_file is set
+
+	// Multi-tenant mode
+	TenantsFile string `key:"tenants.file" env:"TENANTS_FILE"` // JSON file with the importers served, empty runs single-tenant
+
+	// Reference data
+	ReferencesDir string `key:"references.dir" env:"REFERENCES_DIR"` // directory of <table>@<version>.csv or .json code lists, replacing the shipped ones; must hold the kantor, pelabuhan, kemasan and satuan tables
+
+	// Exchange rates
+	KursStorePath string `key:"kurs.store_path" env:"KURS_STORE_PATH"` // empty keeps imported exchange rates in memory only
+
+	// Audit log
+	AuditLogPath string `key:"audit.log_path" env:"AUDIT_LOG_PATH"` // append-only hash-chained JSONL file, empty disables auditing
+
+	// Telemetry
+	OTLPEndpoint string `key:"telemetry.otlp_endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT"`                        // OpenTelemetry collector receiving traces over OTLP/HTTP, empty disables export
+	ServiceName  string `key:"telemetry.service_name" env:"OTEL_SERVICE_NAME" default:"json-response-generator"` // service name reported with exported traces
 }
 
 // AppConfig represents the configuration returned to the frontend
@@ -34,22 +97,145 @@ type AppConfig struct {
 	MaxFileSize int64  `json:"max_file_size"`
 }
 
-// Load loads configuration from environment variables
-func Load() *Config {
-	return &Config{
-		Host:        getEnv("HOST", "127.0.0.1"),
-		Port:        getEnv("PORT", "5001"),
-		Debug:       getEnvBool("DEBUG", true),
-		FrontendURL: getEnv("FRONTEND_URL", "http://localhost:3000"),
+// Error lists every problem found while loading the configuration
+type Error struct {
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
 
-		MaxFileSize: getEnvInt64("MAX_FILE_SIZE", 16*1024*1024), // 16MB
+// Load loads the configuration from the file named by CONFIG_FILE, if any,
+// with environment variables taking precedence
+func Load() (*Config, error) {
+	return LoadFile(os.Getenv(ConfigFileEnv))
+}
+
+// LoadFile loads the configuration from a YAML or TOML file, which may be
+// empty to use environment variables and defaults only. Malformed or unknown
+// settings are reported together rather than replaced by defaults.
+func LoadFile(path string) (*Config, error) {
+	cfg := &Config{}
+	var problems []string
+
+	file := map[string]string{}
+	if path != "" {
+		var err error
+		file, err = readFile(path)
+		if err != nil {
+			return nil, &Error{Problems: []string{err.Error()}}
+		}
+	}
+
+	known := map[string]bool{}
+	explicit := map[string]bool{}
+	forEachSetting(cfg, func(field reflect.StructField, value reflect.Value) {
+		key := field.Tag.Get("key")
+		env := field.Tag.Get("env")
+		known[key] = true
+
+		raw, source := field.Tag.Get("default"), "default"
+		if v, ok := file[key]; ok {
+			raw, source = v, path
+		}
+		if v := os.Getenv(env); v != "" {
+			raw, source = v, "environment"
+		}
+		explicit[key] = source != "default"
+
+		if err := setValue(value, raw); err != nil {
+			problems = append(problems, fmt.Sprintf("%s (%s): %v, set in %s", key, env, err, source))
+		}
+	})
+
+	for key := range file {
+		if !known[key] {
+			problems = append(problems, fmt.Sprintf("%s: unknown setting %s", path, key))
+		}
+	}
+
+	// Approval before submission is the rule once users authenticate, unless
+	// turned off explicitly
+	if !explicit["documents.require_approval"] {
+		cfg.RequireApproval = cfg.AuthUsersFile != ""
+	}
 
-		APIEndpoint: getEnv("API_ENDPOINT", ""),
-		APIKey:      getEnv("API_KEY", ""),
-		APIUsername: getEnv("API_USERNAME", ""),
-		APIPassword: getEnv("API_PASSWORD", ""),
-		APITimeout:  getEnvInt("API_TIMEOUT", 30),
+	// Values that failed to parse would be reported again by validation
+	sort.Strings(problems)
+	if len(problems) == 0 {
+		problems = cfg.validate()
 	}
+	if len(problems) > 0 {
+		return nil, &Error{Problems: problems}
+	}
+	return cfg, nil
+}
+
+// validate checks the values of the settings
+func (c *Config) validate() []string {
+	var problems []string
+	check := func(ok bool, setting, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, setting+": "+fmt.Sprintf(format, args...))
+		}
+	}
+
+	port, err := strconv.Atoi(c.Port)
+	check(err == nil && port > 0 && port < 65536, "server.port (PORT)", "%q is not a port number", c.Port)
+	check(c.ReadTimeout > 0, "server.read_timeout (SERVER_READ_TIMEOUT)", "must be positive")
+	check(c.WriteTimeout > c.APITimeout, "server.write_timeout (SERVER_WRITE_TIMEOUT)", "must exceed ceisa.timeout (%d) so submissions can finish", c.APITimeout)
+	check(c.IdleTimeout > 0, "server.idle_timeout (SERVER_IDLE_TIMEOUT)", "must be positive")
+	check(c.ShutdownDelay >= 0, "server.shutdown_delay (SHUTDOWN_DELAY)", "must not be negative")
+	check(c.ShutdownTimeout > 0, "server.shutdown_timeout (SHUTDOWN_TIMEOUT)", "must be positive")
+	check(c.ReadinessCacheTTL >= 0, "server.readiness_cache_ttl (READINESS_CACHE_TTL)", "must not be negative")
+	check(c.MaxFileSize > 0, "upload.max_file_size (MAX_FILE_SIZE)", "must be positive")
+	check(c.TempDir != "", "upload.temp_dir (TEMP_DIR)", "is required")
+	check(c.APITimeout > 0, "ceisa.timeout (API_TIMEOUT)", "must be positive")
+	check(c.OAuthRefreshFraction > 0 && c.OAuthRefreshFraction < 1, "oauth.refresh_fraction (OAUTH_REFRESH_FRACTION)", "must be between 0 and 1")
+	check(c.OAuthRefreshInterval > 0, "oauth.refresh_interval (OAUTH_REFRESH_INTERVAL)", "must be positive")
+	check(!c.RequireApproval || c.AuthUsersFile != "", "documents.require_approval (REQUIRE_APPROVAL)", "needs auth.users_file (AUTH_USERS_FILE) so someone other than the preparer can approve")
+
+	checkURL := func(value, setting string, required bool) {
+		if value == "" {
+			check(!required, setting, "is required")
+			return
+		}
+		parsed, err := url.Parse(value)
+		check(err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != "", setting, "%q is not an http(s) URL", value)
+	}
+	checkURL(c.FrontendURL, "server.frontend_url (FRONTEND_URL)", true)
+	for _, origin := range c.CORSOrigins {
+		checkURL(origin, "server.cors_origins (CORS_ORIGINS)", true)
+	}
+	checkURL(c.APIEndpoint, "ceisa.endpoint (API_ENDPOINT)", false)
+	checkURL(c.OAuthTokenURL, "oauth.token_url (OAUTH_TOKEN_URL)", true)
+	checkURL(c.OAuthRefreshURL, "oauth.refresh_url (OAUTH_REFRESH_URL)", true)
+	checkURL(c.OTLPEndpoint, "telemetry.otlp_endpoint (OTEL_EXPORTER_OTLP_ENDPOINT)", false)
+
+	return problems
+}
+
+// Reloaded returns next with the settings that need a restart kept at their
+// current values, and the keys of those that differ
+func (c *Config) Reloaded(next *Config) (*Config, []string) {
+	merged := *next
+	var ignored []string
+
+	current := reflect.ValueOf(c).Elem()
+	target := reflect.ValueOf(&merged).Elem()
+	forEachSetting(&merged, func(field reflect.StructField, value reflect.Value) {
+		if field.Tag.Get("reload") == "true" {
+			return
+		}
+		old := current.FieldByIndex(field.Index)
+		if !reflect.DeepEqual(old.Interface(), value.Interface()) {
+			ignored = append(ignored, field.Tag.Get("key"))
+		}
+		target.FieldByIndex(field.Index).Set(old)
+	})
+
+	return &merged, ignored
 }
 
 // ToAppConfig converts Config to AppConfig for frontend consumption
@@ -64,37 +250,119 @@ func (c *Config) ToAppConfig() *AppConfig {
 	}
 }
 
-// Helper functions
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// forEachSetting calls fn for every field of cfg
+func forEachSetting(cfg *Config, fn func(field reflect.StructField, value reflect.Value)) {
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		fn(t.Field(i), v.Field(i))
 	}
-	return defaultValue
 }
 
-func getEnvBool(key string, defaultValue bool) bool {
-	if value := os.Getenv(key); value != "" {
-		if parsed, err := strconv.ParseBool(value); err == nil {
-			return parsed
+// setValue parses raw into the setting
+func setValue(value reflect.Value, raw string) error {
+	raw = strings.TrimSpace(raw)
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Bool:
+		if raw == "" {
+			return nil
+		}
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", raw)
+		}
+		value.SetBool(parsed)
+	case reflect.Int, reflect.Int64:
+		if raw == "" {
+			return nil
+		}
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", raw)
+		}
+		value.SetInt(parsed)
+	case reflect.Float64:
+		if raw == "" {
+			return nil
+		}
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", raw)
+		}
+		value.SetFloat(parsed)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
 		}
+		value.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported setting type %s", value.Type())
 	}
-	return defaultValue
+	return nil
 }
 
-func getEnvInt(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		if parsed, err := strconv.Atoi(value); err == nil {
-			return parsed
-		}
+// readFile parses a YAML or TOML config file into settings keyed by their
+// dotted path, e.g. server.port
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var document map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &document)
+	case ".toml":
+		err = toml.Unmarshal(data, &document)
+	default:
+		return nil, fmt.Errorf("%s: unsupported config file format, use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
 	}
-	return defaultValue
+
+	settings := map[string]string{}
+	if err := flatten("", document, settings); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return settings, nil
 }
 
-func getEnvInt64(key string, defaultValue int64) int64 {
-	if value := os.Getenv(key); value != "" {
-		if parsed, err := strconv.ParseInt(value, 10, 64); err == nil {
-			return parsed
+// flatten stores the scalar and list values of a nested document by path
+func flatten(prefix string, document map[string]interface{}, settings map[string]string) error {
+	for name, value := range document {
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if err := flatten(key, v, settings); err != nil {
+				return err
+			}
+		case []interface{}:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				s := fmt.Sprint(item)
+				if strings.Contains(s, ",") {
+					return fmt.Errorf("%s: list items must not contain commas", key)
+				}
+				items = append(items, s)
+			}
+			settings[key] = strings.Join(items, ",")
+		case nil:
+			settings[key] = ""
+		default:
+			settings[key] = fmt.Sprint(v)
 		}
 	}
-	return defaultValue
+	return nil
 }
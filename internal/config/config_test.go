@@ -0,0 +1,193 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, err := LoadFile("")
+	if err != nil {
+		t.Fatalf("defaults must be valid: %v", err)
+	}
+	if cfg.Port != "5001" || cfg.APITimeout != 30 || cfg.MaxFileSize != 16*1024*1024 || cfg.RequireApproval {
+		t.Errorf("unexpected defaults: %+v", cfg)
+	}
+	if len(cfg.CORSOrigins) != 2 || cfg.CORSOrigins[0] != "http://localhost:3000" {
+		t.Errorf("unexpected default CORS origins: %v", cfg.CORSOrigins)
+	}
+}
+
+func TestRequireApprovalDefaultsToAuthentication(t *testing.T) {
+	users := filepath.Join(t.TempDir(), "users.json")
+	t.Setenv("AUTH_USERS_FILE", users)
+
+	cfg, err := LoadFile("")
+	if err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+	if !cfg.RequireApproval {
+		t.Error("approval should be required once authentication is configured")
+	}
+
+	t.Setenv("REQUIRE_APPROVAL", "false")
+	cfg, err = LoadFile("")
+	if err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+	if cfg.RequireApproval {
+		t.Error("an explicit REQUIRE_APPROVAL=false should turn approval off")
+	}
+}
+
+func TestLoadYAMLWithEnvOverride(t *testing.T) {
+	path := writeConfig(t, "config.yaml", `
+server:
+  port: 8080
+  cors_origins:
+    - https://app.example.com
+    - https://admin.example.com
+upload:
+  max_file_size: 1048576
+  temp_dir: /var/tmp/uploads
+ceisa:
+  endpoint: https://ceisa.example.com/api
+  timeout: 45
+oauth:
+  refresh_fraction: 0.5
+  token_store:
+    path: /data/tokens.json
+`)
+	t.Setenv("API_TIMEOUT", "60")
+
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+
+	if cfg.Port != "8080" || cfg.MaxFileSize != 1048576 || cfg.TempDir != "/var/tmp/uploads" {
+		t.Errorf("file settings not applied: %+v", cfg)
+	}
+	if strings.Join(cfg.CORSOrigins, " ") != "https://app.example.com https://admin.example.com" {
+		t.Errorf("unexpected CORS origins: %v", cfg.CORSOrigins)
+	}
+	if cfg.OAuthRefreshFraction != 0.5 || cfg.TokenStorePath != "/data/tokens.json" {
+		t.Errorf("nested settings not applied: %+v", cfg)
+	}
+	if cfg.APITimeout != 60 {
+		t.Errorf("API_TIMEOUT should override the file, got %d", cfg.APITimeout)
+	}
+}
+
+func TestLoadTOML(t *testing.T) {
+	path := writeConfig(t, "config.toml", `
+[server]
+port = 9090
+debug = false
+
+[oauth]
+token_url = "https://login.example.com/token"
+`)
+
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+	if cfg.Port != "9090" || cfg.Debug || cfg.OAuthTokenURL != "https://login.example.com/token" {
+		t.Errorf("TOML settings not applied: %+v", cfg)
+	}
+}
+
+func TestLoadReportsAllProblems(t *testing.T) {
+	path := writeConfig(t, "config.yaml", `
+server:
+  prot: 8080
+upload:
+  max_file_size: 16MB
+`)
+	t.Setenv("DEBUG", "maybe")
+
+	_, err := LoadFile(path)
+	var cfgErr *Error
+	if !errors.As(err, &cfgErr) {
+		t.Fatalf("expected a configuration error, got %v", err)
+	}
+
+	message := err.Error()
+	for _, want := range []string{
+		"unknown setting server.prot",
+		`upload.max_file_size (MAX_FILE_SIZE): "16MB" is not an integer`,
+		`server.debug (DEBUG): "maybe" is not a boolean, set in environment`,
+	} {
+		if !strings.Contains(message, want) {
+			t.Errorf("error should mention %q:\n%s", want, message)
+		}
+	}
+}
+
+func TestLoadValidatesValues(t *testing.T) {
+	t.Setenv("PORT", "70000")
+	t.Setenv("API_ENDPOINT", "ceisa.example.com")
+	t.Setenv("OAUTH_REFRESH_FRACTION", "1.5")
+	t.Setenv("SERVER_WRITE_TIMEOUT", "10")
+	t.Setenv("REQUIRE_APPROVAL", "true")
+
+	_, err := LoadFile("")
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+
+	message := err.Error()
+	for _, want := range []string{
+		"server.port (PORT)",
+		"ceisa.endpoint (API_ENDPOINT)",
+		"oauth.refresh_fraction (OAUTH_REFRESH_FRACTION)",
+		"server.write_timeout (SERVER_WRITE_TIMEOUT)",
+		"documents.require_approval (REQUIRE_APPROVAL)",
+	} {
+		if !strings.Contains(message, want) {
+			t.Errorf("error should mention %q:\n%s", want, message)
+		}
+	}
+}
+
+func TestLoadRejectsUnknownFormat(t *testing.T) {
+	path := writeConfig(t, "config.ini", "port=1")
+	if _, err := LoadFile(path); err == nil || !strings.Contains(err.Error(), "unsupported config file format") {
+		t.Errorf("expected an unsupported format error, got %v", err)
+	}
+}
+
+func TestReloadedKeepsStructuralSettings(t *testing.T) {
+	current, _ := LoadFile("")
+
+	next := *current
+	next.Port = "6000"
+	next.AuditLogPath = "/data/audit.jsonl"
+	next.MaxFileSize = 1024
+	next.CORSOrigins = []string{"https://app.example.com"}
+
+	merged, ignored := current.Reloaded(&next)
+
+	if merged.Port != current.Port || merged.AuditLogPath != current.AuditLogPath {
+		t.Errorf("structural settings must keep their values: %+v", merged)
+	}
+	if merged.MaxFileSize != 1024 || len(merged.CORSOrigins) != 1 {
+		t.Errorf("reloadable settings must apply: %+v", merged)
+	}
+	if strings.Join(ignored, ",") != "server.port,audit.log_path" {
+		t.Errorf("ignored = %v, want server.port and audit.log_path", ignored)
+	}
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"json-response-generator/internal/middleware"
+	"json-response-generator/internal/models"
+	"json-response-generator/internal/services"
+)
+
+// Approval Workflow Handlers
+
+// CreateDocument stores a declaration as a new draft
+func (h *Handlers) CreateDocument(c *gin.Context) {
+	var request models.DocumentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		middleware.HandleError(c, models.BindingError(err))
+		return
+	}
+
+	if request.JsonData.Declaration == nil {
+		middleware.HandleError(c, declarationRequired())
+		return
+	}
+	h.jsonGenerator.PrefillTenantDefaults(request.JsonData.Declaration, h.tenantDefaults(c))
+
+	document, err := h.documents.Create(request.JsonData.Declaration, middleware.CurrentPrincipal(c), h.tenantID(c))
+	if err != nil {
+		h.handleDocumentError(c, err)
+		return
+	}
+
+	h.audit(c, models.AuditEntry{
+		Action:    services.AuditDocumentCreate,
+		Target:    document.ID,
+		AfterHash: services.HashValue(document.Data),
+	})
+
+	middleware.Logger(c).WithFields(logrus.Fields{
+		"document_id": document.ID,
+		"nomor_aju":   document.NomorAju,
+		"user":        document.CreatedBy,
+	}).Info("Declaration draft created")
+
+	c.JSON(http.StatusCreated, models.ApiResponse{
+		Success: true,
+		Data:    document,
+		Message: "Draft created",
+	})
+}
+
+// ListDocuments returns declarations, optionally filtered by ?status=
+func (h *Handlers) ListDocuments(c *gin.Context) {
+	middleware.HandleSuccess(c, h.documents.List(h.tenantID(c), c.Query("status")))
+}
+
+// GetDocument returns a declaration with its workflow history
+func (h *Handlers) GetDocument(c *gin.Context) {
+	document, err := h.getDocument(c, c.Param("id"))
+	if err != nil {
+		h.handleDocumentError(c, err)
+		return
+	}
+
+	middleware.HandleSuccess(c, document)
+}
+
+// UpdateDocument replaces the data of a draft declaration
+func (h *Handlers) UpdateDocument(c *gin.Context) {
+	var request models.DocumentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		middleware.HandleError(c, models.BindingError(err))
+		return
+	}
+
+	if request.JsonData.Declaration == nil {
+		middleware.HandleError(c, declarationRequired())
+		return
+	}
+
+	previous, err := h.getDocument(c, c.Param("id"))
+	if err != nil {
+		h.handleDocumentError(c, err)
+		return
+	}
+
+	document, err := h.documents.Update(c.Param("id"), request.JsonData.Declaration, middleware.CurrentPrincipal(c))
+	if err != nil {
+		h.handleDocumentError(c, err)
+		return
+	}
+
+	h.audit(c, models.AuditEntry{
+		Action:     services.AuditDocumentUpdate,
+		Target:     document.ID,
+		BeforeHash: services.HashValue(previous.Data),
+		AfterHash:  services.HashValue(document.Data),
+	})
+
+	middleware.HandleSuccess(c, document, "Draft updated")
+}
+
+// TransitionDocument moves a declaration through the approval workflow
+func (h *Handlers) TransitionDocument(c *gin.Context) {
+	var request models.DocumentTransitionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		middleware.HandleError(c, models.BindingError(err))
+		return
+	}
+
+	previous, err := h.getDocument(c, c.Param("id"))
+	if err != nil {
+		h.handleDocumentError(c, err)
+		return
+	}
+
+	principal := middleware.CurrentPrincipal(c)
+	document, err := h.documents.Transition(c.Param("id"), request.Status, principal, request.Comment)
+
+	entry := models.AuditEntry{
+		Action:  services.AuditDocumentTransition,
+		Target:  previous.ID,
+		Outcome: auditOutcome(err),
+		Detail:  previous.Status + " -> " + request.Status,
+	}
+	if request.Comment != "" {
+		entry.Detail += ": " + request.Comment
+	}
+	h.audit(c, entry)
+
+	if err != nil {
+		h.handleDocumentError(c, err)
+		return
+	}
+
+	middleware.Logger(c).WithFields(logrus.Fields{
+		"document_id": document.ID,
+		"status":      document.Status,
+		"user":        principal.Username,
+	}).Info("Declaration status changed")
+
+	middleware.HandleSuccess(c, document, "Status changed to "+document.Status)
+}
+
+// RecordDocumentResponse stores the CEISA response to a submitted declaration
+func (h *Handlers) RecordDocumentResponse(c *gin.Context) {
+	var request models.DocumentResponseRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		middleware.HandleError(c, models.BindingError(err))
+		return
+	}
+
+	if _, err := h.getDocument(c, c.Param("id")); err != nil {
+		h.handleDocumentError(c, err)
+		return
+	}
+
+	document, err := h.documents.RecordResponse(c.Param("id"), middleware.CurrentPrincipal(c), request.Response, request.Comment)
+	if err != nil {
+		h.handleDocumentError(c, err)
+		return
+	}
+
+	h.audit(c, models.AuditEntry{
+		Action:    services.AuditDocumentResponse,
+		Target:    document.ID,
+		AfterHash: services.HashValue(request.Response),
+	})
+
+	middleware.HandleSuccess(c, document, "Response recorded")
+}
+
+// getDocument returns a document of the request's tenant; documents of other
+// tenants are reported as not found
+func (h *Handlers) getDocument(c *gin.Context, id string) (*models.Document, error) {
+	document, err := h.documents.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if document.TenantID != h.tenantID(c) {
+		return nil, services.ErrDocumentNotFound
+	}
+	return document, nil
+}
+
+// handleDocumentError maps document store errors to HTTP responses
+func (h *Handlers) handleDocumentError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrDocumentNotFound):
+		middleware.HandleError(c, models.NewError(models.ErrCodeNotFound, "Document not found", nil))
+	case errors.Is(err, services.ErrTransitionForbidden):
+		middleware.HandleError(c, models.NewError(models.ErrCodeForbidden, err.Error(), nil))
+	case errors.Is(err, services.ErrInvalidTransition):
+		middleware.HandleError(c, models.NewError(models.ErrCodeInvalidTransition, err.Error(), nil))
+	default:
+		middleware.HandleError(c, models.NewError(models.ErrCodeInternal, "Failed to update document", err))
+	}
+}
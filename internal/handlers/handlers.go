@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -8,6 +9,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -17,6 +19,7 @@ import (
 	"json-response-generator/internal/middleware"
 	"json-response-generator/internal/models"
 	"json-response-generator/internal/services"
+	"json-response-generator/internal/version"
 )
 
 // Handlers contains all the HTTP handlers
@@ -25,80 +28,181 @@ type Handlers struct {
 	excelHandler  *services.ExcelHandler
 	apiClient     *services.ApiClient
 	oauthService  *services.OAuthService
-	config        *config.Config
+	idempotency   *services.IdempotencyStore
+	returnLocks   *services.TemporaryExportLocks
+	userStore     *services.UserStore
+	documents     *services.DocumentStore
+	tenants       *services.TenantStore
+	auditLog      *services.AuditLog
+	kurs          *services.KursTable
+	references    *services.ReferenceData
+	settings      atomic.Pointer[config.Config]
+	readiness     *services.ReadinessChecker
+	draining      atomic.Bool
 }
 
-// New creates a new Handlers instance
-func New(jsonGenerator *services.JsonGenerator, excelHandler *services.ExcelHandler, apiClient *services.ApiClient, oauthService *services.OAuthService) *Handlers {
-	return &Handlers{
+// New creates a new Handlers instance. A nil userStore disables authentication,
+// a nil tenants store runs the server in single-tenant mode. The exchange
+// rates and reference tables are required.
+func New(cfg *config.Config, jsonGenerator *services.JsonGenerator, excelHandler *services.ExcelHandler, apiClient *services.ApiClient, oauthService *services.OAuthService, userStore *services.UserStore, documents *services.DocumentStore, tenants *services.TenantStore, kurs *services.KursTable, references *services.ReferenceData) *Handlers {
+	h := &Handlers{
 		jsonGenerator: jsonGenerator,
 		excelHandler:  excelHandler,
 		apiClient:     apiClient,
 		oauthService:  oauthService,
-		config:        config.Load(),
+		userStore:     userStore,
+		documents:     documents,
+		tenants:       tenants,
+		kurs:          kurs,
+		references:    references,
+		idempotency:   services.NewIdempotencyStore(24 * time.Hour),
+		returnLocks:   services.NewTemporaryExportLocks(),
 	}
+	h.settings.Store(cfg)
+
+	// Probes read the settings on every run so reloads apply to them
+	h.readiness = services.NewReadinessChecker(time.Duration(cfg.ReadinessCacheTTL)*time.Second, 5*time.Second)
+	h.readiness.Add("temp_dir", func(ctx context.Context) (string, error) {
+		return services.TempDirProbe(h.config().TempDir)(ctx)
+	})
+	h.readiness.Add("oauth_token", oauthService.TokenProbe())
+	h.readiness.Add("ceisa_endpoint", func(ctx context.Context) (string, error) {
+		return apiClient.EndpointProbe(h.config().APIEndpoint)(ctx)
+	})
+
+	return h
+}
+
+// config returns the active configuration
+func (h *Handlers) config() *config.Config {
+	return h.settings.Load()
+}
+
+// SetConfig replaces the configuration, e.g. after a reload. Requests in
+// flight keep the configuration they started with.
+func (h *Handlers) SetConfig(cfg *config.Config) {
+	h.settings.Store(cfg)
+}
+
+// SetAuditLog enables recording of state-changing actions
+func (h *Handlers) SetAuditLog(auditLog *services.AuditLog) {
+	h.auditLog = auditLog
+}
+
+// StartDraining reports the service as unavailable to health checks, so load
+// balancers stop routing new requests before the server shuts down
+func (h *Handlers) StartDraining() {
+	h.draining.Store(true)
 }
 
 // HealthCheck handles the health check endpoint
 func (h *Handlers) HealthCheck(c *gin.Context) {
+	if h.draining.Load() {
+		middleware.HandleError(c, models.NewError(models.ErrCodeUnavailable, "server is shutting down", nil))
+		return
+	}
+
 	response := models.HealthResponse{
 		Status:    "healthy",
 		Service:   "JSON Response Generator API",
-		Version:   "2.0.0",
+		Version:   version.Version,
+		Commit:    version.Commit,
 		Timestamp: time.Now(),
 	}
 
 	middleware.HandleSuccess(c, response)
 }
 
+// Ready handles the readiness endpoint. It responds 503 when a dependency the
+// service needs is unavailable or the server is shutting down.
+func (h *Handlers) Ready(c *gin.Context) {
+	if h.draining.Load() {
+		middleware.HandleError(c, models.NewError(models.ErrCodeUnavailable, "server is shutting down", nil))
+		return
+	}
+
+	checks, ready := h.readiness.Check(c.Request.Context())
+	if !ready {
+		appErr := models.NewError(models.ErrCodeUnavailable, "service is not ready", nil)
+		for _, check := range checks {
+			if check.Status == models.CheckFailed {
+				appErr.WithFields(models.FieldError{
+					Field:   "checks." + check.Name,
+					Code:    models.FieldInvalidValue,
+					Message: check.Message,
+				})
+			}
+		}
+		middleware.HandleError(c, appErr)
+		return
+	}
+
+	middleware.HandleSuccess(c, models.ReadinessResponse{
+		Ready:   true,
+		Version: version.Version,
+		Commit:  version.Commit,
+		Checks:  checks,
+	})
+}
+
 // GetConfig handles the configuration endpoint
 func (h *Handlers) GetConfig(c *gin.Context) {
-	appConfig := h.config.ToAppConfig()
+	appConfig := h.tenantConfig(c).ToAppConfig()
 	middleware.HandleSuccess(c, appConfig)
 }
 
-// UploadExcel handles Excel file upload and processing
+// UploadExcel handles Excel file upload and processing. The sheets are laid
+// out by the kodeDokumen form field or, without it, the kodeDokumen of the
+// header sheet.
 func (h *Handlers) UploadExcel(c *gin.Context) {
 	// Parse multipart form
 	file, header, err := c.Request.FormFile("file")
 	if err != nil {
-		middleware.HandleError(c, http.StatusBadRequest, "No file uploaded", err)
+		middleware.HandleError(c, models.NewError(models.ErrCodeFileMissing, "No file uploaded", err))
 		return
 	}
 	defer file.Close()
 
 	// Validate file
 	if header.Filename == "" {
-		middleware.HandleError(c, http.StatusBadRequest, "No file selected", nil)
+		middleware.HandleError(c, models.NewError(models.ErrCodeFileMissing, "No file selected", nil))
 		return
 	}
 
 	// Check file extension
 	ext := filepath.Ext(header.Filename)
 	if ext != ".xlsx" && ext != ".xls" {
-		middleware.HandleError(c, http.StatusBadRequest, "Invalid file format. Please upload .xlsx or .xls files only.", nil)
+		middleware.HandleError(c, models.NewError(models.ErrCodeFileTypeUnsupported, "Invalid file format. Please upload .xlsx or .xls files only.", nil))
 		return
 	}
 
 	// Check file size
-	if header.Size > h.config.MaxFileSize {
-		middleware.HandleError(c, http.StatusBadRequest, fmt.Sprintf("File too large. Maximum size is %d MB.", h.config.MaxFileSize/(1024*1024)), nil)
+	maxFileSize := h.config().MaxFileSize
+	if header.Size > maxFileSize {
+		middleware.HandleError(c, models.Errorf(models.ErrCodeFileTooLarge, "File too large. Maximum size is %d MB.", maxFileSize/(1024*1024)))
 		return
 	}
 
 	// Save uploaded file temporarily
 	tempFile, err := h.saveUploadedFile(file, header)
 	if err != nil {
-		middleware.HandleError(c, http.StatusInternalServerError, "Failed to save uploaded file", err)
+		middleware.HandleError(c, models.NewError(models.ErrCodeInternal, "Failed to save uploaded file", err))
 		return
 	}
 	defer os.Remove(tempFile) // Clean up
 
 	// Parse Excel file
-	excelData, err := h.excelHandler.ParseExcelFile(tempFile)
+	excelData, err := h.excelHandler.ParseExcelFileForType(tempFile, c.PostForm("kodeDokumen"))
+
+	entry := models.AuditEntry{Action: services.AuditUpload, Target: header.Filename, Outcome: auditOutcome(err)}
+	if content, readErr := os.ReadFile(tempFile); readErr == nil {
+		entry.AfterHash = services.HashBytes(content)
+	}
+	h.audit(c, entry)
+
 	if err != nil {
-		logrus.WithError(err).Error("Excel parsing failed")
-		middleware.HandleError(c, http.StatusBadRequest, fmt.Sprintf("Error processing file: %v", err), err)
+		middleware.Logger(c).WithError(err).Error("Excel parsing failed")
+		middleware.HandleError(c, models.AsAppError(err, models.ErrCodeExcelUnreadable, "Error processing file"))
 		return
 	}
 
@@ -110,17 +214,24 @@ func (h *Handlers) UploadExcel(c *gin.Context) {
 	})
 }
 
-// DownloadTemplate handles Excel template download
+// DownloadTemplate handles Excel template download of the ?kodeDokumen=
+// document type
 func (h *Handlers) DownloadTemplate(c *gin.Context) {
-	templatePath, err := h.excelHandler.GenerateTemplate()
+	dt, err := services.LookupDocumentType(c.Query("kodeDokumen"))
+	if err != nil {
+		middleware.HandleError(c, err)
+		return
+	}
+
+	templatePath, err := h.excelHandler.GenerateTemplateForType(dt, h.tenantDefaults(c))
 	if err != nil {
-		middleware.HandleError(c, http.StatusInternalServerError, "Failed to generate template", err)
+		middleware.HandleError(c, models.NewError(models.ErrCodeInternal, "Failed to generate template", err))
 		return
 	}
 	defer os.Remove(templatePath) // Clean up after sending
 
 	// Set headers for file download
-	fileName := fmt.Sprintf("customs_data_template_%s.xlsx", time.Now().Format("20060102"))
+	fileName := fmt.Sprintf("customs_data_template_%s_%s.xlsx", dt.Code, time.Now().Format("20060102"))
 	c.Header("Content-Description", "File Transfer")
 	c.Header("Content-Transfer-Encoding", "binary")
 	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fileName))
@@ -130,49 +241,148 @@ func (h *Handlers) DownloadTemplate(c *gin.Context) {
 	c.File(templatePath)
 }
 
-// GenerateJson handles JSON generation from form data or Excel data
+// GenerateJson handles JSON generation from form data or Excel data. The
+// model is chosen by the kodeDokumen of the data; problems found by the
+// document type's validation rules, unknown reference codes and an NDPBM
+// differing from the exchange rate in force are reported without failing the
+// request. With derive set,
+// a missing NDPBM and computed fields are filled and the changes listed.
 func (h *Handlers) GenerateJson(c *gin.Context) {
 	var request models.GenerateJsonRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
-		middleware.HandleError(c, http.StatusBadRequest, "Invalid request data", err)
+		middleware.HandleError(c, models.BindingError(err))
 		return
 	}
 
 	// Convert request data to map
 	dataMap, ok := request.Data.(map[string]interface{})
 	if !ok {
-		middleware.HandleError(c, http.StatusBadRequest, "Invalid data format", nil)
+		middleware.HandleError(c, models.NewError(models.ErrCodeValidationFailed, "Invalid data format", nil).WithFields(models.FieldError{
+			Field:   "data",
+			Code:    models.FieldInvalidType,
+			Message: "data must be an object",
+		}))
 		return
 	}
 
-	// Generate ResponseData from input
-	responseData, err := h.jsonGenerator.GenerateFromData(dataMap)
+	// Generate the declaration from input
+	declaration, err := h.jsonGenerator.GenerateFromData(dataMap)
 	if err != nil {
-		middleware.HandleError(c, http.StatusInternalServerError, "Failed to generate response data", err)
+		middleware.HandleError(c, models.AsAppError(err, models.ErrCodeInternal, "Failed to generate response data"))
+		return
+	}
+	h.jsonGenerator.PrefillTenantDefaults(declaration, h.tenantDefaults(c))
+
+	var changes []models.DerivedChange
+	if request.Derive {
+		filled := h.kurs.FillNdpbm(declaration)
+		declaration, changes, err = h.jsonGenerator.Derive(declaration)
+		changes = append(filled, changes...)
+		if err != nil {
+			middleware.HandleError(c, models.AsAppError(err, models.ErrCodeInternal, "Failed to derive computed fields"))
+			return
+		}
+	}
+
+	dt, err := services.DocumentTypeOf(declaration)
+	if err != nil {
+		middleware.HandleError(c, err)
 		return
 	}
 
 	// Generate JSON string
-	jsonString, err := h.jsonGenerator.GenerateJsonString(responseData)
+	jsonString, err := h.jsonGenerator.GenerateJsonString(declaration)
 	if err != nil {
-		middleware.HandleError(c, http.StatusInternalServerError, "Failed to generate JSON string", err)
+		middleware.HandleError(c, models.NewError(models.ErrCodeInternal, "Failed to generate JSON string", err))
 		return
 	}
 
+	h.audit(c, models.AuditEntry{
+		Action:     services.AuditGenerate,
+		Target:     *declaration.Header().NomorAju,
+		BeforeHash: services.HashValue(request.Data),
+		AfterHash:  services.HashBytes([]byte(jsonString)),
+	})
+
 	// Parse JSON string back to object for response
 	var jsonData interface{}
 	if err := json.Unmarshal([]byte(jsonString), &jsonData); err != nil {
-		middleware.HandleError(c, http.StatusInternalServerError, "Failed to parse generated JSON", err)
+		middleware.HandleError(c, models.NewError(models.ErrCodeInternal, "Failed to parse generated JSON", err))
 		return
 	}
 
+	problems := append(dt.Check(declaration), h.temporaryExports(c).CheckReturn(declaration)...)
+	problems = append(problems, h.kurs.Check(declaration)...)
+	problems = append(problems, h.references.Check(declaration)...)
+
+	data := map[string]interface{}{
+		"json_data":     jsonData,
+		"json_string":   jsonString,
+		"document_type": dt.Info(),
+		"problems":      problems,
+	}
+	if request.Derive {
+		data["changes"] = changes
+	}
+
 	// Return success response
 	c.JSON(http.StatusOK, models.ApiResponse{
 		Success: true,
-		Data: map[string]interface{}{
-			"json_data":   jsonData,
-			"json_string": jsonString,
-		},
+		Data:    data,
+	})
+}
+
+// CalculateDuties computes the import duties and taxes of a declaration from
+// the rates in its barangTarif rows and returns it with the rows filled
+func (h *Handlers) CalculateDuties(c *gin.Context) {
+	var request models.DutyCalculationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		middleware.HandleError(c, models.BindingError(err))
+		return
+	}
+
+	declaration := request.JsonData.Declaration
+	if declaration == nil {
+		middleware.HandleError(c, declarationRequired())
+		return
+	}
+
+	calculation, err := services.ApplyDuties(declaration, request.Api)
+	if err != nil {
+		middleware.HandleError(c, err)
+		return
+	}
+
+	middleware.HandleSuccess(c, map[string]interface{}{
+		"json_data":   declaration,
+		"calculation": calculation,
+	})
+}
+
+// CalculateValuation converts the invoice value of a declaration at its
+// incoterm to the customs (CIF) value and returns it with the values filled
+func (h *Handlers) CalculateValuation(c *gin.Context) {
+	var request models.ValuationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		middleware.HandleError(c, models.BindingError(err))
+		return
+	}
+
+	declaration := request.JsonData.Declaration
+	if declaration == nil {
+		middleware.HandleError(c, declarationRequired())
+		return
+	}
+
+	valuation, err := services.ApplyValuation(declaration, request.Basis)
+	if err != nil {
+		middleware.HandleError(c, err)
+		return
+	}
+
+	middleware.HandleSuccess(c, map[string]interface{}{
+		"json_data": declaration,
+		"valuation": valuation,
 	})
 }
 
@@ -180,24 +390,27 @@ func (h *Handlers) GenerateJson(c *gin.Context) {
 func (h *Handlers) TestConnection(c *gin.Context) {
 	var request models.TestConnectionRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
-		middleware.HandleError(c, http.StatusBadRequest, "Invalid request data", err)
+		middleware.HandleError(c, models.BindingError(err))
 		return
 	}
 
 	endpoint := request.Endpoint
 	if endpoint == "" {
-		endpoint = h.config.APIEndpoint
+		endpoint = h.tenantConfig(c).APIEndpoint
 	}
 
 	if endpoint == "" {
-		middleware.HandleError(c, http.StatusBadRequest, "No endpoint provided", nil)
+		middleware.HandleError(c, models.NewError(models.ErrCodeValidationFailed, "No endpoint provided", nil).WithFields(models.FieldError{
+			Field: "endpoint",
+			Code:  models.FieldRequired,
+		}))
 		return
 	}
 
 	// Test connection
-	success, message, err := h.apiClient.TestConnection(endpoint)
+	success, message, err := h.apiClient.TestConnection(c.Request.Context(), endpoint)
 	if err != nil {
-		logrus.WithError(err).Error("Connection test failed")
+		middleware.Logger(c).WithError(err).Error("Connection test failed")
 	}
 
 	// Return response
@@ -214,61 +427,292 @@ func (h *Handlers) TestConnection(c *gin.Context) {
 func (h *Handlers) SendToApi(c *gin.Context) {
 	var request models.SendToApiRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
-		middleware.HandleError(c, http.StatusBadRequest, "Invalid request data", err)
+		middleware.HandleError(c, models.BindingError(err))
+		return
+	}
+
+	// Tenants submit with their own endpoint and credentials only, an
+	// override could send the tenant's token elsewhere
+	if middleware.CurrentTenant(c) != nil && request.ApiConfig != nil {
+		middleware.HandleError(c, models.NewError(models.ErrCodeValidationFailed, "The API configuration of a tenant cannot be overridden", nil).WithFields(models.FieldError{
+			Field: "api_config",
+			Code:  models.FieldInvalidValue,
+		}))
 		return
 	}
 
 	// Use provided config or default
 	apiConfig := request.ApiConfig
 	if apiConfig == nil {
+		cfg := h.tenantConfig(c)
 		apiConfig = &models.ApiConfig{
-			Endpoint: h.config.APIEndpoint,
-			APIKey:   h.config.APIKey,
-			Username: h.config.APIUsername,
-			Password: h.config.APIPassword,
-			Timeout:  h.config.APITimeout,
+			Endpoint: cfg.APIEndpoint,
+			APIKey:   cfg.APIKey,
+			Username: cfg.APIUsername,
+			Password: cfg.APIPassword,
+			Timeout:  cfg.APITimeout,
+		}
+		if tenant := middleware.CurrentTenant(c); tenant != nil && tenant.API != nil {
+			apiConfig.AuthType = tenant.API.AuthType
 		}
 	}
 
 	// Validate config
 	if err := h.apiClient.ValidateConfig(apiConfig); err != nil {
-		middleware.HandleError(c, http.StatusBadRequest, "Invalid API configuration", err)
+		middleware.HandleError(c, models.AsAppError(err, models.ErrCodeValidationFailed, "Invalid API configuration"))
+		return
+	}
+
+	// Approved declarations are sent exactly as they were approved
+	var document *models.Document
+	if request.DocumentID != "" {
+		var err error
+		document, err = h.getDocument(c, request.DocumentID)
+		if err != nil {
+			h.handleDocumentError(c, err)
+			return
+		}
+		request.JsonData = document.Data
+	}
+
+	declaration := request.JsonData.Declaration
+
+	// Dry runs never reach CEISA, so they bypass deduplication
+	if request.DryRun {
+		if !h.validateDeclaration(c, declaration) {
+			return
+		}
+		success, response, err := h.apiClient.SendData(c.Request.Context(), declaration, apiConfig, true, nil)
+		if err != nil {
+			middleware.HandleError(c, models.AsAppError(err, models.ErrCodeInternal, "Failed to send data to API"))
+			return
+		}
+		h.respondSendResult(c, success, response, true, "", false)
+		return
+	}
+
+	// Four-eyes rule: only approved declarations go to Bea Cukai
+	if document == nil && h.config().RequireApproval {
+		middleware.HandleError(c, models.NewError(models.ErrCodeValidationFailed, "Submissions require the document_id of an approved declaration", nil).WithFields(models.FieldError{
+			Field: "document_id",
+			Code:  models.FieldRequired,
+		}))
+		return
+	}
+	if document != nil && document.Status != models.DocumentStatusApproved {
+		middleware.HandleError(c, models.Errorf(models.ErrCodeNotApproved, "Declaration is not approved (status: %s)", document.Status))
 		return
 	}
 
+	// Returns of a BC 2.6.1 are checked against its balance and sent one at a
+	// time, so the balance cannot be claimed twice
+	unlock := h.returnLocks.Lock(h.tenantID(c), services.ReturnSources(declaration))
+	defer unlock()
+
+	if !h.validateDeclaration(c, declaration) {
+		return
+	}
+
+	// Submissions go out under the caller's own CEISA identity, which must be
+	// the declarant, e.g. the importer on BC 2.0 or the exporter on BC 3.0.
+	// With a JWKS configured the identity must come from a verified token.
+	session, _ := h.oauthSession(c, false)
+	if session != nil && apiConfig.AuthType == "oauth2" {
+		claims := session.Claims()
+		if claims != nil && !claims.Verified && h.oauthService.VerifiesTokens() {
+			middleware.HandleError(c, models.NewError(models.ErrCodeIdentityMismatch, "CEISA token identity is not verified, please login again", nil))
+			return
+		}
+		if err := services.MatchImporterIdentity(claims, declaration); err != nil {
+			middleware.HandleError(c, models.AsAppError(err, models.ErrCodeIdentityMismatch, "Identity mismatch"))
+			return
+		}
+	}
+
+	// Resolve idempotency key. Keys are scoped to the tenant, and keys chosen
+	// by the client also to the caller, so nobody can replay or probe the
+	// submissions of others.
+	idempotencyKey := c.GetHeader("Idempotency-Key")
+	scopedKey := h.tenantID(c) + "\x00"
+	if idempotencyKey == "" {
+		derivedKey, err := services.DeriveIdempotencyKey(declaration)
+		if err != nil {
+			middleware.HandleError(c, models.NewError(models.ErrCodeInternal, "Failed to derive idempotency key", err))
+			return
+		}
+		idempotencyKey = derivedKey
+	} else if principal := middleware.CurrentPrincipal(c); principal != nil {
+		scopedKey += principal.Username
+	}
+	scopedKey += "\x00" + idempotencyKey
+
+	// A key reused for another declaration must not replay its result
+	fingerprint := services.HashValue(declaration)
+	if !request.Force {
+		recorded, replayed, err := h.idempotency.Begin(scopedKey, fingerprint)
+		if err != nil {
+			middleware.HandleError(c, models.AsAppError(err, models.ErrCodeConflict, "Idempotency key conflict"))
+			return
+		}
+		if replayed {
+			middleware.Logger(c).WithFields(logrus.Fields{
+				"idempotency_key": idempotencyKey,
+				"nomor_aju":       *declaration.Header().NomorAju,
+			}).Info("Duplicate submission, returning recorded result")
+
+			c.Header("Idempotent-Replayed", "true")
+			h.respondSendResult(c, recorded.Success, recorded.Response, false, idempotencyKey, true)
+			return
+		}
+	}
+
+	// Claim the approved document, so concurrent sends cannot submit it twice
+	if document != nil {
+		if _, err := h.documents.ClaimSubmission(document.ID, middleware.CurrentPrincipal(c)); err != nil {
+			if !request.Force {
+				h.idempotency.Abandon(scopedKey)
+			}
+			h.handleDocumentError(c, err)
+			return
+		}
+	}
+
 	// Send data
-	success, response, err := h.apiClient.SendData(&request.JsonData, apiConfig, request.DryRun)
+	success, response, err := h.apiClient.SendData(c.Request.Context(), declaration, apiConfig, false, session)
+
+	// A failed send leaves the document approved, so it can be sent again
+	if document != nil && (err != nil || !success) {
+		if _, releaseErr := h.documents.ReleaseSubmission(document.ID, middleware.CurrentPrincipal(c), "submission failed"); releaseErr != nil {
+			middleware.Logger(c).WithError(releaseErr).WithField("document_id", document.ID).Error("Failed to release submission")
+		}
+	}
+
+	entry := models.AuditEntry{
+		Action:     services.AuditSubmit,
+		Target:     *declaration.Header().NomorAju,
+		Outcome:    auditOutcome(err),
+		BeforeHash: services.HashValue(declaration),
+		AfterHash:  services.HashValue(response),
+	}
+	if err == nil && !success {
+		entry.Outcome = models.AuditOutcomeFailure
+	}
+	if document != nil {
+		entry.Detail = "document " + document.ID
+	}
+	h.audit(c, entry)
+
 	if err != nil {
-		middleware.HandleError(c, http.StatusInternalServerError, "Failed to send data to API", err)
+		if !request.Force {
+			h.idempotency.Abandon(scopedKey)
+		}
+		middleware.HandleError(c, models.AsAppError(err, models.ErrCodeInternal, "Failed to send data to API"))
 		return
 	}
 
-	// Return response
+	// Only accepted submissions are replayed, rejected ones may be corrected
+	// and sent again under the same key
+	switch {
+	case request.Force:
+		if success {
+			h.idempotency.Store(scopedKey, fingerprint, success, response)
+		}
+	case success:
+		h.idempotency.Complete(scopedKey, success, response)
+	default:
+		h.idempotency.Abandon(scopedKey)
+	}
+
+	switch {
+	case document != nil && success:
+		if _, err := h.documents.MarkSubmitted(document.ID, middleware.CurrentPrincipal(c), response); err != nil {
+			middleware.Logger(c).WithError(err).WithField("document_id", document.ID).Error("Failed to record submission")
+		}
+	case success && services.InTemporaryExportLedger(declaration):
+		// Sent without the approval workflow, recorded for the temporary
+		// export balances
+		if _, err := h.documents.RecordSubmission(declaration, middleware.CurrentPrincipal(c), h.tenantID(c), response); err != nil {
+			middleware.Logger(c).WithError(err).WithField("nomor_aju", *declaration.Header().NomorAju).Error("Failed to record submission")
+		}
+	}
+
+	h.respondSendResult(c, success, response, false, idempotencyKey, false)
+}
+
+// validateDeclaration checks a declaration against the rules of its document
+// type and responds with the problems found. It reports whether the
+// declaration is valid.
+func (h *Handlers) validateDeclaration(c *gin.Context, declaration models.Declaration) bool {
+	if declaration == nil {
+		middleware.HandleError(c, declarationRequired())
+		return false
+	}
+
+	dt, err := services.DocumentTypeOf(declaration)
+	if err == nil {
+		err = dt.Validate(declaration)
+	}
+	if err == nil {
+		err = h.checkTemporaryReturn(c, declaration)
+	}
+	if err == nil {
+		err = h.checkReferences(declaration)
+	}
+	if err != nil {
+		middleware.HandleError(c, err)
+		return false
+	}
+	return true
+}
+
+// declarationRequired is the error for a request without json_data
+func declarationRequired() *models.AppError {
+	return models.NewError(models.ErrCodeValidationFailed, "No declaration provided", nil).WithFields(models.FieldError{
+		Field: "json_data",
+		Code:  models.FieldRequired,
+	})
+}
+
+// respondSendResult writes the response for a (possibly replayed) submission
+func (h *Handlers) respondSendResult(c *gin.Context, success bool, response map[string]interface{}, dryRun bool, idempotencyKey string, replayed bool) {
+	data := map[string]interface{}{
+		"response": response,
+		"dry_run":  dryRun,
+	}
+	if idempotencyKey != "" {
+		data["idempotency_key"] = idempotencyKey
+		data["replayed"] = replayed
+	}
+
 	c.JSON(http.StatusOK, models.ApiResponse{
 		Success: success,
-		Data: map[string]interface{}{
-			"response": response,
-			"dry_run":  request.DryRun,
-		},
+		Data:    data,
 	})
 }
 
-// GetSampleData handles sample data generation
+// GetSampleData handles sample data generation for the ?kodeDokumen=
+// document type
 func (h *Handlers) GetSampleData(c *gin.Context) {
+	dt, err := services.LookupDocumentType(c.Query("kodeDokumen"))
+	if err != nil {
+		middleware.HandleError(c, err)
+		return
+	}
+
 	// Generate sample data
-	sampleData := h.jsonGenerator.GenerateSampleData()
+	sampleData := h.jsonGenerator.GenerateSampleForType(dt, h.tenantDefaults(c))
 
 	// Generate JSON string
 	jsonString, err := h.jsonGenerator.GenerateJsonString(sampleData)
 	if err != nil {
-		middleware.HandleError(c, http.StatusInternalServerError, "Failed to generate JSON string", err)
+		middleware.HandleError(c, models.NewError(models.ErrCodeInternal, "Failed to generate JSON string", err))
 		return
 	}
 
 	// Parse JSON string back to object for response
 	var jsonData interface{}
 	if err := json.Unmarshal([]byte(jsonString), &jsonData); err != nil {
-		middleware.HandleError(c, http.StatusInternalServerError, "Failed to parse generated JSON", err)
+		middleware.HandleError(c, models.NewError(models.ErrCodeInternal, "Failed to parse generated JSON", err))
 		return
 	}
 
@@ -282,10 +726,32 @@ func (h *Handlers) GetSampleData(c *gin.Context) {
 	})
 }
 
+// ListDocumentTypes returns the supported document types
+func (h *Handlers) ListDocumentTypes(c *gin.Context) {
+	types := []models.DocumentTypeInfo{}
+	for _, dt := range services.DocumentTypes() {
+		types = append(types, dt.Info())
+	}
+
+	middleware.HandleSuccess(c, types)
+}
+
+// GetDocumentTypeSchema serves the CEISA JSON schema of a document type for
+// reference; declarations are validated by the type's rules
+func (h *Handlers) GetDocumentTypeSchema(c *gin.Context) {
+	dt, err := services.LookupDocumentType(c.Param("kodeDokumen"))
+	if err != nil {
+		middleware.HandleError(c, err)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json", dt.Schema)
+}
+
 // saveUploadedFile saves the uploaded file to a temporary location
 func (h *Handlers) saveUploadedFile(file multipart.File, header *multipart.FileHeader) (string, error) {
 	// Use app temp directory instead of /tmp to avoid Alpine Linux issues
-	tempDir := "/app/temp"
+	tempDir := h.config().TempDir
 	if err := os.MkdirAll(tempDir, 0755); err != nil {
 		return "", fmt.Errorf("failed to create temp directory %s: %w", tempDir, err)
 	}
@@ -307,61 +773,132 @@ func (h *Handlers) saveUploadedFile(file multipart.File, header *multipart.FileH
 	return tempFile.Name(), nil
 }
 
+// oauthSession resolves the caller's OAuth session from the session cookie or
+// header. When create is set, a new session is issued if none exists.
+// Sessions bound to a user of this service are only usable by that user.
+// In multi-tenant mode the tenant's shared session is used instead.
+func (h *Handlers) oauthSession(c *gin.Context, create bool) (*services.OAuthSession, error) {
+	if tenant := middleware.CurrentTenant(c); tenant != nil {
+		return h.oauthService.TenantSession(tenant), nil
+	}
+
+	principal := middleware.CurrentPrincipal(c)
+
+	if session, ok := h.oauthService.GetSession(middleware.SessionID(c)); ok && session.ID != services.DefaultSessionID {
+		owner := session.Principal()
+		if owner == nil || principal == nil || owner.Username == principal.Username {
+			return session, nil
+		}
+	}
+
+	if !create {
+		return nil, nil
+	}
+
+	session, err := h.oauthService.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	if principal != nil && principal.Method != "anonymous" {
+		session.SetPrincipal(principal)
+	}
+	middleware.SetSessionCookie(c, session.ID, h.oauthService.SessionTTL())
+
+	return session, nil
+}
+
+// allowCredentialChange reports whether the caller may change the CEISA
+// credentials of its session. A tenant's credentials are shared by all its
+// users, so only administrators may change them.
+func (h *Handlers) allowCredentialChange(c *gin.Context) bool {
+	if middleware.CurrentTenant(c) == nil || middleware.CurrentPrincipal(c).HasRole(models.RoleAdmin) {
+		return true
+	}
+
+	middleware.HandleError(c, models.NewError(models.ErrCodeForbidden, "Only administrators may change the CEISA credentials of a tenant", nil))
+	return false
+}
+
 // OAuth 2.0 Handlers
 
 // OAuthLogin handles OAuth 2.0 login
 func (h *Handlers) OAuthLogin(c *gin.Context) {
 	var req models.OAuthLoginApiRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		middleware.HandleError(c, http.StatusBadRequest, "Invalid request format", err)
+		middleware.HandleError(c, models.BindingError(err))
 		return
 	}
 
 	// Validate request
 	if req.Username == "" || req.Password == "" {
-		middleware.HandleError(c, http.StatusBadRequest, "Username and password are required", nil)
+		appErr := models.NewError(models.ErrCodeValidationFailed, "Username and password are required", nil)
+		if req.Username == "" {
+			appErr.WithFields(models.FieldError{Field: "username", Code: models.FieldRequired})
+		}
+		if req.Password == "" {
+			appErr.WithFields(models.FieldError{Field: "password", Code: models.FieldRequired})
+		}
+		middleware.HandleError(c, appErr)
 		return
 	}
 
-	logrus.WithFields(logrus.Fields{
+	if !h.allowCredentialChange(c) {
+		return
+	}
+
+	middleware.Logger(c).WithFields(logrus.Fields{
 		"username": req.Username,
 	}).Info("OAuth 2.0 login attempt")
 
+	session, err := h.oauthSession(c, true)
+	if err != nil {
+		middleware.HandleError(c, models.NewError(models.ErrCodeInternal, "Failed to create session", err))
+		return
+	}
+
 	// Perform login
-	tokenInfo, err := h.oauthService.Login(req.Username, req.Password)
+	tokenInfo, err := session.LoginContext(c.Request.Context(), req.Username, req.Password)
+	h.audit(c, models.AuditEntry{Action: services.AuditOAuthLogin, Target: req.Username, Outcome: auditOutcome(err)})
 	if err != nil {
-		logrus.WithError(err).Error("OAuth 2.0 login failed")
-		middleware.HandleError(c, http.StatusUnauthorized, "Login failed", err)
+		middleware.Logger(c).WithError(err).Error("OAuth 2.0 login failed")
+		middleware.HandleError(c, models.AsAppError(err, models.ErrCodeOAuthLoginFailed, "Login failed"))
 		return
 	}
 
-	logrus.WithFields(logrus.Fields{
+	middleware.Logger(c).WithFields(logrus.Fields{
 		"username":   req.Username,
 		"expires_at": tokenInfo.ExpiresAt,
 	}).Info("OAuth 2.0 login successful")
 
 	middleware.HandleSuccess(c, gin.H{
-		"message":      "Login successful",
-		"access_token": tokenInfo.AccessToken,
-		"expires_at":   tokenInfo.ExpiresAt,
-		"token_type":   tokenInfo.TokenType,
-		"scope":        tokenInfo.Scope,
+		"message":       "Login successful",
+		"session_token": session.ID,
+		"access_token":  tokenInfo.AccessToken,
+		"expires_at":    tokenInfo.ExpiresAt,
+		"token_type":    tokenInfo.TokenType,
+		"scope":         tokenInfo.Scope,
 	})
 }
 
 // OAuthRefresh handles OAuth 2.0 token refresh
 func (h *Handlers) OAuthRefresh(c *gin.Context) {
-	logrus.Info("OAuth 2.0 token refresh attempt")
+	middleware.Logger(c).Info("OAuth 2.0 token refresh attempt")
+
+	session, _ := h.oauthSession(c, false)
+	if session == nil {
+		middleware.HandleError(c, models.NewError(models.ErrCodeOAuthLoginRequired, "No active session, please login first", nil))
+		return
+	}
 
 	// Perform token refresh
-	tokenInfo, err := h.oauthService.RefreshToken()
+	tokenInfo, err := session.RefreshTokenContext(c.Request.Context())
 	if err != nil {
-		logrus.WithError(err).Error("OAuth 2.0 token refresh failed")
-		middleware.HandleError(c, http.StatusUnauthorized, "Token refresh failed", err)
+		middleware.Logger(c).WithError(err).Error("OAuth 2.0 token refresh failed")
+		middleware.HandleError(c, models.AsAppError(err, models.ErrCodeOAuthExpired, "Token refresh failed"))
 		return
 	}
 
-	logrus.WithFields(logrus.Fields{
+	middleware.Logger(c).WithFields(logrus.Fields{
 		"expires_at": tokenInfo.ExpiresAt,
 	}).Info("OAuth 2.0 token refresh successful")
 
@@ -376,8 +913,17 @@ func (h *Handlers) OAuthRefresh(c *gin.Context) {
 
 // OAuthStatus returns the current OAuth 2.0 token status
 func (h *Handlers) OAuthStatus(c *gin.Context) {
-	tokenInfo := h.oauthService.GetTokenInfo()
-	isValid := h.oauthService.IsTokenValid()
+	session, _ := h.oauthSession(c, false)
+	if session == nil {
+		middleware.HandleSuccess(c, gin.H{
+			"authenticated": false,
+			"message":       "No active session",
+		})
+		return
+	}
+
+	tokenInfo := session.GetTokenInfo()
+	isValid := session.IsTokenValid()
 
 	if tokenInfo == nil {
 		middleware.HandleSuccess(c, gin.H{
@@ -387,13 +933,27 @@ func (h *Handlers) OAuthStatus(c *gin.Context) {
 		return
 	}
 
-	middleware.HandleSuccess(c, gin.H{
+	status := gin.H{
 		"authenticated": isValid,
 		"expires_at":    tokenInfo.ExpiresAt,
 		"token_type":    tokenInfo.TokenType,
 		"scope":         tokenInfo.Scope,
 		"time_left":     time.Until(tokenInfo.ExpiresAt).Seconds(),
-	})
+	}
+
+	if claims := tokenInfo.Claims; claims != nil {
+		status["identity"] = gin.H{
+			"npwp":       claims.NPWP,
+			"nib":        claims.NIB,
+			"username":   claims.Username,
+			"name":       claims.Name,
+			"roles":      claims.Roles,
+			"expires_at": claims.ExpiresAt,
+			"verified":   claims.Verified,
+		}
+	}
+
+	middleware.HandleSuccess(c, status)
 }
 
 // OAuthConfig handles OAuth 2.0 configuration
@@ -404,12 +964,15 @@ func (h *Handlers) OAuthConfig(c *gin.Context) {
 	case "POST":
 		h.setOAuthConfig(c)
 	default:
-		middleware.HandleError(c, http.StatusMethodNotAllowed, "Method not allowed", nil)
+		middleware.HandleError(c, models.NewError(models.ErrCodeMethodNotAllowed, "Method not allowed", nil))
 	}
 }
 
 func (h *Handlers) getOAuthConfig(c *gin.Context) {
-	config := h.oauthService.GetConfig()
+	var config *models.OAuth2Config
+	if session, _ := h.oauthSession(c, false); session != nil {
+		config = session.GetConfig()
+	}
 	if config == nil {
 		config = h.oauthService.GetDefaultConfig()
 	}
@@ -428,7 +991,7 @@ func (h *Handlers) getOAuthConfig(c *gin.Context) {
 func (h *Handlers) setOAuthConfig(c *gin.Context) {
 	var req models.OAuthConfigRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		middleware.HandleError(c, http.StatusBadRequest, "Invalid request format", err)
+		middleware.HandleError(c, models.BindingError(err))
 		return
 	}
 
@@ -440,16 +1003,34 @@ func (h *Handlers) setOAuthConfig(c *gin.Context) {
 		Password:   req.Password,
 	}
 
+	if !h.allowCredentialChange(c) {
+		return
+	}
+
 	// Validate config
 	if err := h.oauthService.ValidateConfig(config); err != nil {
-		middleware.HandleError(c, http.StatusBadRequest, "Invalid configuration", err)
+		middleware.HandleError(c, models.AsAppError(err, models.ErrCodeValidationFailed, "Invalid configuration"))
+		return
+	}
+
+	session, err := h.oauthSession(c, true)
+	if err != nil {
+		middleware.HandleError(c, models.NewError(models.ErrCodeInternal, "Failed to create session", err))
 		return
 	}
 
 	// Set config
-	h.oauthService.SetConfig(config)
+	before := services.HashValue(session.GetConfig())
+	session.SetConfig(config)
+
+	h.audit(c, models.AuditEntry{
+		Action:     services.AuditConfigChange,
+		Target:     "oauth_config",
+		BeforeHash: before,
+		AfterHash:  services.HashValue(config),
+	})
 
-	logrus.WithFields(logrus.Fields{
+	middleware.Logger(c).WithFields(logrus.Fields{
 		"token_url":   config.TokenURL,
 		"refresh_url": config.RefreshURL,
 		"username":    config.Username,
@@ -462,11 +1043,131 @@ func (h *Handlers) setOAuthConfig(c *gin.Context) {
 
 // OAuthLogout clears the stored OAuth 2.0 token
 func (h *Handlers) OAuthLogout(c *gin.Context) {
-	h.oauthService.ClearToken()
+	session, _ := h.oauthSession(c, false)
+	switch {
+	case session == nil:
+		middleware.ClearSessionCookie(c)
+	case session.TenantID() != "":
+		// Tenant credentials are shared, only drop the token
+		session.ClearToken()
+	case session.Principal() != nil && session.Principal().Method == "password":
+		// Keep the login to this service, only drop the CEISA credentials
+		session.ClearCredentials()
+	default:
+		h.oauthService.DeleteSession(session.ID)
+		middleware.ClearSessionCookie(c)
+	}
+
+	h.audit(c, models.AuditEntry{Action: services.AuditOAuthLogout})
+	middleware.Logger(c).Info("OAuth 2.0 logout completed")
+
+	middleware.HandleSuccess(c, gin.H{
+		"message": "Logout successful",
+	})
+}
+
+// Authentication Handlers
+
+// ResolvePrincipal identifies the caller from an API key or a login session.
+// Without a user store every caller is treated as an anonymous administrator.
+func (h *Handlers) ResolvePrincipal(c *gin.Context) *models.Principal {
+	if h.userStore == nil {
+		return &models.Principal{
+			Username: "anonymous",
+			Role:     models.RoleAdmin,
+			Method:   "anonymous",
+		}
+	}
+
+	if key := c.GetHeader(middleware.APIKeyHeaderName); key != "" {
+		principal, ok := h.userStore.AuthenticateAPIKey(key)
+		if !ok {
+			return nil
+		}
+		return principal
+	}
+
+	// The user may have been disabled, removed or given another role since
+	// the session was issued
+	if session, ok := h.oauthService.GetSession(middleware.SessionID(c)); ok && session.ID != services.DefaultSessionID {
+		if principal := session.Principal(); principal != nil {
+			if current, ok := h.userStore.Lookup(principal.Username); ok {
+				return current
+			}
+		}
+	}
+
+	return nil
+}
+
+// Login authenticates a user of this service and issues a session
+func (h *Handlers) Login(c *gin.Context) {
+	if h.userStore == nil {
+		middleware.HandleError(c, models.NewError(models.ErrCodeNotEnabled, "Authentication is not enabled", nil))
+		return
+	}
 
-	logrus.Info("OAuth 2.0 logout completed")
+	var req models.LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.HandleError(c, models.BindingError(err))
+		return
+	}
+
+	principal, err := h.userStore.Authenticate(req.Username, req.Password)
+	entry := models.AuditEntry{Action: services.AuditLogin, Actor: req.Username, Outcome: auditOutcome(err)}
+	if principal != nil {
+		entry.Role = principal.Role
+	}
+	h.audit(c, entry)
+	if err != nil {
+		middleware.Logger(c).WithFields(logrus.Fields{
+			"username": req.Username,
+			"ip":       c.ClientIP(),
+		}).Warn("Login failed")
+		middleware.HandleError(c, models.NewError(models.ErrCodeUnauthenticated, "Invalid username or password", nil))
+		return
+	}
+
+	// Always issue a fresh session on login
+	if session, ok := h.oauthService.GetSession(middleware.SessionID(c)); ok && session.ID != services.DefaultSessionID {
+		h.oauthService.DeleteSession(session.ID)
+	}
+
+	session, err := h.oauthService.NewSession()
+	if err != nil {
+		middleware.HandleError(c, models.NewError(models.ErrCodeInternal, "Failed to create session", err))
+		return
+	}
+	session.SetPrincipal(principal)
+	middleware.SetSessionCookie(c, session.ID, h.oauthService.SessionTTL())
+
+	middleware.Logger(c).WithFields(logrus.Fields{
+		"username": principal.Username,
+		"role":     principal.Role,
+	}).Info("Login successful")
+
+	middleware.HandleSuccess(c, gin.H{
+		"message":       "Login successful",
+		"session_token": session.ID,
+		"user":          principal,
+	})
+}
+
+// Logout ends the caller's session, including any CEISA credentials
+func (h *Handlers) Logout(c *gin.Context) {
+	h.audit(c, models.AuditEntry{Action: services.AuditLogout})
+
+	if session, ok := h.oauthService.GetSession(middleware.SessionID(c)); ok && session.ID != services.DefaultSessionID {
+		h.oauthService.DeleteSession(session.ID)
+	}
+	middleware.ClearSessionCookie(c)
 
 	middleware.HandleSuccess(c, gin.H{
 		"message": "Logout successful",
 	})
 }
+
+// Me returns the authenticated caller
+func (h *Handlers) Me(c *gin.Context) {
+	middleware.HandleSuccess(c, middleware.CurrentPrincipal(c))
+}
@@ -3,13 +3,19 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 
+	"json-response-generator/internal/config"
+	"json-response-generator/internal/middleware"
 	"json-response-generator/internal/models"
 	"json-response-generator/internal/services"
 )
@@ -22,9 +28,23 @@ func setupTestRouter() (*gin.Engine, *Handlers) {
 	excelHandler := services.NewExcelHandler()
 	oauthService := services.NewOAuthService()
 	apiClient := services.NewApiClientWithOAuth(oauthService)
+	documents, _ := services.NewDocumentStore("")
+	kurs, err := services.NewKursTable("")
+	if err != nil {
+		panic(err)
+	}
+	references, err := services.LoadReferenceData("../services/testdata/references")
+	if err != nil {
+		panic(err)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		panic(err)
+	}
 
 	// Initialize handlers
-	h := New(jsonGenerator, excelHandler, apiClient, oauthService)
+	h := New(cfg, jsonGenerator, excelHandler, apiClient, oauthService, nil, documents, nil, kurs, references)
 
 	// Setup router
 	router := gin.New()
@@ -55,6 +75,82 @@ func TestHealthCheck(t *testing.T) {
 	assert.Equal(t, "2.0.0", healthData["version"])
 }
 
+func TestHealthCheckWhileDraining(t *testing.T) {
+	router, h := setupTestRouter()
+	router.GET("/api/health", h.HealthCheck)
+	h.StartDraining()
+
+	req, _ := http.NewRequest("GET", "/api/health", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	var response struct {
+		Success bool                `json:"success"`
+		Details models.ErrorDetails `json:"details"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.False(t, response.Success)
+	assert.Equal(t, models.ErrCodeUnavailable, response.Details.Code)
+}
+
+func TestReady(t *testing.T) {
+	t.Setenv("TEMP_DIR", t.TempDir())
+	t.Setenv("API_ENDPOINT", "")
+	router, h := setupTestRouter()
+	router.GET("/api/ready", h.Ready)
+
+	req, _ := http.NewRequest("GET", "/api/ready", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Success bool                     `json:"success"`
+		Data    models.ReadinessResponse `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.True(t, response.Data.Ready)
+	assert.Equal(t, "2.0.0", response.Data.Version)
+
+	statuses := map[string]string{}
+	for _, check := range response.Data.Checks {
+		statuses[check.Name] = check.Status
+	}
+	assert.Equal(t, map[string]string{
+		"temp_dir":       models.CheckPassed,
+		"oauth_token":    models.CheckSkipped,
+		"ceisa_endpoint": models.CheckSkipped,
+	}, statuses)
+}
+
+func TestReadyReportsFailedChecks(t *testing.T) {
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	t.Setenv("TEMP_DIR", t.TempDir())
+	t.Setenv("API_ENDPOINT", unreachable.URL)
+	router, h := setupTestRouter()
+	router.GET("/api/ready", h.Ready)
+
+	req, _ := http.NewRequest("GET", "/api/ready", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	var response struct {
+		Details models.ErrorDetails `json:"details"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, models.ErrCodeUnavailable, response.Details.Code)
+	if assert.Len(t, response.Details.Fields, 1) {
+		assert.Equal(t, "checks.ceisa_endpoint", response.Details.Fields[0].Field)
+	}
+}
+
 func TestGetConfig(t *testing.T) {
 	router, h := setupTestRouter()
 	router.GET("/api/config", h.GetConfig)
@@ -124,6 +220,41 @@ func TestGenerateJson(t *testing.T) {
 	assert.Contains(t, responseData, "json_string")
 }
 
+func TestGenerateJsonDerive(t *testing.T) {
+	router, h := setupTestRouter()
+	router.POST("/api/generate-json", h.GenerateJson)
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"derive": true,
+		"data": map[string]interface{}{
+			"kodeDokumen": "20",
+			"cif":         0.0,
+			"barang": []interface{}{
+				map[string]interface{}{"seriBarang": 4, "cif": 100.0, "ndpbm": 15000.0, "cifRupiah": 0.0},
+				map[string]interface{}{"seriBarang": 9, "cif": 50.0, "ndpbm": 15000.0, "cifRupiah": 0.0},
+			},
+		},
+	})
+	req, _ := http.NewRequest("POST", "/api/generate-json", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.ApiResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	data := response.Data.(map[string]interface{})
+	assert.NotEmpty(t, data["changes"])
+
+	generated := data["json_data"].(map[string]interface{})
+	assert.Equal(t, 150.0, generated["cif"])
+	barang := generated["barang"].([]interface{})
+	assert.Equal(t, 2.0, barang[1].(map[string]interface{})["seriBarang"])
+	assert.Equal(t, 750000.0, barang[1].(map[string]interface{})["cifRupiah"])
+}
+
 func TestGetSampleData(t *testing.T) {
 	router, h := setupTestRouter()
 	router.GET("/api/sample-data", h.GetSampleData)
@@ -153,6 +284,214 @@ func TestGetSampleData(t *testing.T) {
 	assert.Contains(t, sampleData, "entitas")
 }
 
+func TestDocumentTypes(t *testing.T) {
+	router, h := setupTestRouter()
+	router.GET("/api/document-types", h.ListDocumentTypes)
+	router.GET("/api/document-types/:kodeDokumen/schema", h.GetDocumentTypeSchema)
+	router.GET("/api/sample-data", h.GetSampleData)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/api/document-types")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Data []models.DocumentTypeInfo `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	codes := []string{}
+	for _, info := range response.Data {
+		codes = append(codes, info.KodeDokumen)
+	}
+	assert.Contains(t, codes, "20")
+
+	w = get("/api/document-types/20/schema")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, json.Valid(w.Body.Bytes()))
+
+	for _, path := range []string{"/api/document-types/99/schema", "/api/sample-data?kodeDokumen=99"} {
+		w = get(path)
+		assert.Equal(t, http.StatusBadRequest, w.Code, path)
+		assert.Contains(t, w.Body.String(), string(models.ErrCodeUnknownDocumentType), path)
+	}
+}
+
+func TestCalculateDuties(t *testing.T) {
+	router, h := setupTestRouter()
+	router.POST("/api/calculate-duties", h.CalculateDuties)
+
+	calculate := func(body interface{}) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(body)
+		req, _ := http.NewRequest("POST", "/api/calculate-duties", bytes.NewBuffer(data))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := calculate(map[string]interface{}{
+		"json_data": services.NewJsonGenerator().GenerateSampleData(),
+		"api":       false,
+	})
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		Data struct {
+			JsonData    models.ResponseData    `json:"json_data"`
+			Calculation models.DutyCalculation `json:"calculation"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.False(t, response.Data.Calculation.Api)
+	assert.NotEmpty(t, response.Data.Calculation.Summary)
+
+	var pph *models.BarangTarif
+	for i, tarif := range response.Data.JsonData.Barang[0].BarangTarif {
+		if tarif.KodeJenisPungutan == services.KodePungutanPph {
+			pph = &response.Data.JsonData.Barang[0].BarangTarif[i]
+		}
+	}
+	if assert.NotNil(t, pph) {
+		assert.Equal(t, services.TarifPphNonApi, pph.Tarif)
+	}
+
+	w = calculate(map[string]interface{}{"json_data": services.NewJsonGenerator().GenerateExportSampleData()})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCalculateValuation(t *testing.T) {
+	router, h := setupTestRouter()
+	router.POST("/api/calculate-valuation", h.CalculateValuation)
+
+	calculate := func(body interface{}) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(body)
+		req, _ := http.NewRequest("POST", "/api/calculate-valuation", bytes.NewBuffer(data))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	sample := services.NewJsonGenerator().GenerateSampleData()
+	sample.KodeIncoterm = "FOB"
+	sample.Freight = 40
+	w := calculate(map[string]interface{}{"json_data": sample, "basis": "weight"})
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		Data struct {
+			JsonData  models.ResponseData `json:"json_data"`
+			Valuation models.Valuation    `json:"valuation"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, services.ProrateByWeight, response.Data.Valuation.Basis)
+	assert.Equal(t, response.Data.Valuation.Cif, response.Data.JsonData.Cif)
+	assert.Equal(t, 40.0, response.Data.JsonData.Barang[0].Freight+response.Data.JsonData.Barang[1].Freight)
+
+	w = calculate(map[string]interface{}{"json_data": sample, "basis": "volume"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestKurs(t *testing.T) {
+	router, h := setupTestRouter()
+	router.POST("/api/kurs/import", h.ImportKurs)
+	router.GET("/api/kurs", h.ListKurs)
+	router.POST("/api/generate-json", h.GenerateJson)
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", "kurs.csv")
+	part.Write([]byte("kodeValuta,nilai,tanggalAwal,tanggalAkhir\nCNY,2150.25,2021-12-22,2021-12-28\nUSD,14350,2021-12-22,2021-12-28\n"))
+	form.Close()
+
+	req, _ := http.NewRequest("POST", "/api/kurs/import", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	req, _ = http.NewRequest("GET", "/api/kurs?kodeValuta=USD&tanggal=2021-12-25", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var rates struct {
+		Data []models.Kurs `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &rates))
+	if assert.Len(t, rates.Data, 1) {
+		assert.Equal(t, 14350.0, rates.Data[0].Nilai)
+	}
+
+	// The sample is a CNY declaration of 2021-12-25
+	sample := services.NewJsonGenerator().GenerateSampleData()
+	sample.Ndpbm = 0
+	sample.Barang[0].Ndpbm = 0
+	data, _ := json.Marshal(map[string]interface{}{"data": sample, "derive": true})
+	req, _ = http.NewRequest("POST", "/api/generate-json", bytes.NewBuffer(data))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		Data struct {
+			JsonData models.ResponseData    `json:"json_data"`
+			Problems []models.FieldError    `json:"problems"`
+			Changes  []models.DerivedChange `json:"changes"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 2150.25, response.Data.JsonData.Ndpbm)
+	assert.Equal(t, 2150.25, response.Data.JsonData.Barang[0].Ndpbm)
+	assert.Contains(t, response.Data.Changes, models.DerivedChange{Field: "ndpbm", From: 0.0, To: 2150.25, Reason: "kurs CNY in force on 2021-12-25"})
+	assert.Contains(t, response.Data.Problems, models.FieldError{Field: "barang[1].ndpbm", Code: models.FieldMismatch,
+		Message: "ndpbm 1234.5600 differs from the CNY rate of 2150.2500 in force on 2021-12-25"})
+}
+
+func TestReferences(t *testing.T) {
+	router, h := setupTestRouter()
+	router.GET("/api/references", h.ListReferenceTables)
+	router.GET("/api/references/:table", h.SearchReferences)
+	router.POST("/api/generate-json", h.GenerateJson)
+
+	req, _ := http.NewRequest("GET", "/api/references/valuta?q=usd", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var codes struct {
+		Data []models.ReferenceCode `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &codes))
+	assert.Equal(t, []models.ReferenceCode{{Kode: "USD", Uraian: "US DOLLAR"}}, codes.Data)
+
+	req, _ = http.NewRequest("GET", "/api/references/unknown", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	sample := services.NewJsonGenerator().GenerateSampleData()
+	sample.KodeValuta = "CNH"
+	data, _ := json.Marshal(map[string]interface{}{"data": sample})
+	req, _ = http.NewRequest("POST", "/api/generate-json", bytes.NewBuffer(data))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Data struct {
+			Problems []models.FieldError `json:"problems"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Contains(t, response.Data.Problems, models.FieldError{Field: "kodeValuta", Code: models.FieldInvalidValue,
+		Message: `unknown valuta code "CNH", did you mean CNY?`})
+}
+
 func TestTestConnection(t *testing.T) {
 	router, h := setupTestRouter()
 	router.POST("/api/test-connection", h.TestConnection)
@@ -181,3 +520,752 @@ func TestTestConnection(t *testing.T) {
 	assert.Contains(t, responseData, "endpoint")
 	assert.Contains(t, responseData, "message")
 }
+
+func TestOAuthConfigIsScopedToSession(t *testing.T) {
+	router, h := setupTestRouter()
+	router.Any("/api/oauth/config", h.OAuthConfig)
+
+	requestBody := models.OAuthConfigRequest{
+		TokenURL:   "https://example.com/token",
+		RefreshURL: "https://example.com/refresh",
+		Username:   "operator-a",
+		Password:   "secret",
+	}
+
+	jsonData, _ := json.Marshal(requestBody)
+	req, _ := http.NewRequest("POST", "/api/oauth/config", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	cookies := w.Result().Cookies()
+	assert.Len(t, cookies, 1)
+
+	// The issuing session sees its own configuration
+	req, _ = http.NewRequest("GET", "/api/oauth/config", nil)
+	req.AddCookie(cookies[0])
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response models.ApiResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	configData := response.Data.(map[string]interface{})
+	assert.Equal(t, "operator-a", configData["username"])
+
+	// Another caller does not
+	req, _ = http.NewRequest("GET", "/api/oauth/config", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	configData = response.Data.(map[string]interface{})
+	assert.Equal(t, "", configData["username"])
+}
+
+func TestRoleBasedAccess(t *testing.T) {
+	_, h := setupTestRouter()
+
+	hash, err := services.HashPassword("s3cret")
+	assert.NoError(t, err)
+	userStore, err := services.NewUserStore([]services.UserAccount{
+		{Username: "viewer", PasswordHash: hash, Role: models.RoleViewer},
+		{Username: "approver", PasswordHash: hash, Role: models.RoleApprover},
+	}, nil)
+	assert.NoError(t, err)
+	h.userStore = userStore
+
+	router := gin.New()
+	router.POST("/api/auth/login", h.Login)
+	authed := router.Group("/api", middleware.Authenticate(h.ResolvePrincipal))
+	authed.POST("/send-to-api", middleware.RequireRole(models.RoleApprover), func(c *gin.Context) {
+		middleware.HandleSuccess(c, nil)
+	})
+
+	login := func(username string) string {
+		body, _ := json.Marshal(models.LoginRequest{Username: username, Password: "s3cret"})
+		req, _ := http.NewRequest("POST", "/api/auth/login", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response models.ApiResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		data, _ := response.Data.(map[string]interface{})
+		token, _ := data["session_token"].(string)
+		return token
+	}
+
+	send := func(token string) int {
+		req, _ := http.NewRequest("POST", "/api/send-to-api", nil)
+		if token != "" {
+			req.Header.Set(middleware.SessionHeaderName, token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, send(""))
+	assert.Equal(t, http.StatusForbidden, send(login("viewer")))
+	token := login("approver")
+	assert.Equal(t, http.StatusOK, send(token))
+
+	// Sessions follow changes to the users file
+	h.userStore, err = services.NewUserStore([]services.UserAccount{
+		{Username: "approver", PasswordHash: hash, Role: models.RoleViewer},
+	}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, send(token))
+
+	h.userStore, err = services.NewUserStore([]services.UserAccount{
+		{Username: "approver", PasswordHash: hash, Role: models.RoleApprover, Disabled: true},
+	}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, send(token))
+}
+
+func TestSendToApiWithDefaults(t *testing.T) {
+	ceisa := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status": "OK"}`))
+	}))
+	defer ceisa.Close()
+
+	// Without a users file approval is off, so the anonymous caller can submit
+	router, h := setupTestRouter()
+	h.RegisterRoutes(router.Group("/api"))
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"json_data":  services.NewJsonGenerator().GenerateExportSampleData(),
+		"api_config": map[string]interface{}{"endpoint": ceisa.URL, "timeout": 5, "auth_type": "none"},
+	})
+	req, _ := http.NewRequest("POST", "/api/send-to-api", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
+
+func TestSendToApiRequiresTokenIdentity(t *testing.T) {
+	submitted := false
+	ceisa := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/token" {
+			w.Write([]byte(`{"status": "success", "item": {"access_token": "opaque-token", "expires_in": 300, "token_type": "Bearer"}}`))
+			return
+		}
+		submitted = true
+		w.Write([]byte(`{"status": "OK"}`))
+	}))
+	defer ceisa.Close()
+
+	router, h := setupTestRouter()
+	router.POST("/api/send-to-api", h.SendToApi)
+
+	session, err := h.oauthService.NewSession()
+	assert.NoError(t, err)
+	session.SetConfig(&models.OAuth2Config{TokenURL: ceisa.URL + "/token", RefreshURL: ceisa.URL + "/refresh", Username: "operator", Password: "secret"})
+	_, err = session.Login("operator", "secret")
+	assert.NoError(t, err)
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"json_data":  services.NewJsonGenerator().GenerateExportSampleData(),
+		"api_config": map[string]interface{}{"endpoint": ceisa.URL + "/submit", "timeout": 5, "auth_type": "oauth2"},
+	})
+	req, _ := http.NewRequest("POST", "/api/send-to-api", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(middleware.SessionHeaderName, session.ID)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// A token without NPWP or NIB cannot show the caller is the declarant
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), string(models.ErrCodeIdentityMismatch))
+	assert.False(t, submitted)
+}
+
+func TestSendToApiRequiresApproval(t *testing.T) {
+	router, h := setupTestRouter()
+	h.config().RequireApproval = true
+	router.Use(middleware.Authenticate(h.ResolvePrincipal))
+	router.POST("/api/send-to-api", h.SendToApi)
+
+	preparer := &models.Principal{Username: "alice", Role: models.RolePreparer}
+	document, err := h.documents.Create(&models.ResponseData{NomorAju: "000020010203202401010000001"}, preparer, "")
+	assert.NoError(t, err)
+
+	send := func(request map[string]interface{}) int {
+		body, _ := json.Marshal(request)
+		req, _ := http.NewRequest("POST", "/api/send-to-api", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	apiConfig := map[string]interface{}{"endpoint": "http://127.0.0.1:1/api", "timeout": 1, "auth_type": "none"}
+
+	assert.Equal(t, http.StatusBadRequest, send(map[string]interface{}{"api_config": apiConfig}))
+	assert.Equal(t, http.StatusConflict, send(map[string]interface{}{"api_config": apiConfig, "document_id": document.ID}))
+	assert.Equal(t, http.StatusNotFound, send(map[string]interface{}{"api_config": apiConfig, "document_id": "unknown"}))
+}
+
+func TestSendToApiClaimsApprovedDocument(t *testing.T) {
+	calls := 0
+	reject := true
+	entered := make(chan struct{}, 1)
+	release := make(chan struct{})
+	ceisa := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		if reject {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"status": "REJECTED"}`))
+			return
+		}
+		entered <- struct{}{}
+		<-release
+		w.Write([]byte(`{"status": "OK"}`))
+	}))
+	defer ceisa.Close()
+
+	router, h := setupTestRouter()
+	router.Use(middleware.Authenticate(h.ResolvePrincipal))
+	router.POST("/api/send-to-api", h.SendToApi)
+
+	preparer := &models.Principal{Username: "alice", Role: models.RolePreparer}
+	approver := &models.Principal{Username: "bob", Role: models.RoleApprover}
+	document, err := h.documents.Create(services.NewJsonGenerator().GenerateExportSampleData(), preparer, "")
+	assert.NoError(t, err)
+	_, err = h.documents.Transition(document.ID, models.DocumentStatusReadyForReview, preparer, "")
+	assert.NoError(t, err)
+	_, err = h.documents.Transition(document.ID, models.DocumentStatusApproved, approver, "")
+	assert.NoError(t, err)
+
+	send := func(key string, force bool) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]interface{}{
+			"document_id": document.ID,
+			"api_config":  map[string]interface{}{"endpoint": ceisa.URL, "timeout": 5, "auth_type": "none"},
+			"force":       force,
+		})
+		req, _ := http.NewRequest("POST", "/api/send-to-api", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	status := func() string {
+		current, _ := h.documents.Get(document.ID)
+		return current.Status
+	}
+
+	// A rejected send leaves the document approved
+	assert.Contains(t, send("first", false).Body.String(), `"success":false`)
+	assert.Equal(t, models.DocumentStatusApproved, status())
+
+	// While a send is in flight the document cannot be sent again
+	reject = false
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- send("second", false) }()
+	<-entered
+	assert.Equal(t, models.DocumentStatusSubmitting, status())
+	assert.Equal(t, http.StatusConflict, send("third", false).Code)
+	assert.Equal(t, http.StatusConflict, send("fourth", true).Code)
+
+	close(release)
+	assert.Contains(t, (<-done).Body.String(), `"success":true`)
+	assert.Equal(t, models.DocumentStatusSubmitted, status())
+	assert.Equal(t, 2, calls)
+}
+
+func TestSendToApiExportDeclaration(t *testing.T) {
+	var received map[string]interface{}
+	ceisa := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status": "OK"}`))
+	}))
+	defer ceisa.Close()
+
+	router, h := setupTestRouter()
+	h.config().RequireApproval = false
+	router.POST("/api/send-to-api", h.SendToApi)
+
+	send := func(declaration interface{}) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]interface{}{
+			"json_data":  declaration,
+			"api_config": map[string]interface{}{"endpoint": ceisa.URL, "timeout": 5, "auth_type": "none"},
+		})
+		req, _ := http.NewRequest("POST", "/api/send-to-api", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	declaration := services.NewJsonGenerator().GenerateExportSampleData()
+	w := send(declaration)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "30", received["kodeDokumen"])
+	assert.Contains(t, received, "kesiapanBarang")
+	assert.Contains(t, received, "bankDevisa")
+
+	// The buyer is required on an export declaration
+	declaration.NomorAju = "300100EXP00120211225000002"
+	declaration.Entitas = declaration.Entitas[:1]
+	w = send(declaration)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "kodeEntitas 8 (buyer)")
+}
+
+func TestSendToApiIdempotency(t *testing.T) {
+	calls := 0
+	ceisa := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		if calls == 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"status": "REJECTED"}`))
+			return
+		}
+		w.Write([]byte(`{"status": "OK"}`))
+	}))
+	defer ceisa.Close()
+
+	router, h := setupTestRouter()
+	router.POST("/api/send-to-api", h.SendToApi)
+
+	send := func(declaration interface{}) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]interface{}{
+			"json_data":  declaration,
+			"api_config": map[string]interface{}{"endpoint": ceisa.URL, "timeout": 5, "auth_type": "none"},
+		})
+		req, _ := http.NewRequest("POST", "/api/send-to-api", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", "submission-1")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// A rejection is not replayed, the declaration can be sent again
+	declaration := services.NewJsonGenerator().GenerateExportSampleData()
+	assert.Contains(t, send(declaration).Body.String(), `"success":false`)
+	w := send(declaration)
+	assert.Contains(t, w.Body.String(), `"success":true`)
+	assert.Contains(t, w.Body.String(), `"replayed":false`)
+	assert.Equal(t, 2, calls)
+
+	// An accepted submission is replayed
+	w = send(declaration)
+	assert.Contains(t, w.Body.String(), `"replayed":true`)
+	assert.Equal(t, 2, calls)
+
+	// The key cannot be reused for another declaration
+	declaration.NomorAju = "300100EXP00120211225000002"
+	w = send(declaration)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, 2, calls)
+}
+
+func TestSendToApiIdempotencyIsScoped(t *testing.T) {
+	calls := 0
+	ceisa := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status": "OK"}`))
+	}))
+	defer ceisa.Close()
+
+	_, h := setupTestRouter()
+	api := &models.ApiConfig{Endpoint: ceisa.URL, AuthType: "none", Timeout: 5}
+	tenants, err := services.NewTenantStore([]models.Tenant{
+		{ID: "acme", Users: []string{"alice", "bob"}, API: api},
+		{ID: "globex", Users: []string{"mallory"}, API: api},
+		{ID: "initech", Users: []string{"eve"}, API: api},
+	})
+	assert.NoError(t, err)
+	h.tenants = tenants
+
+	var caller *models.Principal
+	router := gin.New()
+	scoped := router.Group("/api", middleware.Authenticate(func(*gin.Context) *models.Principal { return caller }), h.SelectTenant)
+	scoped.POST("/send-to-api", h.SendToApi)
+
+	send := func(tenant, username string, declaration interface{}) *httptest.ResponseRecorder {
+		caller = &models.Principal{Username: username, Role: models.RolePreparer}
+		body, _ := json.Marshal(map[string]interface{}{"json_data": declaration})
+		req, _ := http.NewRequest("POST", "/api/send-to-api", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(middleware.TenantHeaderName, tenant)
+		req.Header.Set("Idempotency-Key", "shared-key")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	declaration := services.NewJsonGenerator().GenerateExportSampleData()
+	w := send("acme", "alice", declaration)
+	assert.Contains(t, w.Body.String(), `"replayed":false`)
+	assert.Equal(t, 1, calls)
+
+	// Other tenants reusing the key neither get the recorded response nor
+	// learn of it through a conflict
+	w = send("initech", "eve", declaration)
+	assert.Contains(t, w.Body.String(), `"replayed":false`)
+	other := services.NewJsonGenerator().GenerateExportSampleData()
+	other.NomorAju = "300100EXP00120211225000002"
+	w = send("globex", "mallory", other)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"replayed":false`)
+	assert.Equal(t, 3, calls)
+
+	// Client keys are also scoped to the caller within a tenant
+	w = send("acme", "bob", declaration)
+	assert.Contains(t, w.Body.String(), `"replayed":false`)
+	w = send("acme", "alice", declaration)
+	assert.Contains(t, w.Body.String(), `"replayed":true`)
+	assert.Equal(t, 4, calls)
+}
+
+func TestSendToApiTemporaryReturn(t *testing.T) {
+	ceisa := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status": "OK"}`))
+	}))
+	defer ceisa.Close()
+
+	router, h := setupTestRouter()
+	h.config().RequireApproval = false
+	router.Use(middleware.Authenticate(h.ResolvePrincipal))
+	router.POST("/api/send-to-api", h.SendToApi)
+	router.GET("/api/temporary-exports/:nomorAju", h.GetTemporaryExport)
+
+	send := func(declaration interface{}) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]interface{}{
+			"json_data":  declaration,
+			"api_config": map[string]interface{}{"endpoint": ceisa.URL, "timeout": 5, "auth_type": "none"},
+			"force":      true,
+		})
+		req, _ := http.NewRequest("POST", "/api/send-to-api", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Goods can only come back on a BC 2.6.1 that was submitted
+	generator := services.NewJsonGenerator()
+	returned := generator.GenerateTemporaryReturnSampleData()
+	w := send(returned)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "has not been submitted")
+
+	preparer := &models.Principal{Username: "alice", Role: models.RolePreparer}
+	approver := &models.Principal{Username: "bob", Role: models.RoleApprover}
+	source := generator.GenerateTemporaryExportSampleData()
+	document, err := h.documents.Create(source, preparer, "")
+	assert.NoError(t, err)
+	_, err = h.documents.Transition(document.ID, models.DocumentStatusReadyForReview, preparer, "")
+	assert.NoError(t, err)
+	_, err = h.documents.Transition(document.ID, models.DocumentStatusApproved, approver, "")
+	assert.NoError(t, err)
+	_, err = h.documents.ClaimSubmission(document.ID, approver)
+	assert.NoError(t, err)
+	_, err = h.documents.MarkSubmitted(document.ID, approver, nil)
+	assert.NoError(t, err)
+
+	w = send(returned)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Returning more than was sent out is rejected
+	returned.Barang[0].JumlahRealisasi = 11
+	returned.Barang[0].SaldoAkhir = -1
+	w = send(returned)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "barang[0].jumlahRealisasi")
+
+	// The return sent directly counts, its quantities cannot come back again
+	again := generator.GenerateTemporaryReturnSampleData()
+	again.NomorAju = "00026205050020211225000002"
+	w = send(again)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "only 0.00 of item 2")
+
+	// An approved return holds its quantities until it is sent
+	approvedReturn := generator.GenerateTemporaryReturnSampleData()
+	approvedReturn.NomorAju = "00026205050020211225000003"
+	approvedReturn.Barang = approvedReturn.Barang[:1]
+	approvedReturn.Barang[0].SaldoAwal, approvedReturn.Barang[0].JumlahRealisasi, approvedReturn.Barang[0].SaldoAkhir = 4, 4, 0
+	pending, err := h.documents.Create(approvedReturn, preparer, "")
+	assert.NoError(t, err)
+	_, err = h.documents.Transition(pending.ID, models.DocumentStatusReadyForReview, preparer, "")
+	assert.NoError(t, err)
+	_, err = h.documents.Transition(pending.ID, models.DocumentStatusApproved, approver, "")
+	assert.NoError(t, err)
+
+	competing := *approvedReturn
+	competing.NomorAju = "00026205050020211225000004"
+	w = send(&competing)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "pending on approved returns")
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"document_id": pending.ID,
+		"api_config":  map[string]interface{}{"endpoint": ceisa.URL, "timeout": 5, "auth_type": "none"},
+	})
+	req, _ := http.NewRequest("POST", "/api/send-to-api", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	req, _ = http.NewRequest("GET", "/api/temporary-exports/"+source.NomorAju, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"outstanding":false`)
+}
+
+func TestTenantScoping(t *testing.T) {
+	_, h := setupTestRouter()
+
+	tenants, err := services.NewTenantStore([]models.Tenant{
+		{ID: "acme", Defaults: models.TenantDefaults{KodeKantor: "040300", IdPengguna: "ACME01"}},
+		{ID: "globex"},
+	})
+	assert.NoError(t, err)
+	h.tenants = tenants
+
+	router := gin.New()
+	scoped := router.Group("/api", middleware.Authenticate(h.ResolvePrincipal), h.SelectTenant)
+	scoped.GET("/sample-data", h.GetSampleData)
+	scoped.GET("/documents/:id", h.GetDocument)
+
+	get := func(path, tenant string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path, nil)
+		if tenant != "" {
+			req.Header.Set(middleware.TenantHeaderName, tenant)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusBadRequest, get("/api/sample-data", "").Code)
+	assert.Equal(t, http.StatusNotFound, get("/api/sample-data", "initech").Code)
+
+	w := get("/api/sample-data", "acme")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"idPengguna":"ACME01"`)
+
+	preparer := &models.Principal{Username: "alice", Role: models.RolePreparer}
+	document, err := h.documents.Create(&models.ResponseData{}, preparer, "acme")
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, get("/api/documents/"+document.ID, "acme").Code)
+	assert.Equal(t, http.StatusNotFound, get("/api/documents/"+document.ID, "globex").Code)
+}
+
+func TestTenantCredentialsAreProtected(t *testing.T) {
+	_, h := setupTestRouter()
+
+	tenants, err := services.NewTenantStore([]models.Tenant{{ID: "acme", Users: []string{"bob"}}})
+	assert.NoError(t, err)
+	h.tenants = tenants
+
+	var caller *models.Principal
+	router := gin.New()
+	scoped := router.Group("/api", middleware.Authenticate(func(*gin.Context) *models.Principal { return caller }), h.SelectTenant)
+	scoped.POST("/send-to-api", h.SendToApi)
+	scoped.POST("/oauth/login", h.OAuthLogin)
+	scoped.POST("/oauth/config", h.OAuthConfig)
+
+	post := func(path string, request interface{}) int {
+		body, _ := json.Marshal(request)
+		req, _ := http.NewRequest("POST", path, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(middleware.TenantHeaderName, "acme")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	oauthConfig := models.OAuthConfigRequest{TokenURL: "https://example.test/token", RefreshURL: "https://example.test/refresh", Username: "acme", Password: "secret"}
+
+	// Tenant members use the tenant's endpoint and credentials as configured
+	caller = &models.Principal{Username: "bob", Role: models.RoleApprover}
+	assert.Equal(t, http.StatusBadRequest, post("/api/send-to-api", map[string]interface{}{
+		"json_data":  services.NewJsonGenerator().GenerateExportSampleData(),
+		"api_config": map[string]interface{}{"endpoint": "https://attacker.example/collect", "auth_type": "oauth2"},
+	}))
+	assert.Equal(t, http.StatusForbidden, post("/api/oauth/login", models.OAuthLoginApiRequest{Username: "other", Password: "secret"}))
+	assert.Equal(t, http.StatusForbidden, post("/api/oauth/config", oauthConfig))
+
+	caller = &models.Principal{Username: "root", Role: models.RoleAdmin}
+	assert.Equal(t, http.StatusOK, post("/api/oauth/config", oauthConfig))
+}
+
+func TestActionsAreAudited(t *testing.T) {
+	router, h := setupTestRouter()
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	auditLog, err := services.OpenAuditLog(path)
+	assert.NoError(t, err)
+	h.SetAuditLog(auditLog)
+
+	router.Use(middleware.RequestID(), middleware.Authenticate(h.ResolvePrincipal))
+	router.POST("/api/generate-json", h.GenerateJson)
+	router.GET("/api/audit/export", h.ExportAudit)
+
+	body, _ := json.Marshal(models.GenerateJsonRequest{Data: map[string]interface{}{"nomorAju": "000020010203202401010000001"}})
+	req, _ := http.NewRequest("POST", "/api/generate-json", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(middleware.RequestIDHeaderName, "req-123")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "req-123", w.Header().Get(middleware.RequestIDHeaderName))
+
+	req, _ = http.NewRequest("GET", "/api/audit/export?format=jsonl", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var entry models.AuditEntry
+	assert.NoError(t, json.Unmarshal(bytes.TrimSpace(w.Body.Bytes()), &entry))
+	assert.Equal(t, services.AuditGenerate, entry.Action)
+	assert.Equal(t, "anonymous", entry.Actor)
+	assert.Equal(t, "req-123", entry.RequestID)
+	assert.Equal(t, "000020010203202401010000001", entry.Target)
+	assert.NotEmpty(t, entry.BeforeHash)
+	assert.NotEmpty(t, entry.AfterHash)
+}
+
+func TestErrorResponsesCarryCodes(t *testing.T) {
+	router, h := setupTestRouter()
+	router.Use(middleware.Authenticate(h.ResolvePrincipal))
+	router.POST("/api/documents", h.CreateDocument)
+	router.GET("/api/documents/:id", h.GetDocument)
+
+	errorDetails := func(w *httptest.ResponseRecorder) models.ErrorDetails {
+		var response struct {
+			Success bool                `json:"success"`
+			Error   string              `json:"error"`
+			Details models.ErrorDetails `json:"details"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.False(t, response.Success)
+		assert.NotEmpty(t, response.Error)
+		return response.Details
+	}
+
+	req, _ := http.NewRequest("POST", "/api/documents", bytes.NewBufferString(`{"json_data": {"nomorAju": 5}}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	details := errorDetails(w)
+	assert.Equal(t, models.ErrCodeValidationFailed, details.Code)
+	if assert.Len(t, details.Fields, 1) {
+		assert.Equal(t, "json_data.nomorAju", details.Fields[0].Field)
+		assert.Equal(t, models.FieldInvalidType, details.Fields[0].Code)
+	}
+
+	req, _ = http.NewRequest("GET", "/api/documents/unknown", nil)
+	req.Header.Set("Accept-Language", "id-ID,id;q=0.9,en;q=0.8")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	details = errorDetails(w)
+	assert.Equal(t, models.ErrCodeNotFound, details.Code)
+	assert.Equal(t, "Data yang diminta tidak ditemukan", details.Message)
+	assert.Equal(t, "Document not found", details.Detail)
+}
+
+func TestErrorCodesAreLocalized(t *testing.T) {
+	for _, code := range models.ErrorCodes() {
+		for _, lang := range []string{middleware.LanguageEnglish, middleware.LanguageIndonesian} {
+			message := middleware.ErrorMessage(code, lang)
+			if code != models.ErrCodeInternal {
+				assert.NotEqual(t, middleware.ErrorMessage(models.ErrCodeInternal, lang), message, "code %s has no %s message", code, lang)
+			}
+		}
+	}
+}
+
+// openAPISpecFile is the committed specification; regenerate it with
+// UPDATE_OPENAPI=1 go test ./internal/handlers/
+const openAPISpecFile = "../../api/openapi.json"
+
+func TestOpenAPISpecIsUpToDate(t *testing.T) {
+	_, h := setupTestRouter()
+
+	generated, err := json.MarshalIndent(h.OpenAPISpec(), "", "  ")
+	assert.NoError(t, err)
+	generated = append(generated, '\n')
+
+	if os.Getenv("UPDATE_OPENAPI") != "" {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(openAPISpecFile), 0755))
+		assert.NoError(t, os.WriteFile(openAPISpecFile, generated, 0644))
+	}
+
+	committed, err := os.ReadFile(openAPISpecFile)
+	assert.NoError(t, err)
+	assert.Equal(t, string(committed), string(generated), "api/openapi.json is out of date, run UPDATE_OPENAPI=1 go test ./internal/handlers/")
+}
+
+func TestOpenAPISpecCoversRoutes(t *testing.T) {
+	router, h := setupTestRouter()
+	h.RegisterRoutes(router.Group(APIBasePath))
+	h.RegisterRoutes(router.Group("/api"))
+
+	paths := h.OpenAPISpec()["paths"].(map[string]interface{})
+
+	registered := 0
+	for _, info := range router.Routes() {
+		if !strings.HasPrefix(info.Path, APIBasePath+"/") {
+			continue
+		}
+		registered++
+
+		path := openAPIPath(strings.TrimPrefix(info.Path, APIBasePath))
+		item, ok := paths[path].(map[string]interface{})
+		if assert.True(t, ok, "route %s %s is missing from the specification", info.Method, info.Path) {
+			assert.Contains(t, item, strings.ToLower(info.Method), "route %s %s is missing from the specification", info.Method, info.Path)
+		}
+	}
+
+	operations := 0
+	for _, item := range paths {
+		operations += len(item.(map[string]interface{}))
+	}
+	assert.Equal(t, operations, registered, "the specification documents routes that are not registered")
+
+	// The unversioned paths remain as aliases
+	for _, prefix := range []string{APIBasePath, "/api"} {
+		req, _ := http.NewRequest("GET", prefix+"/health", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, prefix)
+	}
+
+	req, _ := http.NewRequest("GET", "/api/openapi.json", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var spec map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &spec))
+	schemas := spec["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	for _, name := range []string{"ResponseData", "Barang", "ApiResponse", "ErrorDetails", "ErrorCode"} {
+		assert.Contains(t, schemas, name)
+	}
+}
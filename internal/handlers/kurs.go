@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"json-response-generator/internal/middleware"
+	"json-response-generator/internal/models"
+	"json-response-generator/internal/services"
+)
+
+// Exchange Rate Handlers
+
+// ListKurs returns the stored exchange rates, of one currency with
+// ?kodeValuta= and only those in force on a day with ?tanggal=YYYY-MM-DD
+func (h *Handlers) ListKurs(c *gin.Context) {
+	kodeValuta := c.Query("kodeValuta")
+	tanggal := c.Query("tanggal")
+	if tanggal == "" {
+		middleware.HandleSuccess(c, h.kurs.List(kodeValuta))
+		return
+	}
+
+	rates := []models.Kurs{}
+	for _, kurs := range h.kurs.List(kodeValuta) {
+		if kurs.TanggalAwal <= tanggal && tanggal <= kurs.TanggalAkhir {
+			rates = append(rates, kurs)
+		}
+	}
+	middleware.HandleSuccess(c, rates)
+}
+
+// ImportKurs adds the exchange rates of an uploaded CSV or Excel file
+func (h *Handlers) ImportKurs(c *gin.Context) {
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		middleware.HandleError(c, models.NewError(models.ErrCodeFileMissing, "No file uploaded", err))
+		return
+	}
+	defer file.Close()
+
+	maxFileSize := h.config().MaxFileSize
+	if header.Size > maxFileSize {
+		middleware.HandleError(c, models.Errorf(models.ErrCodeFileTooLarge, "File too large. Maximum size is %d MB.", maxFileSize/(1024*1024)))
+		return
+	}
+
+	result, err := h.kurs.Import(services.KursFile{Name: header.Filename, Reader: file})
+	h.audit(c, models.AuditEntry{Action: services.AuditKursImport, Target: header.Filename, Outcome: auditOutcome(err)})
+	if err != nil {
+		middleware.HandleError(c, models.AsAppError(err, models.ErrCodeInternal, "Failed to import exchange rates"))
+		return
+	}
+
+	middleware.HandleSuccess(c, result)
+}
@@ -0,0 +1,371 @@
+package handlers
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"json-response-generator/internal/middleware"
+	"json-response-generator/internal/models"
+	"json-response-generator/internal/services"
+	"json-response-generator/internal/version"
+)
+
+// APIBasePath is the prefix of the current API version. The unversioned /api
+// prefix remains as an alias.
+const APIBasePath = "/api/v1"
+
+// OpenAPI serves the OpenAPI 3 specification of the API
+func (h *Handlers) OpenAPI(c *gin.Context) {
+	c.JSON(http.StatusOK, h.OpenAPISpec())
+}
+
+// OpenAPISpec generates the OpenAPI 3 specification from the route table and
+// the models
+func (h *Handlers) OpenAPISpec() map[string]interface{} {
+	g := &specGenerator{schemas: map[string]interface{}{}}
+
+	g.schemas["ErrorResponse"] = map[string]interface{}{
+		"allOf": []interface{}{
+			schemaRef("ApiResponse"),
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"details": g.schema(reflect.TypeOf(models.ErrorDetails{})),
+				},
+			},
+		},
+	}
+	g.schema(reflect.TypeOf(models.ApiResponse{}))
+
+	paths := map[string]interface{}{}
+	for _, r := range h.routes() {
+		path := openAPIPath(r.path)
+		item, ok := paths[path].(map[string]interface{})
+		if !ok {
+			item = map[string]interface{}{}
+			paths[path] = item
+		}
+		item[strings.ToLower(r.method)] = g.operation(r)
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "JSON Response Generator API",
+			"version":     version.Version,
+			"description": "Prepares, approves and submits CEISA 4.0 customs declarations. Error responses carry a stable code in details.code; send Accept-Language: id for Indonesian messages.",
+		},
+		"servers": []interface{}{
+			map[string]interface{}{"url": APIBasePath},
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": g.schemas,
+			"responses": map[string]interface{}{
+				"Error": map[string]interface{}{
+					"description": "Error",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{"schema": schemaRef("ErrorResponse")},
+					},
+				},
+			},
+			"parameters": map[string]interface{}{
+				"TenantID": map[string]interface{}{
+					"name":        middleware.TenantHeaderName,
+					"in":          "header",
+					"description": "Tenant to act for, required in multi-tenant mode",
+					"schema":      map[string]interface{}{"type": "string"},
+				},
+			},
+			"securitySchemes": map[string]interface{}{
+				"sessionToken":  map[string]interface{}{"type": "apiKey", "in": "header", "name": middleware.SessionHeaderName},
+				"sessionCookie": map[string]interface{}{"type": "apiKey", "in": "cookie", "name": middleware.SessionCookieName},
+				"bearer":        map[string]interface{}{"type": "http", "scheme": "bearer"},
+				"apiKey":        map[string]interface{}{"type": "apiKey", "in": "header", "name": middleware.APIKeyHeaderName},
+			},
+		},
+	}
+}
+
+// specGenerator collects the component schemas referenced by operations
+type specGenerator struct {
+	schemas map[string]interface{}
+}
+
+// operation describes a route as an OpenAPI operation
+func (g *specGenerator) operation(r route) map[string]interface{} {
+	operation := map[string]interface{}{
+		"summary":     r.summary,
+		"operationId": strings.ToLower(r.method) + operationName(r.path),
+		"tags":        []string{r.tag},
+	}
+
+	var parameters []interface{}
+	for _, name := range pathParamPattern.FindAllStringSubmatch(r.path, -1) {
+		parameters = append(parameters, map[string]interface{}{
+			"name":     name[1],
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]interface{}{"type": "string"},
+		})
+	}
+	for _, param := range r.query {
+		parameters = append(parameters, map[string]interface{}{
+			"name":        param.name,
+			"in":          "query",
+			"description": param.description,
+			"schema":      map[string]interface{}{"type": "string"},
+		})
+	}
+	if r.access == accessTenant {
+		parameters = append(parameters, schemaRefTo("#/components/parameters/TenantID"))
+	}
+	if len(parameters) > 0 {
+		operation["parameters"] = parameters
+	}
+
+	if r.access != accessPublic {
+		operation["security"] = []interface{}{
+			map[string]interface{}{"sessionToken": []string{}},
+			map[string]interface{}{"sessionCookie": []string{}},
+			map[string]interface{}{"bearer": []string{}},
+			map[string]interface{}{"apiKey": []string{}},
+		}
+	}
+	if r.role != "" {
+		operation["x-required-role"] = r.role
+	}
+
+	switch {
+	case r.upload:
+		properties := map[string]interface{}{
+			"file": map[string]interface{}{"type": "string", "format": "binary"},
+		}
+		for _, field := range r.form {
+			properties[field.name] = map[string]interface{}{"type": "string", "description": field.description}
+		}
+		operation["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"multipart/form-data": map[string]interface{}{
+					"schema": map[string]interface{}{
+						"type":       "object",
+						"required":   []string{"file"},
+						"properties": properties,
+					},
+				},
+			},
+		}
+	case r.request != nil:
+		operation["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": g.schema(reflect.TypeOf(r.request))},
+			},
+		}
+	}
+
+	status := r.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	content := map[string]interface{}{}
+	switch {
+	case len(r.produces) > 0:
+		for _, contentType := range r.produces {
+			content[contentType] = map[string]interface{}{
+				"schema": map[string]interface{}{"type": "string", "format": "binary"},
+			}
+		}
+	case r.raw:
+		content["application/json"] = map[string]interface{}{"schema": map[string]interface{}{"type": "object"}}
+	default:
+		data := map[string]interface{}{}
+		if r.response != nil {
+			data = g.schema(reflect.TypeOf(r.response))
+		}
+		content["application/json"] = map[string]interface{}{
+			"schema": map[string]interface{}{
+				"allOf": []interface{}{
+					schemaRef("ApiResponse"),
+					map[string]interface{}{
+						"type":       "object",
+						"properties": map[string]interface{}{"data": data},
+					},
+				},
+			},
+		}
+	}
+
+	operation["responses"] = map[string]interface{}{
+		strconv.Itoa(status): map[string]interface{}{
+			"description": http.StatusText(status),
+			"content":     content,
+		},
+		"default": schemaRefTo("#/components/responses/Error"),
+	}
+
+	return operation
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+var errorCodeType = reflect.TypeOf(models.ErrorCode(""))
+var declarationDataType = reflect.TypeOf(models.DeclarationData{})
+
+// schema returns the JSON schema of t. Named structs become components and
+// are referenced.
+func (g *specGenerator) schema(t reflect.Type) map[string]interface{} {
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == errorCodeType:
+		if _, exists := g.schemas["ErrorCode"]; !exists {
+			codes := make([]string, 0)
+			for _, code := range models.ErrorCodes() {
+				codes = append(codes, string(code))
+			}
+			sort.Strings(codes)
+			g.schemas["ErrorCode"] = map[string]interface{}{"type": "string", "enum": codes}
+		}
+		return schemaRef("ErrorCode")
+	case t == declarationDataType:
+		if _, exists := g.schemas["Declaration"]; !exists {
+			var oneOf []interface{}
+			mapping := map[string]interface{}{}
+			seen := map[interface{}]bool{}
+			for _, dt := range services.DocumentTypes() {
+				ref := g.schema(reflect.TypeOf(dt.New()))
+				if nullable, ok := ref["allOf"].([]interface{}); ok {
+					ref = nullable[0].(map[string]interface{})
+				}
+				// Document types may share a model, e.g. BC 4.0 and BC 4.1
+				if !seen[ref["$ref"]] {
+					oneOf = append(oneOf, ref)
+					seen[ref["$ref"]] = true
+				}
+				mapping[dt.Code] = ref["$ref"]
+			}
+			g.schemas["Declaration"] = map[string]interface{}{
+				"description": "Declaration of the document type given by kodeDokumen, BC 2.0 when it is empty",
+				"oneOf":       oneOf,
+				"discriminator": map[string]interface{}{
+					"propertyName": "kodeDokumen",
+					"mapping":      mapping,
+				},
+			}
+		}
+		return schemaRef("Declaration")
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := g.schema(t.Elem())
+		if _, isRef := schema["$ref"]; isRef {
+			return map[string]interface{}{"allOf": []interface{}{schema}, "nullable": true}
+		}
+		schema["nullable"] = true
+		return schema
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		if _, exists := g.schemas[t.Name()]; !exists {
+			g.schemas[t.Name()] = map[string]interface{}{} // placeholder for recursive types
+			g.schemas[t.Name()] = g.structSchema(t)
+		}
+		return schemaRef(t.Name())
+	default:
+		// interface{} accepts any value
+		return map[string]interface{}{}
+	}
+}
+
+// structSchema returns the object schema of a struct from its json tags.
+// Fields tagged validate:"required" are required.
+func (g *specGenerator) structSchema(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name := field.Name
+		if tag := field.Tag.Get("json"); tag != "" {
+			if tag == "-" {
+				continue
+			}
+			if tagName := strings.Split(tag, ",")[0]; tagName != "" {
+				name = tagName
+			}
+		}
+
+		properties[name] = g.schema(field.Type)
+		if strings.Contains(field.Tag.Get("validate"), "required") {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+var pathParamPattern = regexp.MustCompile(`:(\w+)`)
+
+// openAPIPath converts a gin path to OpenAPI form, e.g. /documents/:id to /documents/{id}
+func openAPIPath(path string) string {
+	return pathParamPattern.ReplaceAllString(path, "{$1}")
+}
+
+// operationName turns a path into a camel-case operation name, e.g.
+// /documents/:id/transitions to DocumentsIdTransitions
+func operationName(path string) string {
+	var name strings.Builder
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/' || r == '-' || r == ':' || r == '.'
+	}) {
+		name.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return name.String()
+}
+
+func schemaRef(name string) map[string]interface{} {
+	return schemaRefTo("#/components/schemas/" + name)
+}
+
+func schemaRefTo(ref string) map[string]interface{} {
+	return map[string]interface{}{"$ref": ref}
+}
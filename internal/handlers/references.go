@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"json-response-generator/internal/middleware"
+	"json-response-generator/internal/models"
+	"json-response-generator/internal/services"
+)
+
+// Reference Data Handlers
+
+// ListReferenceTables describes the loaded customs reference tables
+func (h *Handlers) ListReferenceTables(c *gin.Context) {
+	middleware.HandleSuccess(c, h.references.Tables())
+}
+
+// SearchReferences returns the codes of a reference table matching ?q= for
+// autocomplete, at most ?limit= of them
+func (h *Handlers) SearchReferences(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	codes, err := h.references.Search(c.Param("table"), c.Query("q"), limit)
+	if errors.Is(err, services.ErrReferenceTableNotFound) {
+		middleware.HandleError(c, models.Errorf(models.ErrCodeNotFound, "reference table %s not found", c.Param("table")))
+		return
+	}
+	if err != nil {
+		middleware.HandleError(c, models.NewError(models.ErrCodeInternal, "Failed to search reference table", err))
+		return
+	}
+
+	middleware.HandleSuccess(c, codes)
+}
+
+// checkReferences checks the reference codes of a declaration against the
+// loaded tables
+func (h *Handlers) checkReferences(declaration models.Declaration) error {
+	problems := h.references.Check(declaration)
+	if len(problems) == 0 {
+		return nil
+	}
+
+	return models.NewError(models.ErrCodeValidationFailed, "declaration has unknown reference codes", nil).WithFields(problems...)
+}
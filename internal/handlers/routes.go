@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"json-response-generator/internal/config"
+	"json-response-generator/internal/middleware"
+	"json-response-generator/internal/models"
+)
+
+// routeAccess describes who may call a route
+type routeAccess int
+
+const (
+	// accessPublic routes need no authentication
+	accessPublic routeAccess = iota
+	// accessAuthenticated routes need an authenticated caller
+	accessAuthenticated
+	// accessTenant routes need an authenticated caller acting for a tenant
+	accessTenant
+)
+
+// queryParam documents a query string parameter or form field of a route
+type queryParam struct {
+	name        string
+	description string
+}
+
+// kodeDokumenParam selects the document type of a route
+var kodeDokumenParam = queryParam{"kodeDokumen", "Document type, e.g. 20 for BC 2.0 (default)"}
+
+// route describes an API endpoint. The same table registers the gin routes
+// and generates the OpenAPI specification.
+type route struct {
+	method   string
+	path     string
+	handler  gin.HandlerFunc
+	access   routeAccess
+	role     string // minimum role, empty for none
+	tag      string
+	summary  string
+	query    []queryParam
+	request  interface{}  // JSON request body
+	upload   bool         // multipart/form-data request with a "file" field
+	form     []queryParam // further fields of an upload
+	response interface{}  // type of ApiResponse.Data
+	status   int          // success status, 200 when zero
+	produces []string     // content types of a non-JSON response
+	raw      bool         // the response is not wrapped in ApiResponse
+}
+
+// routes returns every API endpoint
+func (h *Handlers) routes() []route {
+	return []route{
+		{method: http.MethodGet, path: "/health", handler: h.HealthCheck, tag: "System",
+			summary: "Health check", response: models.HealthResponse{}},
+		{method: http.MethodGet, path: "/ready", handler: h.Ready, tag: "System",
+			summary: "Readiness check of the temp directory, CEISA token and CEISA endpoint", response: models.ReadinessResponse{}},
+		{method: http.MethodGet, path: "/openapi.json", handler: h.OpenAPI, tag: "System",
+			summary: "OpenAPI specification of this API", raw: true},
+
+		{method: http.MethodPost, path: "/auth/login", handler: h.Login, tag: "Authentication",
+			summary: "Log in to this service and obtain a session token", request: models.LoginRequest{}, response: map[string]interface{}{}},
+		{method: http.MethodPost, path: "/auth/logout", handler: h.Logout, access: accessAuthenticated, tag: "Authentication",
+			summary: "End the caller's session", response: map[string]interface{}{}},
+		{method: http.MethodGet, path: "/auth/me", handler: h.Me, access: accessAuthenticated, tag: "Authentication",
+			summary: "Return the authenticated caller", response: models.Principal{}},
+		{method: http.MethodGet, path: "/tenants", handler: h.ListTenants, access: accessAuthenticated, tag: "Tenants",
+			summary: "List the tenants the caller may act for", response: map[string]interface{}{}},
+		{method: http.MethodGet, path: "/audit/export", handler: h.ExportAudit, access: accessAuthenticated, role: models.RoleAdmin, tag: "Audit",
+			summary: "Export the audit log",
+			query: []queryParam{
+				{"format", "jsonl (default) or csv"},
+				{"from", "Earliest entry time, RFC 3339"},
+				{"to", "Latest entry time, RFC 3339"},
+			},
+			produces: []string{"application/x-ndjson", "text/csv"}},
+
+		{method: http.MethodGet, path: "/config", handler: h.GetConfig, access: accessTenant, role: models.RoleViewer, tag: "System",
+			summary: "Get application configuration", response: config.AppConfig{}},
+		{method: http.MethodGet, path: "/download-template", handler: h.DownloadTemplate, access: accessTenant, role: models.RoleViewer, tag: "Excel",
+			summary:  "Download the Excel template",
+			query:    []queryParam{kodeDokumenParam},
+			produces: []string{"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"}},
+		{method: http.MethodGet, path: "/sample-data", handler: h.GetSampleData, access: accessTenant, role: models.RoleViewer, tag: "JSON",
+			summary: "Get sample declaration data", query: []queryParam{kodeDokumenParam}, response: map[string]interface{}{}},
+		{method: http.MethodGet, path: "/document-types", handler: h.ListDocumentTypes, access: accessAuthenticated, tag: "JSON",
+			summary: "List the supported document types", response: []models.DocumentTypeInfo{}},
+		{method: http.MethodGet, path: "/document-types/:kodeDokumen/schema", handler: h.GetDocumentTypeSchema, access: accessAuthenticated, tag: "JSON",
+			summary: "Get the JSON schema of a document type", raw: true},
+		{method: http.MethodGet, path: "/references", handler: h.ListReferenceTables, access: accessAuthenticated, tag: "References",
+			summary: "List the customs reference tables with their version", response: []models.ReferenceTableInfo{}},
+		{method: http.MethodGet, path: "/references/:table", handler: h.SearchReferences, access: accessAuthenticated, tag: "References",
+			summary: "Search the codes of a reference table for autocomplete",
+			query: []queryParam{
+				{"q", "Code prefix or part of the description"},
+				{"limit", "Maximum number of codes, 20 by default and at most 100"},
+			},
+			response: []models.ReferenceCode{}},
+		{method: http.MethodGet, path: "/oauth/status", handler: h.OAuthStatus, access: accessTenant, role: models.RoleViewer, tag: "CEISA OAuth",
+			summary: "Get the CEISA token status", response: map[string]interface{}{}},
+		{method: http.MethodGet, path: "/oauth/config", handler: h.OAuthConfig, access: accessTenant, role: models.RoleViewer, tag: "CEISA OAuth",
+			summary: "Get the CEISA OAuth configuration without secrets", response: map[string]interface{}{}},
+		{method: http.MethodGet, path: "/documents", handler: h.ListDocuments, access: accessTenant, role: models.RoleViewer, tag: "Documents",
+			summary:  "List declarations, newest first",
+			query:    []queryParam{{"status", "Only return declarations in this workflow status"}},
+			response: []models.Document{}},
+		{method: http.MethodGet, path: "/documents/:id", handler: h.GetDocument, access: accessTenant, role: models.RoleViewer, tag: "Documents",
+			summary: "Get a declaration with its transition history", response: models.Document{}},
+		{method: http.MethodGet, path: "/temporary-exports", handler: h.ListTemporaryExports, access: accessTenant, role: models.RoleViewer, tag: "Documents",
+			summary:  "List the quantities still outstanding on submitted BC 2.6.1 declarations",
+			query:    []queryParam{{"outstanding", "Only return declarations with goods still out when true"}},
+			response: []models.TemporaryExportBalance{}},
+		{method: http.MethodGet, path: "/temporary-exports/:nomorAju", handler: h.GetTemporaryExport, access: accessTenant, role: models.RoleViewer, tag: "Documents",
+			summary: "Get the quantities still outstanding on a submitted BC 2.6.1", response: models.TemporaryExportBalance{}},
+		{method: http.MethodGet, path: "/kurs", handler: h.ListKurs, access: accessAuthenticated, tag: "Kurs",
+			summary: "List the exchange rates (NDPBM) by currency and period",
+			query: []queryParam{
+				{"kodeValuta", "Only return rates of this currency"},
+				{"tanggal", "Only return rates in force on this day, YYYY-MM-DD"},
+			},
+			response: []models.Kurs{}},
+
+		{method: http.MethodPost, path: "/upload-excel", handler: h.UploadExcel, access: accessTenant, role: models.RolePreparer, tag: "Excel",
+			summary: "Upload and parse an Excel file", upload: true, form: []queryParam{kodeDokumenParam}, response: models.ExcelData{}},
+		{method: http.MethodPost, path: "/generate-json", handler: h.GenerateJson, access: accessTenant, role: models.RolePreparer, tag: "JSON",
+			summary: "Generate CEISA JSON from form or Excel data", request: models.GenerateJsonRequest{}, response: map[string]interface{}{}},
+		{method: http.MethodPost, path: "/calculate-duties", handler: h.CalculateDuties, access: accessTenant, role: models.RolePreparer, tag: "JSON",
+			summary: "Compute import duties and taxes and fill the barangTarif rows", request: models.DutyCalculationRequest{}, response: map[string]interface{}{}},
+		{method: http.MethodPost, path: "/calculate-valuation", handler: h.CalculateValuation, access: accessTenant, role: models.RolePreparer, tag: "JSON",
+			summary: "Convert the incoterm invoice value to the customs (CIF) value", request: models.ValuationRequest{}, response: map[string]interface{}{}},
+		{method: http.MethodPost, path: "/test-connection", handler: h.TestConnection, access: accessTenant, role: models.RolePreparer, tag: "CEISA",
+			summary: "Test the connection to an API endpoint", request: models.TestConnectionRequest{}, response: map[string]interface{}{}},
+		{method: http.MethodPost, path: "/documents", handler: h.CreateDocument, access: accessTenant, role: models.RolePreparer, tag: "Documents",
+			summary: "Create a declaration draft", request: models.DocumentRequest{}, response: models.Document{}, status: http.StatusCreated},
+		{method: http.MethodPut, path: "/documents/:id", handler: h.UpdateDocument, access: accessTenant, role: models.RolePreparer, tag: "Documents",
+			summary: "Edit a draft", request: models.DocumentRequest{}, response: models.Document{}},
+		// The workflow checks the role required by each transition
+		{method: http.MethodPost, path: "/documents/:id/transitions", handler: h.TransitionDocument, access: accessTenant, role: models.RolePreparer, tag: "Documents",
+			summary: "Change the workflow status of a declaration", request: models.DocumentTransitionRequest{}, response: models.Document{}},
+
+		{method: http.MethodPost, path: "/kurs/import", handler: h.ImportKurs, access: accessAuthenticated, role: models.RoleAdmin, tag: "Kurs",
+			summary: "Import exchange rates from a CSV or Excel file with the columns kodeValuta, nilai, tanggalAwal and tanggalAkhir", upload: true, response: models.KursImport{}},
+
+		{method: http.MethodPost, path: "/send-to-api", handler: h.SendToApi, access: accessTenant, role: models.RoleApprover, tag: "CEISA",
+			summary: "Send an approved declaration to CEISA", request: models.SendToApiRequest{}, response: map[string]interface{}{}},
+		{method: http.MethodPost, path: "/documents/:id/response", handler: h.RecordDocumentResponse, access: accessTenant, role: models.RoleApprover, tag: "Documents",
+			summary: "Record the CEISA response to a submitted declaration", request: models.DocumentResponseRequest{}, response: models.Document{}},
+		{method: http.MethodPost, path: "/oauth/login", handler: h.OAuthLogin, access: accessTenant, role: models.RoleApprover, tag: "CEISA OAuth",
+			summary: "Log in to CEISA", request: models.OAuthLoginApiRequest{}, response: map[string]interface{}{}},
+		{method: http.MethodPost, path: "/oauth/refresh", handler: h.OAuthRefresh, access: accessTenant, role: models.RoleApprover, tag: "CEISA OAuth",
+			summary: "Refresh the CEISA access token", response: map[string]interface{}{}},
+		{method: http.MethodPost, path: "/oauth/config", handler: h.OAuthConfig, access: accessTenant, role: models.RoleApprover, tag: "CEISA OAuth",
+			summary: "Set the CEISA OAuth configuration", request: models.OAuthConfigRequest{}, response: map[string]interface{}{}},
+		{method: http.MethodPost, path: "/oauth/logout", handler: h.OAuthLogout, access: accessTenant, role: models.RoleApprover, tag: "CEISA OAuth",
+			summary: "Clear the stored CEISA tokens", response: map[string]interface{}{}},
+	}
+}
+
+// RegisterRoutes adds every API endpoint to group
+func (h *Handlers) RegisterRoutes(group *gin.RouterGroup) {
+	authenticate := middleware.Authenticate(h.ResolvePrincipal)
+
+	for _, r := range h.routes() {
+		var chain []gin.HandlerFunc
+		switch r.access {
+		case accessAuthenticated:
+			chain = append(chain, authenticate)
+		case accessTenant:
+			chain = append(chain, authenticate, h.SelectTenant)
+		}
+		if r.role != "" {
+			chain = append(chain, middleware.RequireRole(r.role))
+		}
+		chain = append(chain, r.handler)
+
+		group.Handle(r.method, r.path, chain...)
+	}
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+
+	"json-response-generator/internal/middleware"
+	"json-response-generator/internal/models"
+	"json-response-generator/internal/services"
+)
+
+// Temporary Export Handlers
+
+// ListTemporaryExports returns the balances of the submitted BC 2.6.1
+// declarations, only those with goods still out when ?outstanding=true
+func (h *Handlers) ListTemporaryExports(c *gin.Context) {
+	balances := h.temporaryExports(c).Balances()
+	if c.Query("outstanding") == "true" {
+		outstanding := balances[:0]
+		for _, balance := range balances {
+			if balance.Outstanding {
+				outstanding = append(outstanding, balance)
+			}
+		}
+		balances = outstanding
+	}
+
+	middleware.HandleSuccess(c, balances)
+}
+
+// GetTemporaryExport returns the balance of a submitted BC 2.6.1 by nomorAju
+func (h *Handlers) GetTemporaryExport(c *gin.Context) {
+	balance, err := h.temporaryExports(c).Balance(c.Param("nomorAju"))
+	if errors.Is(err, services.ErrTemporaryExportNotFound) {
+		middleware.HandleError(c, models.NewError(models.ErrCodeNotFound, "BC 2.6.1 not found or not submitted", nil))
+		return
+	}
+
+	middleware.HandleSuccess(c, balance)
+}
+
+// temporaryExports builds the temporary export ledger of the request's tenant
+func (h *Handlers) temporaryExports(c *gin.Context) *services.TemporaryExportLedger {
+	return services.NewTemporaryExportLedger(h.documents.List(h.tenantID(c), ""))
+}
+
+// checkTemporaryReturn checks a BC 2.6.2 against the quantities still
+// outstanding on the BC 2.6.1 declarations it returns goods of
+func (h *Handlers) checkTemporaryReturn(c *gin.Context, declaration models.Declaration) error {
+	problems := h.temporaryExports(c).CheckReturn(declaration)
+	if len(problems) == 0 {
+		return nil
+	}
+
+	return models.NewError(models.ErrCodeValidationFailed, "declaration returns goods that are not outstanding", nil).WithFields(problems...)
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"json-response-generator/internal/config"
+	"json-response-generator/internal/middleware"
+	"json-response-generator/internal/models"
+)
+
+// Tenant Handlers
+
+// SelectTenant resolves the tenant of the request in multi-tenant mode and
+// checks that the caller may act for it
+func (h *Handlers) SelectTenant(c *gin.Context) {
+	if h.tenants == nil {
+		c.Next()
+		return
+	}
+
+	id := middleware.TenantID(c)
+	if id == "" {
+		middleware.HandleError(c, models.NewError(models.ErrCodeTenantRequired, "Tenant required, set the "+middleware.TenantHeaderName+" header", nil))
+		c.Abort()
+		return
+	}
+
+	tenant, exists := h.tenants.Get(id)
+	if !exists {
+		middleware.HandleError(c, models.NewError(models.ErrCodeNotFound, "Unknown tenant "+id, nil))
+		c.Abort()
+		return
+	}
+
+	if !h.tenants.Allowed(tenant, middleware.CurrentPrincipal(c)) {
+		middleware.HandleError(c, models.NewError(models.ErrCodeForbidden, "Not permitted to act for tenant "+id, nil))
+		c.Abort()
+		return
+	}
+
+	middleware.SetTenant(c, tenant)
+	c.Next()
+}
+
+// ListTenants returns the tenants the caller may act for, without credentials
+func (h *Handlers) ListTenants(c *gin.Context) {
+	tenants := []gin.H{}
+	if h.tenants != nil {
+		for _, tenant := range h.tenants.List(middleware.CurrentPrincipal(c)) {
+			tenants = append(tenants, gin.H{
+				"id":       tenant.ID,
+				"name":     tenant.Name,
+				"defaults": tenant.Defaults,
+			})
+		}
+	}
+
+	middleware.HandleSuccess(c, gin.H{
+		"multi_tenant": h.tenants != nil,
+		"tenants":      tenants,
+	})
+}
+
+// tenantID returns the ID of the request's tenant, empty in single-tenant mode
+func (h *Handlers) tenantID(c *gin.Context) string {
+	if tenant := middleware.CurrentTenant(c); tenant != nil {
+		return tenant.ID
+	}
+	return ""
+}
+
+// tenantDefaults returns the declaration defaults of the request's tenant
+func (h *Handlers) tenantDefaults(c *gin.Context) *models.TenantDefaults {
+	if tenant := middleware.CurrentTenant(c); tenant != nil {
+		return &tenant.Defaults
+	}
+	return nil
+}
+
+// tenantConfig returns the server configuration with the API settings of the
+// request's tenant applied
+func (h *Handlers) tenantConfig(c *gin.Context) *config.Config {
+	base := h.config()
+	tenant := middleware.CurrentTenant(c)
+	if tenant == nil || tenant.API == nil {
+		return base
+	}
+
+	cfg := *base
+	if tenant.API.Endpoint != "" {
+		cfg.APIEndpoint = tenant.API.Endpoint
+	}
+	if tenant.API.APIKey != "" {
+		cfg.APIKey = tenant.API.APIKey
+	}
+	if tenant.API.Username != "" {
+		cfg.APIUsername = tenant.API.Username
+	}
+	if tenant.API.Password != "" {
+		cfg.APIPassword = tenant.API.Password
+	}
+	if tenant.API.Timeout > 0 {
+		cfg.APITimeout = tenant.API.Timeout
+	}
+	return &cfg
+}
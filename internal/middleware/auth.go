@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"json-response-generator/internal/models"
+)
+
+// APIKeyHeaderName is the header carrying a static API key
+const APIKeyHeaderName = "X-API-Key"
+
+const principalKey = "principal"
+
+// Authenticate resolves the caller of the request and rejects anonymous calls
+func Authenticate(resolve func(c *gin.Context) *models.Principal) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := resolve(c)
+		if principal == nil {
+			HandleError(c, models.NewError(models.ErrCodeUnauthenticated, "Authentication required", nil))
+			c.Abort()
+			return
+		}
+
+		c.Set(principalKey, principal)
+		c.Next()
+	}
+}
+
+// RequireRole rejects callers that do not hold the given role
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := CurrentPrincipal(c)
+		if !principal.HasRole(role) {
+			HandleError(c, models.NewError(models.ErrCodeForbidden, "Insufficient permissions, requires role "+role, nil))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// CurrentPrincipal returns the authenticated caller of the request, if any
+func CurrentPrincipal(c *gin.Context) *models.Principal {
+	if value, exists := c.Get(principalKey); exists {
+		if principal, ok := value.(*models.Principal); ok {
+			return principal
+		}
+	}
+	return nil
+}
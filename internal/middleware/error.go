@@ -31,30 +31,64 @@ func ErrorHandler() gin.HandlerFunc {
 			}
 
 			// Return error response
+			appErr := models.AsAppError(err.Err, models.ErrCodeInternal, err.Error())
 			c.JSON(statusCode, models.ApiResponse{
 				Success: false,
 				Error:   err.Error(),
+				Details: errorDetails(c, appErr),
 			})
 		}
 	}
 }
 
-// HandleError is a helper function to handle errors in handlers
-func HandleError(c *gin.Context, statusCode int, message string, err error) {
-	if err != nil {
-		logrus.WithFields(logrus.Fields{
-			"method": c.Request.Method,
-			"path":   c.Request.URL.Path,
-			"error":  err.Error(),
-		}).Error(message)
+// HandleError responds with the code, HTTP status and details of err.
+// Errors without a code are reported as INTERNAL_ERROR.
+func HandleError(c *gin.Context, err error) {
+	appErr := models.AsAppError(err, models.ErrCodeInternal, "")
+	statusCode := appErr.Code.HTTPStatus()
+
+	if appErr.Err != nil || statusCode >= http.StatusInternalServerError {
+		Logger(c).WithFields(logrus.Fields{
+			"method":     c.Request.Method,
+			"path":       c.Request.URL.Path,
+			"code":       appErr.Code,
+			"request_id": GetRequestID(c),
+			"error":      appErr.Error(),
+		}).Error(errorMessage(appErr))
 	}
 
 	c.JSON(statusCode, models.ApiResponse{
 		Success: false,
-		Error:   message,
+		Error:   errorMessage(appErr),
+		Details: errorDetails(c, appErr),
 	})
 }
 
+// errorMessage returns the specific description of an error, falling back to
+// the English description of its code
+func errorMessage(appErr *models.AppError) string {
+	if appErr.Detail != "" {
+		return appErr.Detail
+	}
+	return ErrorMessage(appErr.Code, LanguageEnglish)
+}
+
+// errorDetails builds the Details of an error response. The underlying cause
+// is withheld for internal errors.
+func errorDetails(c *gin.Context, appErr *models.AppError) *models.ErrorDetails {
+	details := &models.ErrorDetails{
+		Code:      appErr.Code,
+		Message:   ErrorMessage(appErr.Code, Language(c)),
+		Detail:    appErr.Detail,
+		Fields:    appErr.Fields,
+		RequestID: GetRequestID(c),
+	}
+	if appErr.Err != nil && appErr.Code != models.ErrCodeInternal {
+		details.Cause = appErr.Err.Error()
+	}
+	return details
+}
+
 // HandleSuccess is a helper function to handle successful responses
 func HandleSuccess(c *gin.Context, data interface{}, message ...string) {
 	response := models.ApiResponse{
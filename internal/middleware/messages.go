@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"json-response-generator/internal/models"
+)
+
+// Supported response languages
+const (
+	LanguageEnglish    = "en"
+	LanguageIndonesian = "id"
+)
+
+// errorMessages holds the localized description of every error code
+var errorMessages = map[string]map[models.ErrorCode]string{
+	LanguageEnglish: {
+		models.ErrCodeBadRequest:          "The request is invalid",
+		models.ErrCodeValidationFailed:    "The request data failed validation",
+		models.ErrCodeUnauthenticated:     "Authentication required",
+		models.ErrCodeForbidden:           "You are not permitted to perform this action",
+		models.ErrCodeNotFound:            "The requested resource was not found",
+		models.ErrCodeMethodNotAllowed:    "Method not allowed",
+		models.ErrCodeConflict:            "The request conflicts with the current state",
+		models.ErrCodeFileMissing:         "No file was uploaded",
+		models.ErrCodeFileTooLarge:        "The uploaded file is too large",
+		models.ErrCodeFileTypeUnsupported: "Unsupported file type, upload an .xlsx or .xls file",
+		models.ErrCodeExcelUnreadable:     "The Excel file could not be read",
+		models.ErrCodeExcelMissingSheet:   "The Excel file is missing required sheets",
+		models.ErrCodeExcelInvalidSheet:   "A sheet of the Excel file contains invalid data",
+		models.ErrCodeOAuthNotConfigured:  "CEISA login is not configured",
+		models.ErrCodeOAuthLoginRequired:  "Please log in to CEISA first",
+		models.ErrCodeOAuthLoginFailed:    "CEISA login failed",
+		models.ErrCodeOAuthExpired:        "The CEISA session has expired, please log in again",
+		models.ErrCodeIdentityMismatch:    "The declaration does not belong to the logged-in importer",
+		models.ErrCodeTenantRequired:      "Select the importer to act for",
+		models.ErrCodeNotApproved:         "The declaration has not been approved",
+		models.ErrCodeUnknownDocumentType: "The document type is not supported",
+		models.ErrCodeInvalidTransition:   "The declaration cannot change to that status",
+		models.ErrCodeUpstreamTimeout:     "CEISA did not respond in time",
+		models.ErrCodeUpstreamUnavailable: "CEISA could not be reached",
+		models.ErrCodeUpstreamInvalid:     "CEISA returned an invalid response",
+		models.ErrCodeNotEnabled:          "This feature is not enabled",
+		models.ErrCodeUnavailable:         "The service is temporarily unavailable",
+		models.ErrCodeInternal:            "An internal error occurred",
+	},
+	LanguageIndonesian: {
+		models.ErrCodeBadRequest:          "Permintaan tidak valid",
+		models.ErrCodeValidationFailed:    "Data permintaan tidak lolos validasi",
+		models.ErrCodeUnauthenticated:     "Autentikasi diperlukan",
+		models.ErrCodeForbidden:           "Anda tidak berwenang melakukan tindakan ini",
+		models.ErrCodeNotFound:            "Data yang diminta tidak ditemukan",
+		models.ErrCodeMethodNotAllowed:    "Metode tidak diizinkan",
+		models.ErrCodeConflict:            "Permintaan bertentangan dengan status saat ini",
+		models.ErrCodeFileMissing:         "Tidak ada file yang diunggah",
+		models.ErrCodeFileTooLarge:        "Ukuran file terlalu besar",
+		models.ErrCodeFileTypeUnsupported: "Jenis file tidak didukung, unggah file .xlsx atau .xls",
+		models.ErrCodeExcelUnreadable:     "File Excel tidak dapat dibaca",
+		models.ErrCodeExcelMissingSheet:   "File Excel tidak memiliki sheet yang diwajibkan",
+		models.ErrCodeExcelInvalidSheet:   "Salah satu sheet pada file Excel berisi data yang tidak valid",
+		models.ErrCodeOAuthNotConfigured:  "Login CEISA belum dikonfigurasi",
+		models.ErrCodeOAuthLoginRequired:  "Silakan login ke CEISA terlebih dahulu",
+		models.ErrCodeOAuthLoginFailed:    "Login CEISA gagal",
+		models.ErrCodeOAuthExpired:        "Sesi CEISA telah berakhir, silakan login kembali",
+		models.ErrCodeIdentityMismatch:    "Dokumen tidak sesuai dengan importir yang sedang login",
+		models.ErrCodeTenantRequired:      "Pilih importir yang diwakili",
+		models.ErrCodeNotApproved:         "Dokumen belum disetujui",
+		models.ErrCodeUnknownDocumentType: "Jenis dokumen tidak didukung",
+		models.ErrCodeInvalidTransition:   "Status dokumen tidak dapat diubah ke status tersebut",
+		models.ErrCodeUpstreamTimeout:     "CEISA tidak merespons tepat waktu",
+		models.ErrCodeUpstreamUnavailable: "CEISA tidak dapat dihubungi",
+		models.ErrCodeUpstreamInvalid:     "CEISA mengembalikan respons yang tidak valid",
+		models.ErrCodeNotEnabled:          "Fitur ini tidak diaktifkan",
+		models.ErrCodeUnavailable:         "Layanan sedang tidak tersedia",
+		models.ErrCodeInternal:            "Terjadi kesalahan internal",
+	},
+}
+
+// Language returns the response language of the request, chosen by the ?lang=
+// query parameter or the Accept-Language header. English is the default.
+func Language(c *gin.Context) string {
+	if lang := strings.ToLower(c.Query("lang")); lang != "" {
+		if _, ok := errorMessages[lang]; ok {
+			return lang
+		}
+	}
+
+	for _, part := range strings.Split(c.GetHeader("Accept-Language"), ",") {
+		tag := strings.ToLower(strings.TrimSpace(strings.SplitN(part, ";", 2)[0]))
+		if tag == LanguageIndonesian || strings.HasPrefix(tag, LanguageIndonesian+"-") {
+			return LanguageIndonesian
+		}
+		if tag == LanguageEnglish || strings.HasPrefix(tag, LanguageEnglish+"-") {
+			return LanguageEnglish
+		}
+	}
+
+	return LanguageEnglish
+}
+
+// ErrorMessage returns the localized description of an error code
+func ErrorMessage(code models.ErrorCode, lang string) string {
+	messages, ok := errorMessages[lang]
+	if !ok {
+		messages = errorMessages[LanguageEnglish]
+	}
+	if message, ok := messages[code]; ok {
+		return message
+	}
+	return errorMessages[LanguageEnglish][models.ErrCodeInternal]
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/gin-gonic/gin"
+
+	"json-response-generator/internal/telemetry"
+)
+
+// RequestIDHeaderName is the header carrying the request ID
+const RequestIDHeaderName = "X-Request-ID"
+
+const requestIDKey = "request_id"
+
+// RequestID assigns every request an ID, reusing a valid one sent by the caller,
+// and echoes it in the response. The ID is carried by the request context to
+// log lines and outbound calls.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeaderName)
+		if !validRequestID(id) {
+			buf := make([]byte, 16)
+			if _, err := rand.Read(buf); err == nil {
+				id = hex.EncodeToString(buf)
+			}
+		}
+
+		c.Set(requestIDKey, id)
+		c.Request = c.Request.WithContext(telemetry.WithRequestID(c.Request.Context(), id))
+		c.Header(RequestIDHeaderName, id)
+		c.Next()
+	}
+}
+
+// GetRequestID returns the ID of the request
+func GetRequestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// validRequestID accepts short IDs of printable characters so callers cannot
+// inject into logs
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		if r < 0x21 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SessionCookieName is the cookie carrying the session token issued by this service
+const SessionCookieName = "ceisa_session"
+
+// SessionHeaderName is the header API clients may use instead of the cookie
+const SessionHeaderName = "X-Session-Token"
+
+// SessionID extracts the session token from the request. The cookie is used
+// by the browser frontend; API clients send it as a header or bearer token.
+func SessionID(c *gin.Context) string {
+	if token := c.GetHeader(SessionHeaderName); token != "" {
+		return token
+	}
+
+	if auth := c.GetHeader("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+
+	if cookie, err := c.Cookie(SessionCookieName); err == nil {
+		return cookie
+	}
+
+	return ""
+}
+
+// SetSessionCookie issues the session cookie to the browser
+func SetSessionCookie(c *gin.Context, sessionID string, maxAge time.Duration) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(SessionCookieName, sessionID, int(maxAge.Seconds()), "/", "", c.Request.TLS != nil, true)
+}
+
+// ClearSessionCookie removes the session cookie from the browser
+func ClearSessionCookie(c *gin.Context) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(SessionCookieName, "", -1, "/", "", c.Request.TLS != nil, true)
+}
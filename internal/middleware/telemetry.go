@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"json-response-generator/internal/telemetry"
+)
+
+// unmatchedRoute labels requests that matched no route, keeping metric
+// cardinality bounded
+const unmatchedRoute = "unmatched"
+
+// Telemetry traces every request and records its count and latency by route
+func Telemetry() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		ctx, span := telemetry.StartServerSpan(c.Request.Context(), c.Request.Method, c.Request.Header)
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		status := c.Writer.Status()
+
+		span.SetName(c.Request.Method + " " + route)
+		span.SetAttributes(
+			attribute.String("http.method", c.Request.Method),
+			attribute.String("http.route", route),
+			attribute.Int("http.status_code", status),
+		)
+		if id := GetRequestID(c); id != "" {
+			span.SetAttributes(attribute.String("request_id", id))
+		}
+		if status >= 500 {
+			span.SetStatus(codes.Error, strconv.Itoa(status))
+		}
+		span.End()
+
+		telemetry.HTTPRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(status)).Inc()
+		telemetry.HTTPRequestDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// AccessLog logs every request with its request ID
+func AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		Logger(c).WithFields(logrus.Fields{
+			"method":  c.Request.Method,
+			"path":    c.Request.URL.Path,
+			"status":  c.Writer.Status(),
+			"latency": time.Since(start).String(),
+			"ip":      c.ClientIP(),
+		}).Info("Request")
+	}
+}
+
+// Logger returns a log entry carrying the request and trace IDs of the request
+func Logger(c *gin.Context) *logrus.Entry {
+	return logrus.WithContext(c.Request.Context())
+}
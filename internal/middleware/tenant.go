@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"json-response-generator/internal/models"
+)
+
+// TenantHeaderName is the header selecting the tenant of a request
+const TenantHeaderName = "X-Tenant-ID"
+
+const tenantKey = "tenant"
+
+// TenantID returns the tenant selected by the request header or ?tenant= query
+func TenantID(c *gin.Context) string {
+	if id := c.GetHeader(TenantHeaderName); id != "" {
+		return id
+	}
+	return c.Query("tenant")
+}
+
+// SetTenant stores the resolved tenant of the request
+func SetTenant(c *gin.Context, tenant *models.Tenant) {
+	c.Set(tenantKey, tenant)
+}
+
+// CurrentTenant returns the tenant of the request, nil in single-tenant mode
+func CurrentTenant(c *gin.Context) *models.Tenant {
+	if value, exists := c.Get(tenantKey); exists {
+		if tenant, ok := value.(*models.Tenant); ok {
+			return tenant
+		}
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package models
+
+// BondedImportDeclaration is a BC 2.3 declaration of goods imported into a
+// bonded storage area (Tempat Penimbunan Berikat). Duties and taxes are
+// suspended rather than paid.
+type BondedImportDeclaration struct {
+	AsalData          string       `json:"asalData" validate:"required"`
+	Asuransi          float64      `json:"asuransi" validate:"required"`
+	BiayaPengurang    float64      `json:"biayaPengurang" validate:"required"`
+	BiayaTambahan     float64      `json:"biayaTambahan" validate:"required"`
+	Bruto             float64      `json:"bruto" validate:"required"`
+	Cif               float64      `json:"cif" validate:"required"`
+	Disclaimer        string       `json:"disclaimer" validate:"required"`
+	Fob               float64      `json:"fob" validate:"required"`
+	Freight           float64      `json:"freight" validate:"required"`
+	HargaPenyerahan   float64      `json:"hargaPenyerahan" validate:"required"`
+	IdPengguna        string       `json:"idPengguna" validate:"required"`
+	JabatanTtd        string       `json:"jabatanTtd" validate:"required"`
+	JumlahKontainer   int          `json:"jumlahKontainer" validate:"required"`
+	KodeAsuransi      string       `json:"kodeAsuransi" validate:"required"`
+	KodeDokumen       string       `json:"kodeDokumen" validate:"required"`
+	KodeIncoterm      string       `json:"kodeIncoterm" validate:"required"`
+	KodeJenisTpb      string       `json:"kodeJenisTpb" validate:"required"`
+	KodeKantor        string       `json:"kodeKantor" validate:"required"`
+	KodeKantorBongkar string       `json:"kodeKantorBongkar" validate:"required"`
+	KodePelBongkar    string       `json:"kodePelBongkar" validate:"required"`
+	KodePelMuat       string       `json:"kodePelMuat" validate:"required"`
+	KodePelTransit    string       `json:"kodePelTransit" validate:"required"`
+	KodeTps           string       `json:"kodeTps" validate:"required"`
+	KodeTujuanTpb     string       `json:"kodeTujuanTpb" validate:"required"`
+	KodeTutupPu       string       `json:"kodeTutupPu" validate:"required"`
+	KodeValuta        string       `json:"kodeValuta" validate:"required"`
+	KotaTtd           string       `json:"kotaTtd" validate:"required"`
+	NamaTtd           string       `json:"namaTtd" validate:"required"`
+	Ndpbm             float64      `json:"ndpbm" validate:"required"`
+	Netto             float64      `json:"netto" validate:"required"`
+	NilaiBarang       float64      `json:"nilaiBarang" validate:"required"`
+	NomorAju          string       `json:"nomorAju" validate:"required"`
+	NomorBc11         string       `json:"nomorBc11" validate:"required"`
+	PosBc11           string       `json:"posBc11" validate:"required"`
+	Seri              int          `json:"seri" validate:"required"`
+	SubPosBc11        string       `json:"subPosBc11" validate:"required"`
+	TanggalAju        string       `json:"tanggalAju" validate:"required"`
+	TanggalBc11       string       `json:"tanggalBc11" validate:"required"`
+	TanggalTiba       string       `json:"tanggalTiba" validate:"required"`
+	TanggalTtd        string       `json:"tanggalTtd" validate:"required"`
+	Volume            float64      `json:"volume" validate:"required"`
+	Barang            []Barang     `json:"barang"`
+	Entitas           []Entitas    `json:"entitas"`
+	Kemasan           []Kemasan    `json:"kemasan"`
+	Kontainer         []Kontainer  `json:"kontainer"`
+	Dokumen           []Dokumen    `json:"dokumen"`
+	Pengangkut        []Pengangkut `json:"pengangkut"`
+}
+
+// Header implements Declaration
+func (d *BondedImportDeclaration) Header() DeclarationHeader {
+	return DeclarationHeader{
+		KodeDokumen: &d.KodeDokumen,
+		NomorAju:    &d.NomorAju,
+		KodeKantor:  &d.KodeKantor,
+		IdPengguna:  &d.IdPengguna,
+		TanggalAju:  &d.TanggalAju,
+		KodeValuta:  &d.KodeValuta,
+		Ndpbm:       &d.Ndpbm,
+		Entitas:     &d.Entitas,
+		Barang:      &d.Barang,
+	}
+}
+
+// BondedTransferDeclaration is a BC 2.7 declaration of goods moved from one
+// bonded area to another. Every item refers to the document it entered the
+// sending area with.
+type BondedTransferDeclaration struct {
+	AsalData             string       `json:"asalData" validate:"required"`
+	Bruto                float64      `json:"bruto" validate:"required"`
+	Cif                  float64      `json:"cif" validate:"required"`
+	Disclaimer           string       `json:"disclaimer" validate:"required"`
+	HargaPenyerahan      float64      `json:"hargaPenyerahan" validate:"required"`
+	IdPengguna           string       `json:"idPengguna" validate:"required"`
+	JabatanTtd           string       `json:"jabatanTtd" validate:"required"`
+	JumlahKontainer      int          `json:"jumlahKontainer" validate:"required"`
+	KodeDokumen          string       `json:"kodeDokumen" validate:"required"`
+	KodeJenisTpb         string       `json:"kodeJenisTpb" validate:"required"`
+	KodeKantor           string       `json:"kodeKantor" validate:"required"`
+	KodeKantorTujuan     string       `json:"kodeKantorTujuan" validate:"required"`
+	KodeTujuanPengiriman string       `json:"kodeTujuanPengiriman" validate:"required"`
+	KodeTujuanTpb        string       `json:"kodeTujuanTpb" validate:"required"`
+	KodeValuta           string       `json:"kodeValuta" validate:"required"`
+	KotaTtd              string       `json:"kotaTtd" validate:"required"`
+	NamaTtd              string       `json:"namaTtd" validate:"required"`
+	Ndpbm                float64      `json:"ndpbm" validate:"required"`
+	Netto                float64      `json:"netto" validate:"required"`
+	NilaiBarang          float64      `json:"nilaiBarang" validate:"required"`
+	NomorAju             string       `json:"nomorAju" validate:"required"`
+	Seri                 int          `json:"seri" validate:"required"`
+	TanggalAju           string       `json:"tanggalAju" validate:"required"`
+	TanggalTtd           string       `json:"tanggalTtd" validate:"required"`
+	Volume               float64      `json:"volume" validate:"required"`
+	Barang               []Barang     `json:"barang"`
+	Entitas              []Entitas    `json:"entitas"`
+	Kemasan              []Kemasan    `json:"kemasan"`
+	Kontainer            []Kontainer  `json:"kontainer"`
+	Dokumen              []Dokumen    `json:"dokumen"`
+	Pengangkut           []Pengangkut `json:"pengangkut"`
+}
+
+// Header implements Declaration
+func (d *BondedTransferDeclaration) Header() DeclarationHeader {
+	return DeclarationHeader{
+		KodeDokumen: &d.KodeDokumen,
+		NomorAju:    &d.NomorAju,
+		KodeKantor:  &d.KodeKantor,
+		IdPengguna:  &d.IdPengguna,
+		TanggalAju:  &d.TanggalAju,
+		KodeValuta:  &d.KodeValuta,
+		Ndpbm:       &d.Ndpbm,
+		Entitas:     &d.Entitas,
+		Barang:      &d.Barang,
+	}
+}
+
+// LocalDeliveryDeclaration is a BC 4.0 or BC 4.1 declaration of goods moved
+// between the domestic customs area (TLDDP) and a bonded area: BC 4.0 brings
+// local goods in, BC 4.1 takes them back out. Goods are valued at their
+// delivery price (harga penyerahan) instead of CIF.
+type LocalDeliveryDeclaration struct {
+	AsalData             string       `json:"asalData" validate:"required"`
+	BiayaPengurang       float64      `json:"biayaPengurang" validate:"required"`
+	BiayaTambahan        float64      `json:"biayaTambahan" validate:"required"`
+	Bruto                float64      `json:"bruto" validate:"required"`
+	Disclaimer           string       `json:"disclaimer" validate:"required"`
+	HargaPenyerahan      float64      `json:"hargaPenyerahan" validate:"required"`
+	IdPengguna           string       `json:"idPengguna" validate:"required"`
+	JabatanTtd           string       `json:"jabatanTtd" validate:"required"`
+	JumlahKontainer      int          `json:"jumlahKontainer" validate:"required"`
+	KodeDokumen          string       `json:"kodeDokumen" validate:"required"`
+	KodeJenisTpb         string       `json:"kodeJenisTpb" validate:"required"`
+	KodeKantor           string       `json:"kodeKantor" validate:"required"`
+	KodeTujuanPengiriman string       `json:"kodeTujuanPengiriman" validate:"required"`
+	KotaTtd              string       `json:"kotaTtd" validate:"required"`
+	NamaTtd              string       `json:"namaTtd" validate:"required"`
+	Netto                float64      `json:"netto" validate:"required"`
+	NilaiJasa            float64      `json:"nilaiJasa" validate:"required"`
+	NomorAju             string       `json:"nomorAju" validate:"required"`
+	Seri                 int          `json:"seri" validate:"required"`
+	TanggalAju           string       `json:"tanggalAju" validate:"required"`
+	TanggalTtd           string       `json:"tanggalTtd" validate:"required"`
+	UangMuka             float64      `json:"uangMuka" validate:"required"`
+	Volume               float64      `json:"volume" validate:"required"`
+	Barang               []Barang     `json:"barang"`
+	Entitas              []Entitas    `json:"entitas"`
+	Kemasan              []Kemasan    `json:"kemasan"`
+	Kontainer            []Kontainer  `json:"kontainer"`
+	Dokumen              []Dokumen    `json:"dokumen"`
+	Pengangkut           []Pengangkut `json:"pengangkut"`
+}
+
+// Header implements Declaration
+func (d *LocalDeliveryDeclaration) Header() DeclarationHeader {
+	return DeclarationHeader{
+		KodeDokumen: &d.KodeDokumen,
+		NomorAju:    &d.NomorAju,
+		KodeKantor:  &d.KodeKantor,
+		IdPengguna:  &d.IdPengguna,
+		TanggalAju:  &d.TanggalAju,
+		Entitas:     &d.Entitas,
+		Barang:      &d.Barang,
+	}
+}
+
+// TemporaryExportDeclaration is a BC 2.6.1 or BC 2.6.2 declaration of goods
+// taken out of a bonded area for processing elsewhere, e.g. repair or
+// subcontracting: BC 2.6.1 sends them out, BC 2.6.2 brings them back. Every
+// returned item refers to the item it left on and reports the quantity
+// returned (jumlahRealisasi) against the outstanding balance.
+type TemporaryExportDeclaration struct {
+	AsalData             string       `json:"asalData" validate:"required"`
+	Bruto                float64      `json:"bruto" validate:"required"`
+	Cif                  float64      `json:"cif" validate:"required"`
+	Disclaimer           string       `json:"disclaimer" validate:"required"`
+	HargaPenyerahan      float64      `json:"hargaPenyerahan" validate:"required"`
+	IdPengguna           string       `json:"idPengguna" validate:"required"`
+	JabatanTtd           string       `json:"jabatanTtd" validate:"required"`
+	JumlahKontainer      int          `json:"jumlahKontainer" validate:"required"`
+	KodeDokumen          string       `json:"kodeDokumen" validate:"required"`
+	KodeJenisTpb         string       `json:"kodeJenisTpb" validate:"required"`
+	KodeKantor           string       `json:"kodeKantor" validate:"required"`
+	KodeTujuanPengiriman string       `json:"kodeTujuanPengiriman" validate:"required"`
+	KodeValuta           string       `json:"kodeValuta" validate:"required"`
+	KotaTtd              string       `json:"kotaTtd" validate:"required"`
+	NamaTtd              string       `json:"namaTtd" validate:"required"`
+	Ndpbm                float64      `json:"ndpbm" validate:"required"`
+	Netto                float64      `json:"netto" validate:"required"`
+	NilaiMaklon          float64      `json:"nilaiMaklon" validate:"required"`
+	NomorAju             string       `json:"nomorAju" validate:"required"`
+	Seri                 int          `json:"seri" validate:"required"`
+	TanggalAju           string       `json:"tanggalAju" validate:"required"`
+	TanggalTtd           string       `json:"tanggalTtd" validate:"required"`
+	Volume               float64      `json:"volume" validate:"required"`
+	Barang               []Barang     `json:"barang"`
+	Entitas              []Entitas    `json:"entitas"`
+	Kemasan              []Kemasan    `json:"kemasan"`
+	Kontainer            []Kontainer  `json:"kontainer"`
+	Dokumen              []Dokumen    `json:"dokumen"`
+	Pengangkut           []Pengangkut `json:"pengangkut"`
+}
+
+// Header implements Declaration
+func (d *TemporaryExportDeclaration) Header() DeclarationHeader {
+	return DeclarationHeader{
+		KodeDokumen: &d.KodeDokumen,
+		NomorAju:    &d.NomorAju,
+		KodeKantor:  &d.KodeKantor,
+		IdPengguna:  &d.IdPengguna,
+		TanggalAju:  &d.TanggalAju,
+		KodeValuta:  &d.KodeValuta,
+		Ndpbm:       &d.Ndpbm,
+		Entitas:     &d.Entitas,
+		Barang:      &d.Barang,
+	}
+}
+
+// TemporaryExportBalance is what remains to be returned of a submitted BC 2.6.1
+type TemporaryExportBalance struct {
+	DocumentID  string                `json:"document_id"`
+	NomorAju    string                `json:"nomor_aju"`
+	Returns     []string              `json:"returns"` // nomorAju of the BC 2.6.2 returning goods
+	Outstanding bool                  `json:"outstanding"`
+	Items       []TemporaryExportItem `json:"items"`
+}
+
+// TemporaryExportItem is the balance of one item of a BC 2.6.1
+type TemporaryExportItem struct {
+	SeriBarang       int     `json:"seri_barang"`
+	PosTarif         string  `json:"pos_tarif"`
+	Uraian           string  `json:"uraian"`
+	KodeSatuanBarang string  `json:"kode_satuan_barang"`
+	JumlahSatuan     float64 `json:"jumlah_satuan"`    // sent out
+	JumlahRealisasi  float64 `json:"jumlah_realisasi"` // returned so far
+	Pending          float64 `json:"pending"`          // on approved returns not yet submitted
+	Saldo            float64 `json:"saldo"`
+}
@@ -0,0 +1,182 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// DefaultKodeDokumen is the document type assumed when a declaration does not
+// state its kodeDokumen: BC 2.0, the only type earlier versions supported
+const DefaultKodeDokumen = "20"
+
+// DeclarationHeader points at the fields every CEISA document carries, so
+// they can be read and prefilled without knowing the document type
+type DeclarationHeader struct {
+	KodeDokumen *string
+	NomorAju    *string
+	KodeKantor  *string
+	IdPengguna  *string
+	TanggalAju  *string
+	KodeValuta  *string  // nil when the document type is valued in rupiah
+	Ndpbm       *float64 // nil when the document type is valued in rupiah
+	Entitas     *[]Entitas
+	Barang      *[]Barang // nil when the document type has its own goods model
+}
+
+// Declaration is a customs document of one of the registered document types
+type Declaration interface {
+	Header() DeclarationHeader
+}
+
+// Header implements Declaration
+func (d *ResponseData) Header() DeclarationHeader {
+	return DeclarationHeader{
+		KodeDokumen: &d.KodeDokumen,
+		NomorAju:    &d.NomorAju,
+		KodeKantor:  &d.KodeKantor,
+		IdPengguna:  &d.IdPengguna,
+		TanggalAju:  &d.TanggalAju,
+		KodeValuta:  &d.KodeValuta,
+		Ndpbm:       &d.Ndpbm,
+		Entitas:     &d.Entitas,
+		Barang:      &d.Barang,
+	}
+}
+
+var (
+	declarationModels = map[string]func() Declaration{}
+	declarationMutex  sync.RWMutex
+)
+
+// RegisterDeclaration registers the Go model of a document type
+func RegisterDeclaration(kodeDokumen string, newModel func() Declaration) {
+	declarationMutex.Lock()
+	defer declarationMutex.Unlock()
+	declarationModels[kodeDokumen] = newModel
+}
+
+// DeclarationCodes returns the kodeDokumen of every registered model
+func DeclarationCodes() []string {
+	declarationMutex.RLock()
+	defer declarationMutex.RUnlock()
+
+	codes := make([]string, 0, len(declarationModels))
+	for code := range declarationModels {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// NewDeclaration returns an empty model of the document type. An empty
+// kodeDokumen selects DefaultKodeDokumen.
+func NewDeclaration(kodeDokumen string) (Declaration, error) {
+	if kodeDokumen == "" {
+		kodeDokumen = DefaultKodeDokumen
+	}
+
+	declarationMutex.RLock()
+	newModel, ok := declarationModels[kodeDokumen]
+	declarationMutex.RUnlock()
+	if !ok {
+		return nil, UnknownDocumentType(kodeDokumen)
+	}
+
+	return newModel(), nil
+}
+
+// UnknownDocumentType is the error for a kodeDokumen without a registered type
+func UnknownDocumentType(kodeDokumen string) *AppError {
+	return Errorf(ErrCodeUnknownDocumentType, "document type %q is not supported", kodeDokumen).WithFields(FieldError{
+		Field:   "kodeDokumen",
+		Code:    FieldInvalidValue,
+		Message: fmt.Sprintf("supported document types: %v", DeclarationCodes()),
+	})
+}
+
+// DecodeDeclaration decodes JSON into the model registered for its kodeDokumen
+func DecodeDeclaration(data []byte) (Declaration, error) {
+	var probe struct {
+		KodeDokumen string `json:"kodeDokumen"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, err
+	}
+
+	declaration, err := NewDeclaration(probe.KodeDokumen)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, declaration); err != nil {
+		return nil, err
+	}
+	if header := declaration.Header(); *header.KodeDokumen == "" {
+		*header.KodeDokumen = DefaultKodeDokumen
+	}
+
+	return declaration, nil
+}
+
+// DeclarationData carries a declaration of any registered type in requests
+// and stored documents. It decodes into the model registered for the
+// kodeDokumen of the JSON.
+type DeclarationData struct {
+	Declaration
+}
+
+// MarshalJSON encodes the declaration itself
+func (d DeclarationData) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.Declaration)
+}
+
+// UnmarshalJSON decodes into the model of the declared document type
+func (d *DeclarationData) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		d.Declaration = nil
+		return nil
+	}
+
+	declaration, err := DecodeDeclaration(data)
+	if err != nil {
+		return &declarationError{err: err}
+	}
+	d.Declaration = declaration
+	return nil
+}
+
+// declarationError marks an error decoding DeclarationData. encoding/json
+// does not add the enclosing field to errors returned by UnmarshalJSON, so
+// the requests carrying a declaration add it themselves.
+type declarationError struct {
+	err error
+}
+
+func (e *declarationError) Error() string { return e.err.Error() }
+func (e *declarationError) Unwrap() error { return e.err }
+
+// withDeclarationField prefixes the field of a type error raised while
+// decoding the declaration in field, e.g. nomorAju becomes json_data.nomorAju
+func withDeclarationField(err error, field string) error {
+	var declErr *declarationError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &declErr) && errors.As(declErr.err, &typeErr) {
+		typeErr.Field = field + "." + jsonFieldPath(typeErr.Field)
+	}
+	return err
+}
+
+// UnmarshalJSON reports declaration type errors under json_data
+func (r *SendToApiRequest) UnmarshalJSON(data []byte) error {
+	type plain SendToApiRequest
+	return withDeclarationField(json.Unmarshal(data, (*plain)(r)), "json_data")
+}
+
+// UnmarshalJSON reports declaration type errors under json_data
+func (r *DocumentRequest) UnmarshalJSON(data []byte) error {
+	type plain DocumentRequest
+	return withDeclarationField(json.Unmarshal(data, (*plain)(r)), "json_data")
+}
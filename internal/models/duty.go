@@ -0,0 +1,44 @@
+package models
+
+import "encoding/json"
+
+// DutyCalculationRequest asks for the import duties and taxes of a
+// declaration to be computed from the rates in its barangTarif rows
+type DutyCalculationRequest struct {
+	JsonData DeclarationData `json:"json_data" validate:"required"`
+	// Api overrides whether the importer holds an import identification
+	// number (API), which lowers the PPh 22 rate. By default the importer
+	// entitas holds one when it has a kodeJenisApi.
+	Api *bool `json:"api,omitempty"`
+}
+
+// UnmarshalJSON reports declaration type errors under json_data
+func (r *DutyCalculationRequest) UnmarshalJSON(data []byte) error {
+	type plain DutyCalculationRequest
+	return withDeclarationField(json.Unmarshal(data, (*plain)(r)), "json_data")
+}
+
+// DutyCalculation is the outcome of computing the duties of a declaration
+type DutyCalculation struct {
+	NilaiPabean float64          `json:"nilai_pabean"` // customs value in rupiah
+	Api         bool             `json:"api"`
+	Summary     []DutySummary    `json:"summary"`
+	Barang      []DutyItemResult `json:"barang"`
+}
+
+// DutySummary is the total of one levy over all goods. Payable amounts are
+// rounded up to whole thousands of rupiah.
+type DutySummary struct {
+	KodeJenisPungutan string  `json:"kode_jenis_pungutan"`
+	NilaiPungutan     float64 `json:"nilai_pungutan"`    // levy before facilities
+	NilaiFasilitas    float64 `json:"nilai_fasilitas"`   // exempted, suspended or borne by the government
+	NilaiBayar        float64 `json:"nilai_bayar"`       // to pay, rounded
+	NilaiBayarExact   float64 `json:"nilai_bayar_exact"` // to pay, before rounding
+}
+
+// DutyItemResult is the computation of the duties of one item
+type DutyItemResult struct {
+	SeriBarang  int     `json:"seri_barang"`
+	NilaiPabean float64 `json:"nilai_pabean"` // cif x ndpbm
+	NilaiImpor  float64 `json:"nilai_impor"`  // tax base: customs value plus import duty
+}
@@ -0,0 +1,213 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ErrorCode is a stable, machine-readable error code returned in ApiResponse.Details
+type ErrorCode string
+
+// Error codes. Integrations branch on these values, so they must never change.
+const (
+	ErrCodeBadRequest          ErrorCode = "BAD_REQUEST"
+	ErrCodeValidationFailed    ErrorCode = "VALIDATION_FAILED"
+	ErrCodeUnauthenticated     ErrorCode = "UNAUTHENTICATED"
+	ErrCodeForbidden           ErrorCode = "FORBIDDEN"
+	ErrCodeNotFound            ErrorCode = "NOT_FOUND"
+	ErrCodeMethodNotAllowed    ErrorCode = "METHOD_NOT_ALLOWED"
+	ErrCodeConflict            ErrorCode = "CONFLICT"
+	ErrCodeFileMissing         ErrorCode = "FILE_MISSING"
+	ErrCodeFileTooLarge        ErrorCode = "FILE_TOO_LARGE"
+	ErrCodeFileTypeUnsupported ErrorCode = "FILE_TYPE_UNSUPPORTED"
+	ErrCodeExcelUnreadable     ErrorCode = "EXCEL_UNREADABLE"
+	ErrCodeExcelMissingSheet   ErrorCode = "EXCEL_MISSING_SHEET"
+	ErrCodeExcelInvalidSheet   ErrorCode = "EXCEL_INVALID_SHEET"
+	ErrCodeUnknownDocumentType ErrorCode = "UNKNOWN_DOCUMENT_TYPE"
+	ErrCodeOAuthNotConfigured  ErrorCode = "OAUTH_NOT_CONFIGURED"
+	ErrCodeOAuthLoginRequired  ErrorCode = "OAUTH_LOGIN_REQUIRED"
+	ErrCodeOAuthLoginFailed    ErrorCode = "OAUTH_LOGIN_FAILED"
+	ErrCodeOAuthExpired        ErrorCode = "OAUTH_EXPIRED"
+	ErrCodeIdentityMismatch    ErrorCode = "IDENTITY_MISMATCH"
+	ErrCodeTenantRequired      ErrorCode = "TENANT_REQUIRED"
+	ErrCodeNotApproved         ErrorCode = "NOT_APPROVED"
+	ErrCodeInvalidTransition   ErrorCode = "INVALID_TRANSITION"
+	ErrCodeUpstreamTimeout     ErrorCode = "UPSTREAM_TIMEOUT"
+	ErrCodeUpstreamUnavailable ErrorCode = "UPSTREAM_UNAVAILABLE"
+	ErrCodeUpstreamInvalid     ErrorCode = "UPSTREAM_INVALID_RESPONSE"
+	ErrCodeNotEnabled          ErrorCode = "NOT_ENABLED"
+	ErrCodeUnavailable         ErrorCode = "SERVICE_UNAVAILABLE"
+	ErrCodeInternal            ErrorCode = "INTERNAL_ERROR"
+)
+
+var errorCodeStatus = map[ErrorCode]int{
+	ErrCodeBadRequest:          http.StatusBadRequest,
+	ErrCodeValidationFailed:    http.StatusBadRequest,
+	ErrCodeUnauthenticated:     http.StatusUnauthorized,
+	ErrCodeForbidden:           http.StatusForbidden,
+	ErrCodeNotFound:            http.StatusNotFound,
+	ErrCodeMethodNotAllowed:    http.StatusMethodNotAllowed,
+	ErrCodeConflict:            http.StatusConflict,
+	ErrCodeFileMissing:         http.StatusBadRequest,
+	ErrCodeFileTooLarge:        http.StatusBadRequest,
+	ErrCodeFileTypeUnsupported: http.StatusBadRequest,
+	ErrCodeExcelUnreadable:     http.StatusBadRequest,
+	ErrCodeExcelMissingSheet:   http.StatusBadRequest,
+	ErrCodeExcelInvalidSheet:   http.StatusBadRequest,
+	ErrCodeUnknownDocumentType: http.StatusBadRequest,
+	ErrCodeOAuthNotConfigured:  http.StatusBadRequest,
+	ErrCodeOAuthLoginRequired:  http.StatusUnauthorized,
+	ErrCodeOAuthLoginFailed:    http.StatusUnauthorized,
+	ErrCodeOAuthExpired:        http.StatusUnauthorized,
+	ErrCodeIdentityMismatch:    http.StatusForbidden,
+	ErrCodeTenantRequired:      http.StatusBadRequest,
+	ErrCodeNotApproved:         http.StatusConflict,
+	ErrCodeInvalidTransition:   http.StatusConflict,
+	ErrCodeUpstreamTimeout:     http.StatusGatewayTimeout,
+	ErrCodeUpstreamUnavailable: http.StatusBadGateway,
+	ErrCodeUpstreamInvalid:     http.StatusBadGateway,
+	ErrCodeNotEnabled:          http.StatusNotFound,
+	ErrCodeUnavailable:         http.StatusServiceUnavailable,
+	ErrCodeInternal:            http.StatusInternalServerError,
+}
+
+// ErrorCodes returns all defined error codes
+func ErrorCodes() []ErrorCode {
+	codes := make([]ErrorCode, 0, len(errorCodeStatus))
+	for code := range errorCodeStatus {
+		codes = append(codes, code)
+	}
+	return codes
+}
+
+// HTTPStatus returns the HTTP status code for the error code
+func (code ErrorCode) HTTPStatus() int {
+	if status, ok := errorCodeStatus[code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// Field error codes
+const (
+	FieldRequired      = "required"
+	FieldInvalidType   = "invalid_type"
+	FieldInvalidFormat = "invalid_format"
+	FieldInvalidValue  = "invalid_value"
+	FieldMismatch      = "mismatch"
+)
+
+// FieldError points at an offending field by its JSON path, e.g. "barang[0].kodeHs"
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// AppError is an error with a stable code, optional field errors and an
+// optional underlying cause
+type AppError struct {
+	Code   ErrorCode
+	Detail string // specific English description
+	Fields []FieldError
+	Err    error
+}
+
+// NewError creates an AppError
+func NewError(code ErrorCode, detail string, err error) *AppError {
+	return &AppError{
+		Code:   code,
+		Detail: detail,
+		Err:    err,
+	}
+}
+
+// Errorf creates an AppError with a formatted detail
+func Errorf(code ErrorCode, format string, args ...interface{}) *AppError {
+	return NewError(code, fmt.Sprintf(format, args...), nil)
+}
+
+// WithFields attaches field errors
+func (e *AppError) WithFields(fields ...FieldError) *AppError {
+	e.Fields = append(e.Fields, fields...)
+	return e
+}
+
+// Error implements the error interface
+func (e *AppError) Error() string {
+	message := e.Detail
+	if message == "" {
+		message = string(e.Code)
+	}
+	if e.Err != nil {
+		message += ": " + e.Err.Error()
+	}
+	return message
+}
+
+// Unwrap returns the underlying cause
+func (e *AppError) Unwrap() error {
+	return e.Err
+}
+
+// AsAppError returns the AppError in err's chain. Other errors are wrapped in
+// a new AppError with the fallback code and detail.
+func AsAppError(err error, fallback ErrorCode, detail string) *AppError {
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return NewError(fallback, detail, err)
+}
+
+// ErrorCodeOf returns the code of the AppError in err's chain, empty if there is none
+func ErrorCodeOf(err error) ErrorCode {
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		return appErr.Code
+	}
+	return ""
+}
+
+// BindingError converts a request decoding error into a VALIDATION_FAILED
+// error pointing at the offending field. Errors that already carry a code,
+// e.g. for an unknown document type, are returned as they are.
+func BindingError(err error) *AppError {
+	var appErr *AppError
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+
+	switch {
+	case errors.As(err, &appErr):
+		return appErr
+	case errors.As(err, &typeErr):
+		return NewError(ErrCodeValidationFailed, "Invalid request data", err).WithFields(FieldError{
+			Field:   jsonFieldPath(typeErr.Field),
+			Code:    FieldInvalidType,
+			Message: fmt.Sprintf("expected %s, got %s", typeErr.Type.String(), typeErr.Value),
+		})
+	case errors.As(err, &syntaxErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return NewError(ErrCodeValidationFailed, "Request body is not valid JSON", err)
+	default:
+		return NewError(ErrCodeValidationFailed, "Invalid request data", err)
+	}
+}
+
+// jsonFieldPath strips the leading dot encoding/json reports for top-level fields
+func jsonFieldPath(field string) string {
+	return strings.TrimPrefix(field, ".")
+}
+
+// ErrorDetails is the body of ApiResponse.Details for failed requests
+type ErrorDetails struct {
+	Code      ErrorCode    `json:"code"`
+	Message   string       `json:"message"` // localized description of the code
+	Detail    string       `json:"detail,omitempty"`
+	Cause     string       `json:"cause,omitempty"`
+	Fields    []FieldError `json:"fields,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+}
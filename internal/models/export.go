@@ -0,0 +1,128 @@
+package models
+
+// BarangPemilik links goods to the entitas owning them
+type BarangPemilik struct {
+	SeriEntitas int `json:"seriEntitas" validate:"required"`
+}
+
+// ExportBarang represents goods on an export declaration. Export duty (bea
+// keluar) is a BarangTarif with kodeJenisPungutan BK.
+type ExportBarang struct {
+	Bruto            float64         `json:"bruto" validate:"required"`
+	Fob              float64         `json:"fob" validate:"required"`
+	HargaEkspor      float64         `json:"hargaEkspor" validate:"required"`
+	HargaPatokan     float64         `json:"hargaPatokan" validate:"required"`
+	HargaSatuan      float64         `json:"hargaSatuan" validate:"required"`
+	JumlahKemasan    int             `json:"jumlahKemasan" validate:"required"`
+	JumlahSatuan     float64         `json:"jumlahSatuan" validate:"required"`
+	KodeBarang       string          `json:"kodeBarang"`
+	KodeDaerahAsal   string          `json:"kodeDaerahAsal" validate:"required"`
+	KodeJenisKemasan string          `json:"kodeJenisKemasan" validate:"required"`
+	KodeNegaraAsal   string          `json:"kodeNegaraAsal" validate:"required"`
+	KodeSatuanBarang string          `json:"kodeSatuanBarang" validate:"required"`
+	Merk             string          `json:"merk" validate:"required"`
+	Netto            float64         `json:"netto" validate:"required"`
+	NilaiDanaSawit   float64         `json:"nilaiDanaSawit" validate:"required"`
+	NilaiDevisa      float64         `json:"nilaiDevisa" validate:"required"`
+	PosTarif         string          `json:"posTarif" validate:"required"`
+	SeriBarang       int             `json:"seriBarang" validate:"required"`
+	SpesifikasiLain  string          `json:"spesifikasiLain"`
+	Tipe             string          `json:"tipe" validate:"required"`
+	Ukuran           string          `json:"ukuran"`
+	Uraian           string          `json:"uraian" validate:"required"`
+	Volume           float64         `json:"volume" validate:"required"`
+	BarangDokumen    []BarangDokumen `json:"barangDokumen"`
+	BarangTarif      []BarangTarif   `json:"barangTarif"`
+	BarangPemilik    []BarangPemilik `json:"barangPemilik"`
+}
+
+// BankDevisa represents a bank receiving the export proceeds
+type BankDevisa struct {
+	KodeBank string `json:"kodeBank" validate:"required"`
+	SeriBank int    `json:"seriBank" validate:"required"`
+}
+
+// KesiapanBarang represents where and when the goods are ready for inspection
+type KesiapanBarang struct {
+	Alamat            string `json:"alamat" validate:"required"`
+	JumlahContainer20 int    `json:"jumlahContainer20"`
+	JumlahContainer40 int    `json:"jumlahContainer40"`
+	KodeCaraStuffing  string `json:"kodeCaraStuffing" validate:"required"`
+	KodeJenisBarang   string `json:"kodeJenisBarang" validate:"required"`
+	KodeJenisGudang   string `json:"kodeJenisGudang" validate:"required"`
+	KodeJenisPartOf   string `json:"kodeJenisPartOf"`
+	LokasiSiapPeriksa string `json:"lokasiSiapPeriksa" validate:"required"`
+	NamaPic           string `json:"namaPic" validate:"required"`
+	NomorTelpPic      string `json:"nomorTelpPic" validate:"required"`
+	TanggalPkb        string `json:"tanggalPkb" validate:"required"`
+	WaktuSiapPeriksa  string `json:"waktuSiapPeriksa" validate:"required"`
+}
+
+// ExportDeclaration is a BC 3.0 export declaration (Pemberitahuan Ekspor Barang)
+type ExportDeclaration struct {
+	AsalData           string           `json:"asalData" validate:"required"`
+	Asuransi           float64          `json:"asuransi" validate:"required"`
+	Bruto              float64          `json:"bruto" validate:"required"`
+	Cif                float64          `json:"cif" validate:"required"`
+	Disclaimer         string           `json:"disclaimer" validate:"required"`
+	FlagCurah          string           `json:"flagCurah" validate:"required"`
+	FlagMigas          string           `json:"flagMigas" validate:"required"`
+	Fob                float64          `json:"fob" validate:"required"`
+	Freight            float64          `json:"freight" validate:"required"`
+	IdPengguna         string           `json:"idPengguna" validate:"required"`
+	JabatanTtd         string           `json:"jabatanTtd" validate:"required"`
+	JumlahKontainer    int              `json:"jumlahKontainer" validate:"required"`
+	KodeAsuransi       string           `json:"kodeAsuransi" validate:"required"`
+	KodeCaraBayar      string           `json:"kodeCaraBayar" validate:"required"`
+	KodeCaraDagang     string           `json:"kodeCaraDagang" validate:"required"`
+	KodeDokumen        string           `json:"kodeDokumen" validate:"required"`
+	KodeIncoterm       string           `json:"kodeIncoterm" validate:"required"`
+	KodeJenisEkspor    string           `json:"kodeJenisEkspor" validate:"required"`
+	KodeJenisProsedur  string           `json:"kodeJenisProsedur" validate:"required"`
+	KodeKantor         string           `json:"kodeKantor" validate:"required"`
+	KodeKantorEkspor   string           `json:"kodeKantorEkspor" validate:"required"`
+	KodeKantorMuat     string           `json:"kodeKantorMuat" validate:"required"`
+	KodeKantorPeriksa  string           `json:"kodeKantorPeriksa" validate:"required"`
+	KodeKategoriEkspor string           `json:"kodeKategoriEkspor" validate:"required"`
+	KodeNegaraTujuan   string           `json:"kodeNegaraTujuan" validate:"required"`
+	KodePelBongkar     string           `json:"kodePelBongkar" validate:"required"`
+	KodePelEkspor      string           `json:"kodePelEkspor" validate:"required"`
+	KodePelMuat        string           `json:"kodePelMuat" validate:"required"`
+	KodePelTujuan      string           `json:"kodePelTujuan" validate:"required"`
+	KodeTps            string           `json:"kodeTps" validate:"required"`
+	KodeValuta         string           `json:"kodeValuta" validate:"required"`
+	KotaTtd            string           `json:"kotaTtd" validate:"required"`
+	NamaTtd            string           `json:"namaTtd" validate:"required"`
+	Ndpbm              float64          `json:"ndpbm" validate:"required"`
+	Netto              float64          `json:"netto" validate:"required"`
+	NilaiMaklon        float64          `json:"nilaiMaklon" validate:"required"`
+	NomorAju           string           `json:"nomorAju" validate:"required"`
+	Seri               int              `json:"seri" validate:"required"`
+	TanggalAju         string           `json:"tanggalAju" validate:"required"`
+	TanggalEkspor      string           `json:"tanggalEkspor" validate:"required"`
+	TanggalTtd         string           `json:"tanggalTtd" validate:"required"`
+	TotalDanaSawit     float64          `json:"totalDanaSawit" validate:"required"`
+	Volume             float64          `json:"volume" validate:"required"`
+	Barang             []ExportBarang   `json:"barang"`
+	Entitas            []Entitas        `json:"entitas"`
+	Kemasan            []Kemasan        `json:"kemasan"`
+	Kontainer          []Kontainer      `json:"kontainer"`
+	Dokumen            []Dokumen        `json:"dokumen"`
+	Pengangkut         []Pengangkut     `json:"pengangkut"`
+	BankDevisa         []BankDevisa     `json:"bankDevisa"`
+	KesiapanBarang     []KesiapanBarang `json:"kesiapanBarang"`
+}
+
+// Header implements Declaration
+func (d *ExportDeclaration) Header() DeclarationHeader {
+	return DeclarationHeader{
+		KodeDokumen: &d.KodeDokumen,
+		NomorAju:    &d.NomorAju,
+		KodeKantor:  &d.KodeKantor,
+		IdPengguna:  &d.IdPengguna,
+		TanggalAju:  &d.TanggalAju,
+		KodeValuta:  &d.KodeValuta,
+		Ndpbm:       &d.Ndpbm,
+		Entitas:     &d.Entitas,
+	}
+}
@@ -0,0 +1,17 @@
+package models
+
+// Kurs is the exchange rate the Ministry of Finance sets for customs
+// purposes (nilai dasar perhitungan bea masuk, NDPBM) over a period, usually
+// a week
+type Kurs struct {
+	KodeValuta   string  `json:"kode_valuta"`
+	Nilai        float64 `json:"nilai"`         // rupiah per unit of the currency
+	TanggalAwal  string  `json:"tanggal_awal"`  // first day in force, YYYY-MM-DD
+	TanggalAkhir string  `json:"tanggal_akhir"` // last day in force, YYYY-MM-DD
+}
+
+// KursImport is the outcome of importing exchange rates
+type KursImport struct {
+	Imported int `json:"imported"`
+	Replaced int `json:"replaced"` // stored rates whose period a new rate overlaps
+}
@@ -212,18 +212,54 @@ type HealthResponse struct {
 	Status    string    `json:"status"`
 	Service   string    `json:"service"`
 	Version   string    `json:"version"`
+	Commit    string    `json:"commit"`
 	Timestamp time.Time `json:"timestamp"`
 }
 
-// Excel data structures
-type ExcelData struct {
-	MainData   interface{}   `json:"MainData,omitempty"`
-	Barang     []interface{} `json:"Barang,omitempty"`
-	Entitas    []interface{} `json:"Entitas,omitempty"`
-	Kemasan    []interface{} `json:"Kemasan,omitempty"`
-	Kontainer  []interface{} `json:"Kontainer,omitempty"`
-	Dokumen    []interface{} `json:"Dokumen,omitempty"`
-	Pengangkut []interface{} `json:"Pengangkut,omitempty"`
+// Readiness check statuses
+const (
+	CheckPassed   = "pass"
+	CheckFailed   = "fail"
+	CheckSkipped  = "skipped"
+	CheckDegraded = "degraded" // a problem that does not make the service unready
+)
+
+// ReadinessCheck is the result of checking one dependency
+type ReadinessCheck struct {
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	Message   string    `json:"message,omitempty"`
+	Duration  string    `json:"duration"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// ReadinessResponse reports whether the service can handle requests
+type ReadinessResponse struct {
+	Ready   bool             `json:"ready"`
+	Version string           `json:"version"`
+	Commit  string           `json:"commit"`
+	Checks  []ReadinessCheck `json:"checks"`
+}
+
+// ExcelData holds the parsed sheets of an uploaded workbook by sheet name. The
+// header sheet is an object, every other sheet a list of rows.
+type ExcelData map[string]interface{}
+
+// SheetLayout describes one sheet of a document type's Excel template
+type SheetLayout struct {
+	Name    string   `json:"name"`
+	Field   string   `json:"field,omitempty"`  // list of the declaration the rows fill, empty for the header sheet
+	Parent  string   `json:"parent,omitempty"` // list whose entries hold Field, empty for top-level lists
+	Key     string   `json:"key,omitempty"`    // column matching a row to its parent entry, e.g. seriBarang
+	Columns []string `json:"columns"`
+}
+
+// DocumentTypeInfo describes a supported customs document type
+type DocumentTypeInfo struct {
+	KodeDokumen string        `json:"kodeDokumen"`
+	Name        string        `json:"name"`
+	Declarant   string        `json:"declarant"` // kodeEntitas of the party filing the declaration
+	Sheets      []SheetLayout `json:"sheets"`
 }
 
 // OAuth 2.0 Token Response from CEISA 4.0 API
@@ -256,11 +292,147 @@ type OAuthRefreshRequest struct {
 
 // OAuth 2.0 Token Info for internal use
 type OAuthTokenInfo struct {
-	AccessToken  string    `json:"access_token"`
-	RefreshToken string    `json:"refresh_token"`
-	ExpiresAt    time.Time `json:"expires_at"`
-	TokenType    string    `json:"token_type"`
-	Scope        string    `json:"scope"`
+	AccessToken      string       `json:"access_token"`
+	RefreshToken     string       `json:"refresh_token"`
+	IssuedAt         time.Time    `json:"issued_at"`
+	ExpiresAt        time.Time    `json:"expires_at"`
+	RefreshExpiresAt time.Time    `json:"refresh_expires_at"` // zero when CEISA did not report it
+	TokenType        string       `json:"token_type"`
+	Scope            string       `json:"scope"`
+	IDToken          string       `json:"id_token,omitempty"`
+	Claims           *TokenClaims `json:"claims,omitempty"`
+}
+
+// Identity claims decoded from the CEISA access and ID tokens
+type TokenClaims struct {
+	Subject   string    `json:"subject,omitempty"`
+	Username  string    `json:"username,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	Email     string    `json:"email,omitempty"`
+	NPWP      string    `json:"npwp,omitempty"`
+	NIB       string    `json:"nib,omitempty"`
+	Roles     []string  `json:"roles,omitempty"`
+	Issuer    string    `json:"issuer,omitempty"`
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Verified  bool      `json:"verified"`
+}
+
+// Roles of this service's own users, from least to most privileged
+const (
+	RoleViewer   = "viewer"
+	RolePreparer = "preparer"
+	RoleApprover = "approver"
+	RoleAdmin    = "admin"
+)
+
+var roleRank = map[string]int{
+	RoleViewer:   1,
+	RolePreparer: 2,
+	RoleApprover: 3,
+	RoleAdmin:    4,
+}
+
+// IsValidRole reports whether role is a known role
+func IsValidRole(role string) bool {
+	_, ok := roleRank[role]
+	return ok
+}
+
+// Principal is the authenticated caller of this service's API
+type Principal struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	Method   string `json:"method"` // "password", "api_key" or "anonymous"
+}
+
+// HasRole reports whether the principal holds role or a more privileged one
+func (p *Principal) HasRole(role string) bool {
+	return p != nil && roleRank[p.Role] >= roleRank[role] && roleRank[role] > 0
+}
+
+// Approval workflow states of a declaration
+const (
+	DocumentStatusDraft          = "draft"
+	DocumentStatusReadyForReview = "ready_for_review"
+	DocumentStatusApproved       = "approved"
+	DocumentStatusRejected       = "rejected"
+	DocumentStatusSubmitting     = "submitting" // being sent to CEISA
+	DocumentStatusSubmitted      = "submitted"
+	DocumentStatusResponded      = "responded"
+)
+
+// Document is a declaration going through the approval workflow
+type Document struct {
+	ID          string                 `json:"id"`
+	TenantID    string                 `json:"tenant_id,omitempty"`
+	NomorAju    string                 `json:"nomor_aju"`
+	KodeDokumen string                 `json:"kode_dokumen"`
+	Status      string                 `json:"status"`
+	Data        DeclarationData        `json:"data"`
+	CreatedBy   string                 `json:"created_by"`
+	CreatedAt   time.Time              `json:"created_at"`
+	UpdatedAt   time.Time              `json:"updated_at"`
+	Response    map[string]interface{} `json:"response,omitempty"` // latest CEISA response
+	History     []DocumentTransition   `json:"history"`
+}
+
+// DocumentActionEdited marks history entries recording an edit of a draft
+const DocumentActionEdited = "edited"
+
+// DocumentTransition records a change of a document's workflow state, or an
+// edit of its declaration data
+type DocumentTransition struct {
+	From    string    `json:"from,omitempty"`
+	To      string    `json:"to"`
+	Action  string    `json:"action,omitempty"` // DocumentActionEdited for edits
+	User    string    `json:"user"`
+	Role    string    `json:"role"`
+	At      time.Time `json:"at"`
+	Comment string    `json:"comment,omitempty"`
+}
+
+// Tenant is an importer a customs broker (PPJK) files declarations for
+type Tenant struct {
+	ID       string         `json:"id"`
+	Name     string         `json:"name"`
+	Users    []string       `json:"users,omitempty"` // users acting for the tenant besides administrators
+	Open     bool           `json:"open,omitempty"`  // any user may act for the tenant
+	Defaults TenantDefaults `json:"defaults"`
+	API      *ApiConfig     `json:"api,omitempty"`   // overrides of the server API defaults
+	OAuth    *OAuth2Config  `json:"oauth,omitempty"` // CEISA credentials of the tenant
+}
+
+// TenantDefaults are the values prefilled into a tenant's declarations
+type TenantDefaults struct {
+	KodeKantor string    `json:"kodeKantor,omitempty"`
+	IdPengguna string    `json:"idPengguna,omitempty"`
+	Entitas    []Entitas `json:"entitas,omitempty"`
+}
+
+// Audit outcomes
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+)
+
+// AuditEntry is one record of the hash-chained audit log
+type AuditEntry struct {
+	Seq        int64     `json:"seq"`
+	Time       time.Time `json:"time"`
+	Action     string    `json:"action"`
+	Actor      string    `json:"actor"`
+	Role       string    `json:"role,omitempty"`
+	Tenant     string    `json:"tenant,omitempty"`
+	IP         string    `json:"ip,omitempty"`
+	RequestID  string    `json:"request_id,omitempty"`
+	Target     string    `json:"target,omitempty"`
+	Outcome    string    `json:"outcome"`
+	Detail     string    `json:"detail,omitempty"`
+	BeforeHash string    `json:"before_hash,omitempty"`
+	AfterHash  string    `json:"after_hash,omitempty"`
+	PrevHash   string    `json:"prev_hash"`
+	Hash       string    `json:"hash"`
 }
 
 // API configuration with OAuth 2.0 support
@@ -287,13 +459,24 @@ type OAuth2Config struct {
 
 // Request structures
 type GenerateJsonRequest struct {
-	Data interface{} `json:"data" validate:"required"`
+	Data   interface{} `json:"data" validate:"required"`
+	Derive bool        `json:"derive"` // compute totals, CIF values and seri numbers from the goods
+}
+
+// DerivedChange is a value the derive pass of GenerateJson changed
+type DerivedChange struct {
+	Field  string      `json:"field"`
+	From   interface{} `json:"from"`
+	To     interface{} `json:"to"`
+	Reason string      `json:"reason"`
 }
 
 type SendToApiRequest struct {
-	JsonData  ResponseData `json:"json_data" validate:"required"`
-	ApiConfig *ApiConfig   `json:"api_config,omitempty"`
-	DryRun    bool         `json:"dry_run"`
+	JsonData   DeclarationData `json:"json_data" validate:"required"`
+	ApiConfig  *ApiConfig      `json:"api_config,omitempty"`
+	DryRun     bool            `json:"dry_run"`
+	Force      bool            `json:"force"`       // resubmit even if an identical send was recorded
+	DocumentID string          `json:"document_id"` // approved declaration to submit
 }
 
 type TestConnectionRequest struct {
@@ -316,3 +499,24 @@ type OAuthConfigRequest struct {
 	Username   string `json:"username" validate:"required"`
 	Password   string `json:"password" validate:"required"`
 }
+
+// Authentication request structures for this service's own API
+type LoginRequest struct {
+	Username string `json:"username" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+// Approval workflow request structures
+type DocumentRequest struct {
+	JsonData DeclarationData `json:"json_data" validate:"required"`
+}
+
+type DocumentTransitionRequest struct {
+	Status  string `json:"status" validate:"required"`
+	Comment string `json:"comment"`
+}
+
+type DocumentResponseRequest struct {
+	Response map[string]interface{} `json:"response" validate:"required"`
+	Comment  string                 `json:"comment"`
+}
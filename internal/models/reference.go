@@ -0,0 +1,17 @@
+package models
+
+// ReferenceCode is an entry of a customs reference table, e.g. a currency or
+// customs office
+type ReferenceCode struct {
+	Kode   string `json:"kode"`
+	Uraian string `json:"uraian"`
+}
+
+// ReferenceTableInfo describes a loaded reference table
+type ReferenceTableInfo struct {
+	Name    string   `json:"name"`
+	Version string   `json:"version"`
+	Source  string   `json:"source"` // file the table was loaded from
+	Count   int      `json:"count"`
+	Fields  []string `json:"fields"` // declaration fields checked against it
+}
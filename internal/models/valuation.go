@@ -0,0 +1,48 @@
+package models
+
+import "encoding/json"
+
+// ValuationRequest asks for the customs (CIF) value of a declaration to be
+// computed from the invoice value at its incoterm
+type ValuationRequest struct {
+	JsonData DeclarationData `json:"json_data" validate:"required"`
+	// Basis spreads header freight and insurance over the goods by value
+	// (default) or by gross weight
+	Basis string `json:"basis,omitempty"`
+}
+
+// UnmarshalJSON reports declaration type errors under json_data
+func (r *ValuationRequest) UnmarshalJSON(data []byte) error {
+	type plain ValuationRequest
+	return withDeclarationField(json.Unmarshal(data, (*plain)(r)), "json_data")
+}
+
+// Valuation is the outcome of converting the invoice value of a declaration
+// to its customs value. Amounts are in the invoice currency unless named
+// rupiah.
+type Valuation struct {
+	KodeIncoterm      string          `json:"kode_incoterm"`
+	NilaiIncoterm     float64         `json:"nilai_incoterm"` // invoice value at the incoterm
+	Diskon            float64         `json:"diskon"`
+	BiayaTambahan     float64         `json:"biaya_tambahan"`
+	BiayaPengurang    float64         `json:"biaya_pengurang"`
+	Fob               float64         `json:"fob"`
+	Freight           float64         `json:"freight"`
+	Asuransi          float64         `json:"asuransi"`
+	AsuransiEstimated bool            `json:"asuransi_estimated"` // none declared, estimated from FOB
+	Cif               float64         `json:"cif"`
+	CifRupiah         float64         `json:"cif_rupiah"`
+	Basis             string          `json:"basis"`
+	Barang            []ValuationItem `json:"barang"`
+}
+
+// ValuationItem is the customs value of one item
+type ValuationItem struct {
+	SeriBarang int     `json:"seri_barang"`
+	Nilai      float64 `json:"nilai"` // invoice value less discount
+	Fob        float64 `json:"fob"`
+	Freight    float64 `json:"freight"`
+	Asuransi   float64 `json:"asuransi"`
+	Cif        float64 `json:"cif"`
+	CifRupiah  float64 `json:"cif_rupiah"`
+}
@@ -2,16 +2,24 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"time"
 
 	"github.com/sirupsen/logrus"
 
 	"json-response-generator/internal/models"
+	"json-response-generator/internal/telemetry"
+	"json-response-generator/internal/version"
 )
 
+// userAgent identifies this service to CEISA
+var userAgent = "JSON-Response-Generator/" + version.Version
+
 // ApiClient service for sending data to external APIs
 type ApiClient struct {
 	httpClient   *http.Client
@@ -21,9 +29,7 @@ type ApiClient struct {
 // NewApiClient creates a new ApiClient instance
 func NewApiClient() *ApiClient {
 	return &ApiClient{
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		httpClient:   telemetry.NewHTTPClient(30 * time.Second),
 		oauthService: NewOAuthService(),
 	}
 }
@@ -31,27 +37,25 @@ func NewApiClient() *ApiClient {
 // NewApiClientWithOAuth creates a new ApiClient instance with OAuth service
 func NewApiClientWithOAuth(oauthService *OAuthService) *ApiClient {
 	return &ApiClient{
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		httpClient:   telemetry.NewHTTPClient(30 * time.Second),
 		oauthService: oauthService,
 	}
 }
 
 // TestConnection tests the connection to an API endpoint
-func (ac *ApiClient) TestConnection(endpoint string) (bool, string, error) {
+func (ac *ApiClient) TestConnection(ctx context.Context, endpoint string) (bool, string, error) {
 	if endpoint == "" {
 		return false, "Endpoint URL is required", nil
 	}
 
 	// Create a simple GET request to test connectivity
-	req, err := http.NewRequest("GET", endpoint, nil)
+	req, err := http.NewRequestWithContext(telemetry.WithOperation(ctx, telemetry.OperationConnectionTest), "GET", endpoint, nil)
 	if err != nil {
 		return false, fmt.Sprintf("Failed to create request: %v", err), err
 	}
 
 	// Set headers
-	req.Header.Set("User-Agent", "JSON-Response-Generator/2.0.0")
+	req.Header.Set("User-Agent", userAgent)
 	req.Header.Set("Accept", "application/json")
 
 	// Send request
@@ -69,10 +73,46 @@ func (ac *ApiClient) TestConnection(endpoint string) (bool, string, error) {
 	}
 }
 
-// SendData sends JSON data to the API endpoint
-func (ac *ApiClient) SendData(data *models.ResponseData, config *models.ApiConfig, dryRun bool) (bool, map[string]interface{}, error) {
+// Ping sends a GET request to endpoint and returns the response status.
+// Only failing to get a response is an error.
+func (ac *ApiClient) Ping(ctx context.Context, endpoint string) (int, error) {
+	req, err := http.NewRequestWithContext(telemetry.WithOperation(ctx, telemetry.OperationPing), "GET", endpoint, nil)
+	if err != nil {
+		return 0, fmt.Errorf("invalid endpoint %s: %w", endpoint, err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := ac.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("%s is unreachable: %w", endpoint, err)
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode, nil
+}
+
+// EndpointProbe checks that the CEISA endpoint answers. Any HTTP response
+// counts as reachable; authentication is not part of the check.
+func (ac *ApiClient) EndpointProbe(endpoint string) ReadinessProbe {
+	return func(ctx context.Context) (string, error) {
+		if endpoint == "" {
+			return "", SkipCheck("no CEISA endpoint configured")
+		}
+
+		status, err := ac.Ping(ctx, endpoint)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s responded with status %d", endpoint, status), nil
+	}
+}
+
+// SendData sends JSON data to the API endpoint. OAuth 2.0 calls authenticate
+// with the CEISA token of the given session.
+func (ac *ApiClient) SendData(ctx context.Context, data models.Declaration, config *models.ApiConfig, dryRun bool, session *OAuthSession) (bool, map[string]interface{}, error) {
+	log := logrus.WithContext(ctx)
 	if dryRun {
-		logrus.Info("Dry run mode - data would be sent to:", config.Endpoint)
+		log.Info("Dry run mode - data would be sent to:", config.Endpoint)
 		return true, map[string]interface{}{
 			"message":   "Dry run completed - no data was actually sent",
 			"endpoint":  config.Endpoint,
@@ -81,7 +121,18 @@ func (ac *ApiClient) SendData(data *models.ResponseData, config *models.ApiConfi
 	}
 
 	if config.Endpoint == "" {
-		return false, nil, fmt.Errorf("API endpoint is required")
+		return false, nil, models.NewError(models.ErrCodeValidationFailed, "API endpoint is required", nil).WithFields(models.FieldError{
+			Field: "api_config.endpoint",
+			Code:  models.FieldRequired,
+		})
+	}
+
+	// The timeout covers obtaining a token and the submission itself. It is
+	// applied per request since the client is shared.
+	if config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(config.Timeout)*time.Second)
+		defer cancel()
 	}
 
 	// Marshal data to JSON
@@ -91,14 +142,14 @@ func (ac *ApiClient) SendData(data *models.ResponseData, config *models.ApiConfi
 	}
 
 	// Create request
-	req, err := http.NewRequest("POST", config.Endpoint, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(telemetry.WithOperation(ctx, telemetry.OperationSubmit), "POST", config.Endpoint, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return false, nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	// Set headers
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("User-Agent", "JSON-Response-Generator/2.0.0")
+	req.Header.Set("User-Agent", userAgent)
 
 	// Add authentication based on auth type
 	switch config.AuthType {
@@ -111,18 +162,22 @@ func (ac *ApiClient) SendData(data *models.ResponseData, config *models.ApiConfi
 			req.SetBasicAuth(config.Username, config.Password)
 		}
 	case "oauth2":
-		if config.OAuth2Config != nil {
-			// Set OAuth configuration if provided
-			ac.oauthService.SetConfig(config.OAuth2Config)
+		if session == nil {
+			return false, nil, models.NewError(models.ErrCodeOAuthLoginRequired, "no OAuth session, please login first", nil)
+		}
 
-			// Get valid access token
-			accessToken, err := ac.oauthService.GetValidToken()
-			if err != nil {
-				return false, nil, fmt.Errorf("failed to get valid OAuth token: %w", err)
-			}
+		// Set OAuth configuration if provided
+		if config.OAuth2Config != nil {
+			session.SetConfig(config.OAuth2Config)
+		}
 
-			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
+		// Get valid access token
+		accessToken, err := session.GetValidTokenContext(ctx)
+		if err != nil {
+			return false, nil, fmt.Errorf("failed to get valid OAuth token: %w", err)
 		}
+
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
 	default:
 		// Legacy support for backward compatibility
 		if config.APIKey != "" {
@@ -133,12 +188,7 @@ func (ac *ApiClient) SendData(data *models.ResponseData, config *models.ApiConfi
 		}
 	}
 
-	// Set timeout if specified
-	if config.Timeout > 0 {
-		ac.httpClient.Timeout = time.Duration(config.Timeout) * time.Second
-	}
-
-	logrus.WithFields(logrus.Fields{
+	log.WithFields(logrus.Fields{
 		"endpoint": config.Endpoint,
 		"method":   "POST",
 		"size":     len(jsonData),
@@ -147,7 +197,7 @@ func (ac *ApiClient) SendData(data *models.ResponseData, config *models.ApiConfi
 	// Send request
 	resp, err := ac.httpClient.Do(req)
 	if err != nil {
-		return false, nil, fmt.Errorf("request failed: %w", err)
+		return false, nil, upstreamError("request failed", err)
 	}
 	defer resp.Body.Close()
 
@@ -163,7 +213,7 @@ func (ac *ApiClient) SendData(data *models.ResponseData, config *models.ApiConfi
 
 	// Check if request was successful
 	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		logrus.WithFields(logrus.Fields{
+		log.WithFields(logrus.Fields{
 			"endpoint":    config.Endpoint,
 			"status_code": resp.StatusCode,
 		}).Info("Data sent successfully")
@@ -172,7 +222,7 @@ func (ac *ApiClient) SendData(data *models.ResponseData, config *models.ApiConfi
 		responseData["status_code"] = resp.StatusCode
 		return true, responseData, nil
 	} else {
-		logrus.WithFields(logrus.Fields{
+		log.WithFields(logrus.Fields{
 			"endpoint":    config.Endpoint,
 			"status_code": resp.StatusCode,
 			"status":      resp.Status,
@@ -185,14 +235,29 @@ func (ac *ApiClient) SendData(data *models.ResponseData, config *models.ApiConfi
 	}
 }
 
+// upstreamError classifies a failed call to CEISA as a timeout or an unreachable service
+func upstreamError(detail string, err error) error {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return models.NewError(models.ErrCodeUpstreamTimeout, detail, err)
+	}
+	return models.NewError(models.ErrCodeUpstreamUnavailable, detail, err)
+}
+
 // ValidateConfig validates API configuration
 func (ac *ApiClient) ValidateConfig(config *models.ApiConfig) error {
 	if config.Endpoint == "" {
-		return fmt.Errorf("API endpoint is required")
+		return models.NewError(models.ErrCodeValidationFailed, "API endpoint is required", nil).WithFields(models.FieldError{
+			Field: "endpoint",
+			Code:  models.FieldRequired,
+		})
 	}
 
 	if config.Timeout < 0 {
-		return fmt.Errorf("timeout must be positive")
+		return models.NewError(models.ErrCodeValidationFailed, "timeout must be positive", nil).WithFields(models.FieldError{
+			Field: "timeout",
+			Code:  models.FieldInvalidValue,
+		})
 	}
 
 	if config.Timeout == 0 {
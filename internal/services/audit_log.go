@@ -0,0 +1,301 @@
+package services
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"json-response-generator/internal/models"
+)
+
+// Audit actions
+const (
+	AuditConfigChange       = "config.change"
+	AuditLogin              = "auth.login"
+	AuditLogout             = "auth.logout"
+	AuditOAuthLogin         = "oauth.login"
+	AuditOAuthLogout        = "oauth.logout"
+	AuditUpload             = "excel.upload"
+	AuditGenerate           = "json.generate"
+	AuditSubmit             = "ceisa.submit"
+	AuditDocumentCreate     = "document.create"
+	AuditDocumentUpdate     = "document.update"
+	AuditDocumentResponse   = "document.response"
+	AuditDocumentTransition = "document.transition"
+	AuditKursImport         = "kurs.import"
+)
+
+// AuditLog is an append-only JSONL file of hash-chained entries. Every entry
+// carries the hash of its predecessor, so changing or removing an entry breaks
+// the chain from that point on. Truncation of the tail can only be detected
+// against a head hash kept elsewhere, as reported by VerifyAuditLog.
+type AuditLog struct {
+	path     string
+	lastSeq  int64
+	lastHash string
+	mutex    sync.Mutex
+}
+
+// OpenAuditLog opens the audit log at path, verifying the existing chain
+func OpenAuditLog(path string) (*AuditLog, error) {
+	count, lastHash, err := VerifyAuditLog(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("audit log %s failed verification: %w", path, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+
+	return &AuditLog{
+		path:     path,
+		lastSeq:  int64(count),
+		lastHash: lastHash,
+	}, nil
+}
+
+// Record appends an entry to the log, filling in its sequence number, time and hashes
+func (al *AuditLog) Record(entry models.AuditEntry) error {
+	al.mutex.Lock()
+	defer al.mutex.Unlock()
+
+	entry.Seq = al.lastSeq + 1
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	entry.Time = entry.Time.UTC()
+	entry.PrevHash = al.lastHash
+	entry.Hash = ""
+
+	hash, err := auditEntryHash(&entry)
+	if err != nil {
+		return err
+	}
+	entry.Hash = hash
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+
+	f, err := os.OpenFile(al.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("failed to sync audit log: %w", err)
+	}
+
+	al.lastSeq = entry.Seq
+	al.lastHash = entry.Hash
+	return nil
+}
+
+// Head returns the sequence number and hash of the latest entry
+func (al *AuditLog) Head() (int64, string) {
+	al.mutex.Lock()
+	defer al.mutex.Unlock()
+	return al.lastSeq, al.lastHash
+}
+
+// AuditSnapshot is the head of the log at a point in time, so an export
+// covers exactly the entries up to it while new ones are appended
+type AuditSnapshot struct {
+	Seq  int64
+	Hash string
+	size int64
+}
+
+// Snapshot returns the current head of the log
+func (al *AuditLog) Snapshot() (AuditSnapshot, error) {
+	al.mutex.Lock()
+	defer al.mutex.Unlock()
+
+	snapshot := AuditSnapshot{Seq: al.lastSeq, Hash: al.lastHash}
+	info, err := os.Stat(al.path)
+	if err != nil && !os.IsNotExist(err) {
+		return snapshot, fmt.Errorf("failed to read audit log size: %w", err)
+	}
+	if err == nil {
+		snapshot.size = info.Size()
+	}
+	return snapshot, nil
+}
+
+// Export writes the entries of snapshot between from and to (zero values are
+// open ends) as JSONL or CSV. It does not hold the log lock, so a slow reader
+// does not delay Record.
+func (al *AuditLog) Export(w io.Writer, snapshot AuditSnapshot, format string, from, to time.Time) error {
+	var csvWriter *csv.Writer
+	switch format {
+	case "jsonl":
+	case "csv":
+		csvWriter = csv.NewWriter(w)
+		if err := csvWriter.Write(auditCSVHeader); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported export format %q", format)
+	}
+
+	if snapshot.size > 0 {
+		f, err := os.Open(al.path)
+		if err != nil {
+			return fmt.Errorf("failed to open audit log: %w", err)
+		}
+		defer f.Close()
+
+		err = readAuditEntries(io.LimitReader(f, snapshot.size), func(entry *models.AuditEntry, line []byte) error {
+			if (!from.IsZero() && entry.Time.Before(from)) || (!to.IsZero() && entry.Time.After(to)) {
+				return nil
+			}
+			if csvWriter != nil {
+				return csvWriter.Write(auditCSVRecord(entry))
+			}
+			_, err := w.Write(append(line, '\n'))
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if csvWriter != nil {
+		csvWriter.Flush()
+		return csvWriter.Error()
+	}
+	return nil
+}
+
+// VerifyAuditLog checks the hash chain of the audit log at path. It returns the
+// number of entries and the hash of the last one.
+func VerifyAuditLog(path string) (int, string, error) {
+	count := 0
+	lastHash := ""
+
+	err := readAuditLog(path, func(entry *models.AuditEntry, _ []byte) error {
+		count++
+		if entry.Seq != int64(count) {
+			return fmt.Errorf("entry %d has sequence number %d", count, entry.Seq)
+		}
+		if entry.PrevHash != lastHash {
+			return fmt.Errorf("entry %d does not chain to its predecessor", entry.Seq)
+		}
+
+		stored := entry.Hash
+		entry.Hash = ""
+		hash, err := auditEntryHash(entry)
+		if err != nil {
+			return err
+		}
+		if hash != stored {
+			return fmt.Errorf("entry %d has been modified", entry.Seq)
+		}
+
+		lastHash = stored
+		return nil
+	})
+
+	return count, lastHash, err
+}
+
+// HashValue returns the hex SHA-256 of the JSON form of v, empty for nil
+func HashValue(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return HashBytes(data)
+}
+
+// HashBytes returns the hex SHA-256 of data
+func HashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func auditEntryHash(entry *models.AuditEntry) (string, error) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+	return HashBytes(data), nil
+}
+
+// readAuditLog calls fn for every entry of the log in order
+func readAuditLog(path string, fn func(entry *models.AuditEntry, line []byte) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return readAuditEntries(f, fn)
+}
+
+// readAuditEntries calls fn for every entry read from r in order
+func readAuditEntries(r io.Reader, fn func(entry *models.AuditEntry, line []byte) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var entry models.AuditEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return fmt.Errorf("line %d is not a valid audit entry: %w", lineNumber, err)
+		}
+		if err := fn(&entry, line); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+var auditCSVHeader = []string{
+	"seq", "time", "action", "actor", "role", "tenant", "ip", "request_id",
+	"target", "outcome", "detail", "before_hash", "after_hash", "prev_hash", "hash",
+}
+
+func auditCSVRecord(entry *models.AuditEntry) []string {
+	return []string{
+		strconv.FormatInt(entry.Seq, 10),
+		entry.Time.Format(time.RFC3339Nano),
+		entry.Action,
+		entry.Actor,
+		entry.Role,
+		entry.Tenant,
+		entry.IP,
+		entry.RequestID,
+		entry.Target,
+		entry.Outcome,
+		entry.Detail,
+		entry.BeforeHash,
+		entry.AfterHash,
+		entry.PrevHash,
+		entry.Hash,
+	}
+}
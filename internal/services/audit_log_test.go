@@ -0,0 +1,149 @@
+package services
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"json-response-generator/internal/models"
+)
+
+func TestAuditLogChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	auditLog, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatalf("OpenAuditLog failed: %v", err)
+	}
+
+	for _, action := range []string{AuditLogin, AuditConfigChange, AuditSubmit} {
+		entry := models.AuditEntry{Action: action, Actor: "alice", Outcome: models.AuditOutcomeSuccess}
+		if err := auditLog.Record(entry); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+	}
+
+	count, head, err := VerifyAuditLog(path)
+	if err != nil {
+		t.Fatalf("Expected intact log, got %v", err)
+	}
+	if seq, hash := auditLog.Head(); count != 3 || seq != 3 || hash != head {
+		t.Errorf("Unexpected head: count %d, seq %d, hash %s vs %s", count, seq, hash, head)
+	}
+
+	// Reopening continues the chain
+	reopened, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatalf("Reopening failed: %v", err)
+	}
+	if err := reopened.Record(models.AuditEntry{Action: AuditLogout, Actor: "alice"}); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	if count, _, err := VerifyAuditLog(path); err != nil || count != 4 {
+		t.Fatalf("Expected 4 intact entries, got %d, %v", count, err)
+	}
+
+	// Tampering with an entry breaks the chain
+	data, _ := os.ReadFile(path)
+	tampered := strings.Replace(string(data), `"action":"config.change","actor":"alice"`, `"action":"config.change","actor":"mallory"`, 1)
+	if tampered == string(data) {
+		t.Fatal("Test setup did not modify the log")
+	}
+	os.WriteFile(path, []byte(tampered), 0600)
+
+	if _, _, err := VerifyAuditLog(path); err == nil || !strings.Contains(err.Error(), "entry 2") {
+		t.Errorf("Expected entry 2 to fail verification, got %v", err)
+	}
+	if _, err := OpenAuditLog(path); err == nil {
+		t.Error("Expected opening a tampered log to fail")
+	}
+
+	// Removing an entry breaks the chain too
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	os.WriteFile(path, []byte(strings.Join(append(lines[:1], lines[2:]...), "\n")+"\n"), 0600)
+	if _, _, err := VerifyAuditLog(path); err == nil {
+		t.Error("Expected a removed entry to fail verification")
+	}
+}
+
+func TestAuditLogExport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	auditLog, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatalf("OpenAuditLog failed: %v", err)
+	}
+
+	old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	auditLog.Record(models.AuditEntry{Action: AuditUpload, Actor: "alice", Time: old, Target: "old.xlsx"})
+	auditLog.Record(models.AuditEntry{Action: AuditUpload, Actor: "bob", Target: "new.xlsx", Detail: `quoted "value", with comma`})
+
+	snapshot, err := auditLog.Snapshot()
+	if err != nil || snapshot.Seq != 2 {
+		t.Fatalf("Snapshot() = %+v, %v", snapshot, err)
+	}
+
+	var jsonl bytes.Buffer
+	if err := auditLog.Export(&jsonl, snapshot, "jsonl", time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), time.Time{}); err != nil {
+		t.Fatalf("JSONL export failed: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(jsonl.String()), "\n"); len(lines) != 1 || !strings.Contains(lines[0], "new.xlsx") {
+		t.Errorf("Expected only the recent entry, got %q", jsonl.String())
+	}
+
+	var csvOut bytes.Buffer
+	if err := auditLog.Export(&csvOut, snapshot, "csv", time.Time{}, time.Time{}); err != nil {
+		t.Fatalf("CSV export failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(csvOut.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "seq,time,action") {
+		t.Errorf("Unexpected CSV export %q", csvOut.String())
+	}
+	if !strings.Contains(lines[2], `"quoted ""value"", with comma"`) {
+		t.Errorf("Expected CSV quoting, got %q", lines[2])
+	}
+
+	if err := auditLog.Export(&csvOut, snapshot, "xml", time.Time{}, time.Time{}); err == nil {
+		t.Error("Expected unsupported format to fail")
+	}
+}
+
+func TestAuditLogExportDoesNotBlockRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	auditLog, _ := OpenAuditLog(path)
+	auditLog.Record(models.AuditEntry{Action: AuditUpload, Actor: "alice", Target: "first.xlsx"})
+
+	snapshot, _ := auditLog.Snapshot()
+	reader, writer := io.Pipe()
+	exported := make(chan error, 1)
+	go func() {
+		exported <- auditLog.Export(writer, snapshot, "jsonl", time.Time{}, time.Time{})
+		writer.Close()
+	}()
+
+	// The export is stalled on the unread pipe while an entry is recorded
+	recorded := make(chan error, 1)
+	go func() {
+		recorded <- auditLog.Record(models.AuditEntry{Action: AuditUpload, Actor: "bob", Target: "second.xlsx"})
+	}()
+	select {
+	case err := <-recorded:
+		if err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Record was blocked by a stalled export")
+	}
+
+	data, _ := io.ReadAll(reader)
+	if err := <-exported; err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 1 || !strings.Contains(lines[0], "first.xlsx") {
+		t.Errorf("Expected the export to end at its snapshot, got %q", data)
+	}
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"json-response-generator/internal/models"
+)
+
+// seriLists are the lists numbered from 1 by their seri field
+var seriLists = []struct{ list, field string }{
+	{"barang", "seriBarang"},
+	{"entitas", "seriEntitas"},
+	{"kemasan", "seriKemasan"},
+	{"kontainer", "seriKontainer"},
+	{"dokumen", "seriDokumen"},
+	{"pengangkut", "seriPengangkut"},
+	{"bankDevisa", "seriBank"},
+}
+
+// headerTotals are the header fields holding the sum of the same field of
+// the goods
+var headerTotals = []string{"fob", "freight", "asuransi", "cif", "hargaPenyerahan", "bruto", "netto"}
+
+// deriver computes fields of a declaration decoded to JSON values and records
+// what it changes
+type deriver struct {
+	fields  map[string]interface{}
+	changes []models.DerivedChange
+}
+
+// Derive fills the fields of a declaration that follow from others: seri
+// numbering, the CIF of goods from FOB, freight and insurance, their
+// cifRupiah, the header totals of the goods and jumlahKontainer. It returns
+// the derived declaration and every value changed.
+func (jg *JsonGenerator) Derive(declaration models.Declaration) (models.Declaration, []models.DerivedChange, error) {
+	data, err := json.Marshal(declaration)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal declaration: %w", err)
+	}
+
+	d := &deriver{changes: []models.DerivedChange{}}
+	if err := json.Unmarshal(data, &d.fields); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode declaration: %w", err)
+	}
+
+	d.numberSeri()
+	d.deriveCif()
+	d.deriveCifRupiah()
+	d.deriveTotals()
+
+	if len(d.changes) == 0 {
+		return declaration, d.changes, nil
+	}
+
+	data, err = json.Marshal(d.fields)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal derived declaration: %w", err)
+	}
+	derived, err := models.DecodeDeclaration(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode derived declaration: %w", err)
+	}
+	return derived, d.changes, nil
+}
+
+// numberSeri numbers every list from 1 in order and updates the references
+// goods make to documents (barangDokumen) and owners (barangPemilik)
+func (d *deriver) numberSeri() {
+	renumbered := make(map[string]map[string]int)
+	for _, seri := range seriLists {
+		if _, exists := d.fields[seri.list]; !exists {
+			continue
+		}
+		mapping := make(map[string]int)
+		for i, entry := range objects(d.fields[seri.list]) {
+			if _, exists := mapping[fmt.Sprint(entry[seri.field])]; !exists {
+				mapping[fmt.Sprint(entry[seri.field])] = i + 1
+			}
+			d.set(entry, seri.field, float64(i+1), fmt.Sprintf("%s[%d].%s", seri.list, i, seri.field), "numbered in order")
+		}
+		renumbered[seri.list] = mapping
+	}
+
+	for i, barang := range objects(d.fields["barang"]) {
+		seriBarang := barang["seriBarang"]
+		for _, nested := range []string{"barangTarif", "barangVd"} {
+			for j, entry := range objects(barang[nested]) {
+				if _, exists := entry["seriBarang"]; exists {
+					d.set(entry, "seriBarang", seriBarang, fmt.Sprintf("barang[%d].%s[%d].seriBarang", i, nested, j), "seriBarang of the goods")
+				}
+			}
+		}
+		for j, entry := range objects(barang["barangDokumen"]) {
+			if seri, exists := renumbered["dokumen"][fmt.Sprint(entry["seriDokumen"])]; exists {
+				d.set(entry, "seriDokumen", fmt.Sprint(seri), fmt.Sprintf("barang[%d].barangDokumen[%d].seriDokumen", i, j), "dokumen renumbered")
+			}
+		}
+		for j, entry := range objects(barang["barangPemilik"]) {
+			if seri, exists := renumbered["entitas"][fmt.Sprint(entry["seriEntitas"])]; exists {
+				d.set(entry, "seriEntitas", float64(seri), fmt.Sprintf("barang[%d].barangPemilik[%d].seriEntitas", i, j), "entitas renumbered")
+			}
+		}
+	}
+}
+
+// deriveCif sets the CIF of goods priced without freight or insurance to
+// their FOB plus freight plus insurance
+func (d *deriver) deriveCif() {
+	incoterm, _ := d.fields["kodeIncoterm"].(string)
+	// Prices including freight and insurance are taken as the declared CIF
+	if incotermCosts[strings.ToUpper(incoterm)].insurance {
+		return
+	}
+
+	for i, barang := range objects(d.fields["barang"]) {
+		if _, exists := barang["cif"]; !exists {
+			continue
+		}
+		fob := number(barang["fob"])
+		if fob <= 0 {
+			continue
+		}
+		cif := roundSen(fob + number(barang["freight"]) + number(barang["asuransi"]))
+		d.setAmount(barang, "cif", cif, fmt.Sprintf("barang[%d].cif", i), "fob + freight + asuransi")
+	}
+}
+
+// deriveCifRupiah sets the cifRupiah of goods to their CIF times the NDPBM,
+// taking the header NDPBM for goods without one
+func (d *deriver) deriveCifRupiah() {
+	headerNdpbm := number(d.fields["ndpbm"])
+	for i, barang := range objects(d.fields["barang"]) {
+		if _, exists := barang["cifRupiah"]; !exists {
+			continue
+		}
+		ndpbm := number(barang["ndpbm"])
+		if ndpbm <= 0 && headerNdpbm > 0 {
+			ndpbm = headerNdpbm
+			d.setAmount(barang, "ndpbm", ndpbm, fmt.Sprintf("barang[%d].ndpbm", i), "header ndpbm")
+		}
+		if ndpbm <= 0 {
+			continue
+		}
+		cifRupiah := roundSen(number(barang["cif"]) * ndpbm)
+		d.setAmount(barang, "cifRupiah", cifRupiah, fmt.Sprintf("barang[%d].cifRupiah", i), "cif x ndpbm")
+	}
+}
+
+// deriveTotals sets the header totals the goods carry values for, and the
+// number of containers
+func (d *deriver) deriveTotals() {
+	barang := objects(d.fields["barang"])
+	for _, field := range headerTotals {
+		if _, exists := d.fields[field]; !exists {
+			continue
+		}
+		total, carried := 0.0, false
+		for _, b := range barang {
+			value := number(b[field])
+			total += value
+			carried = carried || value != 0
+		}
+		// Header values the goods do not carry, e.g. freight of the whole
+		// shipment, are left as declared
+		if carried {
+			d.setAmount(d.fields, field, roundSen(total), field, "sum of barang")
+		}
+	}
+
+	if _, exists := d.fields["jumlahKontainer"]; exists {
+		if _, listed := d.fields["kontainer"]; listed {
+			d.set(d.fields, "jumlahKontainer", float64(len(objects(d.fields["kontainer"]))), "jumlahKontainer", "number of kontainer")
+		}
+	}
+}
+
+// set changes a value when it differs and records the change
+func (d *deriver) set(entry map[string]interface{}, key string, value interface{}, field, reason string) {
+	if fmt.Sprint(entry[key]) == fmt.Sprint(value) {
+		return
+	}
+	d.changes = append(d.changes, models.DerivedChange{Field: field, From: entry[key], To: value, Reason: reason})
+	entry[key] = value
+}
+
+// setAmount changes an amount when it differs by more than a sen
+func (d *deriver) setAmount(entry map[string]interface{}, key string, value float64, field, reason string) {
+	if math.Abs(number(entry[key])-value) < 0.005 {
+		return
+	}
+	d.set(entry, key, value, field, reason)
+}
+
+// number returns a decoded JSON number, zero for anything else
+func number(value interface{}) float64 {
+	n, _ := value.(float64)
+	return n
+}
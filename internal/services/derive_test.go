@@ -0,0 +1,61 @@
+package services
+
+import (
+	"testing"
+
+	"json-response-generator/internal/models"
+)
+
+func TestDerive(t *testing.T) {
+	sample := NewJsonGenerator().GenerateSampleData()
+	sample.KodeIncoterm = "FOB"
+	sample.Ndpbm = 15000
+	sample.Barang[0].Fob, sample.Barang[0].Freight, sample.Barang[0].Asuransi = 900, 80, 20
+	sample.Barang[0].Ndpbm = 0
+	sample.Barang[1].SeriBarang = 7
+	sample.Barang[1].BarangTarif[0].SeriBarang = 7
+	sample.Kontainer = append(sample.Kontainer, sample.Kontainer[0])
+	sample.Dokumen[0].SeriDokumen = 5
+	sample.Barang[0].BarangDokumen = []models.BarangDokumen{{SeriDokumen: "5"}}
+
+	declaration, changes, err := NewJsonGenerator().Derive(sample)
+	if err != nil {
+		t.Fatalf("Failed to derive: %v", err)
+	}
+	derived := declaration.(*models.ResponseData)
+
+	if derived.Barang[0].Cif != 1000 || derived.Barang[0].CifRupiah != 15000000 {
+		t.Errorf("Expected a CIF of 1000 and 15,000,000 rupiah, got %.2f and %.2f", derived.Barang[0].Cif, derived.Barang[0].CifRupiah)
+	}
+	if derived.Cif != roundSen(1000+derived.Barang[1].Cif) {
+		t.Errorf("Expected the header CIF to add up the goods, got %.2f", derived.Cif)
+	}
+	if derived.Barang[1].SeriBarang != 2 || derived.Barang[1].BarangTarif[0].SeriBarang != 2 {
+		t.Errorf("Expected the second barang and its tarif numbered 2, got %+v", derived.Barang[1])
+	}
+	if derived.Dokumen[0].SeriDokumen != 1 || derived.Barang[0].BarangDokumen[0].SeriDokumen != "1" {
+		t.Errorf("Expected the dokumen reference to follow the renumbering, got %+v", derived.Barang[0].BarangDokumen)
+	}
+	if derived.JumlahKontainer != len(derived.Kontainer) {
+		t.Errorf("Expected jumlahKontainer %d, got %d", len(derived.Kontainer), derived.JumlahKontainer)
+	}
+
+	reported := make(map[string]models.DerivedChange)
+	for _, change := range changes {
+		reported[change.Field] = change
+	}
+	for _, field := range []string{"barang[0].cif", "barang[0].ndpbm", "barang[0].cifRupiah", "cif", "barang[1].seriBarang", "barang[0].barangDokumen[0].seriDokumen", "jumlahKontainer"} {
+		if _, ok := reported[field]; !ok {
+			t.Errorf("Expected a change of %s, got %+v", field, changes)
+		}
+	}
+	if change := reported["barang[1].seriBarang"]; change.From != float64(7) || change.To != float64(2) {
+		t.Errorf("Expected seriBarang to change from 7 to 2, got %+v", change)
+	}
+
+	// A declaration already consistent is left alone
+	_, changes, err = NewJsonGenerator().Derive(derived)
+	if err != nil || len(changes) != 0 {
+		t.Errorf("Expected no further changes, got %+v, %v", changes, err)
+	}
+}
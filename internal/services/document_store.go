@@ -0,0 +1,371 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"json-response-generator/internal/models"
+)
+
+var (
+	// ErrDocumentNotFound is returned for unknown document IDs
+	ErrDocumentNotFound = errors.New("document not found")
+	// ErrInvalidTransition is returned when the workflow does not allow a state change
+	ErrInvalidTransition = errors.New("invalid workflow transition")
+	// ErrTransitionForbidden is returned when the caller may not perform a state change
+	ErrTransitionForbidden = errors.New("transition not permitted")
+)
+
+// workflowRule describes who may move a document from one state to another
+type workflowRule struct {
+	role           string
+	fourEyes       bool // the actor must not have prepared the document
+	requireComment bool
+}
+
+// workflow lists the allowed transitions by current and target state.
+// Submission is not listed, it only happens through ClaimSubmission and
+// MarkSubmitted. An administrator can release a send that never finished,
+// e.g. after a crash.
+var workflow = map[string]map[string]workflowRule{
+	models.DocumentStatusDraft: {
+		models.DocumentStatusReadyForReview: {role: models.RolePreparer},
+	},
+	models.DocumentStatusReadyForReview: {
+		models.DocumentStatusApproved: {role: models.RoleApprover, fourEyes: true},
+		models.DocumentStatusRejected: {role: models.RoleApprover, requireComment: true},
+		models.DocumentStatusDraft:    {role: models.RolePreparer},
+	},
+	models.DocumentStatusRejected: {
+		models.DocumentStatusDraft: {role: models.RolePreparer},
+	},
+	models.DocumentStatusSubmitting: {
+		models.DocumentStatusApproved: {role: models.RoleAdmin, requireComment: true},
+	},
+	models.DocumentStatusSubmitted: {
+		models.DocumentStatusResponded: {role: models.RoleApprover},
+	},
+}
+
+// DocumentStore keeps declarations and their approval workflow state,
+// optionally persisted to a JSON file
+type DocumentStore struct {
+	path      string
+	documents map[string]*models.Document
+	mutex     sync.RWMutex
+}
+
+// NewDocumentStore opens the store at path. An empty path keeps documents in memory only.
+func NewDocumentStore(path string) (*DocumentStore, error) {
+	store := &DocumentStore{
+		path:      path,
+		documents: make(map[string]*models.Document),
+	}
+
+	if path == "" {
+		return store, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read document store %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(data, &store.documents); err != nil {
+		return nil, fmt.Errorf("failed to parse document store %s: %w", path, err)
+	}
+
+	return store, nil
+}
+
+// Create stores a declaration of a tenant as a new draft. The tenant is empty in single-tenant mode.
+func (ds *DocumentStore) Create(data models.Declaration, principal *models.Principal, tenantID string) (*models.Document, error) {
+	return ds.insert(data, principal, tenantID, models.DocumentStatusDraft, nil)
+}
+
+// RecordSubmission stores a declaration sent to CEISA without going through
+// the approval workflow, so that it counts like an approved submission
+func (ds *DocumentStore) RecordSubmission(data models.Declaration, principal *models.Principal, tenantID string, response map[string]interface{}) (*models.Document, error) {
+	return ds.insert(data, principal, tenantID, models.DocumentStatusSubmitted, response)
+}
+
+// insert stores a declaration as a new document in the given state
+func (ds *DocumentStore) insert(data models.Declaration, principal *models.Principal, tenantID, status string, response map[string]interface{}) (*models.Document, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("failed to generate document id: %w", err)
+	}
+
+	now := time.Now()
+	header := data.Header()
+	document := &models.Document{
+		ID:          hex.EncodeToString(buf),
+		TenantID:    tenantID,
+		NomorAju:    *header.NomorAju,
+		KodeDokumen: *header.KodeDokumen,
+		Status:      status,
+		Data:        models.DeclarationData{Declaration: data},
+		CreatedBy:   principal.Username,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Response:    response,
+		History: []models.DocumentTransition{{
+			To:   status,
+			User: principal.Username,
+			Role: principal.Role,
+			At:   now,
+		}},
+	}
+
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	ds.documents[document.ID] = document
+	if err := ds.writeLocked(); err != nil {
+		delete(ds.documents, document.ID)
+		return nil, err
+	}
+
+	return copyDocument(document), nil
+}
+
+// Get returns a copy of a document
+func (ds *DocumentStore) Get(id string) (*models.Document, error) {
+	ds.mutex.RLock()
+	defer ds.mutex.RUnlock()
+
+	document, exists := ds.documents[id]
+	if !exists {
+		return nil, ErrDocumentNotFound
+	}
+
+	return copyDocument(document), nil
+}
+
+// List returns the documents of a tenant, optionally filtered by status, newest first
+func (ds *DocumentStore) List(tenantID, status string) []*models.Document {
+	ds.mutex.RLock()
+	defer ds.mutex.RUnlock()
+
+	documents := make([]*models.Document, 0, len(ds.documents))
+	for _, document := range ds.documents {
+		if document.TenantID == tenantID && (status == "" || document.Status == status) {
+			documents = append(documents, copyDocument(document))
+		}
+	}
+
+	sort.Slice(documents, func(i, j int) bool {
+		return documents[i].CreatedAt.After(documents[j].CreatedAt)
+	})
+
+	return documents
+}
+
+// Update replaces the declaration data of a draft
+func (ds *DocumentStore) Update(id string, data models.Declaration, principal *models.Principal) (*models.Document, error) {
+	if !principal.HasRole(models.RolePreparer) {
+		return nil, fmt.Errorf("%w: editing requires role %s", ErrTransitionForbidden, models.RolePreparer)
+	}
+
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	document, exists := ds.documents[id]
+	if !exists {
+		return nil, ErrDocumentNotFound
+	}
+	if document.Status != models.DocumentStatusDraft {
+		return nil, fmt.Errorf("%w: only drafts can be edited, document is %s", ErrInvalidTransition, document.Status)
+	}
+
+	// The editor counts as a preparer for the four-eyes rule
+	previous := copyDocument(document)
+	now := time.Now()
+	header := data.Header()
+	document.Data = models.DeclarationData{Declaration: data}
+	document.NomorAju = *header.NomorAju
+	document.KodeDokumen = *header.KodeDokumen
+	document.UpdatedAt = now
+	document.History = append(document.History, models.DocumentTransition{
+		From:   document.Status,
+		To:     document.Status,
+		Action: models.DocumentActionEdited,
+		User:   principal.Username,
+		Role:   principal.Role,
+		At:     now,
+	})
+
+	if err := ds.writeLocked(); err != nil {
+		*document = *previous
+		return nil, err
+	}
+
+	return copyDocument(document), nil
+}
+
+// Transition moves a document to another workflow state on behalf of principal
+func (ds *DocumentStore) Transition(id, to string, principal *models.Principal, comment string) (*models.Document, error) {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	document, exists := ds.documents[id]
+	if !exists {
+		return nil, ErrDocumentNotFound
+	}
+
+	rule, allowed := workflow[document.Status][to]
+	if !allowed {
+		return nil, fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, document.Status, to)
+	}
+	if !principal.HasRole(rule.role) {
+		return nil, fmt.Errorf("%w: %s -> %s requires role %s", ErrTransitionForbidden, document.Status, to, rule.role)
+	}
+	if rule.fourEyes && preparedBy(document, principal.Username) {
+		return nil, fmt.Errorf("%w: a declaration must be approved by someone other than its preparer", ErrTransitionForbidden)
+	}
+	if rule.requireComment && comment == "" {
+		return nil, fmt.Errorf("%w: %s requires a comment", ErrInvalidTransition, to)
+	}
+
+	return ds.applyLocked(document, to, principal, comment, nil)
+}
+
+// ClaimSubmission moves an approved document to submitting before it is sent
+// to CEISA, so concurrent sends cannot submit it twice
+func (ds *DocumentStore) ClaimSubmission(id string, principal *models.Principal) (*models.Document, error) {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	document, exists := ds.documents[id]
+	if !exists {
+		return nil, ErrDocumentNotFound
+	}
+	if document.Status != models.DocumentStatusApproved {
+		return nil, fmt.Errorf("%w: only approved declarations can be submitted, document is %s", ErrInvalidTransition, document.Status)
+	}
+
+	return ds.applyLocked(document, models.DocumentStatusSubmitting, principal, "", nil)
+}
+
+// ReleaseSubmission returns a claimed document to approved after a failed send
+func (ds *DocumentStore) ReleaseSubmission(id string, principal *models.Principal, reason string) (*models.Document, error) {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	document, exists := ds.documents[id]
+	if !exists {
+		return nil, ErrDocumentNotFound
+	}
+	if document.Status != models.DocumentStatusSubmitting {
+		return nil, fmt.Errorf("%w: document is %s, not %s", ErrInvalidTransition, document.Status, models.DocumentStatusSubmitting)
+	}
+
+	return ds.applyLocked(document, models.DocumentStatusApproved, principal, reason, nil)
+}
+
+// MarkSubmitted records that a claimed document was sent to CEISA
+func (ds *DocumentStore) MarkSubmitted(id string, principal *models.Principal, response map[string]interface{}) (*models.Document, error) {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	document, exists := ds.documents[id]
+	if !exists {
+		return nil, ErrDocumentNotFound
+	}
+	if document.Status != models.DocumentStatusSubmitting {
+		return nil, fmt.Errorf("%w: only claimed declarations can be marked submitted, document is %s", ErrInvalidTransition, document.Status)
+	}
+
+	return ds.applyLocked(document, models.DocumentStatusSubmitted, principal, "", response)
+}
+
+// RecordResponse stores the CEISA response to a submitted document
+func (ds *DocumentStore) RecordResponse(id string, principal *models.Principal, response map[string]interface{}, comment string) (*models.Document, error) {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	document, exists := ds.documents[id]
+	if !exists {
+		return nil, ErrDocumentNotFound
+	}
+
+	if _, allowed := workflow[document.Status][models.DocumentStatusResponded]; !allowed {
+		return nil, fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, document.Status, models.DocumentStatusResponded)
+	}
+	if !principal.HasRole(models.RoleApprover) {
+		return nil, fmt.Errorf("%w: recording responses requires role %s", ErrTransitionForbidden, models.RoleApprover)
+	}
+
+	return ds.applyLocked(document, models.DocumentStatusResponded, principal, comment, response)
+}
+
+// applyLocked changes the document state, records the transition and persists the store
+func (ds *DocumentStore) applyLocked(document *models.Document, to string, principal *models.Principal, comment string, response map[string]interface{}) (*models.Document, error) {
+	previous := copyDocument(document)
+	now := time.Now()
+
+	document.History = append(document.History, models.DocumentTransition{
+		From:    document.Status,
+		To:      to,
+		User:    principal.Username,
+		Role:    principal.Role,
+		At:      now,
+		Comment: comment,
+	})
+	document.Status = to
+	document.UpdatedAt = now
+	if response != nil {
+		document.Response = response
+	}
+
+	if err := ds.writeLocked(); err != nil {
+		*document = *previous
+		return nil, err
+	}
+
+	return copyDocument(document), nil
+}
+
+// writeLocked persists all documents when the store has a path
+func (ds *DocumentStore) writeLocked() error {
+	if ds.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(ds.documents, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal document store: %w", err)
+	}
+
+	return writeFileAtomic(ds.path, data)
+}
+
+// preparedBy reports whether username created, edited or sent the document for review
+func preparedBy(document *models.Document, username string) bool {
+	if document.CreatedBy == username {
+		return true
+	}
+	for _, transition := range document.History {
+		prepared := transition.To == models.DocumentStatusReadyForReview || transition.Action == models.DocumentActionEdited
+		if prepared && transition.User == username {
+			return true
+		}
+	}
+	return false
+}
+
+func copyDocument(document *models.Document) *models.Document {
+	clone := *document
+	clone.History = append([]models.DocumentTransition(nil), document.History...)
+	return &clone
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"json-response-generator/internal/models"
+)
+
+func TestDocumentWorkflow(t *testing.T) {
+	store, err := NewDocumentStore("")
+	if err != nil {
+		t.Fatalf("NewDocumentStore failed: %v", err)
+	}
+
+	preparer := &models.Principal{Username: "alice", Role: models.RolePreparer}
+	approver := &models.Principal{Username: "bob", Role: models.RoleApprover}
+	selfApprover := &models.Principal{Username: "alice", Role: models.RoleApprover}
+
+	document, err := store.Create(&models.ResponseData{NomorAju: "000020010203202401010000001"}, preparer, "")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if document.Status != models.DocumentStatusDraft {
+		t.Fatalf("Expected draft, got %s", document.Status)
+	}
+
+	if _, err := store.Transition(document.ID, models.DocumentStatusApproved, approver, ""); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Expected drafts not to be approvable, got %v", err)
+	}
+
+	if _, err := store.Transition(document.ID, models.DocumentStatusReadyForReview, preparer, ""); err != nil {
+		t.Fatalf("Submitting for review failed: %v", err)
+	}
+
+	if _, err := store.Transition(document.ID, models.DocumentStatusApproved, preparer, ""); !errors.Is(err, ErrTransitionForbidden) {
+		t.Errorf("Expected preparer role to be unable to approve, got %v", err)
+	}
+	if _, err := store.Transition(document.ID, models.DocumentStatusApproved, selfApprover, ""); !errors.Is(err, ErrTransitionForbidden) {
+		t.Errorf("Expected four-eyes rule to reject self approval, got %v", err)
+	}
+
+	if _, err := store.Transition(document.ID, models.DocumentStatusRejected, approver, ""); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Expected rejection without comment to fail, got %v", err)
+	}
+	rejected, err := store.Transition(document.ID, models.DocumentStatusRejected, approver, "Wrong HS code")
+	if err != nil {
+		t.Fatalf("Rejection failed: %v", err)
+	}
+	last := rejected.History[len(rejected.History)-1]
+	if last.Comment != "Wrong HS code" || last.User != "bob" || last.At.IsZero() {
+		t.Errorf("Unexpected rejection record %+v", last)
+	}
+
+	if _, err := store.Transition(document.ID, models.DocumentStatusDraft, preparer, ""); err != nil {
+		t.Fatalf("Reopening failed: %v", err)
+	}
+	if _, err := store.Update(document.ID, &models.ResponseData{NomorAju: "000020010203202401010000002"}, preparer); err != nil {
+		t.Fatalf("Update of draft failed: %v", err)
+	}
+	if _, err := store.Transition(document.ID, models.DocumentStatusReadyForReview, preparer, ""); err != nil {
+		t.Fatalf("Submitting for review failed: %v", err)
+	}
+	if _, err := store.Update(document.ID, &models.ResponseData{}, preparer); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Expected documents under review to be read-only, got %v", err)
+	}
+
+	if _, err := store.Transition(document.ID, models.DocumentStatusApproved, approver, ""); err != nil {
+		t.Fatalf("Approval failed: %v", err)
+	}
+	if _, err := store.Transition(document.ID, models.DocumentStatusSubmitted, approver, ""); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Expected submission to only happen through MarkSubmitted, got %v", err)
+	}
+	if _, err := store.MarkSubmitted(document.ID, approver, nil); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Expected submission to need a claim, got %v", err)
+	}
+	if _, err := store.ClaimSubmission(document.ID, approver); err != nil {
+		t.Fatalf("ClaimSubmission failed: %v", err)
+	}
+	if _, err := store.ClaimSubmission(document.ID, approver); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Expected a claimed document not to be claimed again, got %v", err)
+	}
+	if _, err := store.ReleaseSubmission(document.ID, approver, "submission failed"); err != nil {
+		t.Fatalf("ReleaseSubmission failed: %v", err)
+	}
+	if _, err := store.ClaimSubmission(document.ID, approver); err != nil {
+		t.Fatalf("ClaimSubmission after release failed: %v", err)
+	}
+	if _, err := store.MarkSubmitted(document.ID, approver, map[string]interface{}{"status": "OK"}); err != nil {
+		t.Fatalf("MarkSubmitted failed: %v", err)
+	}
+
+	responded, err := store.RecordResponse(document.ID, approver, map[string]interface{}{"respon": "SPPB"}, "")
+	if err != nil {
+		t.Fatalf("RecordResponse failed: %v", err)
+	}
+	if responded.Status != models.DocumentStatusResponded || responded.Response["respon"] != "SPPB" {
+		t.Errorf("Unexpected document after response: %+v", responded)
+	}
+	if responded.NomorAju != "000020010203202401010000002" {
+		t.Errorf("Expected updated nomor aju, got %s", responded.NomorAju)
+	}
+
+	expected := []string{
+		models.DocumentStatusDraft,
+		models.DocumentStatusReadyForReview,
+		models.DocumentStatusRejected,
+		models.DocumentStatusDraft,
+		models.DocumentStatusDraft, // edited
+		models.DocumentStatusReadyForReview,
+		models.DocumentStatusApproved,
+		models.DocumentStatusSubmitting,
+		models.DocumentStatusApproved, // released
+		models.DocumentStatusSubmitting,
+		models.DocumentStatusSubmitted,
+		models.DocumentStatusResponded,
+	}
+	if len(responded.History) != len(expected) {
+		t.Fatalf("Expected %d transitions, got %d", len(expected), len(responded.History))
+	}
+	for i, status := range expected {
+		if responded.History[i].To != status {
+			t.Errorf("Transition %d: expected %s, got %s", i, status, responded.History[i].To)
+		}
+	}
+}
+
+func TestEditorCannotApprove(t *testing.T) {
+	store, _ := NewDocumentStore("")
+	alice := &models.Principal{Username: "alice", Role: models.RolePreparer}
+	bob := &models.Principal{Username: "bob", Role: models.RoleApprover}
+	carol := &models.Principal{Username: "carol", Role: models.RoleApprover}
+
+	document, _ := store.Create(&models.ResponseData{NomorAju: "000020010203202401010000001"}, alice, "")
+	edited, err := store.Update(document.ID, &models.ResponseData{NomorAju: "000020010203202401010000002"}, bob)
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	last := edited.History[len(edited.History)-1]
+	if last.Action != models.DocumentActionEdited || last.User != "bob" {
+		t.Errorf("Expected the edit to be recorded, got %+v", last)
+	}
+
+	if _, err := store.Transition(document.ID, models.DocumentStatusReadyForReview, alice, ""); err != nil {
+		t.Fatalf("Submitting for review failed: %v", err)
+	}
+	if _, err := store.Transition(document.ID, models.DocumentStatusApproved, bob, ""); !errors.Is(err, ErrTransitionForbidden) {
+		t.Errorf("Expected the editor to be unable to approve, got %v", err)
+	}
+	if _, err := store.Transition(document.ID, models.DocumentStatusApproved, carol, ""); err != nil {
+		t.Errorf("Approval by an uninvolved approver failed: %v", err)
+	}
+}
+
+func TestDocumentStorePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "documents.json")
+
+	store, err := NewDocumentStore(path)
+	if err != nil {
+		t.Fatalf("NewDocumentStore failed: %v", err)
+	}
+
+	preparer := &models.Principal{Username: "alice", Role: models.RolePreparer}
+	document, err := store.Create(&models.ResponseData{NomorAju: "000020010203202401010000001"}, preparer, "")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := store.Transition(document.ID, models.DocumentStatusReadyForReview, preparer, ""); err != nil {
+		t.Fatalf("Transition failed: %v", err)
+	}
+
+	reopened, err := NewDocumentStore(path)
+	if err != nil {
+		t.Fatalf("Reopening store failed: %v", err)
+	}
+
+	restored, err := reopened.Get(document.ID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if restored.Status != models.DocumentStatusReadyForReview || len(restored.History) != 2 {
+		t.Errorf("Unexpected restored document %+v", restored)
+	}
+	if len(reopened.List("", models.DocumentStatusReadyForReview)) != 1 || len(reopened.List("", models.DocumentStatusApproved)) != 0 {
+		t.Error("Expected List to filter by status")
+	}
+}
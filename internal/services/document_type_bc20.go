@@ -0,0 +1,110 @@
+package services
+
+import (
+	_ "embed"
+
+	"json-response-generator/internal/models"
+)
+
+//go:embed schemas/bc20.json
+var bc20Schema []byte
+
+// init registers BC 2.0, the import declaration (Pemberitahuan Impor Barang)
+func init() {
+	RegisterDocumentType(&DocumentType{
+		Code:      "20",
+		Name:      "BC 2.0",
+		Declarant: KodeEntitasImportir,
+		New:       func() models.Declaration { return &models.ResponseData{} },
+		Sample:    func() models.Declaration { return NewJsonGenerator().GenerateSampleData() },
+		Schema:    bc20Schema,
+		Sheets: []models.SheetLayout{
+			{Name: HeaderSheet, Columns: getMainDataColumns()},
+			{Name: "Barang", Field: "barang", Columns: getBarangColumns()},
+			{Name: "Entitas", Field: "entitas", Columns: getEntitasColumns()},
+			{Name: "Kemasan", Field: "kemasan", Columns: getKemasanColumns()},
+			{Name: "Kontainer", Field: "kontainer", Columns: getKontainerColumns()},
+			{Name: "Dokumen", Field: "dokumen", Columns: getDokumenColumns()},
+			{Name: "Pengangkut", Field: "pengangkut", Columns: getPengangkutColumns()},
+		},
+		Rules: []ValidationRule{
+			RequireFields(
+				"asalData", "disclaimer", "kodeJenisImpor", "flagVd", "jabatanTtd",
+				"kodeCaraBayar", "kodeKantor", "kodePelMuat", "kodePelTujuan", "kodeTps",
+				"kodeTutupPu", "kodeValuta", "kotaTtd", "namaTtd", "nomorAju",
+				"tanggalTiba", "tanggalTtd",
+			),
+			RequireItems("barang", "entitas"),
+			RequireEntitas(KodeEntitasImportir, "importer"),
+		},
+	})
+}
+
+// Column definitions for each sheet
+func getMainDataColumns() []string {
+	return []string{
+		"asalData", "disclaimer", "flagVd", "idPengguna", "cif", "bruto", "netto",
+		"ndpbm", "vd", "nomorAju", "nomorBc11", "tanggalAju", "tanggalBc11",
+		"tanggalTiba", "tanggalTtd", "namaTtd", "jabatanTtd", "kotaTtd",
+		"asuransi", "biayaPengurang", "biayaTambahan", "fob", "freight",
+		"hargaPenyerahan", "jumlahTandaPengaman", "nilaiBarang", "nilaiIncoterm",
+		"nilaiMaklon", "seri", "totalDanaSawit", "volume", "jumlahKontainer",
+		"kodeAsuransi", "kodeCaraBayar", "kodeDokumen", "kodeIncoterm",
+		"kodeJenisImpor", "kodeJenisNilai", "kodeJenisProsedur", "kodeKantor",
+		"kodePelMuat", "kodePelTransit", "kodePelTujuan", "kodeTps",
+		"kodeTutupPu", "kodeValuta", "posBc11", "subPosBc11",
+	}
+}
+
+func getBarangColumns() []string {
+	return []string{
+		"uraian", "merk", "tipe", "ukuran", "spesifikasiLain", "kodeHs",
+		"cif", "hargaPenyerahan", "hargaSatuan", "jumlahSatuan", "kodeBarang",
+		"kondisiBarang", "negaraAsal", "deskripsiLain", "bruto", "netto",
+		"volume", "jumlahKemasan", "kodeJenisKemasan", "kodeSatuanBarang",
+		"posTarif", "seriBarang", "tahunPembuatan", "asuransi", "diskon",
+		"fob", "freight", "hargaEkspor", "hargaPatokan", "hargaPerolehan",
+		"hjeCukai", "isiPerKemasan", "jumlahBahanBaku", "jumlahDilekatkan",
+		"jumlahPitaCukai", "jumlahRealisasi", "kapasitasSilinder",
+		"kodeKondisiBarang", "kodeNegaraAsal", "ndpbm", "nilaiBarang",
+		"nilaiDanaSawit", "nilaiDevisa", "nilaiTambah", "pernyataanLartas",
+		"persentaseImpor", "saldoAkhir", "saldoAwal", "seriBarangDokAsal",
+		"seriIjin", "tarifCukai", "cifRupiah",
+	}
+}
+
+func getEntitasColumns() []string {
+	return []string{
+		"jenisEntitas", "namaEntitas", "alamatEntitas", "negaraEntitas",
+		"kodeEntitas", "statusApi", "nib", "keterangan", "seriEntitas",
+		"kodeJenisApi", "kodeJenisIdentitas", "kodeStatus", "nibEntitas",
+		"nomorIdentitas", "kodeNegara",
+	}
+}
+
+func getKemasanColumns() []string {
+	return []string{
+		"jumlahKemasan", "kodeJenisKemasan", "merkKemasan", "seriKemasan",
+	}
+}
+
+func getKontainerColumns() []string {
+	return []string{
+		"kodeJenisKontainer", "kodeTipeKontainer", "kodeUkuranKontainer",
+		"nomorKontainer", "seriKontainer",
+	}
+}
+
+func getDokumenColumns() []string {
+	return []string{
+		"idDokumen", "kodeDokumen", "kodeFasilitas", "nomorDokumen",
+		"seriDokumen", "tanggalDokumen", "namaFasilitas",
+	}
+}
+
+func getPengangkutColumns() []string {
+	return []string{
+		"kodeBendera", "namaPengangkut", "nomorPengangkut", "kodeCaraAngkut",
+		"seriPengangkut",
+	}
+}
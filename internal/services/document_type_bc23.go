@@ -0,0 +1,216 @@
+package services
+
+import (
+	_ "embed"
+
+	"json-response-generator/internal/models"
+)
+
+// Parties of a bonded-zone declaration by kodeEntitas
+const (
+	KodeEntitasPengusahaTpb = "3"
+	KodeEntitasPemasok      = "5"
+	KodeEntitasPenerima     = "8"
+)
+
+// KodeFasilitasDitangguhkan is the kodeFasilitasTarif of suspended duties
+const KodeFasilitasDitangguhkan = "3"
+
+//go:embed schemas/bc23.json
+var bc23Schema []byte
+
+// init registers BC 2.3, the import into a bonded storage area
+func init() {
+	RegisterDocumentType(&DocumentType{
+		Code:      "23",
+		Name:      "BC 2.3",
+		Declarant: KodeEntitasPengusahaTpb,
+		New:       func() models.Declaration { return &models.BondedImportDeclaration{} },
+		Sample:    func() models.Declaration { return NewJsonGenerator().GenerateBondedImportSampleData() },
+		Schema:    bc23Schema,
+		Sheets:    bondedSheets(getBondedImportMainDataColumns()),
+		Rules: []ValidationRule{
+			RequireFields(
+				"asalData", "disclaimer", "jabatanTtd", "kodeJenisTpb", "kodeKantor",
+				"kodeKantorBongkar", "kodePelBongkar", "kodePelMuat", "kodeTps",
+				"kodeTujuanTpb", "kodeTutupPu", "kodeValuta", "kotaTtd", "namaTtd",
+				"nomorAju", "tanggalTiba", "tanggalTtd",
+			),
+			RequireItems("barang", "entitas", "dokumen"),
+			RequireEntitas(KodeEntitasPengusahaTpb, "bonded zone operator"),
+			RequireEntitas(KodeEntitasPemasok, "supplier"),
+			RequireDokumenReferences(),
+			RequireNoDutyPayment(),
+		},
+	})
+}
+
+// bondedSheets returns the Excel layout shared by the bonded-zone documents.
+// Source document references and suspended levies of the goods have a sheet
+// each, linked to the goods by seriBarang.
+func bondedSheets(mainDataColumns []string) []models.SheetLayout {
+	return []models.SheetLayout{
+		{Name: HeaderSheet, Columns: mainDataColumns},
+		{Name: "Barang", Field: "barang", Columns: getBarangColumns()},
+		{Name: "BarangDokumen", Field: "barangDokumen", Parent: "barang", Key: "seriBarang",
+			Columns: []string{"seriBarang", "seriDokumen"}},
+		{Name: "BarangTarif", Field: "barangTarif", Parent: "barang", Key: "seriBarang",
+			Columns: getBarangTarifColumns()},
+		{Name: "Entitas", Field: "entitas", Columns: getEntitasColumns()},
+		{Name: "Kemasan", Field: "kemasan", Columns: getKemasanColumns()},
+		{Name: "Kontainer", Field: "kontainer", Columns: getKontainerColumns()},
+		{Name: "Dokumen", Field: "dokumen", Columns: getDokumenColumns()},
+		{Name: "Pengangkut", Field: "pengangkut", Columns: getPengangkutColumns()},
+	}
+}
+
+// suspendDuties moves the levies of sample goods from payment to suspension
+func suspendDuties(barang []models.Barang) {
+	for i := range barang {
+		for j := range barang[i].BarangTarif {
+			tarif := &barang[i].BarangTarif[j]
+			tarif.KodeFasilitasTarif = KodeFasilitasDitangguhkan
+			tarif.NilaiFasilitas = tarif.NilaiBayar
+			tarif.NilaiBayar = 0
+		}
+	}
+}
+
+// GenerateBondedImportSampleData generates a sample BC 2.3 declaration
+func (jg *JsonGenerator) GenerateBondedImportSampleData() *models.BondedImportDeclaration {
+	nomorIdentitas := "0023456789012000"
+	kodeNegara := "CN"
+
+	barang := []models.Barang{jg.createBarang1(), jg.createBarang2()}
+	suspendDuties(barang)
+	for i := range barang {
+		barang[i].BarangDokumen = []models.BarangDokumen{{SeriDokumen: "1"}}
+	}
+
+	return &models.BondedImportDeclaration{
+		AsalData:          "S",
+		Asuransi:          0,
+		BiayaPengurang:    0,
+		BiayaTambahan:     0,
+		Bruto:             350.71,
+		Cif:               1234567.89,
+		Disclaimer:        "1",
+		Fob:               0,
+		Freight:           0,
+		HargaPenyerahan:   0,
+		IdPengguna:        "ABCDE",
+		JabatanTtd:        "MANAGER",
+		JumlahKontainer:   1,
+		KodeAsuransi:      "LN",
+		KodeDokumen:       "23",
+		KodeIncoterm:      "CIF",
+		KodeJenisTpb:      "1",
+		KodeKantor:        "050500",
+		KodeKantorBongkar: "040300",
+		KodePelBongkar:    "IDTPP",
+		KodePelMuat:       "CNSHA",
+		KodePelTransit:    "",
+		KodeTps:           "KOJA",
+		KodeTujuanTpb:     "1",
+		KodeTutupPu:       "11",
+		KodeValuta:        "CNY",
+		KotaTtd:           "BEKASI",
+		NamaTtd:           "AGUS",
+		Ndpbm:             1234.56,
+		Netto:             342.71,
+		NilaiBarang:       0,
+		NomorAju:          "00002305050020211225000001",
+		NomorBc11:         "000001",
+		PosBc11:           "0001",
+		Seri:              1,
+		SubPosBc11:        "000001",
+		TanggalAju:        jg.defaultDate,
+		TanggalBc11:       jg.defaultDate,
+		TanggalTiba:       jg.defaultDate,
+		TanggalTtd:        jg.defaultDate,
+		Volume:            0,
+		Barang:            barang,
+		Entitas: []models.Entitas{
+			{
+				AlamatEntitas:  "KAWASAN BERIKAT MM2100 BLOK A-1, BEKASI",
+				KodeEntitas:    KodeEntitasPengusahaTpb,
+				NamaEntitas:    "PT. SAMPLE KAWASAN BERIKAT",
+				NomorIdentitas: &nomorIdentitas,
+				SeriEntitas:    1,
+			},
+			{
+				AlamatEntitas: "NO. 88 PUDONG ROAD, SHANGHAI",
+				KodeEntitas:   KodeEntitasPemasok,
+				NamaEntitas:   "SAMPLE SUPPLIER CO. LTD",
+				KodeNegara:    &kodeNegara,
+				SeriEntitas:   2,
+			},
+		},
+		Kemasan: []models.Kemasan{
+			{
+				JumlahKemasan:    20,
+				KodeJenisKemasan: "BX",
+				MerkKemasan:      "SAMPLE BOX",
+				SeriKemasan:      1,
+			},
+		},
+		Kontainer: []models.Kontainer{
+			{
+				KodeJenisKontainer:  "8",
+				KodeTipeKontainer:   "1",
+				KodeUkuranKontainer: "20",
+				NomorKontainer:      "SMPL2300001",
+				SeriKontainer:       1,
+			},
+		},
+		Dokumen: []models.Dokumen{
+			{
+				IdDokumen:      "DOC001",
+				KodeDokumen:    "380",
+				KodeFasilitas:  "",
+				NomorDokumen:   "INV/TPB/001",
+				SeriDokumen:    1,
+				TanggalDokumen: jg.defaultDate,
+			},
+			{
+				IdDokumen:      "DOC002",
+				KodeDokumen:    "705",
+				KodeFasilitas:  "",
+				NomorDokumen:   "BL/TPB/001",
+				SeriDokumen:    2,
+				TanggalDokumen: jg.defaultDate,
+			},
+		},
+		Pengangkut: []models.Pengangkut{
+			{
+				KodeBendera:     "CN",
+				NamaPengangkut:  "SAMPLE SHIPPING LINE",
+				NomorPengangkut: "V.023",
+				KodeCaraAngkut:  "1",
+				SeriPengangkut:  1,
+			},
+		},
+	}
+}
+
+// Column definitions for the BC 2.3 sheets
+func getBondedImportMainDataColumns() []string {
+	return []string{
+		"asalData", "disclaimer", "idPengguna", "nomorAju", "tanggalAju",
+		"kodeDokumen", "kodeKantor", "kodeKantorBongkar", "kodeJenisTpb",
+		"kodeTujuanTpb", "kodePelMuat", "kodePelTransit", "kodePelBongkar",
+		"kodeTps", "kodeTutupPu", "nomorBc11", "tanggalBc11", "posBc11",
+		"subPosBc11", "tanggalTiba", "kodeIncoterm", "kodeValuta", "ndpbm",
+		"fob", "freight", "asuransi", "kodeAsuransi", "biayaTambahan",
+		"biayaPengurang", "cif", "hargaPenyerahan", "nilaiBarang", "bruto",
+		"netto", "volume", "jumlahKontainer", "seri", "namaTtd", "jabatanTtd",
+		"kotaTtd", "tanggalTtd",
+	}
+}
+
+func getBarangTarifColumns() []string {
+	return []string{
+		"seriBarang", "kodeJenisPungutan", "kodeJenisTarif", "tarif",
+		"kodeFasilitasTarif", "jumlahSatuan", "nilaiBayar", "nilaiFasilitas",
+	}
+}
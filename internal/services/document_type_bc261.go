@@ -0,0 +1,152 @@
+package services
+
+import (
+	_ "embed"
+
+	"json-response-generator/internal/models"
+)
+
+//go:embed schemas/bc261.json
+var bc261Schema []byte
+
+// init registers BC 2.6.1, goods taken out of a bonded area to be processed
+// elsewhere and returned
+func init() {
+	RegisterDocumentType(&DocumentType{
+		Code:      KodeDokumenKeluarSementara,
+		Name:      "BC 2.6.1",
+		Declarant: KodeEntitasPengusahaTpb,
+		New:       func() models.Declaration { return &models.TemporaryExportDeclaration{} },
+		Sample:    func() models.Declaration { return NewJsonGenerator().GenerateTemporaryExportSampleData() },
+		Schema:    bc261Schema,
+		Sheets:    bondedSheets(getTemporaryExportMainDataColumns()),
+		Rules: []ValidationRule{
+			RequireFields(
+				"asalData", "disclaimer", "jabatanTtd", "kodeJenisTpb", "kodeKantor",
+				"kodeTujuanPengiriman", "kodeValuta", "kotaTtd", "namaTtd", "nomorAju",
+				"tanggalTtd",
+			),
+			RequireItems("barang", "entitas", "dokumen"),
+			RequireEntitas(KodeEntitasPengusahaTpb, "bonded zone operator"),
+			RequireEntitas(KodeEntitasPenerima, "subcontractor"),
+			RequireNoDutyPayment(),
+		},
+	})
+}
+
+// GenerateTemporaryExportSampleData generates a sample BC 2.6.1 declaration
+// sending goods out to a subcontractor
+func (jg *JsonGenerator) GenerateTemporaryExportSampleData() *models.TemporaryExportDeclaration {
+	barang := []models.Barang{jg.createBarang1(), jg.createBarang2()}
+	suspendDuties(barang)
+	for i := range barang {
+		barang[i].BarangDokumen = []models.BarangDokumen{{SeriDokumen: "1"}}
+	}
+
+	data := jg.createTemporaryExport("261", barang)
+	data.Dokumen = []models.Dokumen{
+		{
+			IdDokumen:      "DOC001",
+			KodeDokumen:    "315",
+			KodeFasilitas:  "",
+			NomorDokumen:   "SPK/MAKLON/001",
+			SeriDokumen:    1,
+			TanggalDokumen: jg.defaultDate,
+		},
+	}
+	return data
+}
+
+// createTemporaryExport builds the BC 2.6.1 and BC 2.6.2 sample around their
+// goods
+func (jg *JsonGenerator) createTemporaryExport(kodeDokumen string, barang []models.Barang) *models.TemporaryExportDeclaration {
+	pengusaha := "0023456789012000"
+	penerima := "0056789012345000"
+
+	cif, bruto, netto := 0.0, 0.0, 0.0
+	for _, b := range barang {
+		cif += b.Cif
+		bruto += b.Bruto
+		netto += b.Netto
+	}
+
+	return &models.TemporaryExportDeclaration{
+		AsalData:             "S",
+		Bruto:                bruto,
+		Cif:                  cif,
+		Disclaimer:           "1",
+		HargaPenyerahan:      0,
+		IdPengguna:           "ABCDE",
+		JabatanTtd:           "MANAGER",
+		JumlahKontainer:      0,
+		KodeDokumen:          kodeDokumen,
+		KodeJenisTpb:         "1",
+		KodeKantor:           "050500",
+		KodeTujuanPengiriman: "1",
+		KodeValuta:           "CNY",
+		KotaTtd:              "BEKASI",
+		NamaTtd:              "AGUS",
+		Ndpbm:                1234.56,
+		Netto:                netto,
+		NilaiMaklon:          0,
+		NomorAju:             "000" + kodeDokumen + "05050020211225000001",
+		Seri:                 1,
+		TanggalAju:           jg.defaultDate,
+		TanggalTtd:           jg.defaultDate,
+		Volume:               0,
+		Barang:               barang,
+		Entitas: []models.Entitas{
+			{
+				AlamatEntitas:  "KAWASAN BERIKAT MM2100 BLOK A-1, BEKASI",
+				KodeEntitas:    KodeEntitasPengusahaTpb,
+				NamaEntitas:    "PT. SAMPLE KAWASAN BERIKAT",
+				NomorIdentitas: &pengusaha,
+				SeriEntitas:    1,
+			},
+			{
+				AlamatEntitas:  "JL. RAYA CIBITUNG NO. 12, BEKASI",
+				KodeEntitas:    KodeEntitasPenerima,
+				NamaEntitas:    "PT. SAMPLE SUBKONTRAKTOR",
+				NomorIdentitas: &penerima,
+				SeriEntitas:    2,
+			},
+		},
+		Kemasan: []models.Kemasan{
+			{
+				JumlahKemasan:    20,
+				KodeJenisKemasan: "BX",
+				MerkKemasan:      "SAMPLE BOX",
+				SeriKemasan:      1,
+			},
+		},
+		Kontainer: []models.Kontainer{
+			{
+				KodeJenisKontainer:  "8",
+				KodeTipeKontainer:   "1",
+				KodeUkuranKontainer: "20",
+				NomorKontainer:      "SMPL" + kodeDokumen + "0001",
+				SeriKontainer:       1,
+			},
+		},
+		Pengangkut: []models.Pengangkut{
+			{
+				KodeBendera:     "ID",
+				NamaPengangkut:  "TRUK",
+				NomorPengangkut: "B 5678 XYZ",
+				KodeCaraAngkut:  "3",
+				SeriPengangkut:  1,
+			},
+		},
+	}
+}
+
+// Column definitions for the BC 2.6.1 and BC 2.6.2 sheets
+func getTemporaryExportMainDataColumns() []string {
+	return []string{
+		"asalData", "disclaimer", "idPengguna", "nomorAju", "tanggalAju",
+		"kodeDokumen", "kodeKantor", "kodeJenisTpb", "kodeTujuanPengiriman",
+		"kodeValuta", "ndpbm", "cif", "hargaPenyerahan", "nilaiMaklon", "bruto",
+		"netto", "volume", "jumlahKontainer", "seri", "namaTtd", "jabatanTtd",
+		"kotaTtd", "tanggalTtd",
+	}
+}
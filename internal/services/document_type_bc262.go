@@ -0,0 +1,68 @@
+package services
+
+import (
+	_ "embed"
+
+	"json-response-generator/internal/models"
+)
+
+//go:embed schemas/bc262.json
+var bc262Schema []byte
+
+// init registers BC 2.6.2, the return of goods sent out on a BC 2.6.1
+func init() {
+	RegisterDocumentType(&DocumentType{
+		Code:      KodeDokumenMasukKembali,
+		Name:      "BC 2.6.2",
+		Declarant: KodeEntitasPengusahaTpb,
+		New:       func() models.Declaration { return &models.TemporaryExportDeclaration{} },
+		Sample:    func() models.Declaration { return NewJsonGenerator().GenerateTemporaryReturnSampleData() },
+		Schema:    bc262Schema,
+		Sheets:    bondedSheets(getTemporaryExportMainDataColumns()),
+		Rules: []ValidationRule{
+			RequireFields(
+				"asalData", "disclaimer", "jabatanTtd", "kodeJenisTpb", "kodeKantor",
+				"kodeTujuanPengiriman", "kodeValuta", "kotaTtd", "namaTtd", "nomorAju",
+				"tanggalTtd",
+			),
+			RequireItems("barang", "entitas", "dokumen"),
+			RequireEntitas(KodeEntitasPengusahaTpb, "bonded zone operator"),
+			RequireEntitas(KodeEntitasPenerima, "subcontractor"),
+			RequireDokumenReferences(),
+			RequireSourceItems(),
+			RequireReturnQuantities(),
+		},
+	})
+}
+
+// GenerateTemporaryReturnSampleData generates a sample BC 2.6.2 declaration
+// returning part of the goods of the BC 2.6.1 sample
+func (jg *JsonGenerator) GenerateTemporaryReturnSampleData() *models.TemporaryExportDeclaration {
+	barang := []models.Barang{jg.createBarang1(), jg.createBarang2()}
+	suspendDuties(barang)
+
+	returned := []float64{6, 10}
+	for i := range barang {
+		barang[i].SeriBarangDokAsal = barang[i].SeriBarang
+		barang[i].BarangDokumen = []models.BarangDokumen{{SeriDokumen: "1"}}
+		barang[i].SaldoAwal = barang[i].JumlahSatuan
+		barang[i].JumlahRealisasi = returned[i]
+		barang[i].SaldoAkhir = barang[i].SaldoAwal - returned[i]
+		barang[i].JumlahSatuan = returned[i]
+	}
+
+	source := jg.GenerateTemporaryExportSampleData()
+	data := jg.createTemporaryExport(KodeDokumenMasukKembali, barang)
+	data.NilaiMaklon = 2500000
+	data.Dokumen = []models.Dokumen{
+		{
+			IdDokumen:      "DOC001",
+			KodeDokumen:    KodeDokumenKeluarSementara,
+			KodeFasilitas:  "",
+			NomorDokumen:   source.NomorAju,
+			SeriDokumen:    1,
+			TanggalDokumen: jg.defaultDate,
+		},
+	}
+	return data
+}
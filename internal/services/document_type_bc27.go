@@ -0,0 +1,143 @@
+package services
+
+import (
+	_ "embed"
+
+	"json-response-generator/internal/models"
+)
+
+//go:embed schemas/bc27.json
+var bc27Schema []byte
+
+// init registers BC 2.7, the transfer of goods between bonded areas
+func init() {
+	RegisterDocumentType(&DocumentType{
+		Code:      "27",
+		Name:      "BC 2.7",
+		Declarant: KodeEntitasPengusahaTpb,
+		New:       func() models.Declaration { return &models.BondedTransferDeclaration{} },
+		Sample:    func() models.Declaration { return NewJsonGenerator().GenerateBondedTransferSampleData() },
+		Schema:    bc27Schema,
+		Sheets:    bondedSheets(getBondedTransferMainDataColumns()),
+		Rules: []ValidationRule{
+			RequireFields(
+				"asalData", "disclaimer", "jabatanTtd", "kodeJenisTpb", "kodeKantor",
+				"kodeKantorTujuan", "kodeTujuanPengiriman", "kodeTujuanTpb",
+				"kodeValuta", "kotaTtd", "namaTtd", "nomorAju", "tanggalTtd",
+			),
+			RequireItems("barang", "entitas", "dokumen"),
+			RequireEntitas(KodeEntitasPengusahaTpb, "sending bonded zone"),
+			RequireEntitas(KodeEntitasPenerima, "receiving bonded zone"),
+			RequireDokumenReferences(),
+			RequireSourceItems(),
+			RequireNoDutyPayment(),
+		},
+	})
+}
+
+// GenerateBondedTransferSampleData generates a sample BC 2.7 declaration
+// moving the goods of the BC 2.3 sample to another bonded zone
+func (jg *JsonGenerator) GenerateBondedTransferSampleData() *models.BondedTransferDeclaration {
+	pengirim := "0023456789012000"
+	penerima := "0034567890123000"
+
+	barang := []models.Barang{jg.createBarang1(), jg.createBarang2()}
+	suspendDuties(barang)
+	for i := range barang {
+		barang[i].SeriBarangDokAsal = barang[i].SeriBarang
+		barang[i].BarangDokumen = []models.BarangDokumen{{SeriDokumen: "1"}}
+	}
+
+	return &models.BondedTransferDeclaration{
+		AsalData:             "S",
+		Bruto:                350.71,
+		Cif:                  1234567.89,
+		Disclaimer:           "1",
+		HargaPenyerahan:      1524162.94,
+		IdPengguna:           "ABCDE",
+		JabatanTtd:           "MANAGER",
+		JumlahKontainer:      1,
+		KodeDokumen:          "27",
+		KodeJenisTpb:         "1",
+		KodeKantor:           "050500",
+		KodeKantorTujuan:     "050900",
+		KodeTujuanPengiriman: "1",
+		KodeTujuanTpb:        "1",
+		KodeValuta:           "CNY",
+		KotaTtd:              "BEKASI",
+		NamaTtd:              "AGUS",
+		Ndpbm:                1234.56,
+		Netto:                342.71,
+		NilaiBarang:          0,
+		NomorAju:             "00002705050020211225000001",
+		Seri:                 1,
+		TanggalAju:           jg.defaultDate,
+		TanggalTtd:           jg.defaultDate,
+		Volume:               0,
+		Barang:               barang,
+		Entitas: []models.Entitas{
+			{
+				AlamatEntitas:  "KAWASAN BERIKAT MM2100 BLOK A-1, BEKASI",
+				KodeEntitas:    KodeEntitasPengusahaTpb,
+				NamaEntitas:    "PT. SAMPLE KAWASAN BERIKAT",
+				NomorIdentitas: &pengirim,
+				SeriEntitas:    1,
+			},
+			{
+				AlamatEntitas:  "KAWASAN BERIKAT JABABEKA BLOK C-3, CIKARANG",
+				KodeEntitas:    KodeEntitasPenerima,
+				NamaEntitas:    "PT. SAMPLE PENERIMA BERIKAT",
+				NomorIdentitas: &penerima,
+				SeriEntitas:    2,
+			},
+		},
+		Kemasan: []models.Kemasan{
+			{
+				JumlahKemasan:    20,
+				KodeJenisKemasan: "BX",
+				MerkKemasan:      "SAMPLE BOX",
+				SeriKemasan:      1,
+			},
+		},
+		Kontainer: []models.Kontainer{
+			{
+				KodeJenisKontainer:  "8",
+				KodeTipeKontainer:   "1",
+				KodeUkuranKontainer: "20",
+				NomorKontainer:      "SMPL2700001",
+				SeriKontainer:       1,
+			},
+		},
+		Dokumen: []models.Dokumen{
+			{
+				IdDokumen:      "DOC001",
+				KodeDokumen:    "23",
+				KodeFasilitas:  "",
+				NomorDokumen:   "000123",
+				SeriDokumen:    1,
+				TanggalDokumen: jg.defaultDate,
+			},
+		},
+		Pengangkut: []models.Pengangkut{
+			{
+				KodeBendera:     "ID",
+				NamaPengangkut:  "TRUK",
+				NomorPengangkut: "B 1234 XYZ",
+				KodeCaraAngkut:  "3",
+				SeriPengangkut:  1,
+			},
+		},
+	}
+}
+
+// Column definitions for the BC 2.7 sheets
+func getBondedTransferMainDataColumns() []string {
+	return []string{
+		"asalData", "disclaimer", "idPengguna", "nomorAju", "tanggalAju",
+		"kodeDokumen", "kodeKantor", "kodeKantorTujuan", "kodeJenisTpb",
+		"kodeTujuanTpb", "kodeTujuanPengiriman", "kodeValuta", "ndpbm", "cif",
+		"hargaPenyerahan", "nilaiBarang", "bruto", "netto", "volume",
+		"jumlahKontainer", "seri", "namaTtd", "jabatanTtd", "kotaTtd",
+		"tanggalTtd",
+	}
+}
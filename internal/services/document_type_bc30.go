@@ -0,0 +1,276 @@
+package services
+
+import (
+	_ "embed"
+	"fmt"
+
+	"json-response-generator/internal/models"
+)
+
+// Parties of an export declaration by kodeEntitas
+const (
+	KodeEntitasEksportir = "2"
+	KodeEntitasPpjk      = "4"
+	KodeEntitasPemilik   = "7"
+	KodeEntitasPembeli   = "8"
+)
+
+// KodePungutanBeaKeluar is the kodeJenisPungutan of export duty
+const KodePungutanBeaKeluar = "BK"
+
+//go:embed schemas/bc30.json
+var bc30Schema []byte
+
+// init registers BC 3.0, the export declaration (Pemberitahuan Ekspor Barang)
+func init() {
+	RegisterDocumentType(&DocumentType{
+		Code:      "30",
+		Name:      "BC 3.0",
+		Declarant: KodeEntitasEksportir,
+		New:       func() models.Declaration { return &models.ExportDeclaration{} },
+		Sample:    func() models.Declaration { return NewJsonGenerator().GenerateExportSampleData() },
+		Schema:    bc30Schema,
+		Sheets: []models.SheetLayout{
+			{Name: HeaderSheet, Columns: getExportMainDataColumns()},
+			{Name: "Barang", Field: "barang", Columns: getExportBarangColumns()},
+			{Name: "Entitas", Field: "entitas", Columns: getEntitasColumns()},
+			{Name: "Kemasan", Field: "kemasan", Columns: getKemasanColumns()},
+			{Name: "Kontainer", Field: "kontainer", Columns: getKontainerColumns()},
+			{Name: "Dokumen", Field: "dokumen", Columns: getDokumenColumns()},
+			{Name: "Pengangkut", Field: "pengangkut", Columns: getPengangkutColumns()},
+			{Name: "BankDevisa", Field: "bankDevisa", Columns: []string{"kodeBank", "seriBank"}},
+			{Name: "KesiapanBarang", Field: "kesiapanBarang", Columns: getKesiapanBarangColumns()},
+		},
+		Rules: []ValidationRule{
+			RequireFields(
+				"asalData", "disclaimer", "flagCurah", "flagMigas", "jabatanTtd",
+				"kodeCaraBayar", "kodeCaraDagang", "kodeIncoterm", "kodeJenisEkspor",
+				"kodeKantor", "kodeKantorEkspor", "kodeKantorMuat", "kodeKategoriEkspor",
+				"kodeNegaraTujuan", "kodePelBongkar", "kodePelEkspor", "kodePelMuat",
+				"kodePelTujuan", "kodeValuta", "kotaTtd", "namaTtd", "nomorAju",
+				"tanggalEkspor", "tanggalTtd",
+			),
+			RequireItems("barang", "entitas", "kesiapanBarang"),
+			RequireEntitas(KodeEntitasEksportir, "exporter"),
+			RequireEntitas(KodeEntitasPembeli, "buyer"),
+			requireExportDutyBase,
+		},
+	})
+}
+
+// requireExportDutyBase reports goods charged export duty without the
+// benchmark price (harga patokan ekspor) the duty is calculated on
+func requireExportDutyBase(declaration models.Declaration, _ map[string]interface{}) []models.FieldError {
+	export, ok := declaration.(*models.ExportDeclaration)
+	if !ok {
+		return nil
+	}
+
+	var problems []models.FieldError
+	for i, barang := range export.Barang {
+		for _, tarif := range barang.BarangTarif {
+			if tarif.KodeJenisPungutan == KodePungutanBeaKeluar && barang.HargaPatokan <= 0 {
+				problems = append(problems, models.FieldError{
+					Field:   fmt.Sprintf("barang[%d].hargaPatokan", i),
+					Code:    models.FieldRequired,
+					Message: "goods charged export duty need a benchmark price",
+				})
+				break
+			}
+		}
+	}
+	return problems
+}
+
+// GenerateExportSampleData generates a sample BC 3.0 export declaration
+func (jg *JsonGenerator) GenerateExportSampleData() *models.ExportDeclaration {
+	nomorIdentitas := "0012345678901000"
+
+	return &models.ExportDeclaration{
+		AsalData:           "S",
+		Asuransi:           0,
+		Bruto:              1250.5,
+		Cif:                0,
+		Disclaimer:         "1",
+		FlagCurah:          "2",
+		FlagMigas:          "2",
+		Fob:                24500,
+		Freight:            0,
+		IdPengguna:         "ABCDE",
+		JabatanTtd:         "DIREKTUR",
+		JumlahKontainer:    1,
+		KodeAsuransi:       "LN",
+		KodeCaraBayar:      "1",
+		KodeCaraDagang:     "1",
+		KodeDokumen:        "30",
+		KodeIncoterm:       "FOB",
+		KodeJenisEkspor:    "1",
+		KodeJenisProsedur:  "1",
+		KodeKantor:         "040300",
+		KodeKantorEkspor:   "040300",
+		KodeKantorMuat:     "040300",
+		KodeKantorPeriksa:  "040300",
+		KodeKategoriEkspor: "10",
+		KodeNegaraTujuan:   "SG",
+		KodePelBongkar:     "SGSIN",
+		KodePelEkspor:      "IDTPP",
+		KodePelMuat:        "IDTPP",
+		KodePelTujuan:      "SGSIN",
+		KodeTps:            "KOJA",
+		KodeValuta:         "USD",
+		KotaTtd:            "JAKARTA",
+		NamaTtd:            "BUDI",
+		Ndpbm:              15750,
+		Netto:              1200,
+		NilaiMaklon:        0,
+		NomorAju:           "300100EXP00120211225000001",
+		Seri:               1,
+		TanggalAju:         jg.defaultDate,
+		TanggalEkspor:      jg.defaultDate,
+		TanggalTtd:         jg.defaultDate,
+		TotalDanaSawit:     0,
+		Volume:             28,
+		Barang: []models.ExportBarang{
+			{
+				Bruto:            1250.5,
+				Fob:              24500,
+				HargaEkspor:      24500,
+				HargaPatokan:     20000,
+				HargaSatuan:      20.42,
+				JumlahKemasan:    50,
+				JumlahSatuan:     1200,
+				KodeBarang:       "EXP-001",
+				KodeDaerahAsal:   "3171",
+				KodeJenisKemasan: "BG",
+				KodeNegaraAsal:   "ID",
+				KodeSatuanBarang: "KGM",
+				Merk:             "SAMPLE",
+				Netto:            1200,
+				NilaiDanaSawit:   0,
+				NilaiDevisa:      24500,
+				PosTarif:         "09011110",
+				SeriBarang:       1,
+				Tipe:             "ARABICA",
+				Uraian:           "KOPI BIJI TIDAK DIGONGSENG",
+				Volume:           28,
+				BarangDokumen:    []models.BarangDokumen{{SeriDokumen: "1"}},
+				BarangTarif: []models.BarangTarif{
+					{
+						JumlahSatuan:       1200,
+						KodeFasilitasTarif: "1",
+						KodeJenisPungutan:  KodePungutanBeaKeluar,
+						KodeJenisTarif:     "1",
+						NilaiBayar:         0,
+						NilaiFasilitas:     0,
+						SeriBarang:         1,
+						Tarif:              0,
+					},
+				},
+				BarangPemilik: []models.BarangPemilik{{SeriEntitas: 1}},
+			},
+		},
+		Entitas: []models.Entitas{
+			{
+				AlamatEntitas:  "JL. RAYA JAKARTA NO. 123",
+				KodeEntitas:    KodeEntitasEksportir,
+				NamaEntitas:    "PT. SAMPLE EXPORTER",
+				NomorIdentitas: &nomorIdentitas,
+				SeriEntitas:    1,
+			},
+			{
+				AlamatEntitas: "1 MARINA BOULEVARD, SINGAPORE",
+				KodeEntitas:   KodeEntitasPembeli,
+				NamaEntitas:   "SAMPLE BUYER PTE LTD",
+				SeriEntitas:   2,
+			},
+		},
+		Kemasan: []models.Kemasan{
+			{
+				JumlahKemasan:    50,
+				KodeJenisKemasan: "BG",
+				MerkKemasan:      "SAMPLE BAG",
+				SeriKemasan:      1,
+			},
+		},
+		Kontainer: []models.Kontainer{
+			{
+				KodeJenisKontainer:  "8",
+				KodeTipeKontainer:   "1",
+				KodeUkuranKontainer: "20",
+				NomorKontainer:      "SMPL1234567",
+				SeriKontainer:       1,
+			},
+		},
+		Dokumen: []models.Dokumen{
+			{
+				IdDokumen:      "DOC001",
+				KodeDokumen:    "380",
+				KodeFasilitas:  "",
+				NomorDokumen:   "INV/EXP/001",
+				SeriDokumen:    1,
+				TanggalDokumen: jg.defaultDate,
+			},
+		},
+		Pengangkut: []models.Pengangkut{
+			{
+				KodeBendera:     "SG",
+				NamaPengangkut:  "SAMPLE VESSEL",
+				NomorPengangkut: "V.001",
+				KodeCaraAngkut:  "1",
+				SeriPengangkut:  1,
+			},
+		},
+		BankDevisa: []models.BankDevisa{{KodeBank: "008", SeriBank: 1}},
+		KesiapanBarang: []models.KesiapanBarang{
+			{
+				Alamat:            "JL. GUDANG EKSPOR NO. 1, JAKARTA UTARA",
+				JumlahContainer20: 1,
+				JumlahContainer40: 0,
+				KodeCaraStuffing:  "1",
+				KodeJenisBarang:   "1",
+				KodeJenisGudang:   "1",
+				KodeJenisPartOf:   "",
+				LokasiSiapPeriksa: "GUDANG EKSPORTIR",
+				NamaPic:           "ANDI",
+				NomorTelpPic:      "0215550100",
+				TanggalPkb:        jg.defaultDate,
+				WaktuSiapPeriksa:  jg.defaultDate + " 09:00:00",
+			},
+		},
+	}
+}
+
+// Column definitions for the BC 3.0 sheets
+func getExportMainDataColumns() []string {
+	return []string{
+		"asalData", "disclaimer", "idPengguna", "nomorAju", "tanggalAju",
+		"kodeDokumen", "kodeKantor", "kodeKantorEkspor", "kodeKantorMuat",
+		"kodeKantorPeriksa", "kodeJenisEkspor", "kodeKategoriEkspor",
+		"kodeCaraDagang", "kodeCaraBayar", "kodeJenisProsedur", "flagCurah",
+		"flagMigas", "kodePelMuat", "kodePelEkspor", "kodePelBongkar",
+		"kodePelTujuan", "kodeNegaraTujuan", "kodeTps", "tanggalEkspor",
+		"kodeIncoterm", "kodeValuta", "ndpbm", "fob", "freight", "asuransi",
+		"kodeAsuransi", "cif", "nilaiMaklon", "totalDanaSawit", "bruto",
+		"netto", "volume", "jumlahKontainer", "seri", "namaTtd", "jabatanTtd",
+		"kotaTtd", "tanggalTtd",
+	}
+}
+
+func getExportBarangColumns() []string {
+	return []string{
+		"seriBarang", "kodeBarang", "posTarif", "uraian", "merk", "tipe",
+		"ukuran", "spesifikasiLain", "kodeNegaraAsal", "kodeDaerahAsal",
+		"jumlahSatuan", "kodeSatuanBarang", "hargaSatuan", "jumlahKemasan",
+		"kodeJenisKemasan", "fob", "hargaEkspor", "hargaPatokan",
+		"nilaiDevisa", "nilaiDanaSawit", "bruto", "netto", "volume",
+	}
+}
+
+func getKesiapanBarangColumns() []string {
+	return []string{
+		"kodeJenisBarang", "kodeJenisGudang", "namaPic", "alamat",
+		"nomorTelpPic", "lokasiSiapPeriksa", "kodeCaraStuffing",
+		"kodeJenisPartOf", "tanggalPkb", "waktuSiapPeriksa",
+		"jumlahContainer20", "jumlahContainer40",
+	}
+}
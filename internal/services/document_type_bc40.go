@@ -0,0 +1,184 @@
+package services
+
+import (
+	_ "embed"
+
+	"json-response-generator/internal/models"
+)
+
+// KodeEntitasPengirim is the kodeEntitas of the party sending goods from the
+// domestic customs area
+const KodeEntitasPengirim = "9"
+
+// KodePungutanPpn is the kodeJenisPungutan of VAT
+const KodePungutanPpn = "PPN"
+
+//go:embed schemas/bc40.json
+var bc40Schema []byte
+
+// init registers BC 4.0, the entry of local goods into a bonded area
+func init() {
+	RegisterDocumentType(&DocumentType{
+		Code:      "40",
+		Name:      "BC 4.0",
+		Declarant: KodeEntitasPengusahaTpb,
+		New:       func() models.Declaration { return &models.LocalDeliveryDeclaration{} },
+		Sample:    func() models.Declaration { return NewJsonGenerator().GenerateLocalEntrySampleData() },
+		Schema:    bc40Schema,
+		Sheets:    bondedSheets(getLocalDeliveryMainDataColumns()),
+		Rules: []ValidationRule{
+			RequireFields(
+				"asalData", "disclaimer", "jabatanTtd", "kodeJenisTpb", "kodeKantor",
+				"kodeTujuanPengiriman", "kotaTtd", "namaTtd", "nomorAju", "tanggalTtd",
+			),
+			RequireItems("barang", "entitas", "dokumen"),
+			RequireEntitas(KodeEntitasPengusahaTpb, "bonded zone operator"),
+			RequireEntitas(KodeEntitasPengirim, "sender"),
+			RequireDeliveryValue(),
+			RequireNoDutyPayment(),
+		},
+	})
+}
+
+// GenerateLocalEntrySampleData generates a sample BC 4.0 declaration of
+// goods bought from a local supplier
+func (jg *JsonGenerator) GenerateLocalEntrySampleData() *models.LocalDeliveryDeclaration {
+	pengusaha := "0023456789012000"
+	pengirim := "0045678901234000"
+
+	barang := []models.Barang{
+		jg.createLocalBarang(jg.createBarang1(), 10000000, 10000000),
+		jg.createLocalBarang(jg.createBarang2(), 5000000, 5000000),
+	}
+	suspendDuties(barang)
+	for i := range barang {
+		barang[i].BarangDokumen = []models.BarangDokumen{{SeriDokumen: "1"}}
+	}
+
+	data := jg.createLocalDelivery("40", barang, models.Entitas{
+		AlamatEntitas:  "KAWASAN BERIKAT MM2100 BLOK A-1, BEKASI",
+		KodeEntitas:    KodeEntitasPengusahaTpb,
+		NamaEntitas:    "PT. SAMPLE KAWASAN BERIKAT",
+		NomorIdentitas: &pengusaha,
+		SeriEntitas:    1,
+	}, models.Entitas{
+		AlamatEntitas:  "JL. INDUSTRI NO. 45, KARAWANG",
+		KodeEntitas:    KodeEntitasPengirim,
+		NamaEntitas:    "PT. SAMPLE PEMASOK LOKAL",
+		NomorIdentitas: &pengirim,
+		SeriEntitas:    2,
+	})
+	data.Dokumen = []models.Dokumen{
+		{
+			IdDokumen:      "DOC001",
+			KodeDokumen:    "380",
+			KodeFasilitas:  "",
+			NomorDokumen:   "INV/LOKAL/001",
+			SeriDokumen:    1,
+			TanggalDokumen: jg.defaultDate,
+		},
+	}
+	return data
+}
+
+// createLocalBarang turns sample goods into goods of local origin valued at
+// their delivery price, with VAT on that price
+func (jg *JsonGenerator) createLocalBarang(barang models.Barang, hargaPerolehan, hargaPenyerahan float64) models.Barang {
+	barang.Cif = 0
+	barang.CifRupiah = 0
+	barang.Ndpbm = 0
+	barang.KodeNegaraAsal = "ID"
+	barang.HargaPerolehan = hargaPerolehan
+	barang.HargaPenyerahan = hargaPenyerahan
+	barang.NilaiTambah = hargaPenyerahan - hargaPerolehan
+	barang.HargaSatuan = hargaPenyerahan / barang.JumlahSatuan
+	barang.BarangTarif = []models.BarangTarif{
+		{
+			JumlahSatuan:       barang.JumlahSatuan,
+			KodeFasilitasTarif: "1",
+			KodeJenisPungutan:  KodePungutanPpn,
+			KodeJenisTarif:     "1",
+			NilaiBayar:         hargaPenyerahan * 0.11,
+			NilaiFasilitas:     0,
+			SeriBarang:         barang.SeriBarang,
+			Tarif:              11,
+		},
+	}
+	return barang
+}
+
+// createLocalDelivery builds the BC 4.0 and BC 4.1 sample around their goods
+// and parties
+func (jg *JsonGenerator) createLocalDelivery(kodeDokumen string, barang []models.Barang, entitas ...models.Entitas) *models.LocalDeliveryDeclaration {
+	hargaPenyerahan, bruto, netto := 0.0, 0.0, 0.0
+	for _, b := range barang {
+		hargaPenyerahan += b.HargaPenyerahan
+		bruto += b.Bruto
+		netto += b.Netto
+	}
+
+	return &models.LocalDeliveryDeclaration{
+		AsalData:             "S",
+		BiayaPengurang:       0,
+		BiayaTambahan:        0,
+		Bruto:                bruto,
+		Disclaimer:           "1",
+		HargaPenyerahan:      hargaPenyerahan,
+		IdPengguna:           "ABCDE",
+		JabatanTtd:           "MANAGER",
+		JumlahKontainer:      0,
+		KodeDokumen:          kodeDokumen,
+		KodeJenisTpb:         "1",
+		KodeKantor:           "050500",
+		KodeTujuanPengiriman: "1",
+		KotaTtd:              "BEKASI",
+		NamaTtd:              "AGUS",
+		Netto:                netto,
+		NilaiJasa:            0,
+		NomorAju:             "0000" + kodeDokumen + "05050020211225000001",
+		Seri:                 1,
+		TanggalAju:           jg.defaultDate,
+		TanggalTtd:           jg.defaultDate,
+		UangMuka:             0,
+		Volume:               0,
+		Barang:               barang,
+		Entitas:              entitas,
+		Kemasan: []models.Kemasan{
+			{
+				JumlahKemasan:    20,
+				KodeJenisKemasan: "BX",
+				MerkKemasan:      "SAMPLE BOX",
+				SeriKemasan:      1,
+			},
+		},
+		Kontainer: []models.Kontainer{
+			{
+				KodeJenisKontainer:  "8",
+				KodeTipeKontainer:   "1",
+				KodeUkuranKontainer: "20",
+				NomorKontainer:      "SMPL" + kodeDokumen + "00001",
+				SeriKontainer:       1,
+			},
+		},
+		Pengangkut: []models.Pengangkut{
+			{
+				KodeBendera:     "ID",
+				NamaPengangkut:  "TRUK",
+				NomorPengangkut: "B 1234 XYZ",
+				KodeCaraAngkut:  "3",
+				SeriPengangkut:  1,
+			},
+		},
+	}
+}
+
+// Column definitions for the BC 4.0 and BC 4.1 sheets
+func getLocalDeliveryMainDataColumns() []string {
+	return []string{
+		"asalData", "disclaimer", "idPengguna", "nomorAju", "tanggalAju",
+		"kodeDokumen", "kodeKantor", "kodeJenisTpb", "kodeTujuanPengiriman",
+		"hargaPenyerahan", "uangMuka", "nilaiJasa", "biayaTambahan",
+		"biayaPengurang", "bruto", "netto", "volume", "jumlahKontainer", "seri",
+		"namaTtd", "jabatanTtd", "kotaTtd", "tanggalTtd",
+	}
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	_ "embed"
+
+	"json-response-generator/internal/models"
+)
+
+//go:embed schemas/bc41.json
+var bc41Schema []byte
+
+// init registers BC 4.1, the return of local goods from a bonded area to the
+// domestic customs area
+func init() {
+	RegisterDocumentType(&DocumentType{
+		Code:      "41",
+		Name:      "BC 4.1",
+		Declarant: KodeEntitasPengusahaTpb,
+		New:       func() models.Declaration { return &models.LocalDeliveryDeclaration{} },
+		Sample:    func() models.Declaration { return NewJsonGenerator().GenerateLocalReturnSampleData() },
+		Schema:    bc41Schema,
+		Sheets:    bondedSheets(getLocalDeliveryMainDataColumns()),
+		Rules: []ValidationRule{
+			RequireFields(
+				"asalData", "disclaimer", "jabatanTtd", "kodeJenisTpb", "kodeKantor",
+				"kodeTujuanPengiriman", "kotaTtd", "namaTtd", "nomorAju", "tanggalTtd",
+			),
+			RequireItems("barang", "entitas", "dokumen"),
+			RequireEntitas(KodeEntitasPengusahaTpb, "bonded zone operator"),
+			RequireEntitas(KodeEntitasPenerima, "receiver"),
+			RequireDokumenReferences(),
+			RequireSourceItems(),
+			RequireDeliveryValue(),
+			RequireValueAdded(),
+		},
+	})
+}
+
+// GenerateLocalReturnSampleData generates a sample BC 4.1 declaration
+// returning the goods of the BC 4.0 sample after processing
+func (jg *JsonGenerator) GenerateLocalReturnSampleData() *models.LocalDeliveryDeclaration {
+	pengusaha := "0023456789012000"
+	penerima := "0045678901234000"
+
+	barang := []models.Barang{
+		jg.createLocalBarang(jg.createBarang1(), 10000000, 12500000),
+		jg.createLocalBarang(jg.createBarang2(), 5000000, 6000000),
+	}
+	for i := range barang {
+		barang[i].SeriBarangDokAsal = barang[i].SeriBarang
+		barang[i].BarangDokumen = []models.BarangDokumen{{SeriDokumen: "1"}}
+	}
+
+	data := jg.createLocalDelivery("41", barang, models.Entitas{
+		AlamatEntitas:  "KAWASAN BERIKAT MM2100 BLOK A-1, BEKASI",
+		KodeEntitas:    KodeEntitasPengusahaTpb,
+		NamaEntitas:    "PT. SAMPLE KAWASAN BERIKAT",
+		NomorIdentitas: &pengusaha,
+		SeriEntitas:    1,
+	}, models.Entitas{
+		AlamatEntitas:  "JL. INDUSTRI NO. 45, KARAWANG",
+		KodeEntitas:    KodeEntitasPenerima,
+		NamaEntitas:    "PT. SAMPLE PEMASOK LOKAL",
+		NomorIdentitas: &penerima,
+		SeriEntitas:    2,
+	})
+	data.Dokumen = []models.Dokumen{
+		{
+			IdDokumen:      "DOC001",
+			KodeDokumen:    "40",
+			KodeFasilitas:  "",
+			NomorDokumen:   "000140",
+			SeriDokumen:    1,
+			TanggalDokumen: jg.defaultDate,
+		},
+	}
+	return data
+}
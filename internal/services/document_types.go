@@ -0,0 +1,347 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"json-response-generator/internal/models"
+)
+
+// HeaderSheet is the Excel sheet holding the single row of top-level fields
+const HeaderSheet = "MainData"
+
+// ValidationRule checks a declaration and reports the offending fields. fields
+// holds the top-level JSON fields of the declaration, decoded once for all rules.
+type ValidationRule func(declaration models.Declaration, fields map[string]interface{}) []models.FieldError
+
+// DocumentType describes a customs document type CEISA accepts: its Go model,
+// published JSON schema, Excel layout, sample data and validation rules
+type DocumentType struct {
+	Code      string // kodeDokumen
+	Name      string
+	Declarant string // kodeEntitas of the party filing the declaration
+	New       func() models.Declaration
+	Sample    func() models.Declaration
+	Schema    []byte // CEISA JSON schema, served to clients; Rules do the validation
+	Sheets    []models.SheetLayout
+	Rules     []ValidationRule
+}
+
+var (
+	documentTypes      = map[string]*DocumentType{}
+	documentTypesMutex sync.RWMutex
+)
+
+// RegisterDocumentType makes a document type available to generation, Excel
+// import and submission
+func RegisterDocumentType(dt *DocumentType) {
+	documentTypesMutex.Lock()
+	defer documentTypesMutex.Unlock()
+
+	documentTypes[dt.Code] = dt
+	models.RegisterDeclaration(dt.Code, dt.New)
+}
+
+// LookupDocumentType returns the registered type of kodeDokumen. An empty
+// kodeDokumen selects models.DefaultKodeDokumen.
+func LookupDocumentType(kodeDokumen string) (*DocumentType, error) {
+	if kodeDokumen == "" {
+		kodeDokumen = models.DefaultKodeDokumen
+	}
+
+	documentTypesMutex.RLock()
+	dt, ok := documentTypes[kodeDokumen]
+	documentTypesMutex.RUnlock()
+	if !ok {
+		return nil, models.UnknownDocumentType(kodeDokumen)
+	}
+
+	return dt, nil
+}
+
+// DocumentTypeOf returns the registered type of a declaration
+func DocumentTypeOf(declaration models.Declaration) (*DocumentType, error) {
+	return LookupDocumentType(*declaration.Header().KodeDokumen)
+}
+
+// DocumentTypes returns every registered document type ordered by kodeDokumen
+func DocumentTypes() []*DocumentType {
+	documentTypesMutex.RLock()
+	defer documentTypesMutex.RUnlock()
+
+	types := make([]*DocumentType, 0, len(documentTypes))
+	for _, dt := range documentTypes {
+		types = append(types, dt)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Code < types[j].Code })
+	return types
+}
+
+// Info describes the document type to API clients
+func (dt *DocumentType) Info() models.DocumentTypeInfo {
+	return models.DocumentTypeInfo{
+		KodeDokumen: dt.Code,
+		Name:        dt.Name,
+		Declarant:   dt.Declarant,
+		Sheets:      dt.Sheets,
+	}
+}
+
+// Check runs the validation rules and returns every problem found
+func (dt *DocumentType) Check(declaration models.Declaration) []models.FieldError {
+	fields, err := declarationFields(declaration)
+	if err != nil {
+		return []models.FieldError{{Field: "", Code: models.FieldInvalidValue, Message: err.Error()}}
+	}
+
+	problems := []models.FieldError{}
+	for _, rule := range dt.Rules {
+		problems = append(problems, rule(declaration, fields)...)
+	}
+	return problems
+}
+
+// Validate runs the validation rules and fails with VALIDATION_FAILED when a
+// rule reports a problem
+func (dt *DocumentType) Validate(declaration models.Declaration) error {
+	problems := dt.Check(declaration)
+	if len(problems) == 0 {
+		return nil
+	}
+
+	return models.Errorf(models.ErrCodeValidationFailed, "declaration is not a valid %s", dt.Name).WithFields(problems...)
+}
+
+// RequireFields reports top-level fields that are missing or empty
+func RequireFields(names ...string) ValidationRule {
+	return func(declaration models.Declaration, fields map[string]interface{}) []models.FieldError {
+		var problems []models.FieldError
+		for _, name := range names {
+			if value, ok := fields[name]; !ok || value == nil || value == "" {
+				problems = append(problems, models.FieldError{Field: name, Code: models.FieldRequired})
+			}
+		}
+		return problems
+	}
+}
+
+// RequireItems reports lists that have no entries
+func RequireItems(names ...string) ValidationRule {
+	return func(declaration models.Declaration, fields map[string]interface{}) []models.FieldError {
+		var problems []models.FieldError
+		for _, name := range names {
+			if items, _ := fields[name].([]interface{}); len(items) == 0 {
+				problems = append(problems, models.FieldError{
+					Field:   name,
+					Code:    models.FieldRequired,
+					Message: "at least one entry is required",
+				})
+			}
+		}
+		return problems
+	}
+}
+
+// RequireEntitas reports a missing party, e.g. the importer (kodeEntitas 1)
+func RequireEntitas(kodeEntitas, role string) ValidationRule {
+	return func(declaration models.Declaration, _ map[string]interface{}) []models.FieldError {
+		if hasEntitas(*declaration.Header().Entitas, kodeEntitas) {
+			return nil
+		}
+		return []models.FieldError{{
+			Field:   "entitas",
+			Code:    models.FieldRequired,
+			Message: fmt.Sprintf("an entitas with kodeEntitas %s (%s) is required", kodeEntitas, role),
+		}}
+	}
+}
+
+// declarationFields returns the top-level JSON fields of a declaration
+func declarationFields(declaration models.Declaration) (map[string]interface{}, error) {
+	jsonBytes, err := json.Marshal(declaration)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal declaration: %w", err)
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(jsonBytes, &fields); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal declaration: %w", err)
+	}
+	return fields, nil
+}
+
+// RequireDokumenReferences reports goods that do not refer to a document of
+// the declaration through barangDokumen
+func RequireDokumenReferences() ValidationRule {
+	return func(declaration models.Declaration, fields map[string]interface{}) []models.FieldError {
+		dokumen := make(map[string]bool)
+		for _, entry := range objects(fields["dokumen"]) {
+			dokumen[fmt.Sprint(entry["seriDokumen"])] = true
+		}
+
+		var problems []models.FieldError
+		for i, barang := range objects(fields["barang"]) {
+			references := objects(barang["barangDokumen"])
+			if len(references) == 0 {
+				problems = append(problems, models.FieldError{
+					Field:   fmt.Sprintf("barang[%d].barangDokumen", i),
+					Code:    models.FieldRequired,
+					Message: "goods must refer to their source document",
+				})
+			}
+			for j, reference := range references {
+				if seri := fmt.Sprint(reference["seriDokumen"]); !dokumen[seri] {
+					problems = append(problems, models.FieldError{
+						Field:   fmt.Sprintf("barang[%d].barangDokumen[%d].seriDokumen", i, j),
+						Code:    models.FieldInvalidValue,
+						Message: fmt.Sprintf("no dokumen with seriDokumen %s", seri),
+					})
+				}
+			}
+		}
+		return problems
+	}
+}
+
+// RequireSourceItems reports goods without the item number (seriBarangDokAsal)
+// they have on the source document
+func RequireSourceItems() ValidationRule {
+	return func(declaration models.Declaration, fields map[string]interface{}) []models.FieldError {
+		var problems []models.FieldError
+		for i, barang := range objects(fields["barang"]) {
+			if seri, _ := barang["seriBarangDokAsal"].(float64); seri <= 0 {
+				problems = append(problems, models.FieldError{
+					Field: fmt.Sprintf("barang[%d].seriBarangDokAsal", i),
+					Code:  models.FieldRequired,
+				})
+			}
+		}
+		return problems
+	}
+}
+
+// RequireNoDutyPayment reports levies with an amount to pay on document types
+// whose duties and taxes are suspended
+func RequireNoDutyPayment() ValidationRule {
+	return func(declaration models.Declaration, fields map[string]interface{}) []models.FieldError {
+		var problems []models.FieldError
+		for i, barang := range objects(fields["barang"]) {
+			for j, tarif := range objects(barang["barangTarif"]) {
+				if nilaiBayar, _ := tarif["nilaiBayar"].(float64); nilaiBayar != 0 {
+					problems = append(problems, models.FieldError{
+						Field:   fmt.Sprintf("barang[%d].barangTarif[%d].nilaiBayar", i, j),
+						Code:    models.FieldInvalidValue,
+						Message: "duties are suspended, the amount belongs in nilaiFasilitas",
+					})
+				}
+			}
+		}
+		return problems
+	}
+}
+
+// amountTolerance absorbs rounding when comparing declared and computed amounts
+const amountTolerance = 0.01
+
+// RequireDeliveryValue reports goods without a delivery price (harga
+// penyerahan) and a header hargaPenyerahan that is not the sum of the goods'
+func RequireDeliveryValue() ValidationRule {
+	return func(declaration models.Declaration, fields map[string]interface{}) []models.FieldError {
+		var problems []models.FieldError
+		total := 0.0
+		for i, barang := range objects(fields["barang"]) {
+			hargaPenyerahan, _ := barang["hargaPenyerahan"].(float64)
+			if hargaPenyerahan <= 0 {
+				problems = append(problems, models.FieldError{
+					Field:   fmt.Sprintf("barang[%d].hargaPenyerahan", i),
+					Code:    models.FieldRequired,
+					Message: "goods are valued at their delivery price",
+				})
+			}
+			total += hargaPenyerahan
+		}
+
+		if declared, _ := fields["hargaPenyerahan"].(float64); math.Abs(declared-total) > amountTolerance {
+			problems = append(problems, models.FieldError{
+				Field:   "hargaPenyerahan",
+				Code:    models.FieldMismatch,
+				Message: fmt.Sprintf("the goods add up to %.2f", total),
+			})
+		}
+		return problems
+	}
+}
+
+// RequireValueAdded reports goods whose nilaiTambah is not the difference
+// between their delivery price and their acquisition price (harga perolehan)
+func RequireValueAdded() ValidationRule {
+	return func(declaration models.Declaration, fields map[string]interface{}) []models.FieldError {
+		var problems []models.FieldError
+		for i, barang := range objects(fields["barang"]) {
+			hargaPenyerahan, _ := barang["hargaPenyerahan"].(float64)
+			hargaPerolehan, _ := barang["hargaPerolehan"].(float64)
+			nilaiTambah, _ := barang["nilaiTambah"].(float64)
+
+			if hargaPerolehan <= 0 {
+				problems = append(problems, models.FieldError{
+					Field: fmt.Sprintf("barang[%d].hargaPerolehan", i),
+					Code:  models.FieldRequired,
+				})
+				continue
+			}
+			if expected := hargaPenyerahan - hargaPerolehan; math.Abs(nilaiTambah-expected) > amountTolerance {
+				problems = append(problems, models.FieldError{
+					Field:   fmt.Sprintf("barang[%d].nilaiTambah", i),
+					Code:    models.FieldMismatch,
+					Message: fmt.Sprintf("hargaPenyerahan - hargaPerolehan is %.2f", expected),
+				})
+			}
+		}
+		return problems
+	}
+}
+
+// RequireReturnQuantities reports returned goods without a quantity returned
+// (jumlahRealisasi) or whose closing balance (saldoAkhir) is not the opening
+// balance (saldoAwal) less that quantity
+func RequireReturnQuantities() ValidationRule {
+	return func(declaration models.Declaration, fields map[string]interface{}) []models.FieldError {
+		var problems []models.FieldError
+		for i, barang := range objects(fields["barang"]) {
+			jumlahRealisasi, _ := barang["jumlahRealisasi"].(float64)
+			saldoAwal, _ := barang["saldoAwal"].(float64)
+			saldoAkhir, _ := barang["saldoAkhir"].(float64)
+
+			if jumlahRealisasi <= 0 {
+				problems = append(problems, models.FieldError{
+					Field: fmt.Sprintf("barang[%d].jumlahRealisasi", i),
+					Code:  models.FieldRequired,
+				})
+				continue
+			}
+			if expected := saldoAwal - jumlahRealisasi; math.Abs(saldoAkhir-expected) > amountTolerance {
+				problems = append(problems, models.FieldError{
+					Field:   fmt.Sprintf("barang[%d].saldoAkhir", i),
+					Code:    models.FieldMismatch,
+					Message: fmt.Sprintf("saldoAwal - jumlahRealisasi is %.2f", expected),
+				})
+			}
+		}
+		return problems
+	}
+}
+
+// objects returns the JSON objects of a decoded list
+func objects(value interface{}) []map[string]interface{} {
+	items, _ := value.([]interface{})
+	result := make([]map[string]interface{}, 0, len(items))
+	for _, item := range items {
+		if object, ok := item.(map[string]interface{}); ok {
+			result = append(result, object)
+		}
+	}
+	return result
+}
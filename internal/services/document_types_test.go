@@ -0,0 +1,195 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+
+	"json-response-generator/internal/models"
+)
+
+func TestLookupDocumentType(t *testing.T) {
+	dt, err := LookupDocumentType("")
+	if err != nil || dt.Code != models.DefaultKodeDokumen {
+		t.Fatalf("Expected the default document type, got %v, %v", dt, err)
+	}
+
+	_, err = LookupDocumentType("99")
+	if models.ErrorCodeOf(err) != models.ErrCodeUnknownDocumentType {
+		t.Errorf("Expected %s, got %v", models.ErrCodeUnknownDocumentType, err)
+	}
+}
+
+func TestDocumentTypeSamplesAreValid(t *testing.T) {
+	for _, dt := range DocumentTypes() {
+		sample := dt.Sample()
+		if err := dt.Validate(sample); err != nil {
+			t.Errorf("Sample of %s is invalid: %v", dt.Name, models.AsAppError(err, models.ErrCodeInternal, "").Fields)
+		}
+		if !json.Valid(dt.Schema) {
+			t.Errorf("Schema of %s is not valid JSON", dt.Name)
+		}
+		if got, err := DocumentTypeOf(sample); err != nil || got != dt {
+			t.Errorf("Expected the sample of %s to resolve to its own type, got %v, %v", dt.Name, got, err)
+		}
+	}
+}
+
+func TestDocumentTypeValidateReportsFields(t *testing.T) {
+	dt, _ := LookupDocumentType("20")
+	declaration := dt.Sample().(*models.ResponseData)
+	declaration.NomorAju = ""
+	declaration.Barang = nil
+	declaration.Entitas = declaration.Entitas[1:]
+
+	err := dt.Validate(declaration)
+	if models.ErrorCodeOf(err) != models.ErrCodeValidationFailed {
+		t.Fatalf("Expected %s, got %v", models.ErrCodeValidationFailed, err)
+	}
+
+	fields := make(map[string]bool)
+	for _, field := range models.AsAppError(err, models.ErrCodeInternal, "").Fields {
+		fields[field.Field] = true
+	}
+	for _, field := range []string{"nomorAju", "barang", "entitas"} {
+		if !fields[field] {
+			t.Errorf("Expected a problem with %s, got %v", field, fields)
+		}
+	}
+}
+
+func TestDecodeDeclarationDispatchesOnKodeDokumen(t *testing.T) {
+	declaration, err := models.DecodeDeclaration([]byte(`{"nomorAju": "000020"}`))
+	if err != nil {
+		t.Fatalf("Failed to decode declaration: %v", err)
+	}
+	if _, ok := declaration.(*models.ResponseData); !ok {
+		t.Errorf("Expected a BC 2.0 declaration, got %T", declaration)
+	}
+	if code := *declaration.Header().KodeDokumen; code != models.DefaultKodeDokumen {
+		t.Errorf("Expected kodeDokumen %s, got %q", models.DefaultKodeDokumen, code)
+	}
+
+	_, err = models.DecodeDeclaration([]byte(`{"kodeDokumen": "99"}`))
+	if models.ErrorCodeOf(err) != models.ErrCodeUnknownDocumentType {
+		t.Errorf("Expected %s, got %v", models.ErrCodeUnknownDocumentType, err)
+	}
+}
+
+func TestExportDutyNeedsBenchmarkPrice(t *testing.T) {
+	dt, _ := LookupDocumentType("30")
+	declaration := NewJsonGenerator().GenerateExportSampleData()
+	declaration.Barang[0].HargaPatokan = 0
+
+	problems := dt.Check(declaration)
+	if len(problems) != 1 || problems[0].Field != "barang[0].hargaPatokan" {
+		t.Errorf("Expected a barang[0].hargaPatokan problem, got %+v", problems)
+	}
+
+	declaration.Barang[0].BarangTarif = nil
+	if problems := dt.Check(declaration); len(problems) != 0 {
+		t.Errorf("Expected goods without export duty to pass, got %+v", problems)
+	}
+}
+
+func TestBondedZoneRules(t *testing.T) {
+	bc23, _ := LookupDocumentType("23")
+	imported := NewJsonGenerator().GenerateBondedImportSampleData()
+	imported.Barang[1].BarangTarif[0].NilaiBayar = 100
+	imported.Barang[1].BarangDokumen[0].SeriDokumen = "9"
+
+	fields := make(map[string]bool)
+	for _, problem := range bc23.Check(imported) {
+		fields[problem.Field] = true
+	}
+	for _, field := range []string{"barang[1].barangTarif[0].nilaiBayar", "barang[1].barangDokumen[0].seriDokumen"} {
+		if !fields[field] {
+			t.Errorf("Expected a problem with %s, got %v", field, fields)
+		}
+	}
+
+	bc27, _ := LookupDocumentType("27")
+	transferred := NewJsonGenerator().GenerateBondedTransferSampleData()
+	transferred.Barang[0].SeriBarangDokAsal = 0
+	transferred.Barang[0].BarangDokumen = nil
+	transferred.Entitas = transferred.Entitas[:1]
+
+	fields = make(map[string]bool)
+	for _, problem := range bc27.Check(transferred) {
+		fields[problem.Field] = true
+	}
+	for _, field := range []string{"barang[0].seriBarangDokAsal", "barang[0].barangDokumen", "entitas"} {
+		if !fields[field] {
+			t.Errorf("Expected a problem with %s, got %v", field, fields)
+		}
+	}
+}
+
+func TestLocalDeliveryRules(t *testing.T) {
+	bc40, _ := LookupDocumentType("40")
+	entered := NewJsonGenerator().GenerateLocalEntrySampleData()
+	entered.Barang[1].HargaPenyerahan = 0
+
+	fields := make(map[string]bool)
+	for _, problem := range bc40.Check(entered) {
+		fields[problem.Field] = true
+	}
+	for _, field := range []string{"barang[1].hargaPenyerahan", "hargaPenyerahan"} {
+		if !fields[field] {
+			t.Errorf("Expected a problem with %s, got %v", field, fields)
+		}
+	}
+
+	bc41, _ := LookupDocumentType("41")
+	returned := NewJsonGenerator().GenerateLocalReturnSampleData()
+	returned.Barang[0].NilaiTambah = 1
+	returned.Barang[1].HargaPerolehan = 0
+
+	fields = make(map[string]bool)
+	for _, problem := range bc41.Check(returned) {
+		fields[problem.Field] = true
+	}
+	for _, field := range []string{"barang[0].nilaiTambah", "barang[1].hargaPerolehan"} {
+		if !fields[field] {
+			t.Errorf("Expected a problem with %s, got %v", field, fields)
+		}
+	}
+	if fields["hargaPenyerahan"] {
+		t.Errorf("Expected the header delivery price to match, got %v", fields)
+	}
+}
+
+func TestGenerateFromDataNestsSheetRows(t *testing.T) {
+	excelData := map[string]interface{}{
+		HeaderSheet: map[string]interface{}{"kodeDokumen": int64(27), "nomorAju": "00002705050020211225000001"},
+		"Barang": []interface{}{
+			map[string]interface{}{"seriBarang": int64(1), "seriBarangDokAsal": int64(3)},
+			map[string]interface{}{"seriBarang": int64(2), "seriBarangDokAsal": int64(4)},
+		},
+		"BarangDokumen": []interface{}{
+			map[string]interface{}{"seriBarang": int64(2), "seriDokumen": int64(1)},
+		},
+	}
+
+	declaration, err := NewJsonGenerator().GenerateFromData(excelData)
+	if err != nil {
+		t.Fatalf("Failed to generate declaration: %v", err)
+	}
+	transfer, ok := declaration.(*models.BondedTransferDeclaration)
+	if !ok {
+		t.Fatalf("Expected a BC 2.7 declaration, got %T", declaration)
+	}
+	if len(transfer.Barang[0].BarangDokumen) != 0 || len(transfer.Barang[1].BarangDokumen) != 1 || transfer.Barang[1].BarangDokumen[0].SeriDokumen != "1" {
+		t.Errorf("Expected the reference on the second barang, got %+v", transfer.Barang)
+	}
+
+	excelData["BarangDokumen"] = []interface{}{
+		map[string]interface{}{"seriBarang": int64(5), "seriDokumen": int64(1)},
+	}
+	_, err = NewJsonGenerator().GenerateFromData(excelData)
+	if models.ErrorCodeOf(err) != models.ErrCodeValidationFailed {
+		t.Fatalf("Expected %s, got %v", models.ErrCodeValidationFailed, err)
+	}
+	if fields := models.AsAppError(err, models.ErrCodeInternal, "").Fields; len(fields) != 1 || fields[0].Field != "BarangDokumen[0].seriBarang" {
+		t.Errorf("Expected a BarangDokumen[0].seriBarang problem, got %+v", fields)
+	}
+}
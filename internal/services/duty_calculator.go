@@ -0,0 +1,261 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"json-response-generator/internal/models"
+)
+
+// Import levies by kodeJenisPungutan, besides KodePungutanPpn
+const (
+	KodePungutanBm    = "BM"
+	KodePungutanPpnbm = "PPNBM"
+	KodePungutanPph   = "PPH"
+)
+
+// Kinds of rate by kodeJenisTarif
+const (
+	KodeJenisTarifAdvalorum = "1" // percent of the customs value
+	KodeJenisTarifSpesifik  = "2" // rupiah per unit
+)
+
+// KodeFasilitasDibayar is the kodeFasilitasTarif of a levy paid in full
+const KodeFasilitasDibayar = "1"
+
+// Rates in percent applied when a declaration states none
+const (
+	TarifPpn       = 11.0
+	TarifPphApi    = 2.5 // importers holding an import identification number (API)
+	TarifPphNonApi = 7.5
+)
+
+// dutyOrder is the order levies are listed in, the tax levies being computed
+// on the customs value plus import duty
+var dutyOrder = []string{KodePungutanBm, KodePungutanPpn, KodePungutanPpnbm, KodePungutanPph}
+
+// DutyInput is what the duties of one item are computed from
+type DutyInput struct {
+	SeriBarang   int
+	Cif          float64 // in the invoice currency
+	Ndpbm        float64 // rupiah per unit of the invoice currency
+	JumlahSatuan float64
+	// Tarif holds the rate (tarif, kodeJenisTarif) and facility
+	// (kodeFasilitasTarif, tarifFasilitas) of each levy. PPN and PPh 22 are
+	// added at their default rates when missing, rows of other levies are
+	// kept as they are.
+	Tarif []models.BarangTarif
+}
+
+// CalculateDuties computes import duty (BM), VAT (PPN), luxury tax (PPnBM)
+// and income tax (PPh 22) of each item. It returns the filled barangTarif rows
+// of every item, in the order of items, and the per-levy summary.
+//
+// Item amounts are kept to the sen; the payable total of each levy is rounded
+// up to whole thousands of rupiah, as duties and taxes are paid.
+func CalculateDuties(items []DutyInput, api bool) ([][]models.BarangTarif, *models.DutyCalculation) {
+	calculation := &models.DutyCalculation{
+		Api:     api,
+		Summary: []models.DutySummary{},
+		Barang:  make([]models.DutyItemResult, 0, len(items)),
+	}
+	totals := make(map[string]*models.DutySummary)
+
+	rows := make([][]models.BarangTarif, 0, len(items))
+	for _, item := range items {
+		tarif, result := calculateItem(item, api)
+		rows = append(rows, tarif)
+		calculation.Barang = append(calculation.Barang, result)
+		calculation.NilaiPabean = roundSen(calculation.NilaiPabean + result.NilaiPabean)
+
+		for _, row := range tarif {
+			if !isDuty(row.KodeJenisPungutan) {
+				continue
+			}
+			total := totals[row.KodeJenisPungutan]
+			if total == nil {
+				total = &models.DutySummary{KodeJenisPungutan: row.KodeJenisPungutan}
+				totals[row.KodeJenisPungutan] = total
+			}
+			total.NilaiPungutan = roundSen(total.NilaiPungutan + row.NilaiBayar + row.NilaiFasilitas)
+			total.NilaiFasilitas = roundSen(total.NilaiFasilitas + row.NilaiFasilitas)
+			total.NilaiBayarExact = roundSen(total.NilaiBayarExact + row.NilaiBayar)
+		}
+	}
+
+	for _, code := range dutyOrder {
+		if total := totals[code]; total != nil {
+			total.NilaiBayar = roundUpThousand(total.NilaiBayarExact)
+			calculation.Summary = append(calculation.Summary, *total)
+		}
+	}
+	return rows, calculation
+}
+
+// ApplyDuties computes the duties of the goods of a declaration and fills
+// their barangTarif rows. A nil api is taken from whether the declarant
+// entitas has a kodeJenisApi.
+func ApplyDuties(declaration models.Declaration, api *bool) (*models.DutyCalculation, error) {
+	dt, err := DocumentTypeOf(declaration)
+	if err != nil {
+		return nil, err
+	}
+
+	header := declaration.Header()
+	if header.Barang == nil {
+		return nil, models.Errorf(models.ErrCodeValidationFailed, "%s declarations carry no import duties", dt.Name)
+	}
+	barang := *header.Barang
+
+	var problems []models.FieldError
+	items := make([]DutyInput, 0, len(barang))
+	for i, b := range barang {
+		if b.Cif <= 0 {
+			problems = append(problems, models.FieldError{Field: fmt.Sprintf("barang[%d].cif", i), Code: models.FieldRequired})
+		}
+		if b.Ndpbm <= 0 {
+			problems = append(problems, models.FieldError{Field: fmt.Sprintf("barang[%d].ndpbm", i), Code: models.FieldRequired})
+		}
+		items = append(items, DutyInput{
+			SeriBarang:   b.SeriBarang,
+			Cif:          b.Cif,
+			Ndpbm:        b.Ndpbm,
+			JumlahSatuan: b.JumlahSatuan,
+			Tarif:        b.BarangTarif,
+		})
+	}
+	if len(problems) > 0 {
+		return nil, models.NewError(models.ErrCodeValidationFailed, "duties need the cif and ndpbm of every item", nil).WithFields(problems...)
+	}
+
+	holdsApi := declarantHoldsApi(*header.Entitas, dt.Declarant)
+	if api != nil {
+		holdsApi = *api
+	}
+
+	rows, calculation := CalculateDuties(items, holdsApi)
+	for i := range barang {
+		barang[i].BarangTarif = rows[i]
+	}
+	return calculation, nil
+}
+
+// calculateItem computes the levies of one item
+func calculateItem(item DutyInput, api bool) ([]models.BarangTarif, models.DutyItemResult) {
+	nilaiPabean := roundSen(item.Cif * item.Ndpbm)
+
+	rates := make(map[string]models.BarangTarif)
+	var others []models.BarangTarif
+	for _, tarif := range item.Tarif {
+		code := strings.ToUpper(tarif.KodeJenisPungutan)
+		if !isDuty(code) {
+			others = append(others, tarif)
+			continue
+		}
+		tarif.KodeJenisPungutan = code
+		rates[code] = tarif
+	}
+
+	// PPN and PPh 22 are due on every import
+	if _, exists := rates[KodePungutanPpn]; !exists {
+		rates[KodePungutanPpn] = models.BarangTarif{KodeJenisPungutan: KodePungutanPpn, Tarif: TarifPpn}
+	}
+	if _, exists := rates[KodePungutanPph]; !exists {
+		pph := models.BarangTarif{KodeJenisPungutan: KodePungutanPph, Tarif: TarifPphNonApi}
+		if api {
+			pph.Tarif = TarifPphApi
+		}
+		rates[KodePungutanPph] = pph
+	}
+
+	bm := 0.0
+	if rate, exists := rates[KodePungutanBm]; exists {
+		if rate.KodeJenisTarif == KodeJenisTarifSpesifik {
+			bm = roundSen(rate.Tarif * item.JumlahSatuan)
+		} else {
+			bm = roundSen(nilaiPabean * rate.Tarif / 100)
+		}
+	}
+	nilaiImpor := roundSen(nilaiPabean + bm)
+
+	rows := make([]models.BarangTarif, 0, len(rates)+len(others))
+	for _, code := range dutyOrder {
+		rate, exists := rates[code]
+		if !exists {
+			continue
+		}
+		amount := bm
+		if code != KodePungutanBm {
+			amount = roundSen(nilaiImpor * rate.Tarif / 100)
+		}
+		rows = append(rows, levy(rate, amount, item))
+	}
+
+	return append(rows, others...), models.DutyItemResult{
+		SeriBarang:  item.SeriBarang,
+		NilaiPabean: nilaiPabean,
+		NilaiImpor:  nilaiImpor,
+	}
+}
+
+// levy fills a barangTarif row with an amount, split between what is paid
+// and what a facility covers: tarifFasilitas percent of it, all by default
+func levy(rate models.BarangTarif, amount float64, item DutyInput) models.BarangTarif {
+	rate.SeriBarang = item.SeriBarang
+	rate.JumlahSatuan = item.JumlahSatuan
+	if rate.KodeJenisTarif == "" {
+		rate.KodeJenisTarif = KodeJenisTarifAdvalorum
+	}
+	if rate.KodeFasilitasTarif == "" {
+		rate.KodeFasilitasTarif = KodeFasilitasDibayar
+	}
+
+	rate.NilaiFasilitas = 0
+	if !paidInFull(rate.KodeFasilitasTarif) {
+		share := 100.0
+		if rate.TarifFasilitas != nil {
+			share = *rate.TarifFasilitas
+		}
+		rate.NilaiFasilitas = roundSen(amount * share / 100)
+	}
+	rate.NilaiBayar = roundSen(amount - rate.NilaiFasilitas)
+	return rate
+}
+
+// paidInFull reports whether a kodeFasilitasTarif grants no facility. Older
+// templates use 0 or 00 for that.
+func paidInFull(kodeFasilitasTarif string) bool {
+	return kodeFasilitasTarif == KodeFasilitasDibayar || strings.Trim(kodeFasilitasTarif, "0") == ""
+}
+
+// isDuty reports whether the calculator computes a levy
+func isDuty(kodeJenisPungutan string) bool {
+	for _, code := range dutyOrder {
+		if code == kodeJenisPungutan {
+			return true
+		}
+	}
+	return false
+}
+
+// declarantHoldsApi reports whether the declarant has an import
+// identification number (API)
+func declarantHoldsApi(entitas []models.Entitas, declarant string) bool {
+	for _, e := range entitas {
+		if e.KodeEntitas == declarant && e.KodeJenisApi != nil && *e.KodeJenisApi != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// roundSen rounds a rupiah amount to the sen
+func roundSen(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// roundUpThousand rounds a rupiah amount up to whole thousands
+func roundUpThousand(amount float64) float64 {
+	return math.Ceil(roundSen(amount)/1000) * 1000
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"testing"
+
+	"json-response-generator/internal/models"
+)
+
+func TestCalculateDuties(t *testing.T) {
+	half := 50.0
+	items := []DutyInput{
+		{
+			SeriBarang:   1,
+			Cif:          1000,
+			Ndpbm:        15000,
+			JumlahSatuan: 10,
+			Tarif: []models.BarangTarif{
+				{KodeJenisPungutan: "BM", KodeJenisTarif: KodeJenisTarifAdvalorum, Tarif: 10},
+				{KodeJenisPungutan: "ppnbm", Tarif: 20},
+				{KodeJenisPungutan: "CK", Tarif: 5, NilaiBayar: 123},
+			},
+		},
+		{
+			SeriBarang:   2,
+			Cif:          333.33,
+			Ndpbm:        15000,
+			JumlahSatuan: 7,
+			Tarif: []models.BarangTarif{
+				{KodeJenisPungutan: "BM", KodeJenisTarif: KodeJenisTarifSpesifik, Tarif: 1500, KodeFasilitasTarif: "5", TarifFasilitas: &half},
+			},
+		},
+	}
+
+	rows, calculation := CalculateDuties(items, true)
+
+	// 15,000,000 customs value, 1,500,000 duty, taxes on 16,500,000
+	first := make(map[string]models.BarangTarif)
+	for _, row := range rows[0] {
+		first[row.KodeJenisPungutan] = row
+	}
+	expected := map[string]float64{"BM": 1500000, "PPN": 1815000, "PPNBM": 3300000, "PPH": 412500, "CK": 123}
+	for code, nilaiBayar := range expected {
+		if first[code].NilaiBayar != nilaiBayar {
+			t.Errorf("Expected %s of %.2f, got %+v", code, nilaiBayar, first[code])
+		}
+	}
+	if last := rows[0][len(rows[0])-1]; last.KodeJenisPungutan != "CK" {
+		t.Errorf("Expected other levies to follow the computed ones, got %+v", rows[0])
+	}
+	if calculation.Barang[0].NilaiImpor != 16500000 {
+		t.Errorf("Expected a nilai impor of 16,500,000, got %.2f", calculation.Barang[0].NilaiImpor)
+	}
+
+	// Half of the specific duty of 7 x 1,500 is exempted
+	if bm := rows[1][0]; bm.NilaiFasilitas != 5250 || bm.NilaiBayar != 5250 {
+		t.Errorf("Expected 5,250 paid and exempted, got %+v", bm)
+	}
+
+	summary := make(map[string]models.DutySummary)
+	for _, total := range calculation.Summary {
+		summary[total.KodeJenisPungutan] = total
+	}
+	totals := map[string][2]float64{
+		"BM":  {1505250, 1506000},
+		"PPN": {2366149.5, 2367000},
+		"PPH": {537761.25, 538000},
+	}
+	for code, amounts := range totals {
+		if summary[code].NilaiBayarExact != amounts[0] || summary[code].NilaiBayar != amounts[1] {
+			t.Errorf("Expected %s of %.2f rounded to %.0f, got %+v", code, amounts[0], amounts[1], summary[code])
+		}
+	}
+	if calculation.Summary[0].KodeJenisPungutan != "BM" || len(calculation.Summary) != 4 {
+		t.Errorf("Expected BM, PPN, PPNBM and PPH in order, got %+v", calculation.Summary)
+	}
+
+	// Importers without an API pay a higher PPh 22 rate
+	rows, _ = CalculateDuties(items[:1], false)
+	for _, row := range rows[0] {
+		if row.KodeJenisPungutan == KodePungutanPph && row.NilaiBayar != 1237500 {
+			t.Errorf("Expected PPh 22 of 1,237,500 without API, got %+v", row)
+		}
+	}
+}
+
+func TestApplyDutiesNeedsCustomsValue(t *testing.T) {
+	declaration := NewJsonGenerator().GenerateSampleData()
+	declaration.Barang[1].Ndpbm = 0
+
+	_, err := ApplyDuties(declaration, nil)
+	if models.ErrorCodeOf(err) != models.ErrCodeValidationFailed {
+		t.Fatalf("Expected %s, got %v", models.ErrCodeValidationFailed, err)
+	}
+	if fields := models.AsAppError(err, models.ErrCodeInternal, "").Fields; len(fields) != 1 || fields[0].Field != "barang[1].ndpbm" {
+		t.Errorf("Expected a barang[1].ndpbm problem, got %+v", fields)
+	}
+
+	if _, err := ApplyDuties(NewJsonGenerator().GenerateExportSampleData(), nil); err == nil {
+		t.Error("Expected export declarations to carry no import duties")
+	}
+}
+
+func TestCalculateDutiesRespectsZeroRates(t *testing.T) {
+	// Exempt goods declare 0% PPN and PPh 22 rather than omitting them
+	items := []DutyInput{{
+		SeriBarang: 1,
+		Cif:        1000,
+		Ndpbm:      15000,
+		Tarif: []models.BarangTarif{
+			{KodeJenisPungutan: "PPN", Tarif: 0},
+			{KodeJenisPungutan: "PPH", Tarif: 0},
+		},
+	}}
+
+	rows, _ := CalculateDuties(items, true)
+	for _, row := range rows[0] {
+		if row.Tarif != 0 || row.NilaiBayar != 0 {
+			t.Errorf("Expected a declared 0%% %s to be kept, got %+v", row.KodeJenisPungutan, row)
+		}
+	}
+}
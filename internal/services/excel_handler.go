@@ -4,108 +4,148 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/xuri/excelize/v2"
 
 	"json-response-generator/internal/models"
+	"json-response-generator/internal/telemetry"
 )
 
-// ExcelHandler service for handling Excel file operations
-type ExcelHandler struct {
-	requiredSheets map[string][]string
-}
+// ExcelHandler service for handling Excel file operations. The sheets of a
+// workbook are laid out by its document type.
+type ExcelHandler struct{}
 
 // NewExcelHandler creates a new ExcelHandler instance
 func NewExcelHandler() *ExcelHandler {
-	return &ExcelHandler{
-		requiredSheets: map[string][]string{
-			"MainData":   getMainDataColumns(),
-			"Barang":     getBarangColumns(),
-			"Entitas":    getEntitasColumns(),
-			"Kemasan":    getKemasanColumns(),
-			"Kontainer":  getKontainerColumns(),
-			"Dokumen":    getDokumenColumns(),
-			"Pengangkut": getPengangkutColumns(),
-		},
-	}
+	return &ExcelHandler{}
+}
+
+// ParseExcelFile parses an Excel file and returns structured data. The
+// document type is read from the kodeDokumen of the header sheet.
+func (eh *ExcelHandler) ParseExcelFile(filePath string) (models.ExcelData, error) {
+	return eh.ParseExcelFileForType(filePath, "")
 }
 
-// ParseExcelFile parses an Excel file and returns structured data
-func (eh *ExcelHandler) ParseExcelFile(filePath string) (*models.ExcelData, error) {
+// ParseExcelFileForType parses an Excel file laid out for the document type
+// kodeDokumen. An empty kodeDokumen is read from the header sheet.
+func (eh *ExcelHandler) ParseExcelFileForType(filePath, kodeDokumen string) (models.ExcelData, error) {
+	start := time.Now()
+	excelData, err := eh.parseExcelFile(filePath, kodeDokumen)
+	telemetry.ExcelParseDuration.WithLabelValues(telemetry.Outcome(err)).Observe(time.Since(start).Seconds())
+	return excelData, err
+}
+
+func (eh *ExcelHandler) parseExcelFile(filePath, kodeDokumen string) (models.ExcelData, error) {
 	f, err := excelize.OpenFile(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open Excel file: %w", err)
+		return nil, models.NewError(models.ErrCodeExcelUnreadable, "failed to open Excel file", err)
 	}
 	defer f.Close()
 
+	declared := headerKodeDokumen(f)
+	if kodeDokumen == "" {
+		kodeDokumen = declared
+	}
+	dt, err := LookupDocumentType(kodeDokumen)
+	if err != nil {
+		return nil, err
+	}
+	if declared != "" && declared != dt.Code {
+		return nil, models.Errorf(models.ErrCodeValidationFailed, "workbook is a kodeDokumen %s declaration, not %s", declared, dt.Code).WithFields(models.FieldError{
+			Field: HeaderSheet + ".kodeDokumen",
+			Code:  models.FieldMismatch,
+		})
+	}
+
 	// Get all sheet names
 	sheetNames := f.GetSheetList()
 
 	// Validate required sheets
 	missingSheets := []string{}
-	for sheetName := range eh.requiredSheets {
+	for _, sheet := range dt.Sheets {
 		found := false
 		for _, name := range sheetNames {
-			if name == sheetName {
+			if name == sheet.Name {
 				found = true
 				break
 			}
 		}
 		if !found {
-			missingSheets = append(missingSheets, sheetName)
+			missingSheets = append(missingSheets, sheet.Name)
 		}
 	}
 
 	if len(missingSheets) > 0 {
-		return nil, fmt.Errorf("missing required sheets: %v", missingSheets)
+		sort.Strings(missingSheets)
+		appErr := models.Errorf(models.ErrCodeExcelMissingSheet, "missing required sheets: %v", missingSheets)
+		for _, sheetName := range missingSheets {
+			appErr.WithFields(models.FieldError{
+				Field:   "sheets." + sheetName,
+				Code:    models.FieldRequired,
+				Message: "sheet " + sheetName + " is missing",
+			})
+		}
+		return nil, appErr
 	}
 
 	// Parse each sheet
-	excelData := &models.ExcelData{}
+	excelData := models.ExcelData{}
 
-	for sheetName := range eh.requiredSheets {
-		data, err := eh.parseSheet(f, sheetName)
+	for _, sheet := range dt.Sheets {
+		data, err := eh.parseSheet(f, sheet.Name, sheet.Field == "")
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse sheet %s: %w", sheetName, err)
+			return nil, models.NewError(models.ErrCodeExcelInvalidSheet, "failed to parse sheet "+sheet.Name, err).WithFields(models.FieldError{
+				Field:   "sheets." + sheet.Name,
+				Code:    models.FieldInvalidValue,
+				Message: err.Error(),
+			})
 		}
 
-		switch sheetName {
-		case "MainData":
-			excelData.MainData = data
-		case "Barang":
-			if dataArray, ok := data.([]interface{}); ok {
-				excelData.Barang = dataArray
-			}
-		case "Entitas":
-			if dataArray, ok := data.([]interface{}); ok {
-				excelData.Entitas = dataArray
-			}
-		case "Kemasan":
-			if dataArray, ok := data.([]interface{}); ok {
-				excelData.Kemasan = dataArray
-			}
-		case "Kontainer":
-			if dataArray, ok := data.([]interface{}); ok {
-				excelData.Kontainer = dataArray
-			}
-		case "Dokumen":
-			if dataArray, ok := data.([]interface{}); ok {
-				excelData.Dokumen = dataArray
-			}
-		case "Pengangkut":
-			if dataArray, ok := data.([]interface{}); ok {
-				excelData.Pengangkut = dataArray
-			}
+		rows := 1
+		if dataArray, ok := data.([]interface{}); ok {
+			rows = len(dataArray)
 		}
+		telemetry.ExcelSheetRows.WithLabelValues(sheet.Name).Observe(float64(rows))
+
+		excelData[sheet.Name] = data
+	}
+
+	// JSON generation dispatches on the kodeDokumen of the header
+	if header, ok := excelData[HeaderSheet].(map[string]interface{}); ok && declared == "" {
+		header["kodeDokumen"] = dt.Code
 	}
 
 	return excelData, nil
 }
 
-// parseSheet parses individual sheet data
-func (eh *ExcelHandler) parseSheet(f *excelize.File, sheetName string) (interface{}, error) {
+// headerKodeDokumen returns the kodeDokumen of the header sheet, empty when
+// the sheet does not state it
+func headerKodeDokumen(f *excelize.File) string {
+	rows, err := f.GetRows(HeaderSheet)
+	if err != nil || len(rows) == 0 {
+		return ""
+	}
+
+	for column, header := range rows[0] {
+		if header != "kodeDokumen" {
+			continue
+		}
+		for _, row := range rows[1:] {
+			if column < len(row) && strings.TrimSpace(row[column]) != "" {
+				return strings.TrimSpace(row[column])
+			}
+		}
+	}
+	return ""
+}
+
+// parseSheet parses individual sheet data. The header sheet holds a single
+// row and is returned as an object.
+func (eh *ExcelHandler) parseSheet(f *excelize.File, sheetName string, header bool) (interface{}, error) {
 	rows, err := f.GetRows(sheetName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get rows from sheet %s: %w", sheetName, err)
@@ -135,10 +175,10 @@ func (eh *ExcelHandler) parseSheet(f *excelize.File, sheetName string) (interfac
 		return nil, fmt.Errorf("sheet %s contains no valid data rows", sheetName)
 	}
 
-	if sheetName == "MainData" {
+	if header {
 		// Main data should have only one row
 		if len(validRows) != 1 {
-			return nil, fmt.Errorf("MainData sheet should contain exactly one data row, found %d", len(validRows))
+			return nil, fmt.Errorf("%s sheet should contain exactly one data row, found %d", sheetName, len(validRows))
 		}
 		return eh.rowToMap(headers, validRows[0]), nil
 	} else {
@@ -158,8 +198,9 @@ func (eh *ExcelHandler) rowToMap(headers []string, row []string) map[string]inte
 	for i, header := range headers {
 		var value interface{}
 		if i < len(row) && row[i] != "" {
-			// Try to parse as number first
-			if floatVal, err := strconv.ParseFloat(row[i], 64); err == nil {
+			// Try to parse as number first. Codes such as "051000" stay
+			// text since the number would lose their leading zeros.
+			if floatVal, err := strconv.ParseFloat(row[i], 64); err == nil && isCanonicalNumber(row[i], floatVal) {
 				// Check if it's an integer
 				if floatVal == float64(int64(floatVal)) {
 					value = int64(floatVal)
@@ -180,6 +221,14 @@ func (eh *ExcelHandler) rowToMap(headers []string, row []string) map[string]inte
 	return result
 }
 
+// isCanonicalNumber reports whether text is how the number is normally written
+func isCanonicalNumber(text string, number float64) bool {
+	if number == float64(int64(number)) {
+		return strconv.FormatInt(int64(number), 10) == text
+	}
+	return strconv.FormatFloat(number, 'f', -1, 64) == text
+}
+
 // isEmptyRow checks if a row is empty
 func isEmptyRow(row []string) bool {
 	for _, cell := range row {
@@ -190,51 +239,91 @@ func isEmptyRow(row []string) bool {
 	return true
 }
 
-// GenerateTemplate generates an Excel template file
+// GenerateTemplate generates the BC 2.0 Excel template
 func (eh *ExcelHandler) GenerateTemplate() (string, error) {
-	f := excelize.NewFile()
+	return eh.GenerateTemplateForTenant(nil)
+}
+
+// GenerateTemplateForTenant generates the BC 2.0 Excel template whose sample
+// rows carry the tenant's defaults
+func (eh *ExcelHandler) GenerateTemplateForTenant(defaults *models.TenantDefaults) (string, error) {
+	dt, err := LookupDocumentType(models.DefaultKodeDokumen)
+	if err != nil {
+		return "", err
+	}
+	return eh.GenerateTemplateForType(dt, defaults)
+}
+
+// GenerateTemplateForType generates the Excel template of a document type.
+// The sample rows are the type's sample data with the tenant's defaults.
+func (eh *ExcelHandler) GenerateTemplateForType(dt *DocumentType, defaults *models.TenantDefaults) (string, error) {
+	f, err := eh.buildTemplate(dt, defaults)
+	if err != nil {
+		return "", err
+	}
 	defer f.Close()
 
+	// Create temporary file using app directory instead of /tmp
+	tempDir := "/app/temp"
+	if err := os.MkdirAll(tempDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create temp directory %s: %w", tempDir, err)
+	}
+
+	fileName := fmt.Sprintf("customs_data_template_%s_%s.xlsx", dt.Code, time.Now().Format("20060102"))
+	filePath := filepath.Join(tempDir, fileName)
+
+	// Save the Excel file directly
+	if err := f.SaveAs(filePath); err != nil {
+		return "", fmt.Errorf("failed to save template file %s: %w", filePath, err)
+	}
+
+	return filePath, nil
+}
+
+// buildTemplate creates the template workbook of a document type
+func (eh *ExcelHandler) buildTemplate(dt *DocumentType, defaults *models.TenantDefaults) (*excelize.File, error) {
+	sampleData, err := declarationFields(NewJsonGenerator().GenerateSampleForType(dt, defaults))
+	if err != nil {
+		return nil, err
+	}
+
+	f := excelize.NewFile()
+
 	// Remove default sheet
 	f.DeleteSheet("Sheet1")
 
 	// Create sheets with headers and sample data
-	for sheetName, columns := range eh.requiredSheets {
-		index, err := f.NewSheet(sheetName)
+	for _, sheet := range dt.Sheets {
+		index, err := f.NewSheet(sheet.Name)
 		if err != nil {
-			return "", fmt.Errorf("failed to create sheet %s: %w", sheetName, err)
+			f.Close()
+			return nil, fmt.Errorf("failed to create sheet %s: %w", sheet.Name, err)
 		}
 
 		// Add headers
-		for i, column := range columns {
+		for i, column := range sheet.Columns {
 			cell := fmt.Sprintf("%s1", getColumnName(i))
-			f.SetCellValue(sheetName, cell, column)
+			f.SetCellValue(sheet.Name, cell, column)
 		}
 
-		// Add sample data
-		sampleData := eh.getSampleData(sheetName)
-		if sampleData != nil {
-			if sheetName == "MainData" {
-				// Single row for main data
-				if mainData, ok := sampleData.(map[string]interface{}); ok {
-					for i, column := range columns {
-						cell := fmt.Sprintf("%s2", getColumnName(i))
-						if value, exists := mainData[column]; exists {
-							f.SetCellValue(sheetName, cell, value)
-						}
-					}
-				}
-			} else {
-				// Multiple rows for other sheets
-				if arrayData, ok := sampleData.([]map[string]interface{}); ok {
-					for rowIdx, rowData := range arrayData {
-						for i, column := range columns {
-							cell := fmt.Sprintf("%s%d", getColumnName(i), rowIdx+2)
-							if value, exists := rowData[column]; exists {
-								f.SetCellValue(sheetName, cell, value)
-							}
-						}
-					}
+		// Add sample data: a single row of top-level fields for the header
+		// sheet, one row per entry of its list for the others
+		rows := []interface{}{sampleData}
+		switch {
+		case sheet.Parent != "":
+			rows = nestedSampleRows(sheet, sampleData)
+		case sheet.Field != "":
+			rows, _ = sampleData[sheet.Field].([]interface{})
+		}
+		for rowIdx, row := range rows {
+			rowData, ok := row.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for i, column := range sheet.Columns {
+				cell := fmt.Sprintf("%s%d", getColumnName(i), rowIdx+2)
+				if value, exists := rowData[column]; exists {
+					f.SetCellValue(sheet.Name, cell, value)
 				}
 			}
 		}
@@ -245,21 +334,33 @@ func (eh *ExcelHandler) GenerateTemplate() (string, error) {
 		}
 	}
 
-	// Create temporary file using app directory instead of /tmp
-	tempDir := "/app/temp"
-	if err := os.MkdirAll(tempDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create temp directory %s: %w", tempDir, err)
-	}
-
-	fileName := fmt.Sprintf("customs_data_template_%s.xlsx", time.Now().Format("20060102"))
-	filePath := filepath.Join(tempDir, fileName)
+	return f, nil
+}
 
-	// Save the Excel file directly
-	if err := f.SaveAs(filePath); err != nil {
-		return "", fmt.Errorf("failed to save template file %s: %w", filePath, err)
+// nestedSampleRows flattens the nested lists of the sample's parent entries
+// into rows carrying the parent's key
+func nestedSampleRows(sheet models.SheetLayout, sampleData map[string]interface{}) []interface{} {
+	var rows []interface{}
+	parents, _ := sampleData[sheet.Parent].([]interface{})
+	for _, parent := range parents {
+		entry, ok := parent.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		children, _ := entry[sheet.Field].([]interface{})
+		for _, child := range children {
+			childData, ok := child.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			row := map[string]interface{}{sheet.Key: entry[sheet.Key]}
+			for key, value := range childData {
+				row[key] = value
+			}
+			rows = append(rows, row)
+		}
 	}
-
-	return filePath, nil
+	return rows
 }
 
 // getColumnName converts column index to Excel column name (A, B, C, ...)
@@ -271,157 +372,3 @@ func getColumnName(index int) string {
 	}
 	return result
 }
-
-// getSampleData returns sample data for each sheet
-func (eh *ExcelHandler) getSampleData(sheetName string) interface{} {
-	switch sheetName {
-	case "MainData":
-		return map[string]interface{}{
-			"asalData":            "S",
-			"disclaimer":          "1",
-			"flagVd":              "Y",
-			"idPengguna":          "SAMPLE_USER",
-			"cif":                 1000000.0,
-			"bruto":               100.0,
-			"netto":               95.0,
-			"ndpbm":               15000.0,
-			"vd":                  100.0,
-			"nomorAju":            "SAMPLE123456789",
-			"nomorBc11":           "000001",
-			"tanggalAju":          "2021-12-25",
-			"tanggalBc11":         "2021-12-25",
-			"tanggalTiba":         "2021-12-25",
-			"tanggalTtd":          "2021-12-25",
-			"namaTtd":             "Sample User",
-			"jabatanTtd":          "Manager",
-			"kotaTtd":             "Jakarta",
-			"asuransi":            50000.0,
-			"biayaPengurang":      0.0,
-			"biayaTambahan":       0.0,
-			"fob":                 950000.0,
-			"freight":             50000.0,
-			"hargaPenyerahan":     1000000.0,
-			"jumlahTandaPengaman": 1,
-			"nilaiBarang":         1000000.0,
-			"nilaiIncoterm":       1000000.0,
-			"nilaiMaklon":         0.0,
-			"seri":                1,
-			"totalDanaSawit":      0.0,
-			"volume":              10.0,
-			"jumlahKontainer":     1,
-			"kodeAsuransi":        "LN",
-			"kodeCaraBayar":       "2",
-			"kodeDokumen":         "20",
-			"kodeIncoterm":        "CIF",
-			"kodeJenisImpor":      "1",
-			"kodeJenisNilai":      "KMD",
-			"kodeJenisProsedur":   "1",
-			"kodeKantor":          "051000",
-			"kodeTutupPu":         "11",
-			"kodeValuta":          "CNY",
-		}
-	case "Barang":
-		return []map[string]interface{}{
-			{
-				"uraian":          "Sample Product",
-				"merk":            "Sample Brand",
-				"tipe":            "Type A",
-				"ukuran":          "10x10x10 cm",
-				"spesifikasiLain": "High quality sample product",
-				"kodeHs":          "1234567890",
-				"cif":             500000.0,
-				"hargaPenyerahan": 500000.0,
-				"hargaSatuan":     50000.0,
-				"jumlahSatuan":    10.0,
-				"kodeBarang":      "SMP001",
-				"kondisiBarang":   "1",
-				"negaraAsal":      "CN",
-				"deskripsiLain":   "Sample description",
-			},
-		}
-	case "Entitas":
-		return []map[string]interface{}{
-			{
-				"jenisEntitas":  "1",
-				"namaEntitas":   "Sample Importer",
-				"alamatEntitas": "Jl. Sample No. 123, Jakarta",
-				"negaraEntitas": "ID",
-				"kodeEntitas":   "IMP001",
-				"statusApi":     "Y",
-				"nib":           "1234567890123",
-				"keterangan":    "Sample importer entity",
-			},
-		}
-	default:
-		return nil
-	}
-}
-
-// Column definitions for each sheet
-func getMainDataColumns() []string {
-	return []string{
-		"asalData", "disclaimer", "flagVd", "idPengguna", "cif", "bruto", "netto",
-		"ndpbm", "vd", "nomorAju", "nomorBc11", "tanggalAju", "tanggalBc11",
-		"tanggalTiba", "tanggalTtd", "namaTtd", "jabatanTtd", "kotaTtd",
-		"asuransi", "biayaPengurang", "biayaTambahan", "fob", "freight",
-		"hargaPenyerahan", "jumlahTandaPengaman", "nilaiBarang", "nilaiIncoterm",
-		"nilaiMaklon", "seri", "totalDanaSawit", "volume", "jumlahKontainer",
-		"kodeAsuransi", "kodeCaraBayar", "kodeDokumen", "kodeIncoterm",
-		"kodeJenisImpor", "kodeJenisNilai", "kodeJenisProsedur", "kodeKantor",
-		"kodePelMuat", "kodePelTransit", "kodePelTujuan", "kodeTps",
-		"kodeTutupPu", "kodeValuta", "posBc11", "subPosBc11",
-	}
-}
-
-func getBarangColumns() []string {
-	return []string{
-		"uraian", "merk", "tipe", "ukuran", "spesifikasiLain", "kodeHs",
-		"cif", "hargaPenyerahan", "hargaSatuan", "jumlahSatuan", "kodeBarang",
-		"kondisiBarang", "negaraAsal", "deskripsiLain", "bruto", "netto",
-		"volume", "jumlahKemasan", "kodeJenisKemasan", "kodeSatuanBarang",
-		"posTarif", "seriBarang", "tahunPembuatan", "asuransi", "diskon",
-		"fob", "freight", "hargaEkspor", "hargaPatokan", "hargaPerolehan",
-		"hjeCukai", "isiPerKemasan", "jumlahBahanBaku", "jumlahDilekatkan",
-		"jumlahPitaCukai", "jumlahRealisasi", "kapasitasSilinder",
-		"kodeKondisiBarang", "kodeNegaraAsal", "ndpbm", "nilaiBarang",
-		"nilaiDanaSawit", "nilaiDevisa", "nilaiTambah", "pernyataanLartas",
-		"persentaseImpor", "saldoAkhir", "saldoAwal", "seriBarangDokAsal",
-		"seriIjin", "tarifCukai", "cifRupiah",
-	}
-}
-
-func getEntitasColumns() []string {
-	return []string{
-		"jenisEntitas", "namaEntitas", "alamatEntitas", "negaraEntitas",
-		"kodeEntitas", "statusApi", "nib", "keterangan", "seriEntitas",
-		"kodeJenisApi", "kodeJenisIdentitas", "kodeStatus", "nibEntitas",
-		"nomorIdentitas", "kodeNegara",
-	}
-}
-
-func getKemasanColumns() []string {
-	return []string{
-		"jumlahKemasan", "kodeJenisKemasan", "merkKemasan", "seriKemasan",
-	}
-}
-
-func getKontainerColumns() []string {
-	return []string{
-		"kodeJenisKontainer", "kodeTipeKontainer", "kodeUkuranKontainer",
-		"nomorKontainer", "seriKontainer",
-	}
-}
-
-func getDokumenColumns() []string {
-	return []string{
-		"idDokumen", "kodeDokumen", "kodeFasilitas", "nomorDokumen",
-		"seriDokumen", "tanggalDokumen", "namaFasilitas",
-	}
-}
-
-func getPengangkutColumns() []string {
-	return []string{
-		"kodeBendera", "namaPengangkut", "nomorPengangkut", "kodeCaraAngkut",
-		"seriPengangkut",
-	}
-}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+
+	"json-response-generator/internal/models"
+)
+
+func TestParseExcelFileMissingSheets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "partial.xlsx")
+	f := excelize.NewFile()
+	f.SetSheetName("Sheet1", "MainData")
+	if err := f.SaveAs(path); err != nil {
+		t.Fatalf("Failed to save workbook: %v", err)
+	}
+
+	_, err := NewExcelHandler().ParseExcelFile(path)
+	if models.ErrorCodeOf(err) != models.ErrCodeExcelMissingSheet {
+		t.Fatalf("Expected %s, got %v", models.ErrCodeExcelMissingSheet, err)
+	}
+
+	appErr := models.AsAppError(err, models.ErrCodeInternal, "")
+	fields := make(map[string]bool)
+	for _, field := range appErr.Fields {
+		fields[field.Field] = true
+	}
+	if !fields["sheets.Barang"] || fields["sheets.MainData"] {
+		t.Errorf("Expected missing sheets as field errors, got %+v", appErr.Fields)
+	}
+}
+
+func TestParseExcelFileUnreadable(t *testing.T) {
+	_, err := NewExcelHandler().ParseExcelFile(filepath.Join(t.TempDir(), "missing.xlsx"))
+	if models.ErrorCodeOf(err) != models.ErrCodeExcelUnreadable {
+		t.Errorf("Expected %s, got %v", models.ErrCodeExcelUnreadable, err)
+	}
+}
+
+func TestTemplateRoundTrip(t *testing.T) {
+	for _, dt := range DocumentTypes() {
+		eh := NewExcelHandler()
+		f, err := eh.buildTemplate(dt, nil)
+		if err != nil {
+			t.Fatalf("Failed to build %s template: %v", dt.Name, err)
+		}
+		path := filepath.Join(t.TempDir(), "template.xlsx")
+		if err := f.SaveAs(path); err != nil {
+			t.Fatalf("Failed to save workbook: %v", err)
+		}
+		f.Close()
+
+		excelData, err := eh.ParseExcelFileForType(path, dt.Code)
+		if err != nil {
+			t.Fatalf("Failed to parse %s template: %v", dt.Name, err)
+		}
+
+		declaration, err := NewJsonGenerator().GenerateFromData(excelData)
+		if err != nil {
+			t.Fatalf("Failed to generate %s from its template: %v", dt.Name, err)
+		}
+		if err := dt.Validate(declaration); err != nil {
+			t.Errorf("%s generated from its template is invalid: %v", dt.Name, err)
+		}
+
+		// Leading zeros of codes survive the round trip
+		if got, want := *declaration.Header().NomorAju, *dt.Sample().Header().NomorAju; got != want {
+			t.Errorf("Expected nomorAju %q, got %q", want, got)
+		}
+	}
+}
+
+func TestParseExcelFileChecksDocumentType(t *testing.T) {
+	dt, _ := LookupDocumentType("20")
+	f, err := NewExcelHandler().buildTemplate(dt, nil)
+	if err != nil {
+		t.Fatalf("Failed to build template: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "template.xlsx")
+	if err := f.SaveAs(path); err != nil {
+		t.Fatalf("Failed to save workbook: %v", err)
+	}
+	f.Close()
+
+	_, err = NewExcelHandler().ParseExcelFileForType(path, "99")
+	if models.ErrorCodeOf(err) != models.ErrCodeUnknownDocumentType {
+		t.Errorf("Expected %s, got %v", models.ErrCodeUnknownDocumentType, err)
+	}
+
+	// A BC 2.0 workbook uploaded as BC 3.0
+	_, err = NewExcelHandler().ParseExcelFileForType(path, "30")
+	if models.ErrorCodeOf(err) != models.ErrCodeValidationFailed {
+		t.Fatalf("Expected %s, got %v", models.ErrCodeValidationFailed, err)
+	}
+	if fields := models.AsAppError(err, models.ErrCodeInternal, "").Fields; len(fields) != 1 || fields[0].Field != "MainData.kodeDokumen" {
+		t.Errorf("Expected a MainData.kodeDokumen problem, got %+v", fields)
+	}
+}
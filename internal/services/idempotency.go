@@ -0,0 +1,153 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"json-response-generator/internal/models"
+)
+
+// IdempotencyResult is the recorded outcome of a submission
+type IdempotencyResult struct {
+	Success    bool                   `json:"success"`
+	Response   map[string]interface{} `json:"response"`
+	RecordedAt time.Time              `json:"recorded_at"`
+}
+
+// idempotencyEntry tracks a single in-flight or completed submission
+type idempotencyEntry struct {
+	fingerprint string // hash of the submitted declaration
+	done        chan struct{}
+	result      *IdempotencyResult
+}
+
+// IdempotencyStore deduplicates submissions sharing the same idempotency key
+type IdempotencyStore struct {
+	entries map[string]*idempotencyEntry
+	ttl     time.Duration
+	mutex   sync.Mutex
+}
+
+// NewIdempotencyStore creates a new IdempotencyStore keeping results for ttl
+func NewIdempotencyStore(ttl time.Duration) *IdempotencyStore {
+	return &IdempotencyStore{
+		entries: make(map[string]*idempotencyEntry),
+		ttl:     ttl,
+	}
+}
+
+// DeriveIdempotencyKey builds the default key from nomorAju and a hash of the canonical JSON
+func DeriveIdempotencyKey(data models.Declaration) (string, error) {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal data: %w", err)
+	}
+
+	sum := sha256.Sum256(jsonData)
+	return fmt.Sprintf("%s:%s", *data.Header().NomorAju, hex.EncodeToString(sum[:])), nil
+}
+
+// Begin reserves the key for a new submission of the declaration with the
+// given fingerprint. If the key is already in flight it waits for that
+// submission to finish; if it has completed, the recorded result is returned
+// and the caller must not submit again. A key used for another declaration is
+// a conflict.
+func (is *IdempotencyStore) Begin(key, fingerprint string) (*IdempotencyResult, bool, error) {
+	is.mutex.Lock()
+	is.pruneLocked()
+
+	entry, exists := is.entries[key]
+	if !exists {
+		is.entries[key] = &idempotencyEntry{fingerprint: fingerprint, done: make(chan struct{})}
+		is.mutex.Unlock()
+		return nil, false, nil
+	}
+	is.mutex.Unlock()
+
+	if entry.fingerprint != fingerprint {
+		return nil, false, models.NewError(models.ErrCodeConflict, "idempotency key was already used for a different declaration", nil)
+	}
+
+	<-entry.done
+
+	if entry.result == nil {
+		// The previous attempt was abandoned, try to take over the key
+		return is.Begin(key, fingerprint)
+	}
+
+	return entry.result, true, nil
+}
+
+// Complete records the result of the submission reserved with Begin
+func (is *IdempotencyStore) Complete(key string, success bool, response map[string]interface{}) {
+	is.mutex.Lock()
+	defer is.mutex.Unlock()
+
+	entry, exists := is.entries[key]
+	if !exists {
+		return
+	}
+
+	entry.result = &IdempotencyResult{
+		Success:    success,
+		Response:   response,
+		RecordedAt: time.Now(),
+	}
+	close(entry.done)
+}
+
+// Abandon releases a key whose submission failed before a result was obtained
+func (is *IdempotencyStore) Abandon(key string) {
+	is.mutex.Lock()
+	defer is.mutex.Unlock()
+
+	entry, exists := is.entries[key]
+	if !exists || entry.result != nil {
+		return
+	}
+
+	delete(is.entries, key)
+	close(entry.done)
+}
+
+// Store records a result outside of Begin/Complete, replacing any completed
+// entry for the key. It is used for forced resubmissions.
+func (is *IdempotencyStore) Store(key, fingerprint string, success bool, response map[string]interface{}) {
+	is.mutex.Lock()
+	defer is.mutex.Unlock()
+
+	if entry, exists := is.entries[key]; exists && entry.result == nil {
+		// Leave in-flight submissions to record their own result
+		return
+	}
+
+	done := make(chan struct{})
+	close(done)
+	is.entries[key] = &idempotencyEntry{
+		fingerprint: fingerprint,
+		done:        done,
+		result: &IdempotencyResult{
+			Success:    success,
+			Response:   response,
+			RecordedAt: time.Now(),
+		},
+	}
+}
+
+// pruneLocked drops completed entries older than the ttl
+func (is *IdempotencyStore) pruneLocked() {
+	if is.ttl <= 0 {
+		return
+	}
+
+	cutoff := time.Now().Add(-is.ttl)
+	for key, entry := range is.entries {
+		if entry.result != nil && entry.result.RecordedAt.Before(cutoff) {
+			delete(is.entries, key)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"json-response-generator/internal/models"
+)
+
+func TestDeriveIdempotencyKey(t *testing.T) {
+	generator := NewJsonGenerator()
+	data := generator.GenerateSampleData()
+
+	key1, err := DeriveIdempotencyKey(data)
+	if err != nil {
+		t.Fatalf("DeriveIdempotencyKey() error = %v", err)
+	}
+
+	key2, _ := DeriveIdempotencyKey(generator.GenerateSampleData())
+	if key1 != key2 {
+		t.Errorf("Expected identical payloads to derive the same key, got %s and %s", key1, key2)
+	}
+
+	data.Barang[0].JumlahSatuan = 11
+	key3, _ := DeriveIdempotencyKey(data)
+	if key1 == key3 {
+		t.Error("Expected different payloads to derive different keys")
+	}
+
+	if key1[:len(data.NomorAju)] != data.NomorAju {
+		t.Errorf("Expected key to start with nomorAju %s, got %s", data.NomorAju, key1)
+	}
+}
+
+func TestIdempotencyStoreReplaysCompletedResult(t *testing.T) {
+	store := NewIdempotencyStore(time.Hour)
+
+	if _, replayed, _ := store.Begin("key", "declaration"); replayed {
+		t.Fatal("First Begin should not replay")
+	}
+
+	store.Complete("key", true, map[string]interface{}{"nomorDaftar": "000123"})
+
+	result, replayed, _ := store.Begin("key", "declaration")
+	if !replayed {
+		t.Fatal("Second Begin should replay the recorded result")
+	}
+	if !result.Success || result.Response["nomorDaftar"] != "000123" {
+		t.Errorf("Unexpected replayed result: %+v", result)
+	}
+}
+
+func TestIdempotencyStoreWaitsForInFlight(t *testing.T) {
+	store := NewIdempotencyStore(time.Hour)
+	store.Begin("key", "declaration")
+
+	var wg sync.WaitGroup
+	results := make([]*IdempotencyResult, 5)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _, _ = store.Begin("key", "declaration")
+		}(i)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	store.Complete("key", false, map[string]interface{}{"status": "rejected"})
+	wg.Wait()
+
+	for i, result := range results {
+		if result == nil || result.Response["status"] != "rejected" {
+			t.Errorf("Waiter %d did not receive the recorded result: %+v", i, result)
+		}
+	}
+}
+
+func TestIdempotencyStoreRejectsReusedKey(t *testing.T) {
+	store := NewIdempotencyStore(time.Hour)
+	store.Begin("key", "declaration")
+
+	// In flight or completed, another declaration never gets the result
+	if _, _, err := store.Begin("key", "other"); models.ErrorCodeOf(err) != models.ErrCodeConflict {
+		t.Errorf("Expected a conflict for an in-flight key, got %v", err)
+	}
+
+	store.Complete("key", true, map[string]interface{}{"nomorDaftar": "000123"})
+	result, replayed, err := store.Begin("key", "other")
+	if models.ErrorCodeOf(err) != models.ErrCodeConflict || replayed || result != nil {
+		t.Errorf("Expected a conflict for a completed key, got %+v, %v", result, err)
+	}
+}
+
+func TestIdempotencyStoreAbandon(t *testing.T) {
+	store := NewIdempotencyStore(time.Hour)
+	store.Begin("key", "declaration")
+	store.Abandon("key")
+
+	if _, replayed, _ := store.Begin("key", "declaration"); replayed {
+		t.Error("Abandoned key should be available for a new submission")
+	}
+}
+
+func TestIdempotencyStoreForcedStore(t *testing.T) {
+	store := NewIdempotencyStore(time.Hour)
+	store.Begin("key", "declaration")
+	store.Complete("key", false, nil)
+
+	store.Store("key", "declaration", true, map[string]interface{}{"attempt": 2})
+
+	result, replayed, _ := store.Begin("key", "declaration")
+	if !replayed || !result.Success {
+		t.Errorf("Expected forced result to replace the recorded one, got %+v", result)
+	}
+}
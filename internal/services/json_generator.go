@@ -3,6 +3,9 @@ package services
 import (
 	"encoding/json"
 	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
 
 	"json-response-generator/internal/models"
 )
@@ -148,8 +151,8 @@ func (jg *JsonGenerator) GenerateSampleData() *models.ResponseData {
 	return responseData
 }
 
-// GenerateJsonString generates JSON string from ResponseData object
-func (jg *JsonGenerator) GenerateJsonString(data *models.ResponseData) (string, error) {
+// GenerateJsonString generates JSON string from a declaration
+func (jg *JsonGenerator) GenerateJsonString(data models.Declaration) (string, error) {
 	if data == nil {
 		data = jg.GenerateSampleData()
 	}
@@ -162,71 +165,292 @@ func (jg *JsonGenerator) GenerateJsonString(data *models.ResponseData) (string,
 	return string(jsonBytes), nil
 }
 
-// GenerateFromData generates ResponseData from input data (web forms or Excel)
-func (jg *JsonGenerator) GenerateFromData(inputData map[string]interface{}) (*models.ResponseData, error) {
+// GenerateFromData generates a declaration from input data (web forms or
+// Excel). The model is chosen by the kodeDokumen of the input.
+func (jg *JsonGenerator) GenerateFromData(inputData map[string]interface{}) (models.Declaration, error) {
 	// Handle different input formats
-	if _, exists := inputData["MainData"]; exists {
+	if mainData, exists := inputData[HeaderSheet]; exists {
 		// Excel format
-		return jg.generateFromExcelData(inputData)
+		mainDataMap, ok := mainData.(map[string]interface{})
+		if !ok {
+			return nil, models.NewError(models.ErrCodeValidationFailed, "MainData is not a valid object", nil).WithFields(models.FieldError{
+				Field: HeaderSheet,
+				Code:  models.FieldInvalidType,
+			})
+		}
+
+		dt, err := LookupDocumentType(kodeDokumenOf(mainDataMap))
+		if err != nil {
+			return nil, err
+		}
+		return jg.generateFromExcelData(dt, mainDataMap, inputData)
 	} else {
 		// Web form format
-		return jg.generateFromFormData(inputData)
+		dt, err := LookupDocumentType(kodeDokumenOf(inputData))
+		if err != nil {
+			return nil, err
+		}
+		return jg.generateFromFormData(dt, inputData)
+	}
+}
+
+// kodeDokumenOf returns the kodeDokumen of input data. Spreadsheets may hold
+// it as a number.
+func kodeDokumenOf(data map[string]interface{}) string {
+	switch value := data["kodeDokumen"].(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	default:
+		return fmt.Sprint(value)
 	}
 }
 
-// generateFromExcelData generates ResponseData from Excel data format
-func (jg *JsonGenerator) generateFromExcelData(excelData map[string]interface{}) (*models.ResponseData, error) {
-	// Extract main data
-	mainDataInterface, ok := excelData["MainData"]
-	if !ok {
-		return nil, fmt.Errorf("MainData not found in Excel data")
+// PrefillTenantDefaults fills fields a declaration leaves empty with the
+// tenant's defaults. Default entitas are added when the declaration has no
+// entitas of the same kodeEntitas.
+func (jg *JsonGenerator) PrefillTenantDefaults(data models.Declaration, defaults *models.TenantDefaults) {
+	if defaults == nil {
+		return
 	}
 
-	mainDataMap, ok := mainDataInterface.(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("MainData is not a valid object")
+	header := data.Header()
+	if *header.KodeKantor == "" {
+		*header.KodeKantor = defaults.KodeKantor
+	}
+	if *header.IdPengguna == "" {
+		*header.IdPengguna = defaults.IdPengguna
 	}
 
-	// Convert arrays
-	barangData := jg.convertToBarangArray(excelData["Barang"])
-	entitasData := jg.convertToEntitasArray(excelData["Entitas"])
-	kemasanData := jg.convertToKemasanArray(excelData["Kemasan"])
-	kontainerData := jg.convertToKontainerArray(excelData["Kontainer"])
-	dokumenData := jg.convertToDokumenArray(excelData["Dokumen"])
-	pengangkutData := jg.convertToPengangkutArray(excelData["Pengangkut"])
+	for _, entitas := range defaults.Entitas {
+		if !hasEntitas(*header.Entitas, entitas.KodeEntitas) {
+			entitas.SeriEntitas = len(*header.Entitas) + 1
+			*header.Entitas = append(*header.Entitas, entitas)
+		}
+	}
+}
 
-	// Create response data
-	responseData := &models.ResponseData{
-		Barang:     barangData,
-		Entitas:    entitasData,
-		Kemasan:    kemasanData,
-		Kontainer:  kontainerData,
-		Dokumen:    dokumenData,
-		Pengangkut: pengangkutData,
+// GenerateSampleDataForTenant generates BC 2.0 sample data carrying the
+// tenant's defaults instead of the generic sample values
+func (jg *JsonGenerator) GenerateSampleDataForTenant(defaults *models.TenantDefaults) *models.ResponseData {
+	data := jg.GenerateSampleData()
+	jg.applySampleDefaults(data, defaults)
+	return data
+}
+
+// GenerateSampleForType generates sample data of a document type carrying the
+// tenant's defaults instead of the generic sample values
+func (jg *JsonGenerator) GenerateSampleForType(dt *DocumentType, defaults *models.TenantDefaults) models.Declaration {
+	data := dt.Sample()
+	jg.applySampleDefaults(data, defaults)
+	return data
+}
+
+// applySampleDefaults replaces sample values with the tenant's defaults
+func (jg *JsonGenerator) applySampleDefaults(data models.Declaration, defaults *models.TenantDefaults) {
+	if defaults == nil {
+		return
+	}
+
+	header := data.Header()
+	if defaults.KodeKantor != "" {
+		*header.KodeKantor = defaults.KodeKantor
+	}
+	if defaults.IdPengguna != "" {
+		*header.IdPengguna = defaults.IdPengguna
+	}
+
+	sampleEntitas := *header.Entitas
+	for _, entitas := range defaults.Entitas {
+		for i := range sampleEntitas {
+			if sampleEntitas[i].KodeEntitas == entitas.KodeEntitas {
+				entitas.SeriEntitas = sampleEntitas[i].SeriEntitas
+				sampleEntitas[i] = entitas
+			}
+		}
+	}
+	jg.PrefillTenantDefaults(data, defaults)
+}
+
+func hasEntitas(entitas []models.Entitas, kodeEntitas string) bool {
+	for _, e := range entitas {
+		if e.KodeEntitas == kodeEntitas {
+			return true
+		}
+	}
+	return false
+}
+
+// generateFromExcelData generates a declaration from parsed Excel sheets. The
+// header sheet holds the top-level fields, every other sheet of the type's
+// layout the rows of one list.
+func (jg *JsonGenerator) generateFromExcelData(dt *DocumentType, mainData map[string]interface{}, excelData map[string]interface{}) (models.Declaration, error) {
+	fields := make(map[string]interface{}, len(mainData)+len(dt.Sheets))
+	for key, value := range mainData {
+		fields[key] = value
+	}
+	for _, sheet := range dt.Sheets {
+		if sheet.Field == "" || sheet.Parent != "" {
+			continue
+		}
+		rows, _ := excelData[sheet.Name].([]interface{})
+		if rows == nil {
+			rows = []interface{}{}
+		}
+		fields[sheet.Field] = rows
+	}
+	for _, sheet := range dt.Sheets {
+		if sheet.Parent == "" {
+			continue
+		}
+		rows, _ := excelData[sheet.Name].([]interface{})
+		if err := nestRows(sheet, rows, fields); err != nil {
+			return nil, err
+		}
 	}
 
-	// Map main data fields
-	if err := jg.mapMainDataFields(mainDataMap, responseData); err != nil {
-		return nil, fmt.Errorf("failed to map main data fields: %w", err)
+	declaration := dt.New()
+
+	// Spreadsheet cells carry no type, convert them to the model's
+	jsonBytes, err := json.Marshal(coerceToModel(fields, reflect.TypeOf(declaration)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal Excel data: %w", err)
+	}
+
+	if err := json.Unmarshal(jsonBytes, declaration); err != nil {
+		appErr := models.BindingError(err)
+		appErr.Detail = "failed to unmarshal Excel data to " + dt.Name
+		return nil, appErr
+	}
+
+	return declaration, nil
+}
+
+// nestRows adds the rows of a nested sheet to the entries of its parent list
+// they refer to, e.g. BarangDokumen rows to the barang of the same seriBarang
+func nestRows(sheet models.SheetLayout, rows []interface{}, fields map[string]interface{}) error {
+	// Copy the parent entries, they belong to the parsed workbook
+	source, _ := fields[sheet.Parent].([]interface{})
+	parents := make([]interface{}, len(source))
+	for i, candidate := range source {
+		parents[i] = candidate
+		if entry, ok := candidate.(map[string]interface{}); ok {
+			copied := make(map[string]interface{}, len(entry)+1)
+			for key, value := range entry {
+				copied[key] = value
+			}
+			parents[i] = copied
+		}
 	}
+	fields[sheet.Parent] = parents
+
+	for i, row := range rows {
+		rowData, ok := row.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		var parent map[string]interface{}
+		for _, candidate := range parents {
+			entry, ok := candidate.(map[string]interface{})
+			if ok && entry[sheet.Key] != nil && fmt.Sprint(entry[sheet.Key]) == fmt.Sprint(rowData[sheet.Key]) {
+				parent = entry
+				break
+			}
+		}
+		if parent == nil {
+			return models.Errorf(models.ErrCodeValidationFailed, "%s row %d refers to %s %v, which is not in sheet %s", sheet.Name, i+2, sheet.Key, rowData[sheet.Key], sheet.Parent).WithFields(models.FieldError{
+				Field: fmt.Sprintf("%s[%d].%s", sheet.Name, i, sheet.Key),
+				Code:  models.FieldInvalidValue,
+			})
+		}
 
-	return responseData, nil
+		children, _ := parent[sheet.Field].([]interface{})
+		parent[sheet.Field] = append(children, rowData)
+	}
+	return nil
 }
 
-// generateFromFormData generates ResponseData from web form data format
-func (jg *JsonGenerator) generateFromFormData(formData map[string]interface{}) (*models.ResponseData, error) {
-	// Convert form data to ResponseData
+// generateFromFormData generates a declaration from web form data format
+func (jg *JsonGenerator) generateFromFormData(dt *DocumentType, formData map[string]interface{}) (models.Declaration, error) {
+	// Convert form data to the document type's model
 	jsonBytes, err := json.Marshal(formData)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal form data: %w", err)
 	}
 
-	var responseData models.ResponseData
-	if err := json.Unmarshal(jsonBytes, &responseData); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal to ResponseData: %w", err)
+	declaration := dt.New()
+	if err := json.Unmarshal(jsonBytes, declaration); err != nil {
+		appErr := models.BindingError(err)
+		appErr.Detail = "failed to unmarshal to " + dt.Name
+		return nil, appErr
+	}
+
+	return declaration, nil
+}
+
+// coerceToModel converts spreadsheet values to the JSON types of the fields of
+// t: numbers become text for string fields and numeric text becomes a number
+// for numeric fields. Empty cells are dropped.
+func coerceToModel(value interface{}, t reflect.Type) interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		switch v := value.(type) {
+		case int64:
+			return strconv.FormatInt(v, 10)
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+	case reflect.Int, reflect.Int64, reflect.Float64:
+		if text, ok := value.(string); ok {
+			if number, err := strconv.ParseFloat(strings.TrimSpace(text), 64); err == nil {
+				return number
+			}
+		}
+	case reflect.Slice:
+		if items, ok := value.([]interface{}); ok {
+			coerced := make([]interface{}, 0, len(items))
+			for _, item := range items {
+				coerced = append(coerced, coerceToModel(item, t.Elem()))
+			}
+			return coerced
+		}
+	case reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return value
+		}
+
+		coerced := make(map[string]interface{}, len(object))
+		for key, fieldValue := range object {
+			if fieldValue == "" {
+				continue
+			}
+			coerced[key] = fieldValue
+			if field, ok := fieldByJSONName(t, key); ok {
+				coerced[key] = coerceToModel(fieldValue, field.Type)
+			}
+		}
+		return coerced
 	}
 
-	return &responseData, nil
+	return value
+}
+
+// fieldByJSONName returns the struct field encoded under name
+func fieldByJSONName(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if strings.Split(field.Tag.Get("json"), ",")[0] == name {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
 }
 
 // Helper methods for creating sample data
@@ -281,7 +505,7 @@ func (jg *JsonGenerator) createBarang1() models.Barang {
 		BarangTarif: []models.BarangTarif{
 			{
 				JumlahSatuan:       10,
-				KodeFasilitasTarif: "00",
+				KodeFasilitasTarif: KodeFasilitasDibayar,
 				KodeJenisPungutan:  "1",
 				KodeJenisTarif:     "1",
 				NilaiBayar:         61728.395,
@@ -296,193 +520,6 @@ func (jg *JsonGenerator) createBarang1() models.Barang {
 	}
 }
 
-// Helper methods for converting Excel data arrays
-func (jg *JsonGenerator) convertToBarangArray(data interface{}) []models.Barang {
-	if data == nil {
-		return []models.Barang{}
-	}
-
-	var result []models.Barang
-	if items, ok := data.([]interface{}); ok {
-		for _, item := range items {
-			if itemMap, ok := item.(map[string]interface{}); ok {
-				var barang models.Barang
-				if jsonBytes, err := json.Marshal(itemMap); err == nil {
-					json.Unmarshal(jsonBytes, &barang)
-					result = append(result, barang)
-				}
-			}
-		}
-	}
-	return result
-}
-
-func (jg *JsonGenerator) convertToEntitasArray(data interface{}) []models.Entitas {
-	if data == nil {
-		return []models.Entitas{}
-	}
-
-	var result []models.Entitas
-	if items, ok := data.([]interface{}); ok {
-		for _, item := range items {
-			if itemMap, ok := item.(map[string]interface{}); ok {
-				var entitas models.Entitas
-				if jsonBytes, err := json.Marshal(itemMap); err == nil {
-					json.Unmarshal(jsonBytes, &entitas)
-					result = append(result, entitas)
-				}
-			}
-		}
-	}
-	return result
-}
-
-func (jg *JsonGenerator) convertToKemasanArray(data interface{}) []models.Kemasan {
-	if data == nil {
-		return []models.Kemasan{}
-	}
-
-	var result []models.Kemasan
-	if items, ok := data.([]interface{}); ok {
-		for _, item := range items {
-			if itemMap, ok := item.(map[string]interface{}); ok {
-				var kemasan models.Kemasan
-				if jsonBytes, err := json.Marshal(itemMap); err == nil {
-					json.Unmarshal(jsonBytes, &kemasan)
-					result = append(result, kemasan)
-				}
-			}
-		}
-	}
-	return result
-}
-
-func (jg *JsonGenerator) convertToKontainerArray(data interface{}) []models.Kontainer {
-	if data == nil {
-		return []models.Kontainer{}
-	}
-
-	var result []models.Kontainer
-	if items, ok := data.([]interface{}); ok {
-		for _, item := range items {
-			if itemMap, ok := item.(map[string]interface{}); ok {
-				var kontainer models.Kontainer
-				if jsonBytes, err := json.Marshal(itemMap); err == nil {
-					json.Unmarshal(jsonBytes, &kontainer)
-					result = append(result, kontainer)
-				}
-			}
-		}
-	}
-	return result
-}
-
-func (jg *JsonGenerator) convertToDokumenArray(data interface{}) []models.Dokumen {
-	if data == nil {
-		return []models.Dokumen{}
-	}
-
-	var result []models.Dokumen
-	if items, ok := data.([]interface{}); ok {
-		for _, item := range items {
-			if itemMap, ok := item.(map[string]interface{}); ok {
-				var dokumen models.Dokumen
-				if jsonBytes, err := json.Marshal(itemMap); err == nil {
-					json.Unmarshal(jsonBytes, &dokumen)
-					result = append(result, dokumen)
-				}
-			}
-		}
-	}
-	return result
-}
-
-func (jg *JsonGenerator) convertToPengangkutArray(data interface{}) []models.Pengangkut {
-	if data == nil {
-		return []models.Pengangkut{}
-	}
-
-	var result []models.Pengangkut
-	if items, ok := data.([]interface{}); ok {
-		for _, item := range items {
-			if itemMap, ok := item.(map[string]interface{}); ok {
-				var pengangkut models.Pengangkut
-				if jsonBytes, err := json.Marshal(itemMap); err == nil {
-					json.Unmarshal(jsonBytes, &pengangkut)
-					result = append(result, pengangkut)
-				}
-			}
-		}
-	}
-	return result
-}
-
-// mapMainDataFields maps main data fields from Excel to ResponseData
-func (jg *JsonGenerator) mapMainDataFields(mainDataMap map[string]interface{}, responseData *models.ResponseData) error {
-	jsonBytes, err := json.Marshal(mainDataMap)
-	if err != nil {
-		return fmt.Errorf("failed to marshal main data: %w", err)
-	}
-
-	// Create a temporary struct to unmarshal main data
-	var tempData models.ResponseData
-	if err := json.Unmarshal(jsonBytes, &tempData); err != nil {
-		return fmt.Errorf("failed to unmarshal main data: %w", err)
-	}
-
-	// Copy main data fields (excluding arrays)
-	responseData.AsalData = tempData.AsalData
-	responseData.Asuransi = tempData.Asuransi
-	responseData.BiayaPengurang = tempData.BiayaPengurang
-	responseData.BiayaTambahan = tempData.BiayaTambahan
-	responseData.Bruto = tempData.Bruto
-	responseData.Cif = tempData.Cif
-	responseData.Disclaimer = tempData.Disclaimer
-	responseData.FlagVd = tempData.FlagVd
-	responseData.Fob = tempData.Fob
-	responseData.Freight = tempData.Freight
-	responseData.HargaPenyerahan = tempData.HargaPenyerahan
-	responseData.IdPengguna = tempData.IdPengguna
-	responseData.JabatanTtd = tempData.JabatanTtd
-	responseData.JumlahKontainer = tempData.JumlahKontainer
-	responseData.JumlahTandaPengaman = tempData.JumlahTandaPengaman
-	responseData.KodeAsuransi = tempData.KodeAsuransi
-	responseData.KodeCaraBayar = tempData.KodeCaraBayar
-	responseData.KodeDokumen = tempData.KodeDokumen
-	responseData.KodeIncoterm = tempData.KodeIncoterm
-	responseData.KodeJenisImpor = tempData.KodeJenisImpor
-	responseData.KodeJenisNilai = tempData.KodeJenisNilai
-	responseData.KodeJenisProsedur = tempData.KodeJenisProsedur
-	responseData.KodeKantor = tempData.KodeKantor
-	responseData.KodePelMuat = tempData.KodePelMuat
-	responseData.KodePelTransit = tempData.KodePelTransit
-	responseData.KodePelTujuan = tempData.KodePelTujuan
-	responseData.KodeTps = tempData.KodeTps
-	responseData.KodeTutupPu = tempData.KodeTutupPu
-	responseData.KodeValuta = tempData.KodeValuta
-	responseData.KotaTtd = tempData.KotaTtd
-	responseData.NamaTtd = tempData.NamaTtd
-	responseData.Ndpbm = tempData.Ndpbm
-	responseData.Netto = tempData.Netto
-	responseData.NilaiBarang = tempData.NilaiBarang
-	responseData.NilaiIncoterm = tempData.NilaiIncoterm
-	responseData.NilaiMaklon = tempData.NilaiMaklon
-	responseData.NomorAju = tempData.NomorAju
-	responseData.NomorBc11 = tempData.NomorBc11
-	responseData.PosBc11 = tempData.PosBc11
-	responseData.Seri = tempData.Seri
-	responseData.SubPosBc11 = tempData.SubPosBc11
-	responseData.TanggalAju = tempData.TanggalAju
-	responseData.TanggalBc11 = tempData.TanggalBc11
-	responseData.TanggalTiba = tempData.TanggalTiba
-	responseData.TanggalTtd = tempData.TanggalTtd
-	responseData.TotalDanaSawit = tempData.TotalDanaSawit
-	responseData.Volume = tempData.Volume
-	responseData.Vd = tempData.Vd
-
-	return nil
-}
-
 func (jg *JsonGenerator) createBarang2() models.Barang {
 	return models.Barang{
 		Asuransi:          0,
@@ -534,7 +571,7 @@ func (jg *JsonGenerator) createBarang2() models.Barang {
 		BarangTarif: []models.BarangTarif{
 			{
 				JumlahSatuan:       10,
-				KodeFasilitasTarif: "00",
+				KodeFasilitasTarif: KodeFasilitasDibayar,
 				KodeJenisPungutan:  "1",
 				KodeJenisTarif:     "1",
 				NilaiBayar:         61728.394,
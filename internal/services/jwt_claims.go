@@ -0,0 +1,337 @@
+package services
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"json-response-generator/internal/models"
+)
+
+// KodeEntitasImportir is the kodeEntitas of the importer on a BC 2.0 declaration
+const KodeEntitasImportir = "1"
+
+// jwtHeader is the decoded JOSE header of a JWT
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// ParseTokenClaims decodes the claims of a JWT without verifying its signature
+func ParseTokenClaims(token string) (*models.TokenClaims, error) {
+	_, payload, _, err := splitJWT(token)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse token claims: %w", err)
+	}
+
+	claims := &models.TokenClaims{
+		Subject:  claimString(raw, "sub"),
+		Username: claimString(raw, "preferred_username", "username"),
+		Name:     claimString(raw, "name"),
+		Email:    claimString(raw, "email"),
+		NPWP:     claimString(raw, "npwp", "NPWP", "npwp_perusahaan"),
+		NIB:      claimString(raw, "nib", "NIB"),
+		Roles:    claimRoles(raw),
+		Issuer:   claimString(raw, "iss"),
+	}
+
+	if iat, ok := raw["iat"].(float64); ok {
+		claims.IssuedAt = time.Unix(int64(iat), 0)
+	}
+	if exp, ok := raw["exp"].(float64); ok {
+		claims.ExpiresAt = time.Unix(int64(exp), 0)
+	}
+
+	return claims, nil
+}
+
+// MergeTokenClaims combines access token claims with ID token claims, with
+// access token values taking precedence
+func MergeTokenClaims(access, id *models.TokenClaims) *models.TokenClaims {
+	if access == nil {
+		return id
+	}
+	if id == nil {
+		return access
+	}
+
+	merged := *access
+	fill := func(target *string, value string) {
+		if *target == "" {
+			*target = value
+		}
+	}
+	fill(&merged.Subject, id.Subject)
+	fill(&merged.Username, id.Username)
+	fill(&merged.Name, id.Name)
+	fill(&merged.Email, id.Email)
+	fill(&merged.NPWP, id.NPWP)
+	fill(&merged.NIB, id.NIB)
+	fill(&merged.Issuer, id.Issuer)
+
+	seen := make(map[string]bool)
+	merged.Roles = nil
+	for _, role := range append(append([]string{}, access.Roles...), id.Roles...) {
+		if !seen[role] {
+			seen[role] = true
+			merged.Roles = append(merged.Roles, role)
+		}
+	}
+	sort.Strings(merged.Roles)
+
+	merged.Verified = access.Verified && id.Verified
+	return &merged
+}
+
+// MatchImporterIdentity checks that the identity in the token claims is the
+// party filing the declaration, the importer on an import declaration. The
+// token must carry an NPWP or NIB; identifiers it does not carry are not
+// compared.
+func MatchImporterIdentity(claims *models.TokenClaims, data models.Declaration) error {
+	if claims == nil || (claims.NPWP == "" && claims.NIB == "") {
+		return models.NewError(models.ErrCodeIdentityMismatch, "CEISA token carries no NPWP or NIB to identify the declarant", nil)
+	}
+
+	declarant := KodeEntitasImportir
+	if dt, err := DocumentTypeOf(data); err == nil && dt.Declarant != "" {
+		declarant = dt.Declarant
+	}
+
+	entitas := *data.Header().Entitas
+	index := -1
+	for i := range entitas {
+		if entitas[i].KodeEntitas == declarant {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return models.Errorf(models.ErrCodeIdentityMismatch, "declaration has no declarant entitas (kodeEntitas %s)", declarant).WithFields(models.FieldError{
+			Field: "entitas",
+			Code:  models.FieldRequired,
+		})
+	}
+	importer := &entitas[index]
+
+	if claims.NPWP != "" && importer.NomorIdentitas != nil && *importer.NomorIdentitas != "" {
+		if !sameNPWP(claims.NPWP, *importer.NomorIdentitas) {
+			return models.Errorf(models.ErrCodeIdentityMismatch, "logged-in NPWP %s does not match declarant NPWP %s", claims.NPWP, *importer.NomorIdentitas).WithFields(models.FieldError{
+				Field: fmt.Sprintf("entitas[%d].nomorIdentitas", index),
+				Code:  models.FieldMismatch,
+			})
+		}
+	}
+
+	if claims.NIB != "" && importer.NibEntitas != nil && *importer.NibEntitas != "" {
+		if digitsOnly(claims.NIB) != digitsOnly(*importer.NibEntitas) {
+			return models.Errorf(models.ErrCodeIdentityMismatch, "logged-in NIB %s does not match declarant NIB %s", claims.NIB, *importer.NibEntitas).WithFields(models.FieldError{
+				Field: fmt.Sprintf("entitas[%d].nibEntitas", index),
+				Code:  models.FieldMismatch,
+			})
+		}
+	}
+
+	return nil
+}
+
+// sameNPWP compares NPWP numbers ignoring formatting; a 15 digit NPWP equals
+// its 16 digit form with a leading zero
+func sameNPWP(a, b string) bool {
+	a, b = digitsOnly(a), digitsOnly(b)
+	if len(a) == 15 {
+		a = "0" + a
+	}
+	if len(b) == 15 {
+		b = "0" + b
+	}
+	return a == b
+}
+
+func digitsOnly(value string) string {
+	var sb strings.Builder
+	for _, r := range value {
+		if r >= '0' && r <= '9' {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+func claimString(raw map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		switch value := raw[key].(type) {
+		case string:
+			if value != "" {
+				return value
+			}
+		case float64:
+			return fmt.Sprintf("%.0f", value)
+		}
+	}
+	return ""
+}
+
+// claimRoles collects Keycloak realm and client roles as well as a plain roles claim
+func claimRoles(raw map[string]interface{}) []string {
+	seen := make(map[string]bool)
+	var roles []string
+
+	add := func(value interface{}) {
+		list, ok := value.([]interface{})
+		if !ok {
+			return
+		}
+		for _, item := range list {
+			if role, ok := item.(string); ok && !seen[role] {
+				seen[role] = true
+				roles = append(roles, role)
+			}
+		}
+	}
+
+	add(raw["roles"])
+	if realm, ok := raw["realm_access"].(map[string]interface{}); ok {
+		add(realm["roles"])
+	}
+	if resources, ok := raw["resource_access"].(map[string]interface{}); ok {
+		for _, resource := range resources {
+			if client, ok := resource.(map[string]interface{}); ok {
+				add(client["roles"])
+			}
+		}
+	}
+
+	sort.Strings(roles)
+	return roles
+}
+
+func splitJWT(token string) (*jwtHeader, []byte, []byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, nil, nil, fmt.Errorf("token is not a JWT")
+	}
+
+	headerBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid token header: %w", err)
+	}
+	var header jwtHeader
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid token header: %w", err)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid token payload: %w", err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid token signature: %w", err)
+	}
+
+	return &header, payload, signature, nil
+}
+
+// JWKSVerifier verifies RS256/RS384/RS512 token signatures against keys from a JWKS file
+type JWKSVerifier struct {
+	keys map[string]*rsa.PublicKey
+}
+
+// LoadJWKSVerifier reads RSA keys from a JWKS file
+func LoadJWKSVerifier(path string) (*JWKSVerifier, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS file %s: %w", path, err)
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS file %s: %w", path, err)
+	}
+
+	verifier := &JWKSVerifier{keys: make(map[string]*rsa.PublicKey)}
+	for _, key := range jwks.Keys {
+		if key.Kty != "RSA" {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(key.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus for key %s: %w", key.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(key.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent for key %s: %w", key.Kid, err)
+		}
+
+		verifier.keys[key.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	if len(verifier.keys) == 0 {
+		return nil, fmt.Errorf("JWKS file %s contains no RSA keys", path)
+	}
+
+	return verifier, nil
+}
+
+// Verify checks the signature of a JWT
+func (v *JWKSVerifier) Verify(token string) error {
+	header, _, signature, err := splitJWT(token)
+	if err != nil {
+		return err
+	}
+
+	key, ok := v.keys[header.Kid]
+	if !ok {
+		return fmt.Errorf("unknown signing key %q", header.Kid)
+	}
+
+	signed := token[:strings.LastIndex(token, ".")]
+
+	var hash crypto.Hash
+	var digest []byte
+	switch header.Alg {
+	case "RS256":
+		sum := sha256.Sum256([]byte(signed))
+		hash, digest = crypto.SHA256, sum[:]
+	case "RS384":
+		sum := sha512.Sum384([]byte(signed))
+		hash, digest = crypto.SHA384, sum[:]
+	case "RS512":
+		sum := sha512.Sum512([]byte(signed))
+		hash, digest = crypto.SHA512, sum[:]
+	default:
+		return fmt.Errorf("unsupported signing algorithm %q", header.Alg)
+	}
+
+	if err := rsa.VerifyPKCS1v15(key, hash, digest, signature); err != nil {
+		return fmt.Errorf("invalid token signature: %w", err)
+	}
+
+	return nil
+}
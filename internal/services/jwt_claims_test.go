@@ -0,0 +1,168 @@
+package services
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"json-response-generator/internal/models"
+)
+
+func signTestJWT(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	t.Helper()
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func writeTestJWKS(t *testing.T, key *rsa.PublicKey, kid string) string {
+	t.Helper()
+
+	jwks := map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": kid,
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	}
+
+	data, _ := json.Marshal(jwks)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("failed to write JWKS: %v", err)
+	}
+	return path
+}
+
+func TestParseTokenClaims(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	token := signTestJWT(t, key, "k1", map[string]interface{}{
+		"sub":                "user-1",
+		"preferred_username": "ppjk.operator",
+		"name":               "Budi Santoso",
+		"npwp":               "01.234.567.8-901.000",
+		"nib":                "9120001234567",
+		"exp":                1700000000,
+		"realm_access":       map[string]interface{}{"roles": []string{"importir", "offline_access"}},
+	})
+
+	claims, err := ParseTokenClaims(token)
+	if err != nil {
+		t.Fatalf("ParseTokenClaims() error = %v", err)
+	}
+
+	if claims.Username != "ppjk.operator" || claims.NPWP != "01.234.567.8-901.000" || claims.NIB != "9120001234567" {
+		t.Errorf("Unexpected claims: %+v", claims)
+	}
+	if len(claims.Roles) != 2 || claims.Roles[0] != "importir" {
+		t.Errorf("Expected realm roles, got %v", claims.Roles)
+	}
+	if claims.ExpiresAt.Unix() != 1700000000 {
+		t.Errorf("Expected exp 1700000000, got %d", claims.ExpiresAt.Unix())
+	}
+	if claims.Verified {
+		t.Error("Claims should not be marked verified without a verifier")
+	}
+}
+
+func TestJWKSVerifier(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	verifier, err := LoadJWKSVerifier(writeTestJWKS(t, &key.PublicKey, "k1"))
+	if err != nil {
+		t.Fatalf("LoadJWKSVerifier() error = %v", err)
+	}
+
+	token := signTestJWT(t, key, "k1", map[string]interface{}{"sub": "user-1"})
+	if err := verifier.Verify(token); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	forged := signTestJWT(t, otherKey, "k1", map[string]interface{}{"sub": "user-1"})
+	if err := verifier.Verify(forged); err == nil {
+		t.Error("Expected token signed with another key to fail verification")
+	}
+
+	unknown := signTestJWT(t, key, "k2", map[string]interface{}{"sub": "user-1"})
+	if err := verifier.Verify(unknown); err == nil {
+		t.Error("Expected token with unknown key id to fail verification")
+	}
+}
+
+func TestAttachClaimsRequiresVerifiedToken(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	verifier, err := LoadJWKSVerifier(writeTestJWKS(t, &key.PublicKey, "k1"))
+	if err != nil {
+		t.Fatalf("LoadJWKSVerifier() error = %v", err)
+	}
+
+	service := NewOAuthService()
+	service.SetJWKSVerifier(verifier)
+
+	if err := service.attachClaims(&models.OAuthTokenInfo{AccessToken: "opaque-token"}); err == nil {
+		t.Error("Expected a token that is not a JWT to fail verification")
+	}
+
+	tokenInfo := &models.OAuthTokenInfo{
+		AccessToken: "opaque-token",
+		IDToken:     signTestJWT(t, key, "k1", map[string]interface{}{"npwp": "012345678901000"}),
+	}
+	if err := service.attachClaims(tokenInfo); err != nil {
+		t.Fatalf("Expected the signed ID token to verify, got %v", err)
+	}
+	if tokenInfo.Claims == nil || !tokenInfo.Claims.Verified || tokenInfo.Claims.NPWP != "012345678901000" {
+		t.Errorf("Unexpected claims %+v", tokenInfo.Claims)
+	}
+}
+
+func TestMatchImporterIdentity(t *testing.T) {
+	npwp := "012345678901000"
+	nib := "9120001234567"
+	data := &models.ResponseData{
+		Entitas: []models.Entitas{
+			{KodeEntitas: KodeEntitasImportir, NomorIdentitas: &npwp, NibEntitas: &nib},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		claims  *models.TokenClaims
+		wantErr bool
+	}{
+		{"no claims", nil, true},
+		{"no identifiers", &models.TokenClaims{Username: "operator"}, true},
+		{"formatted NPWP matches", &models.TokenClaims{NPWP: "01.234.567.8-901.000"}, false},
+		{"16 digit NPWP matches", &models.TokenClaims{NPWP: "0012345678901000"}, false},
+		{"NPWP mismatch", &models.TokenClaims{NPWP: "99.999.999.9-999.000"}, true},
+		{"NIB mismatch", &models.TokenClaims{NIB: "9120009999999"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := MatchImporterIdentity(tt.claims, data)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("MatchImporterIdentity() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && models.ErrorCodeOf(err) != models.ErrCodeIdentityMismatch {
+				t.Errorf("Expected code %s, got %s", models.ErrCodeIdentityMismatch, models.ErrorCodeOf(err))
+			}
+		})
+	}
+}
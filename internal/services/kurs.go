@@ -0,0 +1,397 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+
+	"json-response-generator/internal/models"
+)
+
+// KodeValutaRupiah is the currency of declarations valued in rupiah, whose
+// NDPBM is always 1
+const KodeValutaRupiah = "IDR"
+
+// kursDateLayout is the layout of the dates of exchange rates and tanggalAju
+const kursDateLayout = "2006-01-02"
+
+// kursDateLayouts are the date layouts accepted in exchange rate files
+var kursDateLayouts = []string{kursDateLayout, "02/01/2006", "02-01-2006"}
+
+// kursColumns are the columns of an exchange rate file, in any order
+var kursColumns = []string{"kodeValuta", "nilai", "tanggalAwal", "tanggalAkhir"}
+
+// KursSource supplies exchange rates, e.g. a file published by the Ministry
+// of Finance
+type KursSource interface {
+	Kurs() ([]models.Kurs, error)
+}
+
+// StaticKursSource supplies a fixed list of exchange rates, e.g. in tests
+type StaticKursSource []models.Kurs
+
+// Kurs implements KursSource
+func (s StaticKursSource) Kurs() ([]models.Kurs, error) {
+	return s, nil
+}
+
+// KursFile supplies the exchange rates of a CSV or Excel (.xlsx) file. The
+// first row names the columns kodeValuta, nilai, tanggalAwal and
+// tanggalAkhir; the first sheet of a workbook is read.
+type KursFile struct {
+	Name   string // file name, its extension selects the format
+	Reader io.Reader
+}
+
+// Kurs implements KursSource
+func (f KursFile) Kurs() ([]models.Kurs, error) {
+	var rows [][]string
+	switch strings.ToLower(filepath.Ext(f.Name)) {
+	case ".csv":
+		reader := csv.NewReader(f.Reader)
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		records, err := reader.ReadAll()
+		if err != nil {
+			return nil, models.NewError(models.ErrCodeValidationFailed, "failed to read CSV file", err)
+		}
+		rows = records
+	case ".xlsx":
+		workbook, err := excelize.OpenReader(f.Reader)
+		if err != nil {
+			return nil, models.NewError(models.ErrCodeExcelUnreadable, "failed to open Excel file", err)
+		}
+		defer workbook.Close()
+		rows, err = workbook.GetRows(workbook.GetSheetName(0))
+		if err != nil {
+			return nil, models.NewError(models.ErrCodeExcelUnreadable, "failed to read Excel file", err)
+		}
+	default:
+		return nil, models.NewError(models.ErrCodeFileTypeUnsupported, "exchange rates must be a .csv or .xlsx file", nil)
+	}
+	return parseKursRows(rows)
+}
+
+// parseKursRows reads exchange rates from rows whose first names the columns
+func parseKursRows(rows [][]string) ([]models.Kurs, error) {
+	if len(rows) == 0 {
+		return nil, models.NewError(models.ErrCodeValidationFailed, "the exchange rate file is empty", nil)
+	}
+
+	index := make(map[string]int)
+	for i, name := range rows[0] {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	var problems []models.FieldError
+	for _, column := range kursColumns {
+		if _, exists := index[strings.ToLower(column)]; !exists {
+			problems = append(problems, models.FieldError{Field: column, Code: models.FieldRequired, Message: fmt.Sprintf("column %s is missing", column)})
+		}
+	}
+	if len(problems) > 0 {
+		return nil, models.NewError(models.ErrCodeValidationFailed, "the exchange rate file lacks columns", nil).WithFields(problems...)
+	}
+
+	cell := func(row []string, column string) string {
+		if i := index[strings.ToLower(column)]; i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	var rates []models.Kurs
+	for n, row := range rows[1:] {
+		if strings.TrimSpace(strings.Join(row, "")) == "" {
+			continue
+		}
+		line := fmt.Sprintf("rows[%d]", n+2)
+		kurs := models.Kurs{KodeValuta: cell(row, "kodeValuta")}
+
+		nilai, err := strconv.ParseFloat(strings.ReplaceAll(cell(row, "nilai"), ",", ""), 64)
+		if err != nil {
+			problems = append(problems, models.FieldError{Field: line + ".nilai", Code: models.FieldInvalidFormat})
+		}
+		kurs.Nilai = nilai
+
+		for _, column := range []string{"tanggalAwal", "tanggalAkhir"} {
+			date, err := parseKursDate(cell(row, column))
+			if err != nil {
+				problems = append(problems, models.FieldError{Field: line + "." + column, Code: models.FieldInvalidFormat})
+				continue
+			}
+			if column == "tanggalAwal" {
+				kurs.TanggalAwal = date.Format(kursDateLayout)
+			} else {
+				kurs.TanggalAkhir = date.Format(kursDateLayout)
+			}
+		}
+		rates = append(rates, kurs)
+	}
+	if len(problems) > 0 {
+		return nil, models.NewError(models.ErrCodeValidationFailed, "the exchange rate file has invalid rows", nil).WithFields(problems...)
+	}
+	return rates, nil
+}
+
+// parseKursDate parses a date in one of kursDateLayouts
+func parseKursDate(value string) (time.Time, error) {
+	for _, layout := range kursDateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
+
+// KursTable holds exchange rates by currency and period, optionally persisted
+// to a JSON file
+type KursTable struct {
+	path  string
+	rates map[string][]models.Kurs // by currency, ordered by tanggalAwal
+	mutex sync.RWMutex
+}
+
+// NewKursTable opens the table at path. An empty path keeps rates in memory only.
+func NewKursTable(path string) (*KursTable, error) {
+	table := &KursTable{
+		path:  path,
+		rates: make(map[string][]models.Kurs),
+	}
+
+	if path == "" {
+		return table, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return table, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read exchange rates %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(data, &table.rates); err != nil {
+		return nil, fmt.Errorf("failed to parse exchange rates %s: %w", path, err)
+	}
+
+	return table, nil
+}
+
+// Import adds the rates of a source. A rate replaces the stored rates of its
+// currency whose period it overlaps, so a corrected week can be imported
+// again. Nothing is imported when a rate is invalid or rates of the source
+// overlap each other.
+func (kt *KursTable) Import(source KursSource) (*models.KursImport, error) {
+	rates, err := source.Kurs()
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []models.FieldError
+	for i := range rates {
+		rates[i].KodeValuta = strings.ToUpper(strings.TrimSpace(rates[i].KodeValuta))
+		problems = append(problems, checkKurs(rates[i], fmt.Sprintf("kurs[%d]", i))...)
+	}
+	for i := range rates {
+		for j := i + 1; j < len(rates); j++ {
+			if rates[i].KodeValuta == rates[j].KodeValuta && kursOverlap(rates[i], rates[j]) {
+				problems = append(problems, models.FieldError{Field: fmt.Sprintf("kurs[%d].tanggalAwal", j), Code: models.FieldMismatch,
+					Message: fmt.Sprintf("overlaps the %s rate from %s", rates[i].KodeValuta, rates[i].TanggalAwal)})
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return nil, models.NewError(models.ErrCodeValidationFailed, "invalid exchange rates", nil).WithFields(problems...)
+	}
+
+	kt.mutex.Lock()
+	defer kt.mutex.Unlock()
+
+	previous := make(map[string][]models.Kurs, len(kt.rates))
+	for code, stored := range kt.rates {
+		previous[code] = stored
+	}
+
+	result := &models.KursImport{Imported: len(rates)}
+	for _, kurs := range rates {
+		kept := make([]models.Kurs, 0, len(kt.rates[kurs.KodeValuta])+1)
+		for _, stored := range kt.rates[kurs.KodeValuta] {
+			if kursOverlap(stored, kurs) {
+				result.Replaced++
+				continue
+			}
+			kept = append(kept, stored)
+		}
+		kept = append(kept, kurs)
+		sort.Slice(kept, func(i, j int) bool { return kept[i].TanggalAwal < kept[j].TanggalAwal })
+		kt.rates[kurs.KodeValuta] = kept
+	}
+
+	if err := kt.writeLocked(); err != nil {
+		kt.rates = previous
+		return nil, err
+	}
+	return result, nil
+}
+
+// List returns the stored rates of a currency, or of all currencies when
+// kodeValuta is empty, ordered by currency and period
+func (kt *KursTable) List(kodeValuta string) []models.Kurs {
+	kt.mutex.RLock()
+	defer kt.mutex.RUnlock()
+
+	codes := make([]string, 0, len(kt.rates))
+	for code := range kt.rates {
+		if kodeValuta == "" || strings.EqualFold(code, kodeValuta) {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+
+	rates := []models.Kurs{}
+	for _, code := range codes {
+		rates = append(rates, kt.rates[code]...)
+	}
+	return rates
+}
+
+// Rate returns the rate of a currency in force on a date, YYYY-MM-DD. Rupiah
+// is always in force at 1.
+func (kt *KursTable) Rate(kodeValuta, tanggal string) (models.Kurs, bool) {
+	code := strings.ToUpper(strings.TrimSpace(kodeValuta))
+	if code == KodeValutaRupiah {
+		return models.Kurs{KodeValuta: code, Nilai: 1, TanggalAwal: tanggal, TanggalAkhir: tanggal}, true
+	}
+	if _, err := time.Parse(kursDateLayout, tanggal); err != nil {
+		return models.Kurs{}, false
+	}
+
+	kt.mutex.RLock()
+	defer kt.mutex.RUnlock()
+
+	for _, kurs := range kt.rates[code] {
+		if kurs.TanggalAwal <= tanggal && tanggal <= kurs.TanggalAkhir {
+			return kurs, true
+		}
+	}
+	return models.Kurs{}, false
+}
+
+// FillNdpbm sets the NDPBM of a declaration and its goods that have none to
+// the rate of its kodeValuta in force on its tanggalAju, and returns the
+// values changed
+func (kt *KursTable) FillNdpbm(declaration models.Declaration) []models.DerivedChange {
+	header := declaration.Header()
+	kurs, found := kt.declarationRate(header)
+	if !found {
+		return nil
+	}
+
+	reason := fmt.Sprintf("kurs %s in force on %s", kurs.KodeValuta, *header.TanggalAju)
+	var changes []models.DerivedChange
+	if *header.Ndpbm == 0 {
+		changes = append(changes, models.DerivedChange{Field: "ndpbm", From: *header.Ndpbm, To: kurs.Nilai, Reason: reason})
+		*header.Ndpbm = kurs.Nilai
+	}
+	if header.Barang != nil {
+		barang := *header.Barang
+		for i := range barang {
+			if barang[i].Ndpbm == 0 {
+				changes = append(changes, models.DerivedChange{Field: fmt.Sprintf("barang[%d].ndpbm", i), From: barang[i].Ndpbm, To: kurs.Nilai, Reason: reason})
+				barang[i].Ndpbm = kurs.Nilai
+			}
+		}
+	}
+	return changes
+}
+
+// Check reports the NDPBM of a declaration and its goods that differ from
+// the rate of its kodeValuta in force on its tanggalAju. Declarations in a
+// currency without a stored rate are not checked.
+func (kt *KursTable) Check(declaration models.Declaration) []models.FieldError {
+	header := declaration.Header()
+	kurs, found := kt.declarationRate(header)
+	if !found {
+		return nil
+	}
+
+	mismatch := func(field string, ndpbm float64) models.FieldError {
+		return models.FieldError{Field: field, Code: models.FieldMismatch,
+			Message: fmt.Sprintf("ndpbm %.4f differs from the %s rate of %.4f in force on %s", ndpbm, kurs.KodeValuta, kurs.Nilai, *header.TanggalAju)}
+	}
+
+	var problems []models.FieldError
+	if *header.Ndpbm != 0 && math.Abs(*header.Ndpbm-kurs.Nilai) > amountTolerance {
+		problems = append(problems, mismatch("ndpbm", *header.Ndpbm))
+	}
+	if header.Barang != nil {
+		for i, b := range *header.Barang {
+			if b.Ndpbm != 0 && math.Abs(b.Ndpbm-kurs.Nilai) > amountTolerance {
+				problems = append(problems, mismatch(fmt.Sprintf("barang[%d].ndpbm", i), b.Ndpbm))
+			}
+		}
+	}
+	return problems
+}
+
+// declarationRate returns the rate a declaration valued in foreign currency
+// is converted at
+func (kt *KursTable) declarationRate(header models.DeclarationHeader) (models.Kurs, bool) {
+	if header.KodeValuta == nil || header.Ndpbm == nil || header.TanggalAju == nil {
+		return models.Kurs{}, false
+	}
+	return kt.Rate(*header.KodeValuta, *header.TanggalAju)
+}
+
+// writeLocked persists the table, the caller holding the lock
+func (kt *KursTable) writeLocked() error {
+	if kt.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(kt.rates, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal exchange rates: %w", err)
+	}
+
+	return writeFileAtomic(kt.path, data)
+}
+
+// checkKurs reports what is wrong with an exchange rate
+func checkKurs(kurs models.Kurs, field string) []models.FieldError {
+	var problems []models.FieldError
+	if len(kurs.KodeValuta) != 3 {
+		problems = append(problems, models.FieldError{Field: field + ".kodeValuta", Code: models.FieldInvalidFormat, Message: "currency must be a three-letter ISO 4217 code"})
+	}
+	if kurs.Nilai <= 0 {
+		problems = append(problems, models.FieldError{Field: field + ".nilai", Code: models.FieldInvalidValue})
+	}
+	awal, errAwal := time.Parse(kursDateLayout, kurs.TanggalAwal)
+	if errAwal != nil {
+		problems = append(problems, models.FieldError{Field: field + ".tanggalAwal", Code: models.FieldInvalidFormat})
+	}
+	akhir, errAkhir := time.Parse(kursDateLayout, kurs.TanggalAkhir)
+	if errAkhir != nil {
+		problems = append(problems, models.FieldError{Field: field + ".tanggalAkhir", Code: models.FieldInvalidFormat})
+	}
+	if errAwal == nil && errAkhir == nil && akhir.Before(awal) {
+		problems = append(problems, models.FieldError{Field: field + ".tanggalAkhir", Code: models.FieldInvalidValue, Message: "period ends before it starts"})
+	}
+	return problems
+}
+
+// kursOverlap reports whether the periods of two rates share a day
+func kursOverlap(a, b models.Kurs) bool {
+	return a.TanggalAwal <= b.TanggalAkhir && b.TanggalAwal <= a.TanggalAkhir
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+
+	"json-response-generator/internal/models"
+)
+
+func TestKursTable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kurs.json")
+	table, err := NewKursTable(path)
+	if err != nil {
+		t.Fatalf("Failed to open exchange rates: %v", err)
+	}
+
+	_, err = table.Import(StaticKursSource{
+		{KodeValuta: "cny", Nilai: 2150.25, TanggalAwal: "2021-12-22", TanggalAkhir: "2021-12-28"},
+		{KodeValuta: "CNY", Nilai: 2160, TanggalAwal: "2021-12-29", TanggalAkhir: "2022-01-04"},
+		{KodeValuta: "USD", Nilai: 14350, TanggalAwal: "2021-12-22", TanggalAkhir: "2021-12-28"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to import rates: %v", err)
+	}
+
+	kurs, found := table.Rate("CNY", "2021-12-25")
+	if !found || kurs.Nilai != 2150.25 {
+		t.Errorf("Expected the CNY rate of 2150.25 in force, got %+v", kurs)
+	}
+	if _, found := table.Rate("CNY", "2022-01-05"); found {
+		t.Error("Expected no rate after the last period")
+	}
+	if kurs, _ := table.Rate("IDR", "2021-12-25"); kurs.Nilai != 1 {
+		t.Errorf("Expected rupiah at 1, got %+v", kurs)
+	}
+
+	// A corrected week replaces the rate it overlaps
+	result, err := table.Import(StaticKursSource{{KodeValuta: "CNY", Nilai: 2155, TanggalAwal: "2021-12-22", TanggalAkhir: "2021-12-28"}})
+	if err != nil || result.Replaced != 1 {
+		t.Fatalf("Expected one rate replaced, got %+v, %v", result, err)
+	}
+
+	reopened, err := NewKursTable(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen exchange rates: %v", err)
+	}
+	if rates := reopened.List("cny"); len(rates) != 2 || rates[0].Nilai != 2155 {
+		t.Errorf("Expected the corrected rates to persist, got %+v", rates)
+	}
+
+	_, err = table.Import(StaticKursSource{
+		{KodeValuta: "EUR", Nilai: 16000, TanggalAwal: "2021-12-22", TanggalAkhir: "2021-12-28"},
+		{KodeValuta: "EUR", Nilai: 16100, TanggalAwal: "2021-12-28", TanggalAkhir: "2022-01-03"},
+		{KodeValuta: "EURO", Nilai: 0, TanggalAwal: "2021-12-29", TanggalAkhir: "2021-12-22"},
+	})
+	if err == nil {
+		t.Fatal("Expected overlapping and invalid rates to be rejected")
+	}
+	if rates := table.List("EUR"); len(rates) != 0 {
+		t.Errorf("Expected nothing imported from a rejected source, got %+v", rates)
+	}
+}
+
+func TestKursFile(t *testing.T) {
+	csvFile := "tanggalAwal,kodeValuta,nilai,tanggalAkhir\n22/12/2021,USD,\"14,350.50\",28/12/2021\n,,,\n2021-12-29,USD,14400,2022-01-04\n"
+	rates, err := KursFile{Name: "kurs.csv", Reader: strings.NewReader(csvFile)}.Kurs()
+	if err != nil {
+		t.Fatalf("Failed to read CSV rates: %v", err)
+	}
+	if len(rates) != 2 || rates[0].Nilai != 14350.5 || rates[0].TanggalAwal != "2021-12-22" || rates[1].TanggalAkhir != "2022-01-04" {
+		t.Errorf("Expected two USD rates, got %+v", rates)
+	}
+
+	workbook := excelize.NewFile()
+	sheet := workbook.GetSheetName(0)
+	workbook.SetSheetRow(sheet, "A1", &[]interface{}{"kodeValuta", "nilai", "tanggalAwal", "tanggalAkhir"})
+	workbook.SetSheetRow(sheet, "A2", &[]interface{}{"CNY", 2150.25, "2021-12-22", "2021-12-28"})
+	var buf bytes.Buffer
+	if err := workbook.Write(&buf); err != nil {
+		t.Fatalf("Failed to write workbook: %v", err)
+	}
+	rates, err = KursFile{Name: "kurs.xlsx", Reader: &buf}.Kurs()
+	if err != nil || len(rates) != 1 || rates[0].KodeValuta != "CNY" || rates[0].Nilai != 2150.25 {
+		t.Errorf("Expected the CNY rate of the workbook, got %+v, %v", rates, err)
+	}
+
+	_, err = KursFile{Name: "kurs.csv", Reader: strings.NewReader("kodeValuta,nilai\nUSD,1\n")}.Kurs()
+	if err == nil {
+		t.Error("Expected a file without periods to be rejected")
+	}
+	_, err = KursFile{Name: "kurs.txt", Reader: strings.NewReader("")}.Kurs()
+	if err == nil {
+		t.Error("Expected a .txt file to be rejected")
+	}
+}
+
+func TestKursFillAndCheck(t *testing.T) {
+	table, _ := NewKursTable("")
+	table.Import(StaticKursSource{{KodeValuta: "CNY", Nilai: 2150.25, TanggalAwal: "2021-12-22", TanggalAkhir: "2021-12-28"}})
+
+	declaration := NewJsonGenerator().GenerateSampleData()
+	declaration.Ndpbm = 0
+	declaration.Barang[0].Ndpbm = 0
+
+	changes := table.FillNdpbm(declaration)
+	if declaration.Ndpbm != 2150.25 || declaration.Barang[0].Ndpbm != 2150.25 || len(changes) != 2 {
+		t.Errorf("Expected the header and first item filled, got %+v", changes)
+	}
+
+	problems := table.Check(declaration)
+	if len(problems) != 1 || problems[0].Field != "barang[1].ndpbm" || problems[0].Code != models.FieldMismatch {
+		t.Errorf("Expected the second item's stale NDPBM flagged, got %+v", problems)
+	}
+
+	declaration.TanggalAju = "2022-02-01"
+	if problems := table.Check(declaration); len(problems) != 0 {
+		t.Errorf("Expected no check without a rate in force, got %+v", problems)
+	}
+}
@@ -2,52 +2,560 @@ package services
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
 
 	"json-response-generator/internal/models"
+	"json-response-generator/internal/telemetry"
 )
 
-// OAuthService handles OAuth 2.0 authentication for CEISA 4.0 API
+// DefaultSessionID identifies the process-wide session used by callers that
+// are not bound to an application session (e.g. ApiClient helpers and tests)
+const DefaultSessionID = "default"
+
+// tenantSessionPrefix prefixes the IDs of the shared sessions holding a
+// tenant's CEISA credentials
+const tenantSessionPrefix = "tenant:"
+
+// OAuthService handles OAuth 2.0 authentication for CEISA 4.0 API.
+// Tokens and credentials are kept per application session so that operators
+// sharing one server never submit under each other's CEISA identity.
 type OAuthService struct {
 	httpClient *http.Client
-	tokenInfo  *models.OAuthTokenInfo
-	config     *models.OAuth2Config
+	sessions   map[string]*OAuthSession
+	sessionTTL time.Duration
+	store      TokenStore
+	verifier   *JWKSVerifier
+	tokenURL   string
+	refreshURL string
 	mutex      sync.RWMutex
 }
 
+// OAuthSession holds the CEISA configuration and token of a single caller
+type OAuthSession struct {
+	ID        string
+	CreatedAt time.Time
+
+	service   *OAuthService
+	principal *models.Principal
+	tokenInfo *models.OAuthTokenInfo
+	config    *models.OAuth2Config
+	lastUsed  time.Time
+	// lastPersisted is when the session was last written to the token store
+	lastPersisted time.Time
+	mutex         sync.RWMutex
+
+	refreshCall  *refreshCall
+	refreshMutex sync.Mutex
+}
+
+// refreshCall is a token renewal shared by concurrent callers
+type refreshCall struct {
+	done  chan struct{}
+	token *models.OAuthTokenInfo
+	err   error
+}
+
 // NewOAuthService creates a new OAuth service instance
 func NewOAuthService() *OAuthService {
-	return &OAuthService{
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+	os := &OAuthService{
+		httpClient: telemetry.NewHTTPClient(30 * time.Second),
+		sessions:   make(map[string]*OAuthSession),
+		sessionTTL: 12 * time.Hour,
+		tokenURL:   "https://apis-gw.beacukai.go.id/nle-oauth/v1/user/login",
+		refreshURL: "https://apis-gw.beacukai.go.id/nle-oauth/v1/user/update-token",
 	}
+	os.sessions[DefaultSessionID] = os.newSession(DefaultSessionID)
+	return os
 }
 
-// SetConfig sets the OAuth 2.0 configuration
-func (os *OAuthService) SetConfig(config *models.OAuth2Config) {
+// Session management
+
+// NewSession creates a session with a random identifier issued by this service
+func (os *OAuthService) NewSession() (*OAuthSession, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("failed to generate session id: %w", err)
+	}
+
+	session := os.newSession(base64.RawURLEncoding.EncodeToString(buf))
+
+	os.mutex.Lock()
+	os.pruneSessionsLocked()
+	os.sessions[session.ID] = session
+	os.mutex.Unlock()
+
+	return session, nil
+}
+
+// GetSession returns the session with the given identifier if it exists and has not expired
+func (os *OAuthService) GetSession(id string) (*OAuthSession, bool) {
+	if id == "" {
+		return nil, false
+	}
+
+	os.mutex.RLock()
+	session, exists := os.sessions[id]
+	os.mutex.RUnlock()
+
+	if !exists {
+		return nil, false
+	}
+
+	if !isPinnedSession(id) && os.sessionTTL > 0 && time.Since(session.LastUsed()) > os.sessionTTL {
+		os.DeleteSession(id)
+		return nil, false
+	}
+
+	session.touch()
+	return session, true
+}
+
+// DeleteSession removes a session together with its token and credentials,
+// including any persisted copy
+func (os *OAuthService) DeleteSession(id string) {
+	if id == DefaultSessionID {
+		os.defaultSession().ClearToken()
+		return
+	}
+
+	os.mutex.Lock()
+	delete(os.sessions, id)
+	store := os.store
+	os.mutex.Unlock()
+
+	if store != nil {
+		if err := store.Delete(id); err != nil {
+			logrus.WithError(err).Error("Failed to delete stored OAuth session")
+		}
+	}
+}
+
+// TenantSession returns the session holding the CEISA credentials of a
+// tenant, creating it from the tenant's OAuth configuration on first use.
+// Tenant sessions are shared by all users acting for the tenant and never expire.
+func (os *OAuthService) TenantSession(tenant *models.Tenant) *OAuthSession {
+	id := tenantSessionPrefix + tenant.ID
+
+	os.mutex.Lock()
+	session, exists := os.sessions[id]
+	if !exists {
+		session = os.newSession(id)
+		os.sessions[id] = session
+	}
+	os.mutex.Unlock()
+
+	if session.GetConfig() == nil && tenant.OAuth != nil {
+		config := *tenant.OAuth
+		session.SetConfig(&config)
+	}
+
+	return session
+}
+
+// SetDefaultURLs sets the CEISA login and refresh URLs offered by default
+func (os *OAuthService) SetDefaultURLs(tokenURL, refreshURL string) {
+	os.mutex.Lock()
+	defer os.mutex.Unlock()
+	os.tokenURL = tokenURL
+	os.refreshURL = refreshURL
+}
+
+// SetTokenStore enables persistence of sessions to the given store
+func (os *OAuthService) SetTokenStore(store TokenStore) {
+	os.mutex.Lock()
+	defer os.mutex.Unlock()
+	os.store = store
+}
+
+// SetJWKSVerifier enables signature verification of CEISA tokens
+func (os *OAuthService) SetJWKSVerifier(verifier *JWKSVerifier) {
 	os.mutex.Lock()
 	defer os.mutex.Unlock()
-	os.config = config
+	os.verifier = verifier
 }
 
-// GetConfig returns the current OAuth 2.0 configuration
+// VerifiesTokens reports whether CEISA token signatures are verified
+func (os *OAuthService) VerifiesTokens() bool {
+	os.mutex.RLock()
+	defer os.mutex.RUnlock()
+	return os.verifier != nil
+}
+
+// attachClaims decodes the identity claims of the access and ID tokens. When a
+// JWKS verifier is configured, tokens with invalid signatures are rejected and
+// at least one token must verify.
+func (os *OAuthService) attachClaims(tokenInfo *models.OAuthTokenInfo) error {
+	os.mutex.RLock()
+	verifier := os.verifier
+	os.mutex.RUnlock()
+
+	var merged *models.TokenClaims
+	for _, token := range []string{tokenInfo.AccessToken, tokenInfo.IDToken} {
+		if token == "" {
+			continue
+		}
+
+		claims, err := ParseTokenClaims(token)
+		if err != nil {
+			logrus.WithError(err).Debug("Token is not a decodable JWT")
+			continue
+		}
+
+		if verifier != nil {
+			if err := verifier.Verify(token); err != nil {
+				return models.NewError(models.ErrCodeOAuthLoginFailed, "token verification failed", err)
+			}
+			claims.Verified = true
+		}
+
+		if merged == nil {
+			merged = claims
+		} else {
+			merged = MergeTokenClaims(merged, claims)
+		}
+	}
+
+	if verifier != nil && merged == nil {
+		return models.NewError(models.ErrCodeOAuthLoginFailed, "token verification failed: no token is a verifiable JWT", nil)
+	}
+
+	tokenInfo.Claims = merged
+	return nil
+}
+
+// RestoreSessions loads persisted sessions from the token store, dropping
+// those that have been idle for longer than the session TTL
+func (os *OAuthService) RestoreSessions() (int, error) {
+	os.mutex.RLock()
+	store := os.store
+	os.mutex.RUnlock()
+
+	if store == nil {
+		return 0, nil
+	}
+
+	records, err := store.LoadAll()
+	if err != nil {
+		return 0, fmt.Errorf("failed to load stored sessions: %w", err)
+	}
+
+	restored := 0
+	for _, record := range records {
+		if record.ID == DefaultSessionID || (!isPinnedSession(record.ID) && os.sessionTTL > 0 && time.Since(record.LastUsed) > os.sessionTTL) {
+			if err := store.Delete(record.ID); err != nil {
+				logrus.WithError(err).Error("Failed to delete expired OAuth session")
+			}
+			continue
+		}
+
+		session := os.newSession(record.ID)
+		session.CreatedAt = record.CreatedAt
+		session.lastUsed = record.LastUsed
+		session.lastPersisted = record.LastUsed
+		session.principal = record.Principal
+		session.config = record.Config
+		session.tokenInfo = record.Token
+
+		os.mutex.Lock()
+		os.sessions[session.ID] = session
+		os.mutex.Unlock()
+		restored++
+	}
+
+	return restored, nil
+}
+
+// Sessions returns a snapshot of all sessions, including the default one
+func (os *OAuthService) Sessions() []*OAuthSession {
+	os.mutex.RLock()
+	defer os.mutex.RUnlock()
+
+	sessions := make([]*OAuthSession, 0, len(os.sessions))
+	for _, session := range os.sessions {
+		sessions = append(sessions, session)
+	}
+	return sessions
+}
+
+// TokenHealth counts the sessions holding a CEISA token and those whose token
+// is valid or can be renewed unattended
+func (os *OAuthService) TokenHealth() (withToken, usable int) {
+	for _, session := range os.Sessions() {
+		if session.GetTokenInfo() == nil {
+			continue
+		}
+		withToken++
+		if session.IsTokenValid() || session.canRenew() {
+			usable++
+		}
+	}
+	return withToken, usable
+}
+
+// TokenProbe checks that the CEISA sessions holding a token can still use it.
+// Without any login there is nothing to check. Expired sessions only need a
+// new login, so they degrade the check rather than fail it.
+func (os *OAuthService) TokenProbe() ReadinessProbe {
+	return func(ctx context.Context) (string, error) {
+		withToken, usable := os.TokenHealth()
+		if withToken == 0 {
+			return "", SkipCheck("no CEISA session is logged in")
+		}
+		if usable == 0 {
+			return "", DegradeCheck(fmt.Sprintf("all %d CEISA token(s) expired and cannot be renewed", withToken))
+		}
+		return fmt.Sprintf("%d of %d CEISA session(s) have a usable token", usable, withToken), nil
+	}
+}
+
+// SessionTTL returns how long an idle session is kept
+func (os *OAuthService) SessionTTL() time.Duration {
+	return os.sessionTTL
+}
+
+// SessionCount returns the number of active sessions, excluding the default one
+func (os *OAuthService) SessionCount() int {
+	os.mutex.RLock()
+	defer os.mutex.RUnlock()
+	return len(os.sessions) - 1
+}
+
+func (os *OAuthService) newSession(id string) *OAuthSession {
+	now := time.Now()
+	return &OAuthSession{
+		ID:        id,
+		CreatedAt: now,
+		service:   os,
+		lastUsed:  now,
+	}
+}
+
+func (os *OAuthService) defaultSession() *OAuthSession {
+	os.mutex.RLock()
+	defer os.mutex.RUnlock()
+	return os.sessions[DefaultSessionID]
+}
+
+// pruneSessionsLocked drops sessions idle for longer than the session TTL
+func (os *OAuthService) pruneSessionsLocked() {
+	if os.sessionTTL <= 0 {
+		return
+	}
+
+	for id, session := range os.sessions {
+		if !isPinnedSession(id) && time.Since(session.LastUsed()) > os.sessionTTL {
+			delete(os.sessions, id)
+			if os.store != nil {
+				if err := os.store.Delete(id); err != nil {
+					logrus.WithError(err).Error("Failed to delete expired OAuth session")
+				}
+			}
+		}
+	}
+}
+
+// isPinnedSession reports whether a session is exempt from idle expiry
+func isPinnedSession(id string) bool {
+	return id == DefaultSessionID || strings.HasPrefix(id, tenantSessionPrefix)
+}
+
+// Default session helpers, kept for callers without an application session
+
+// SetConfig sets the OAuth 2.0 configuration of the default session
+func (os *OAuthService) SetConfig(config *models.OAuth2Config) {
+	os.defaultSession().SetConfig(config)
+}
+
+// GetConfig returns the OAuth 2.0 configuration of the default session
 func (os *OAuthService) GetConfig() *models.OAuth2Config {
+	return os.defaultSession().GetConfig()
+}
+
+// Login performs OAuth 2.0 login for the default session
+func (os *OAuthService) Login(username, password string) (*models.OAuthTokenInfo, error) {
+	return os.defaultSession().Login(username, password)
+}
+
+// RefreshToken refreshes the access token of the default session
+func (os *OAuthService) RefreshToken() (*models.OAuthTokenInfo, error) {
+	return os.defaultSession().RefreshToken()
+}
+
+// GetValidToken returns a valid access token of the default session
+func (os *OAuthService) GetValidToken() (string, error) {
+	return os.defaultSession().GetValidToken()
+}
+
+// GetTokenInfo returns the token information of the default session
+func (os *OAuthService) GetTokenInfo() *models.OAuthTokenInfo {
+	return os.defaultSession().GetTokenInfo()
+}
+
+// IsTokenValid checks if the token of the default session is valid
+func (os *OAuthService) IsTokenValid() bool {
+	return os.defaultSession().IsTokenValid()
+}
+
+// ClearToken clears the token of the default session
+func (os *OAuthService) ClearToken() {
+	os.defaultSession().ClearToken()
+}
+
+// ValidateConfig validates OAuth 2.0 configuration
+func (os *OAuthService) ValidateConfig(config *models.OAuth2Config) error {
+	required := []struct {
+		field, name, value string
+	}{
+		{"token_url", "token URL", config.TokenURL},
+		{"refresh_url", "refresh URL", config.RefreshURL},
+		{"username", "username", config.Username},
+		{"password", "password", config.Password},
+	}
+	for _, r := range required {
+		if r.value == "" {
+			return models.NewError(models.ErrCodeValidationFailed, r.name+" is required", nil).WithFields(models.FieldError{
+				Field: r.field,
+				Code:  models.FieldRequired,
+			})
+		}
+	}
+	return nil
+}
+
+// GetDefaultConfig returns default OAuth 2.0 configuration for CEISA 4.0
+func (os *OAuthService) GetDefaultConfig() *models.OAuth2Config {
 	os.mutex.RLock()
 	defer os.mutex.RUnlock()
-	return os.config
+
+	return &models.OAuth2Config{
+		TokenURL:   os.tokenURL,
+		RefreshURL: os.refreshURL,
+		Username:   "",
+		Password:   "",
+	}
+}
+
+// Per-session operations
+
+// SetConfig sets the OAuth 2.0 configuration of the session
+func (s *OAuthSession) SetConfig(config *models.OAuth2Config) {
+	s.mutex.Lock()
+	s.config = config
+	s.mutex.Unlock()
+
+	s.persist()
+}
+
+// GetConfig returns the OAuth 2.0 configuration of the session
+func (s *OAuthSession) GetConfig() *models.OAuth2Config {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.config
+}
+
+// SetPrincipal binds the session to an authenticated user of this service
+func (s *OAuthSession) SetPrincipal(principal *models.Principal) {
+	s.mutex.Lock()
+	s.principal = principal
+	s.mutex.Unlock()
+
+	s.persist()
+}
+
+// Principal returns the user of this service the session belongs to, if any
+func (s *OAuthSession) Principal() *models.Principal {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.principal
+}
+
+// TenantID returns the tenant the session holds credentials for, if any
+func (s *OAuthSession) TenantID() string {
+	if !strings.HasPrefix(s.ID, tenantSessionPrefix) {
+		return ""
+	}
+	return strings.TrimPrefix(s.ID, tenantSessionPrefix)
+}
+
+// LastUsed returns when the session was last accessed
+func (s *OAuthSession) LastUsed() time.Time {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.lastUsed
+}
+
+// lastUsedPersistInterval throttles writing the last use of a session to the
+// token store, so active sessions survive a restart without a write per request
+const lastUsedPersistInterval = time.Minute
+
+// touch records a use of the session, persisting it once the stored copy is
+// older than lastUsedPersistInterval
+func (s *OAuthSession) touch() {
+	s.mutex.Lock()
+	s.lastUsed = time.Now()
+	due := !s.lastPersisted.IsZero() && s.lastUsed.Sub(s.lastPersisted) >= lastUsedPersistInterval
+	s.mutex.Unlock()
+
+	if due {
+		s.persist()
+	}
+}
+
+// persist writes the session to the token store, if one is configured
+func (s *OAuthSession) persist() {
+	if s.ID == DefaultSessionID {
+		return
+	}
+
+	s.service.mutex.RLock()
+	store := s.service.store
+	s.service.mutex.RUnlock()
+
+	if store == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	record := &SessionRecord{
+		ID:        s.ID,
+		CreatedAt: s.CreatedAt,
+		LastUsed:  s.lastUsed,
+		Principal: s.principal,
+		Config:    s.config,
+		Token:     s.tokenInfo,
+	}
+	s.lastPersisted = s.lastUsed
+	s.mutex.Unlock()
+
+	if err := store.Save(record); err != nil {
+		logrus.WithError(err).Error("Failed to persist OAuth session")
+	}
 }
 
 // Login performs OAuth 2.0 login and obtains access token
-func (os *OAuthService) Login(username, password string) (*models.OAuthTokenInfo, error) {
-	if os.config == nil {
-		return nil, fmt.Errorf("OAuth 2.0 configuration not set")
+func (s *OAuthSession) Login(username, password string) (*models.OAuthTokenInfo, error) {
+	return s.LoginContext(context.Background(), username, password)
+}
+
+// LoginContext performs OAuth 2.0 login on behalf of the request in ctx
+func (s *OAuthSession) LoginContext(ctx context.Context, username, password string) (tokenInfo *models.OAuthTokenInfo, err error) {
+	defer func() {
+		telemetry.OAuthLogins.WithLabelValues(telemetry.Outcome(err)).Inc()
+	}()
+
+	config := s.GetConfig()
+	if config == nil {
+		return nil, models.NewError(models.ErrCodeOAuthNotConfigured, "OAuth 2.0 configuration not set", nil)
 	}
 
 	// Prepare login request
@@ -62,56 +570,53 @@ func (os *OAuthService) Login(username, password string) (*models.OAuthTokenInfo
 	}
 
 	// Create HTTP request
-	req, err := http.NewRequest("POST", os.config.TokenURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(telemetry.WithOperation(ctx, telemetry.OperationLogin), "POST", config.TokenURL, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create login request: %w", err)
 	}
 
 	// Set headers
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("User-Agent", "JSON-Response-Generator/2.0.0")
+	req.Header.Set("User-Agent", userAgent)
 
-	logrus.WithFields(logrus.Fields{
-		"endpoint": os.config.TokenURL,
+	logrus.WithContext(ctx).WithFields(logrus.Fields{
+		"endpoint": config.TokenURL,
 		"username": username,
 	}).Info("Attempting OAuth 2.0 login")
 
 	// Send request
-	resp, err := os.httpClient.Do(req)
+	resp, err := s.service.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("login request failed: %w", err)
+		return nil, upstreamError("login request failed", err)
 	}
 	defer resp.Body.Close()
 
 	// Parse response
 	var tokenResp models.OAuthTokenResponse
 	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
-		return nil, fmt.Errorf("failed to parse token response: %w", err)
+		return nil, models.NewError(models.ErrCodeUpstreamInvalid, "failed to parse token response", err)
 	}
 
 	// Check if login was successful
 	if resp.StatusCode != http.StatusOK || tokenResp.Status != "success" {
-		return nil, fmt.Errorf("login failed: %s (status: %d)", tokenResp.Message, resp.StatusCode)
+		return nil, models.Errorf(models.ErrCodeOAuthLoginFailed, "login failed: %s (status: %d)", tokenResp.Message, resp.StatusCode)
 	}
 
-	// Calculate expiration time
-	expiresAt := time.Now().Add(time.Duration(tokenResp.Item.ExpiresIn) * time.Second)
-
 	// Create token info
-	tokenInfo := &models.OAuthTokenInfo{
-		AccessToken:  tokenResp.Item.AccessToken,
-		RefreshToken: tokenResp.Item.RefreshToken,
-		ExpiresAt:    expiresAt,
-		TokenType:    tokenResp.Item.TokenType,
-		Scope:        tokenResp.Item.Scope,
+	tokenInfo = newTokenInfo(&tokenResp)
+	expiresAt := tokenInfo.ExpiresAt
+
+	if err := s.service.attachClaims(tokenInfo); err != nil {
+		return nil, err
 	}
 
 	// Store token info
-	os.mutex.Lock()
-	os.tokenInfo = tokenInfo
-	os.mutex.Unlock()
+	s.mutex.Lock()
+	s.tokenInfo = tokenInfo
+	s.mutex.Unlock()
+	s.persist()
 
-	logrus.WithFields(logrus.Fields{
+	logrus.WithContext(ctx).WithFields(logrus.Fields{
 		"expires_at": expiresAt,
 		"token_type": tokenResp.Item.TokenType,
 		"scope":      tokenResp.Item.Scope,
@@ -120,22 +625,111 @@ func (os *OAuthService) Login(username, password string) (*models.OAuthTokenInfo
 	return tokenInfo, nil
 }
 
-// RefreshToken refreshes the access token using refresh token
-func (os *OAuthService) RefreshToken() (*models.OAuthTokenInfo, error) {
-	if os.config == nil {
-		return nil, fmt.Errorf("OAuth 2.0 configuration not set")
+// RefreshToken renews the access token. Concurrent callers share a single
+// renewal; when the refresh token has expired the session logs in again with
+// its stored credentials.
+func (s *OAuthSession) RefreshToken() (*models.OAuthTokenInfo, error) {
+	return s.RefreshTokenContext(context.Background())
+}
+
+// refreshTimeout bounds a shared renewal, which no single caller can cancel
+const refreshTimeout = time.Minute
+
+// RefreshTokenContext renews the access token on behalf of the request in ctx.
+// The renewal is shared with concurrent callers, so it runs detached from ctx;
+// cancelling ctx only stops this caller from waiting for it.
+func (s *OAuthSession) RefreshTokenContext(ctx context.Context) (*models.OAuthTokenInfo, error) {
+	s.refreshMutex.Lock()
+	call := s.refreshCall
+	if call == nil {
+		call = &refreshCall{done: make(chan struct{})}
+		s.refreshCall = call
+		go s.runRefresh(context.WithoutCancel(ctx), call)
 	}
+	s.refreshMutex.Unlock()
 
-	os.mutex.RLock()
-	currentToken := os.tokenInfo
-	os.mutex.RUnlock()
+	select {
+	case <-call.done:
+		return call.token, call.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("stopped waiting for token refresh: %w", ctx.Err())
+	}
+}
+
+// runRefresh performs a shared renewal and releases its waiters
+func (s *OAuthSession) runRefresh(ctx context.Context, call *refreshCall) {
+	ctx, cancel := context.WithTimeout(ctx, refreshTimeout)
+	defer cancel()
+
+	call.token, call.err = s.renew(ctx)
+	telemetry.OAuthRefreshes.WithLabelValues(telemetry.Outcome(call.err)).Inc()
+
+	s.refreshMutex.Lock()
+	s.refreshCall = nil
+	s.refreshMutex.Unlock()
+	close(call.done)
+}
+
+// renew refreshes the token, or logs in again if the refresh token has expired
+func (s *OAuthSession) renew(ctx context.Context) (*models.OAuthTokenInfo, error) {
+	currentToken := s.GetTokenInfo()
+	refreshExpired := currentToken != nil && !currentToken.RefreshExpiresAt.IsZero() &&
+		time.Now().After(currentToken.RefreshExpiresAt)
+
+	if currentToken == nil || currentToken.RefreshToken == "" || refreshExpired {
+		if s.hasStoredCredentials() {
+			logrus.WithContext(ctx).WithField("session", s.logID()).Info("Refresh token expired, logging in again with stored credentials")
+			config := s.GetConfig()
+			return s.LoginContext(ctx, config.Username, config.Password)
+		}
+		if refreshExpired {
+			return nil, models.NewError(models.ErrCodeOAuthExpired, "refresh token expired, please login again", nil)
+		}
+	}
 
+	return s.requestRefresh(ctx)
+}
+
+// hasStoredCredentials reports whether the session can log in again unattended
+func (s *OAuthSession) hasStoredCredentials() bool {
+	config := s.GetConfig()
+	return config != nil && config.Username != "" && config.Password != ""
+}
+
+// canRenew reports whether a renewal can succeed without user interaction
+func (s *OAuthSession) canRenew() bool {
+	token := s.GetTokenInfo()
+	if token == nil {
+		return false
+	}
+	if s.hasStoredCredentials() {
+		return true
+	}
+	return token.RefreshToken != "" && (token.RefreshExpiresAt.IsZero() || time.Now().Before(token.RefreshExpiresAt))
+}
+
+// logID returns a shortened session identifier that is safe to log
+func (s *OAuthSession) logID() string {
+	if len(s.ID) > 8 {
+		return s.ID[:8]
+	}
+	return s.ID
+}
+
+// requestRefresh exchanges the refresh token for a new access token
+func (s *OAuthSession) requestRefresh(ctx context.Context) (*models.OAuthTokenInfo, error) {
+	config := s.GetConfig()
+	if config == nil {
+		return nil, models.NewError(models.ErrCodeOAuthNotConfigured, "OAuth 2.0 configuration not set", nil)
+	}
+
+	currentToken := s.GetTokenInfo()
 	if currentToken == nil || currentToken.RefreshToken == "" {
-		return nil, fmt.Errorf("no refresh token available")
+		return nil, models.NewError(models.ErrCodeOAuthLoginRequired, "no refresh token available", nil)
 	}
 
 	// Create refresh request
-	req, err := http.NewRequest("POST", os.config.RefreshURL, nil)
+	req, err := http.NewRequestWithContext(telemetry.WithOperation(ctx, telemetry.OperationRefresh), "POST", config.RefreshURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create refresh request: %w", err)
 	}
@@ -143,62 +737,82 @@ func (os *OAuthService) RefreshToken() (*models.OAuthTokenInfo, error) {
 	// Set headers with refresh token
 	req.Header.Set("Authorization", currentToken.RefreshToken)
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("User-Agent", "JSON-Response-Generator/2.0.0")
+	req.Header.Set("User-Agent", userAgent)
 
-	logrus.WithFields(logrus.Fields{
-		"endpoint": os.config.RefreshURL,
+	logrus.WithContext(ctx).WithFields(logrus.Fields{
+		"endpoint": config.RefreshURL,
 	}).Info("Attempting token refresh")
 
 	// Send request
-	resp, err := os.httpClient.Do(req)
+	resp, err := s.service.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("refresh request failed: %w", err)
+		return nil, upstreamError("refresh request failed", err)
 	}
 	defer resp.Body.Close()
 
 	// Parse response
 	var tokenResp models.OAuthTokenResponse
 	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
-		return nil, fmt.Errorf("failed to parse refresh response: %w", err)
+		return nil, models.NewError(models.ErrCodeUpstreamInvalid, "failed to parse refresh response", err)
 	}
 
 	// Check if refresh was successful
 	if resp.StatusCode != http.StatusOK || tokenResp.Status != "success" {
-		return nil, fmt.Errorf("token refresh failed: %s (status: %d)", tokenResp.Message, resp.StatusCode)
+		return nil, models.Errorf(models.ErrCodeOAuthExpired, "token refresh failed: %s (status: %d)", tokenResp.Message, resp.StatusCode)
 	}
 
-	// Calculate new expiration time
-	expiresAt := time.Now().Add(time.Duration(tokenResp.Item.ExpiresIn) * time.Second)
-
 	// Create new token info
-	newTokenInfo := &models.OAuthTokenInfo{
+	refreshedToken := newTokenInfo(&tokenResp)
+
+	if err := s.service.attachClaims(refreshedToken); err != nil {
+		return nil, err
+	}
+
+	// Store new token info
+	s.mutex.Lock()
+	s.tokenInfo = refreshedToken
+	s.mutex.Unlock()
+	s.persist()
+
+	logrus.WithContext(ctx).WithFields(logrus.Fields{
+		"expires_at": refreshedToken.ExpiresAt,
+	}).Info("Token refresh successful")
+
+	return refreshedToken, nil
+}
+
+// newTokenInfo converts a CEISA token response into token information
+func newTokenInfo(tokenResp *models.OAuthTokenResponse) *models.OAuthTokenInfo {
+	issuedAt := time.Now()
+
+	tokenInfo := &models.OAuthTokenInfo{
 		AccessToken:  tokenResp.Item.AccessToken,
 		RefreshToken: tokenResp.Item.RefreshToken,
-		ExpiresAt:    expiresAt,
+		IssuedAt:     issuedAt,
+		ExpiresAt:    issuedAt.Add(time.Duration(tokenResp.Item.ExpiresIn) * time.Second),
 		TokenType:    tokenResp.Item.TokenType,
 		Scope:        tokenResp.Item.Scope,
+		IDToken:      tokenResp.Item.IDToken,
 	}
 
-	// Store new token info
-	os.mutex.Lock()
-	os.tokenInfo = newTokenInfo
-	os.mutex.Unlock()
-
-	logrus.WithFields(logrus.Fields{
-		"expires_at": expiresAt,
-	}).Info("Token refresh successful")
+	if tokenResp.Item.RefreshExpiresIn > 0 {
+		tokenInfo.RefreshExpiresAt = issuedAt.Add(time.Duration(tokenResp.Item.RefreshExpiresIn) * time.Second)
+	}
 
-	return newTokenInfo, nil
+	return tokenInfo
 }
 
 // GetValidToken returns a valid access token, refreshing if necessary
-func (os *OAuthService) GetValidToken() (string, error) {
-	os.mutex.RLock()
-	currentToken := os.tokenInfo
-	os.mutex.RUnlock()
+func (s *OAuthSession) GetValidToken() (string, error) {
+	return s.GetValidTokenContext(context.Background())
+}
 
+// GetValidTokenContext returns a valid access token on behalf of the request
+// in ctx, refreshing if necessary
+func (s *OAuthSession) GetValidTokenContext(ctx context.Context) (string, error) {
+	currentToken := s.GetTokenInfo()
 	if currentToken == nil {
-		return "", fmt.Errorf("no token available, please login first")
+		return "", models.NewError(models.ErrCodeOAuthLoginRequired, "no token available, please login first", nil)
 	}
 
 	// Check if token is still valid (with 1 minute buffer)
@@ -207,65 +821,66 @@ func (os *OAuthService) GetValidToken() (string, error) {
 	}
 
 	// Token is expired or about to expire, try to refresh
-	logrus.Info("Access token expired or about to expire, attempting refresh")
-	
-	newToken, err := os.RefreshToken()
+	logrus.WithContext(ctx).Info("Access token expired or about to expire, attempting refresh")
+
+	newToken, err := s.RefreshTokenContext(ctx)
 	if err != nil {
-		return "", fmt.Errorf("failed to refresh token: %w", err)
+		code := models.ErrorCodeOf(err)
+		if code == "" {
+			code = models.ErrCodeOAuthExpired
+		}
+		return "", models.NewError(code, "failed to refresh token", err)
 	}
 
 	return newToken.AccessToken, nil
 }
 
 // GetTokenInfo returns the current token information
-func (os *OAuthService) GetTokenInfo() *models.OAuthTokenInfo {
-	os.mutex.RLock()
-	defer os.mutex.RUnlock()
-	return os.tokenInfo
+func (s *OAuthSession) GetTokenInfo() *models.OAuthTokenInfo {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.tokenInfo
+}
+
+// Claims returns the identity claims of the current token, if any
+func (s *OAuthSession) Claims() *models.TokenClaims {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if s.tokenInfo == nil {
+		return nil
+	}
+	return s.tokenInfo.Claims
 }
 
 // IsTokenValid checks if the current token is valid
-func (os *OAuthService) IsTokenValid() bool {
-	os.mutex.RLock()
-	defer os.mutex.RUnlock()
+func (s *OAuthSession) IsTokenValid() bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
 
-	if os.tokenInfo == nil {
+	if s.tokenInfo == nil {
 		return false
 	}
 
-	return time.Now().Before(os.tokenInfo.ExpiresAt)
+	return time.Now().Before(s.tokenInfo.ExpiresAt)
 }
 
-// ClearToken clears the stored token information
-func (os *OAuthService) ClearToken() {
-	os.mutex.Lock()
-	defer os.mutex.Unlock()
-	os.tokenInfo = nil
-}
+// ClearCredentials removes the CEISA token and configuration, keeping the
+// session itself
+func (s *OAuthSession) ClearCredentials() {
+	s.mutex.Lock()
+	s.tokenInfo = nil
+	s.config = nil
+	s.mutex.Unlock()
 
-// ValidateConfig validates OAuth 2.0 configuration
-func (os *OAuthService) ValidateConfig(config *models.OAuth2Config) error {
-	if config.TokenURL == "" {
-		return fmt.Errorf("token URL is required")
-	}
-	if config.RefreshURL == "" {
-		return fmt.Errorf("refresh URL is required")
-	}
-	if config.Username == "" {
-		return fmt.Errorf("username is required")
-	}
-	if config.Password == "" {
-		return fmt.Errorf("password is required")
-	}
-	return nil
+	s.persist()
 }
 
-// GetDefaultConfig returns default OAuth 2.0 configuration for CEISA 4.0
-func (os *OAuthService) GetDefaultConfig() *models.OAuth2Config {
-	return &models.OAuth2Config{
-		TokenURL:   "https://apis-gw.beacukai.go.id/nle-oauth/v1/user/login",
-		RefreshURL: "https://apis-gw.beacukai.go.id/nle-oauth/v1/user/update-token",
-		Username:   "",
-		Password:   "",
-	}
+// ClearToken clears the stored token information
+func (s *OAuthSession) ClearToken() {
+	s.mutex.Lock()
+	s.tokenInfo = nil
+	s.mutex.Unlock()
+
+	s.persist()
 }
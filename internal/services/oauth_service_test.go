@@ -170,7 +170,7 @@ func TestTokenValidation(t *testing.T) {
 	}
 	
 	// Manually set the expired token (for testing purposes)
-	service.tokenInfo = expiredToken
+	service.defaultSession().tokenInfo = expiredToken
 	
 	// Test that expired token is invalid
 	if service.IsTokenValid() {
@@ -187,7 +187,7 @@ func TestTokenValidation(t *testing.T) {
 	}
 	
 	// Set the valid token
-	service.tokenInfo = validToken
+	service.defaultSession().tokenInfo = validToken
 	
 	// Test that valid token is valid
 	if !service.IsTokenValid() {
@@ -245,3 +245,59 @@ func TestConcurrentAccess(t *testing.T) {
 		t.Error("Config should not be nil after concurrent access")
 	}
 }
+
+func TestSessionsAreIsolated(t *testing.T) {
+	service := NewOAuthService()
+
+	first, err := service.NewSession()
+	if err != nil {
+		t.Fatalf("NewSession() error = %v", err)
+	}
+	second, err := service.NewSession()
+	if err != nil {
+		t.Fatalf("NewSession() error = %v", err)
+	}
+
+	if first.ID == second.ID {
+		t.Fatal("Sessions should have distinct identifiers")
+	}
+
+	first.SetConfig(&models.OAuth2Config{Username: "operator-a"})
+	first.tokenInfo = &models.OAuthTokenInfo{
+		AccessToken: "token-a",
+		ExpiresAt:   time.Now().Add(1 * time.Hour),
+	}
+
+	if second.GetConfig() != nil || second.GetTokenInfo() != nil {
+		t.Error("Second session should not see the first session's credentials")
+	}
+
+	if service.GetTokenInfo() != nil {
+		t.Error("Default session should not see the first session's token")
+	}
+
+	token, err := first.GetValidToken()
+	if err != nil || token != "token-a" {
+		t.Errorf("Expected token-a, got %q (err %v)", token, err)
+	}
+
+	service.DeleteSession(first.ID)
+	if _, ok := service.GetSession(first.ID); ok {
+		t.Error("Deleted session should not be found")
+	}
+	if _, ok := service.GetSession(second.ID); !ok {
+		t.Error("Second session should still exist")
+	}
+}
+
+func TestSessionExpiry(t *testing.T) {
+	service := NewOAuthService()
+	service.sessionTTL = time.Minute
+
+	session, _ := service.NewSession()
+	session.lastUsed = time.Now().Add(-2 * time.Minute)
+
+	if _, ok := service.GetSession(session.ID); ok {
+		t.Error("Idle session should have expired")
+	}
+}
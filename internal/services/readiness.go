@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"json-response-generator/internal/models"
+)
+
+// ReadinessProbe checks a dependency, returning a short description of its
+// state or an error when the service cannot work without it
+type ReadinessProbe func(ctx context.Context) (string, error)
+
+// skippedCheck is returned by probes that do not apply to the configuration
+type skippedCheck struct {
+	reason string
+}
+
+func (e *skippedCheck) Error() string {
+	return e.reason
+}
+
+// SkipCheck marks a readiness check as not applicable
+func SkipCheck(reason string) error {
+	return &skippedCheck{reason: reason}
+}
+
+// degradedCheck is returned by probes finding a problem the service can work
+// around, such as some sessions needing a new login
+type degradedCheck struct {
+	reason string
+}
+
+func (e *degradedCheck) Error() string {
+	return e.reason
+}
+
+// DegradeCheck marks a readiness check as degraded without failing readiness
+func DegradeCheck(reason string) error {
+	return &degradedCheck{reason: reason}
+}
+
+type namedProbe struct {
+	name  string
+	probe ReadinessProbe
+}
+
+// ReadinessChecker runs readiness probes and caches their results, so
+// frequent polling does not hit the dependencies on every request
+type ReadinessChecker struct {
+	ttl     time.Duration
+	timeout time.Duration
+	probes  []namedProbe
+
+	mutex     sync.Mutex
+	cached    []models.ReadinessCheck
+	checkedAt time.Time
+}
+
+// NewReadinessChecker creates a checker caching results for ttl and giving
+// each probe up to timeout
+func NewReadinessChecker(ttl, timeout time.Duration) *ReadinessChecker {
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return &ReadinessChecker{ttl: ttl, timeout: timeout}
+}
+
+// Add registers a probe under name
+func (rc *ReadinessChecker) Add(name string, probe ReadinessProbe) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	rc.probes = append(rc.probes, namedProbe{name: name, probe: probe})
+	rc.cached = nil
+}
+
+// Check returns the result of every probe and whether none of them failed.
+// Results younger than the cache TTL are reused; concurrent callers wait for
+// a single run of the probes. The results are shared, so the probes do not
+// run on the caller's context and a cancelled request cannot fail them.
+func (rc *ReadinessChecker) Check(ctx context.Context) ([]models.ReadinessCheck, bool) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	if rc.cached == nil || time.Since(rc.checkedAt) >= rc.ttl {
+		rc.cached = rc.run(context.WithoutCancel(ctx))
+		rc.checkedAt = time.Now()
+	}
+
+	checks := append([]models.ReadinessCheck(nil), rc.cached...)
+	ready := true
+	for _, check := range checks {
+		if check.Status == models.CheckFailed {
+			ready = false
+		}
+	}
+	return checks, ready
+}
+
+// run executes the probes concurrently
+func (rc *ReadinessChecker) run(ctx context.Context) []models.ReadinessCheck {
+	checks := make([]models.ReadinessCheck, len(rc.probes))
+
+	var wg sync.WaitGroup
+	for i, p := range rc.probes {
+		wg.Add(1)
+		go func(i int, p namedProbe) {
+			defer wg.Done()
+
+			probeCtx, cancel := context.WithTimeout(ctx, rc.timeout)
+			defer cancel()
+
+			start := time.Now()
+			message, err := p.probe(probeCtx)
+			check := models.ReadinessCheck{
+				Name:      p.name,
+				Status:    models.CheckPassed,
+				Message:   message,
+				Duration:  time.Since(start).String(),
+				CheckedAt: start,
+			}
+
+			var skipped *skippedCheck
+			var degraded *degradedCheck
+			switch {
+			case errors.As(err, &skipped):
+				check.Status = models.CheckSkipped
+				check.Message = skipped.reason
+			case errors.As(err, &degraded):
+				check.Status = models.CheckDegraded
+				check.Message = degraded.reason
+			case err != nil:
+				check.Status = models.CheckFailed
+				check.Message = err.Error()
+			}
+			checks[i] = check
+		}(i, p)
+	}
+	wg.Wait()
+
+	return checks
+}
+
+// TempDirProbe checks that files can be created in dir
+func TempDirProbe(dir string) ReadinessProbe {
+	return func(ctx context.Context) (string, error) {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+
+		file, err := os.CreateTemp(dir, "ready_*")
+		if err != nil {
+			return "", fmt.Errorf("%s is not writable: %w", dir, err)
+		}
+		file.Close()
+		os.Remove(file.Name())
+
+		return dir + " is writable", nil
+	}
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"json-response-generator/internal/models"
+)
+
+func TestReadinessCheckerCachesResults(t *testing.T) {
+	var calls int32
+	checker := NewReadinessChecker(time.Minute, time.Second)
+	checker.Add("counter", func(ctx context.Context) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		return "ok", nil
+	})
+
+	for i := 0; i < 3; i++ {
+		if _, ready := checker.Check(context.Background()); !ready {
+			t.Fatal("expected ready")
+		}
+	}
+	if calls != 1 {
+		t.Errorf("probe ran %d times, want 1 within the cache TTL", calls)
+	}
+}
+
+func TestReadinessCheckerStatuses(t *testing.T) {
+	checker := NewReadinessChecker(0, time.Second)
+	checker.Add("passing", func(ctx context.Context) (string, error) { return "fine", nil })
+	checker.Add("skipped", func(ctx context.Context) (string, error) { return "", SkipCheck("not configured") })
+	checker.Add("failing", func(ctx context.Context) (string, error) { return "", errors.New("broken") })
+	checker.Add("degraded", func(ctx context.Context) (string, error) { return "", DegradeCheck("needs login") })
+
+	checks, ready := checker.Check(context.Background())
+	if ready {
+		t.Error("a failing probe must make the service not ready")
+	}
+
+	want := map[string]string{"passing": models.CheckPassed, "skipped": models.CheckSkipped, "failing": models.CheckFailed, "degraded": models.CheckDegraded}
+	for _, check := range checks {
+		if check.Status != want[check.Name] {
+			t.Errorf("check %s has status %s, want %s", check.Name, check.Status, want[check.Name])
+		}
+	}
+	if checks[1].Message != "not configured" || checks[2].Message != "broken" || checks[3].Message != "needs login" {
+		t.Errorf("unexpected messages: %+v", checks)
+	}
+
+	degraded := NewReadinessChecker(0, time.Second)
+	degraded.Add("degraded", func(ctx context.Context) (string, error) { return "", DegradeCheck("needs login") })
+	if _, ready := degraded.Check(context.Background()); !ready {
+		t.Error("a degraded probe must not make the service unready")
+	}
+}
+
+func TestReadinessCheckerIgnoresCallerCancellation(t *testing.T) {
+	checker := NewReadinessChecker(time.Minute, time.Second)
+	checker.Add("context", func(ctx context.Context) (string, error) { return "ok", ctx.Err() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, ready := checker.Check(ctx); !ready {
+		t.Error("a cancelled caller must not fail the shared probe results")
+	}
+}
+
+func TestTempDirProbe(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "temp")
+	if _, err := TempDirProbe(dir)(context.Background()); err != nil {
+		t.Errorf("expected %s to be writable: %v", dir, err)
+	}
+
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := TempDirProbe(filepath.Join(file, "temp"))(context.Background()); err == nil {
+		t.Error("expected an error for a directory below a file")
+	}
+}
+
+func TestEndpointProbe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	client := NewApiClient()
+
+	if _, err := client.EndpointProbe(server.URL)(context.Background()); err != nil {
+		t.Errorf("any HTTP response should count as reachable: %v", err)
+	}
+
+	server.Close()
+	if _, err := client.EndpointProbe(server.URL)(context.Background()); err == nil {
+		t.Error("expected an error for an unreachable endpoint")
+	}
+
+	var skipped *skippedCheck
+	if _, err := client.EndpointProbe("")(context.Background()); !errors.As(err, &skipped) {
+		t.Errorf("expected the check to be skipped without endpoint, got %v", err)
+	}
+}
+
+func TestTokenProbe(t *testing.T) {
+	service := NewOAuthService()
+
+	var skipped *skippedCheck
+	if _, err := service.TokenProbe()(context.Background()); !errors.As(err, &skipped) {
+		t.Errorf("expected the check to be skipped without login, got %v", err)
+	}
+
+	session := service.defaultSession()
+	session.tokenInfo = &models.OAuthTokenInfo{AccessToken: "expired", ExpiresAt: time.Now().Add(-time.Minute)}
+	var degraded *degradedCheck
+	if _, err := service.TokenProbe()(context.Background()); !errors.As(err, &degraded) {
+		t.Errorf("expected the check to be degraded when the only token expired and cannot be renewed, got %v", err)
+	}
+
+	session.tokenInfo.ExpiresAt = time.Now().Add(time.Minute)
+	if _, err := service.TokenProbe()(context.Background()); err != nil {
+		t.Errorf("expected a valid token to pass: %v", err)
+	}
+}
@@ -0,0 +1,404 @@
+package services
+
+import (
+	"embed"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"json-response-generator/internal/models"
+)
+
+// referenceFiles are the reference tables shipped with the service. Lists too
+// long to ship, e.g. customs offices and ports, are loaded from a directory;
+// loading fails while a table of referenceFields is missing.
+//
+//go:embed references
+var referenceFiles embed.FS
+
+// referenceFields maps the declaration fields holding reference codes to
+// their table
+var referenceFields = map[string]string{
+	"kodeKantor":         "kantor",
+	"kodeKantorBongkar":  "kantor",
+	"kodeKantorEkspor":   "kantor",
+	"kodeKantorMuat":     "kantor",
+	"kodeKantorPeriksa":  "kantor",
+	"kodeKantorTujuan":   "kantor",
+	"kodeValuta":         "valuta",
+	"kodeNegara":         "negara",
+	"kodeNegaraAsal":     "negara",
+	"kodeBendera":        "negara",
+	"kodePelBongkar":     "pelabuhan",
+	"kodePelEkspor":      "pelabuhan",
+	"kodePelMuat":        "pelabuhan",
+	"kodePelTransit":     "pelabuhan",
+	"kodePelTujuan":      "pelabuhan",
+	"kodeJenisKemasan":   "kemasan",
+	"kodeSatuanBarang":   "satuan",
+	"kodeCaraAngkut":     "cara_angkut",
+	"kodeFasilitasTarif": "fasilitas_tarif",
+}
+
+// Limits of reference searches
+const (
+	defaultReferenceLimit = 20
+	maxReferenceLimit     = 100
+	maxSuggestions        = 3
+)
+
+// ErrReferenceTableNotFound is returned for a table that is not loaded
+var ErrReferenceTableNotFound = errors.New("reference table not found")
+
+// referenceTable is one loaded code list
+type referenceTable struct {
+	info  models.ReferenceTableInfo
+	codes []models.ReferenceCode
+	index map[string]bool // upper-case codes
+}
+
+// ReferenceData holds the customs reference tables. Each table is the newest
+// version of its files, named <table>@<version>.csv or .json: a CSV file has
+// the columns kode and uraian, a JSON file is an array of {kode, uraian}.
+type ReferenceData struct {
+	tables map[string]*referenceTable
+}
+
+// LoadReferenceData loads the shipped reference tables and, when dir is not
+// empty, the tables of its files, which replace shipped tables of the same name
+func LoadReferenceData(dir string) (*ReferenceData, error) {
+	rd := &ReferenceData{tables: make(map[string]*referenceTable)}
+
+	embedded, err := fs.Sub(referenceFiles, "references")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open shipped reference tables: %w", err)
+	}
+	if err := rd.loadDir(embedded, "shipped"); err != nil {
+		return nil, err
+	}
+
+	if dir != "" {
+		if err := rd.loadDir(os.DirFS(dir), dir); err != nil {
+			return nil, err
+		}
+	}
+
+	// Declarations are only checked against complete tables
+	var missing []string
+	for _, name := range referenceFields {
+		if _, loaded := rd.tables[name]; !loaded && !slices.Contains(missing, name) {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("reference tables %s are missing, add their official lists to the references directory", strings.Join(missing, ", "))
+	}
+
+	return rd, nil
+}
+
+// loadDir loads the newest version of every table in a directory
+func (rd *ReferenceData) loadDir(fsys fs.FS, source string) error {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return fmt.Errorf("failed to read reference tables %s: %w", source, err)
+	}
+
+	newest := make(map[string]fs.DirEntry)
+	for _, entry := range entries {
+		name, version, ok := referenceFileName(entry.Name())
+		if entry.IsDir() || !ok {
+			continue
+		}
+		if current, exists := newest[name]; exists {
+			_, currentVersion, _ := referenceFileName(current.Name())
+			if compareVersions(version, currentVersion) <= 0 {
+				continue
+			}
+		}
+		newest[name] = entry
+	}
+
+	for name, entry := range newest {
+		_, version, _ := referenceFileName(entry.Name())
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return fmt.Errorf("failed to read reference table %s: %w", entry.Name(), err)
+		}
+		codes, err := parseReferenceCodes(entry.Name(), data)
+		if err != nil {
+			return fmt.Errorf("failed to parse reference table %s: %w", entry.Name(), err)
+		}
+
+		table := &referenceTable{
+			info: models.ReferenceTableInfo{
+				Name:    name,
+				Version: version,
+				Source:  path.Join(source, entry.Name()),
+				Count:   len(codes),
+				Fields:  tableFields(name),
+			},
+			codes: codes,
+			index: make(map[string]bool, len(codes)),
+		}
+		for _, code := range codes {
+			table.index[strings.ToUpper(code.Kode)] = true
+		}
+		rd.tables[name] = table
+	}
+	return nil
+}
+
+// Tables describes the loaded tables, ordered by name
+func (rd *ReferenceData) Tables() []models.ReferenceTableInfo {
+	tables := make([]models.ReferenceTableInfo, 0, len(rd.tables))
+	for _, table := range rd.tables {
+		tables = append(tables, table.info)
+	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i].Name < tables[j].Name })
+	return tables
+}
+
+// Search returns up to limit codes of a table matching q for autocomplete:
+// codes starting with q first, then codes whose description contains it. An
+// empty q returns the first codes of the table.
+func (rd *ReferenceData) Search(name, q string, limit int) ([]models.ReferenceCode, error) {
+	table, exists := rd.tables[name]
+	if !exists {
+		return nil, ErrReferenceTableNotFound
+	}
+	if limit <= 0 {
+		limit = defaultReferenceLimit
+	}
+	if limit > maxReferenceLimit {
+		limit = maxReferenceLimit
+	}
+
+	query := strings.ToUpper(strings.TrimSpace(q))
+	type match struct {
+		code models.ReferenceCode
+		rank int
+	}
+	var matches []match
+	for _, code := range table.codes {
+		kode, uraian := strings.ToUpper(code.Kode), strings.ToUpper(code.Uraian)
+		switch {
+		case query == "" || kode == query:
+			matches = append(matches, match{code, 0})
+		case strings.HasPrefix(kode, query):
+			matches = append(matches, match{code, 1})
+		case strings.HasPrefix(uraian, query):
+			matches = append(matches, match{code, 2})
+		case strings.Contains(uraian, query):
+			matches = append(matches, match{code, 3})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].rank < matches[j].rank })
+
+	codes := make([]models.ReferenceCode, 0, limit)
+	for _, m := range matches {
+		if len(codes) == limit {
+			break
+		}
+		codes = append(codes, m.code)
+	}
+	return codes, nil
+}
+
+// Check reports the reference codes of a declaration that are not in their
+// table, suggesting the closest codes. Empty fields are not checked.
+func (rd *ReferenceData) Check(declaration models.Declaration) []models.FieldError {
+	data, err := json.Marshal(declaration)
+	if err != nil {
+		return nil
+	}
+	var fields interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil
+	}
+
+	var problems []models.FieldError
+	rd.checkValue(fields, "", &problems)
+	return problems
+}
+
+// checkValue checks the reference codes in a decoded JSON value at a path
+func (rd *ReferenceData) checkValue(value interface{}, at string, problems *[]models.FieldError) {
+	switch v := value.(type) {
+	case []interface{}:
+		for i, element := range v {
+			rd.checkValue(element, fmt.Sprintf("%s[%d]", at, i), problems)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			field := key
+			if at != "" {
+				field = at + "." + key
+			}
+			code, isString := v[key].(string)
+			name, isReference := referenceFields[key]
+			if !isString || !isReference {
+				rd.checkValue(v[key], field, problems)
+				continue
+			}
+			table, loaded := rd.tables[name]
+			code = strings.TrimSpace(code)
+			if !loaded || code == "" || table.index[strings.ToUpper(code)] {
+				continue
+			}
+
+			message := fmt.Sprintf("unknown %s code %q", name, code)
+			if suggestions := table.suggest(code); len(suggestions) > 0 {
+				message += ", did you mean " + strings.Join(suggestions, ", ") + "?"
+			}
+			*problems = append(*problems, models.FieldError{Field: field, Code: models.FieldInvalidValue, Message: message})
+		}
+	}
+}
+
+// suggest returns the codes closest to an unknown code: codes with a
+// description equal to it, then codes within a small edit distance
+func (rt *referenceTable) suggest(value string) []string {
+	query := strings.ToUpper(value)
+	maxDistance := 1 + len(query)/4
+
+	type candidate struct {
+		kode     string
+		distance int
+	}
+	var candidates []candidate
+	for _, code := range rt.codes {
+		kode := strings.ToUpper(code.Kode)
+		if strings.ToUpper(code.Uraian) == query {
+			candidates = append(candidates, candidate{code.Kode, -1})
+			continue
+		}
+		if distance := editDistance(query, kode); distance <= maxDistance {
+			candidates = append(candidates, candidate{code.Kode, distance})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].distance < candidates[j].distance })
+
+	suggestions := make([]string, 0, maxSuggestions)
+	for _, c := range candidates {
+		if len(suggestions) == maxSuggestions {
+			break
+		}
+		suggestions = append(suggestions, c.kode)
+	}
+	return suggestions
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// referenceFileName splits a file name <table>@<version>.csv or .json
+func referenceFileName(file string) (name, version string, ok bool) {
+	ext := path.Ext(file)
+	if ext != ".csv" && ext != ".json" {
+		return "", "", false
+	}
+	name, version, ok = strings.Cut(strings.TrimSuffix(file, ext), "@")
+	return name, version, ok && name != "" && version != ""
+}
+
+// parseReferenceCodes reads the codes of a CSV or JSON reference file
+func parseReferenceCodes(file string, data []byte) ([]models.ReferenceCode, error) {
+	var codes []models.ReferenceCode
+	if path.Ext(file) == ".json" {
+		if err := json.Unmarshal(data, &codes); err != nil {
+			return nil, err
+		}
+	} else {
+		rows, err := csv.NewReader(strings.NewReader(string(data))).ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) == 0 || len(rows[0]) < 2 || !strings.EqualFold(rows[0][0], "kode") || !strings.EqualFold(rows[0][1], "uraian") {
+			return nil, fmt.Errorf("the first row must name the columns kode and uraian")
+		}
+		for _, row := range rows[1:] {
+			codes = append(codes, models.ReferenceCode{Kode: strings.TrimSpace(row[0]), Uraian: strings.TrimSpace(row[1])})
+		}
+	}
+
+	seen := make(map[string]bool, len(codes))
+	for i, code := range codes {
+		if code.Kode == "" {
+			return nil, fmt.Errorf("code %d is empty", i+1)
+		}
+		if seen[strings.ToUpper(code.Kode)] {
+			return nil, fmt.Errorf("duplicate code %s", code.Kode)
+		}
+		seen[strings.ToUpper(code.Kode)] = true
+	}
+	return codes, nil
+}
+
+// compareVersions compares versions part by part, numerically where both
+// parts are numbers, e.g. 2024.10 is newer than 2024.9
+func compareVersions(a, b string) int {
+	split := func(version string) []string {
+		return strings.FieldsFunc(version, func(r rune) bool { return r == '.' || r == '-' || r == '_' })
+	}
+	partsA, partsB := split(a), split(b)
+	for i := 0; i < len(partsA) && i < len(partsB); i++ {
+		numberA, errA := strconv.Atoi(partsA[i])
+		numberB, errB := strconv.Atoi(partsB[i])
+		switch {
+		case errA == nil && errB == nil && numberA != numberB:
+			if numberA < numberB {
+				return -1
+			}
+			return 1
+		case (errA != nil || errB != nil) && partsA[i] != partsB[i]:
+			return strings.Compare(partsA[i], partsB[i])
+		}
+	}
+	return len(partsA) - len(partsB)
+}
+
+// tableFields returns the declaration fields checked against a table
+func tableFields(name string) []string {
+	var fields []string
+	for field, table := range referenceFields {
+		if table == name {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
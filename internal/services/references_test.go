@@ -0,0 +1,113 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"json-response-generator/internal/models"
+)
+
+// testReferencesDir holds small versions of the tables not shipped
+const testReferencesDir = "testdata/references"
+
+func TestReferenceSearch(t *testing.T) {
+	references, err := LoadReferenceData(testReferencesDir)
+	if err != nil {
+		t.Fatalf("Failed to load reference tables: %v", err)
+	}
+
+	codes, err := references.Search("valuta", "us", 0)
+	if err != nil || len(codes) == 0 || codes[0].Kode != "USD" {
+		t.Errorf("Expected USD first for us, got %+v, %v", codes, err)
+	}
+	codes, _ = references.Search("valuta", "rupiah", 0)
+	if len(codes) == 0 || codes[0].Kode != "IDR" {
+		t.Errorf("Expected IDR by its description, got %+v", codes)
+	}
+	if codes, _ := references.Search("negara", "", 5); len(codes) != 5 {
+		t.Errorf("Expected 5 codes, got %d", len(codes))
+	}
+	if _, err := references.Search("hs", "04", 0); !errors.Is(err, ErrReferenceTableNotFound) {
+		t.Errorf("Expected an unknown table to be reported, got %v", err)
+	}
+}
+
+func TestReferenceDataRequiresCheckedTables(t *testing.T) {
+	_, err := LoadReferenceData("")
+	if err == nil {
+		t.Fatal("Expected loading without the official lists to fail")
+	}
+	for _, table := range []string{"kantor", "kemasan", "pelabuhan", "satuan"} {
+		if !strings.Contains(err.Error(), table) {
+			t.Errorf("Expected %s to be reported missing: %v", table, err)
+		}
+	}
+}
+
+func TestReferenceCheck(t *testing.T) {
+	references, err := LoadReferenceData(testReferencesDir)
+	if err != nil {
+		t.Fatalf("Failed to load reference tables: %v", err)
+	}
+
+	declaration := NewJsonGenerator().GenerateSampleData()
+	if problems := references.Check(declaration); len(problems) != 0 {
+		t.Errorf("Expected the sample to use known codes, got %+v", problems)
+	}
+
+	declaration.KodeValuta = "USDD"
+	declaration.Barang[1].KodeNegaraAsal = "indonesia"
+	declaration.KodeKantor = "999999"
+	problems := references.Check(declaration)
+	if len(problems) != 3 {
+		t.Fatalf("Expected three unknown codes, got %+v", problems)
+	}
+	if problems[0].Field != "barang[1].kodeNegaraAsal" || !strings.Contains(problems[0].Message, "did you mean ID") {
+		t.Errorf("Expected ID suggested for indonesia, got %+v", problems[0])
+	}
+	if problems[1].Field != "kodeKantor" {
+		t.Errorf("Expected the unknown office to be reported, got %+v", problems[1])
+	}
+	if problems[2].Field != "kodeValuta" || problems[2].Code != models.FieldInvalidValue || !strings.Contains(problems[2].Message, "did you mean USD") {
+		t.Errorf("Expected USD suggested for USDD, got %+v", problems[2])
+	}
+}
+
+func TestReferenceDirectory(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"kantor@2023.9.csv":  "kode,uraian\n040300,KPU BEA DAN CUKAI TIPE A TANJUNG PRIOK\n",
+		"kantor@2023.10.csv": "kode,uraian\n040300,KPU BEA DAN CUKAI TIPE A TANJUNG PRIOK\n050100,KPPBC TMP A BEKASI\n",
+		"valuta@2025.1.json": `[{"kode": "CNY", "uraian": "YUAN RENMINBI"}]`,
+		"pelabuhan@1.csv":    "kode,uraian\nIDTPP,TANJUNG PRIOK\n",
+		"kemasan@1.csv":      "kode,uraian\nBX,BOX\n",
+		"satuan@1.csv":       "kode,uraian\nKGM,KILOGRAM\n",
+		"README.md":          "not a table",
+	}
+	for name, content := range files {
+		os.WriteFile(filepath.Join(dir, name), []byte(content), 0600)
+	}
+
+	references, err := LoadReferenceData(dir)
+	if err != nil {
+		t.Fatalf("Failed to load reference tables: %v", err)
+	}
+	tables := make(map[string]models.ReferenceTableInfo)
+	for _, table := range references.Tables() {
+		tables[table.Name] = table
+	}
+	if tables["kantor"].Version != "2023.10" || tables["kantor"].Count != 2 {
+		t.Errorf("Expected the newest kantor version, got %+v", tables["kantor"])
+	}
+	if tables["valuta"].Count != 1 || tables["negara"].Version != "2024.1" {
+		t.Errorf("Expected valuta replaced and negara shipped, got %+v", tables)
+	}
+
+	os.WriteFile(filepath.Join(dir, "satuan@1.csv"), []byte("kode,uraian\nKGM,KILOGRAM\nkgm,KILOGRAM\n"), 0600)
+	if _, err := LoadReferenceData(dir); err == nil {
+		t.Error("Expected a table with duplicate codes to be rejected")
+	}
+}
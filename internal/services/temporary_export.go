@@ -0,0 +1,393 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+
+	"json-response-generator/internal/models"
+)
+
+// Document codes of goods sent out of a bonded area for processing and of
+// their return
+const (
+	KodeDokumenKeluarSementara = "261"
+	KodeDokumenMasukKembali    = "262"
+)
+
+// ErrTemporaryExportNotFound is returned for a BC 2.6.1 that was not submitted
+var ErrTemporaryExportNotFound = errors.New("temporary export not found")
+
+// TemporaryExportLedger tracks the quantities still outstanding on submitted
+// BC 2.6.1 declarations, less what submitted BC 2.6.2 declarations returned.
+// Approved BC 2.6.2 declarations not sent yet hold their quantities pending.
+type TemporaryExportLedger struct {
+	balances map[string]*models.TemporaryExportBalance // by nomorAju of the BC 2.6.1
+	pending  []*models.TemporaryExportDeclaration      // approved BC 2.6.2 not yet submitted
+}
+
+// returnLine is one returned item of a BC 2.6.2 with the BC 2.6.1 it left on
+type returnLine struct {
+	index    int
+	barang   *models.Barang
+	nomorAju string // empty when the item refers to no BC 2.6.1
+}
+
+// NewTemporaryExportLedger builds the ledger from the declarations of a
+// tenant, newest first. Only declarations that have been submitted count,
+// and approved returns, including those being sent, as pending; a
+// declaration submitted more than once counts once.
+func NewTemporaryExportLedger(documents []*models.Document) *TemporaryExportLedger {
+	ledger := &TemporaryExportLedger{balances: make(map[string]*models.TemporaryExportBalance)}
+
+	var returns, pending []*models.Document
+	seen := make(map[string]bool)
+	for _, document := range documents {
+		submitted := document.Status == models.DocumentStatusSubmitted || document.Status == models.DocumentStatusResponded
+		claimed := document.Status == models.DocumentStatusApproved || document.Status == models.DocumentStatusSubmitting
+		switch {
+		case !submitted && !claimed:
+			continue
+		case submitted && seen[document.NomorAju]:
+			continue
+		case submitted:
+			seen[document.NomorAju] = true
+		}
+
+		switch {
+		case document.KodeDokumen == KodeDokumenKeluarSementara && submitted:
+			ledger.addSource(document)
+		case document.KodeDokumen == KodeDokumenMasukKembali && submitted:
+			returns = append(returns, document)
+		case document.KodeDokumen == KodeDokumenMasukKembali:
+			pending = append(pending, document)
+		}
+	}
+
+	// Returns are applied once every source is known
+	for _, document := range returns {
+		ledger.addReturn(document)
+	}
+	for _, document := range pending {
+		if !seen[document.NomorAju] {
+			ledger.addPending(document)
+		}
+	}
+	return ledger
+}
+
+// addSource opens the balance of a BC 2.6.1
+func (tl *TemporaryExportLedger) addSource(document *models.Document) {
+	declaration, ok := document.Data.Declaration.(*models.TemporaryExportDeclaration)
+	if !ok {
+		return
+	}
+
+	balance := &models.TemporaryExportBalance{
+		DocumentID:  document.ID,
+		NomorAju:    declaration.NomorAju,
+		Returns:     []string{},
+		Outstanding: true,
+		Items:       make([]models.TemporaryExportItem, 0, len(declaration.Barang)),
+	}
+	for _, barang := range declaration.Barang {
+		balance.Items = append(balance.Items, models.TemporaryExportItem{
+			SeriBarang:       barang.SeriBarang,
+			PosTarif:         barang.PosTarif,
+			Uraian:           barang.Uraian,
+			KodeSatuanBarang: barang.KodeSatuanBarang,
+			JumlahSatuan:     barang.JumlahSatuan,
+			Saldo:            barang.JumlahSatuan,
+		})
+	}
+	tl.balances[declaration.NomorAju] = balance
+}
+
+// addReturn deducts the items of a BC 2.6.2 from their sources
+func (tl *TemporaryExportLedger) addReturn(document *models.Document) {
+	declaration, ok := document.Data.Declaration.(*models.TemporaryExportDeclaration)
+	if !ok {
+		return
+	}
+
+	for _, line := range returnLines(declaration) {
+		balance := tl.balances[line.nomorAju]
+		if balance == nil {
+			continue
+		}
+		item := findItem(balance, line.barang.SeriBarangDokAsal)
+		if item == nil {
+			continue
+		}
+		item.JumlahRealisasi += line.barang.JumlahRealisasi
+		item.Saldo = item.JumlahSatuan - item.JumlahRealisasi
+
+		if len(balance.Returns) == 0 || balance.Returns[len(balance.Returns)-1] != declaration.NomorAju {
+			balance.Returns = append(balance.Returns, declaration.NomorAju)
+		}
+		balance.Outstanding = hasSaldo(balance)
+	}
+}
+
+// addPending reserves the items of an approved BC 2.6.2 on their sources
+func (tl *TemporaryExportLedger) addPending(document *models.Document) {
+	declaration, ok := document.Data.Declaration.(*models.TemporaryExportDeclaration)
+	if !ok {
+		return
+	}
+
+	tl.pending = append(tl.pending, declaration)
+	for _, line := range returnLines(declaration) {
+		if balance := tl.balances[line.nomorAju]; balance != nil {
+			if item := findItem(balance, line.barang.SeriBarangDokAsal); item != nil {
+				item.Pending += line.barang.JumlahRealisasi
+			}
+		}
+	}
+}
+
+// hasSaldo reports whether any item of a balance has not been fully returned
+func hasSaldo(balance *models.TemporaryExportBalance) bool {
+	for _, item := range balance.Items {
+		if item.Saldo > amountTolerance {
+			return true
+		}
+	}
+	return false
+}
+
+// Balances returns the balance of every submitted BC 2.6.1 by nomorAju
+func (tl *TemporaryExportLedger) Balances() []models.TemporaryExportBalance {
+	balances := make([]models.TemporaryExportBalance, 0, len(tl.balances))
+	for _, balance := range tl.balances {
+		balances = append(balances, copyBalance(balance))
+	}
+
+	sort.Slice(balances, func(i, j int) bool {
+		return balances[i].NomorAju < balances[j].NomorAju
+	})
+	return balances
+}
+
+// Balance returns the balance of the BC 2.6.1 with the given nomorAju
+func (tl *TemporaryExportLedger) Balance(nomorAju string) (*models.TemporaryExportBalance, error) {
+	balance, exists := tl.balances[nomorAju]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrTemporaryExportNotFound, nomorAju)
+	}
+
+	clone := copyBalance(balance)
+	return &clone, nil
+}
+
+// CheckReturn reports the items of a BC 2.6.2 that refer to no submitted
+// BC 2.6.1 item, return more than is outstanding or state another opening
+// balance (saldoAwal). Quantities pending on other approved returns are not
+// outstanding. Other declarations have nothing to check.
+func (tl *TemporaryExportLedger) CheckReturn(declaration models.Declaration) []models.FieldError {
+	returned, ok := declaration.(*models.TemporaryExportDeclaration)
+	if !ok || returned.KodeDokumen != KodeDokumenMasukKembali {
+		return nil
+	}
+
+	// Items returned earlier in the same declaration reduce the balance too,
+	// while what its own approved copy holds pending does not
+	type itemKey struct {
+		nomorAju   string
+		seriBarang int
+	}
+	pending := make(map[itemKey]float64)
+	own := make(map[itemKey]float64)
+	for _, approved := range tl.pending {
+		if approved.NomorAju != returned.NomorAju {
+			continue
+		}
+		for _, line := range returnLines(approved) {
+			own[itemKey{line.nomorAju, line.barang.SeriBarangDokAsal}] += line.barang.JumlahRealisasi
+		}
+	}
+
+	var problems []models.FieldError
+	for _, line := range returnLines(returned) {
+		seriBarang := line.barang.SeriBarangDokAsal
+		if line.nomorAju == "" {
+			problems = append(problems, models.FieldError{
+				Field:   fmt.Sprintf("barang[%d].barangDokumen", line.index),
+				Code:    models.FieldRequired,
+				Message: "returned goods must refer to their BC 2.6.1",
+			})
+			continue
+		}
+
+		balance := tl.balances[line.nomorAju]
+		if balance == nil {
+			problems = append(problems, models.FieldError{
+				Field:   fmt.Sprintf("barang[%d].barangDokumen", line.index),
+				Code:    models.FieldInvalidValue,
+				Message: fmt.Sprintf("BC 2.6.1 %s has not been submitted", line.nomorAju),
+			})
+			continue
+		}
+		item := findItem(balance, seriBarang)
+		if item == nil {
+			problems = append(problems, models.FieldError{
+				Field:   fmt.Sprintf("barang[%d].seriBarangDokAsal", line.index),
+				Code:    models.FieldInvalidValue,
+				Message: fmt.Sprintf("BC 2.6.1 %s has no item %d", line.nomorAju, seriBarang),
+			})
+			continue
+		}
+
+		key := itemKey{line.nomorAju, seriBarang}
+		reserved := item.Pending - own[key]
+		outstanding := item.Saldo - reserved - pending[key]
+		pending[key] += line.barang.JumlahRealisasi
+
+		if line.barang.JumlahRealisasi > outstanding+amountTolerance {
+			message := fmt.Sprintf("only %.2f of item %d of BC 2.6.1 %s is outstanding", outstanding, seriBarang, line.nomorAju)
+			if reserved > amountTolerance {
+				message += fmt.Sprintf(", %.2f is pending on approved returns", reserved)
+			}
+			problems = append(problems, models.FieldError{
+				Field:   fmt.Sprintf("barang[%d].jumlahRealisasi", line.index),
+				Code:    models.FieldInvalidValue,
+				Message: message,
+			})
+			continue
+		}
+		if math.Abs(line.barang.SaldoAwal-outstanding) > amountTolerance {
+			problems = append(problems, models.FieldError{
+				Field:   fmt.Sprintf("barang[%d].saldoAwal", line.index),
+				Code:    models.FieldMismatch,
+				Message: fmt.Sprintf("%.2f is outstanding", outstanding),
+			})
+		}
+	}
+	return problems
+}
+
+// ReturnSources returns the nomorAju of the BC 2.6.1 declarations a BC 2.6.2
+// returns goods of, none for other declarations
+func ReturnSources(declaration models.Declaration) []string {
+	returned, ok := declaration.(*models.TemporaryExportDeclaration)
+	if !ok || returned.KodeDokumen != KodeDokumenMasukKembali {
+		return nil
+	}
+
+	var sources []string
+	for _, dokumen := range returned.Dokumen {
+		if dokumen.KodeDokumen == KodeDokumenKeluarSementara {
+			sources = append(sources, dokumen.NomorDokumen)
+		}
+	}
+	return sources
+}
+
+// InTemporaryExportLedger reports whether the ledger tracks declarations of
+// the given document type
+func InTemporaryExportLedger(declaration models.Declaration) bool {
+	declared, ok := declaration.(*models.TemporaryExportDeclaration)
+	return ok && (declared.KodeDokumen == KodeDokumenKeluarSementara || declared.KodeDokumen == KodeDokumenMasukKembali)
+}
+
+// TemporaryExportLocks serialises checking and sending the returns of a
+// BC 2.6.1, so concurrent returns cannot both claim the same balance
+type TemporaryExportLocks struct {
+	mutex sync.Mutex
+	locks map[string]*sourceLock
+}
+
+// sourceLock is the lock of one BC 2.6.1 with the number of callers using it
+type sourceLock struct {
+	mutex sync.Mutex
+	users int
+}
+
+// NewTemporaryExportLocks creates an empty set of locks
+func NewTemporaryExportLocks() *TemporaryExportLocks {
+	return &TemporaryExportLocks{locks: make(map[string]*sourceLock)}
+}
+
+// Lock locks the BC 2.6.1 declarations of a tenant by nomorAju and returns the
+// function releasing them. Locks are taken in order to avoid deadlocks.
+func (tl *TemporaryExportLocks) Lock(tenantID string, sources []string) func() {
+	keys := make([]string, 0, len(sources))
+	for _, nomorAju := range sources {
+		keys = append(keys, tenantID+"/"+nomorAju)
+	}
+	sort.Strings(keys)
+
+	var held []string
+	for i, key := range keys {
+		if i > 0 && key == keys[i-1] {
+			continue
+		}
+		tl.mutex.Lock()
+		lock := tl.locks[key]
+		if lock == nil {
+			lock = &sourceLock{}
+			tl.locks[key] = lock
+		}
+		lock.users++
+		tl.mutex.Unlock()
+
+		lock.mutex.Lock()
+		held = append(held, key)
+	}
+
+	return func() {
+		tl.mutex.Lock()
+		defer tl.mutex.Unlock()
+		for _, key := range held {
+			lock := tl.locks[key]
+			lock.mutex.Unlock()
+			if lock.users--; lock.users == 0 {
+				delete(tl.locks, key)
+			}
+		}
+	}
+}
+
+// returnLines resolves the BC 2.6.1 each item of a BC 2.6.2 refers to
+// through its barangDokumen
+func returnLines(declaration *models.TemporaryExportDeclaration) []returnLine {
+	sources := make(map[string]string)
+	for _, dokumen := range declaration.Dokumen {
+		if dokumen.KodeDokumen == KodeDokumenKeluarSementara {
+			sources[strconv.Itoa(dokumen.SeriDokumen)] = dokumen.NomorDokumen
+		}
+	}
+
+	lines := make([]returnLine, 0, len(declaration.Barang))
+	for i := range declaration.Barang {
+		line := returnLine{index: i, barang: &declaration.Barang[i]}
+		for _, reference := range declaration.Barang[i].BarangDokumen {
+			if nomorAju, ok := sources[reference.SeriDokumen]; ok {
+				line.nomorAju = nomorAju
+				break
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// findItem returns the item of a balance with the given seriBarang
+func findItem(balance *models.TemporaryExportBalance, seriBarang int) *models.TemporaryExportItem {
+	for i := range balance.Items {
+		if balance.Items[i].SeriBarang == seriBarang {
+			return &balance.Items[i]
+		}
+	}
+	return nil
+}
+
+func copyBalance(balance *models.TemporaryExportBalance) models.TemporaryExportBalance {
+	clone := *balance
+	clone.Returns = append([]string{}, balance.Returns...)
+	clone.Items = append([]models.TemporaryExportItem(nil), balance.Items...)
+	return clone
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"json-response-generator/internal/models"
+)
+
+func TestTemporaryExportLedger(t *testing.T) {
+	generator := NewJsonGenerator()
+	source := generator.GenerateTemporaryExportSampleData()
+	returned := generator.GenerateTemporaryReturnSampleData()
+	draft := generator.GenerateTemporaryReturnSampleData()
+	draft.NomorAju = "00026205050020211225000002"
+
+	ledger := NewTemporaryExportLedger([]*models.Document{
+		{ID: "1", NomorAju: source.NomorAju, KodeDokumen: "261", Status: models.DocumentStatusSubmitted, Data: models.DeclarationData{Declaration: source}},
+		{ID: "2", NomorAju: returned.NomorAju, KodeDokumen: "262", Status: models.DocumentStatusResponded, Data: models.DeclarationData{Declaration: returned}},
+		{ID: "3", NomorAju: draft.NomorAju, KodeDokumen: "262", Status: models.DocumentStatusDraft, Data: models.DeclarationData{Declaration: draft}},
+	})
+
+	balance, err := ledger.Balance(source.NomorAju)
+	if err != nil {
+		t.Fatalf("Failed to get balance: %v", err)
+	}
+	if !balance.Outstanding || balance.Items[0].Saldo != 4 || balance.Items[1].Saldo != 0 {
+		t.Errorf("Expected 4 of the first item outstanding, got %+v", balance)
+	}
+	if len(balance.Returns) != 1 || balance.Returns[0] != returned.NomorAju {
+		t.Errorf("Expected only the submitted return, got %v", balance.Returns)
+	}
+
+	// The remaining 4 of the first item can come back, in two lines
+	next := generator.GenerateTemporaryReturnSampleData()
+	next.Barang[1] = next.Barang[0]
+	next.Barang[0].SaldoAwal, next.Barang[0].JumlahRealisasi, next.Barang[0].SaldoAkhir = 4, 3, 1
+	next.Barang[1].SaldoAwal, next.Barang[1].JumlahRealisasi, next.Barang[1].SaldoAkhir = 1, 1, 0
+	if problems := ledger.CheckReturn(next); len(problems) != 0 {
+		t.Errorf("Expected no problems, got %+v", problems)
+	}
+
+	next.Barang[1].JumlahRealisasi = 2
+	next.Barang[1].SaldoAkhir = -1
+	problems := ledger.CheckReturn(next)
+	if len(problems) != 1 || problems[0].Field != "barang[1].jumlahRealisasi" {
+		t.Errorf("Expected a barang[1].jumlahRealisasi problem, got %+v", problems)
+	}
+
+	next.Dokumen[0].NomorDokumen = "00026105050020211225000009"
+	problems = ledger.CheckReturn(next)
+	if len(problems) != 2 || problems[0].Field != "barang[0].barangDokumen" {
+		t.Errorf("Expected problems with an unknown BC 2.6.1, got %+v", problems)
+	}
+
+	if _, err := ledger.Balance("00026105050020211225000009"); err == nil {
+		t.Error("Expected an unsubmitted BC 2.6.1 to have no balance")
+	}
+}
+
+func TestTemporaryExportLedgerPendingReturns(t *testing.T) {
+	generator := NewJsonGenerator()
+	source := generator.GenerateTemporaryExportSampleData()
+	approved := generator.GenerateTemporaryReturnSampleData()
+
+	ledger := NewTemporaryExportLedger([]*models.Document{
+		{ID: "2", NomorAju: approved.NomorAju, KodeDokumen: "262", Status: models.DocumentStatusApproved, Data: models.DeclarationData{Declaration: approved}},
+		{ID: "1", NomorAju: source.NomorAju, KodeDokumen: "261", Status: models.DocumentStatusSubmitted, Data: models.DeclarationData{Declaration: source}},
+	})
+
+	balance, _ := ledger.Balance(source.NomorAju)
+	if balance.Items[0].Saldo != 10 || balance.Items[0].Pending != 6 {
+		t.Errorf("Expected 6 of 10 pending, got %+v", balance.Items[0])
+	}
+
+	// The approved return itself can still be sent
+	if problems := ledger.CheckReturn(approved); len(problems) != 0 {
+		t.Errorf("Expected the approved return to pass, got %+v", problems)
+	}
+
+	// Another return cannot claim what it holds
+	competing := generator.GenerateTemporaryReturnSampleData()
+	competing.NomorAju = "00026205050020211225000002"
+	if problems := ledger.CheckReturn(competing); len(problems) != 2 {
+		t.Errorf("Expected both items to be held by the approved return, got %+v", problems)
+	}
+}
+
+func TestTemporaryExportLocks(t *testing.T) {
+	locks := NewTemporaryExportLocks()
+	unlock := locks.Lock("", []string{"b", "a", "a"})
+
+	acquired := make(chan struct{})
+	go func() {
+		locks.Lock("", []string{"a"})()
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("Expected the source to stay locked")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// Other tenants and sources are not held up
+	locks.Lock("acme", []string{"a"})()
+	locks.Lock("", []string{"c"})()
+
+	unlock()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the source to be released")
+	}
+	if len(locks.locks) != 0 {
+		t.Errorf("Expected released locks to be dropped, got %d", len(locks.locks))
+	}
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"json-response-generator/internal/models"
+)
+
+// tenantsFile is the on-disk format of the tenant store
+type tenantsFile struct {
+	Tenants []models.Tenant `json:"tenants"`
+}
+
+// TenantStore holds the importers a customs broker (PPJK) files for
+type TenantStore struct {
+	tenants map[string]*models.Tenant
+	order   []string
+}
+
+// LoadTenantStore reads tenants from a JSON file
+func LoadTenantStore(path string) (*TenantStore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenants file %s: %w", path, err)
+	}
+
+	var file tenantsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse tenants file %s: %w", path, err)
+	}
+
+	return NewTenantStore(file.Tenants)
+}
+
+// NewTenantStore creates a tenant store from a list of tenants
+func NewTenantStore(tenants []models.Tenant) (*TenantStore, error) {
+	if len(tenants) == 0 {
+		return nil, fmt.Errorf("no tenants configured")
+	}
+
+	store := &TenantStore{
+		tenants: make(map[string]*models.Tenant),
+	}
+
+	for i := range tenants {
+		tenant := tenants[i]
+		if tenant.ID == "" {
+			return nil, fmt.Errorf("tenant %d has no id", i+1)
+		}
+		if _, exists := store.tenants[tenant.ID]; exists {
+			return nil, fmt.Errorf("duplicate tenant %s", tenant.ID)
+		}
+		store.tenants[tenant.ID] = &tenant
+		store.order = append(store.order, tenant.ID)
+	}
+
+	return store, nil
+}
+
+// Get returns the tenant with the given ID
+func (ts *TenantStore) Get(id string) (*models.Tenant, bool) {
+	tenant, exists := ts.tenants[id]
+	return tenant, exists
+}
+
+// Count returns the number of tenants
+func (ts *TenantStore) Count() int {
+	return len(ts.order)
+}
+
+// List returns the tenants principal may act for, in configuration order
+func (ts *TenantStore) List(principal *models.Principal) []*models.Tenant {
+	tenants := make([]*models.Tenant, 0, len(ts.order))
+	for _, id := range ts.order {
+		if tenant := ts.tenants[id]; ts.Allowed(tenant, principal) {
+			tenants = append(tenants, tenant)
+		}
+	}
+	return tenants
+}
+
+// Allowed reports whether principal may act for tenant. Administrators may act
+// for every tenant; other users only for tenants listing them or marked open.
+func (ts *TenantStore) Allowed(tenant *models.Tenant, principal *models.Principal) bool {
+	if principal == nil {
+		return false
+	}
+	if tenant.Open || principal.HasRole(models.RoleAdmin) {
+		return true
+	}
+	for _, username := range tenant.Users {
+		if username == principal.Username {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"testing"
+
+	"json-response-generator/internal/models"
+)
+
+func TestTenantStoreAllowed(t *testing.T) {
+	store, err := NewTenantStore([]models.Tenant{
+		{ID: "acme", Name: "PT Acme", Users: []string{"alice"}},
+		{ID: "open", Name: "PT Open", Open: true},
+		{ID: "closed", Name: "PT Closed"},
+	})
+	if err != nil {
+		t.Fatalf("NewTenantStore failed: %v", err)
+	}
+
+	alice := &models.Principal{Username: "alice", Role: models.RolePreparer}
+	bob := &models.Principal{Username: "bob", Role: models.RoleApprover}
+	admin := &models.Principal{Username: "root", Role: models.RoleAdmin}
+
+	acme, _ := store.Get("acme")
+	if !store.Allowed(acme, alice) || store.Allowed(acme, bob) || !store.Allowed(acme, admin) {
+		t.Error("Expected only listed users and administrators to act for acme")
+	}
+
+	closed, _ := store.Get("closed")
+	if store.Allowed(closed, alice) || !store.Allowed(closed, admin) {
+		t.Error("Expected a tenant without users to be closed to all but administrators")
+	}
+
+	if got := len(store.List(bob)); got != 1 {
+		t.Errorf("Expected bob to see 1 tenant, got %d", got)
+	}
+	if got := len(store.List(admin)); got != 3 {
+		t.Errorf("Expected admin to see 3 tenants, got %d", got)
+	}
+
+	if _, err := NewTenantStore([]models.Tenant{{ID: "a"}, {ID: "a"}}); err == nil {
+		t.Error("Expected duplicate tenant ids to be rejected")
+	}
+}
+
+func TestPrefillTenantDefaults(t *testing.T) {
+	npwp := "012345678901000"
+	defaults := &models.TenantDefaults{
+		KodeKantor: "040300",
+		IdPengguna: "ACME01",
+		Entitas: []models.Entitas{
+			{KodeEntitas: "1", NamaEntitas: "PT ACME", NomorIdentitas: &npwp},
+			{KodeEntitas: "4", NamaEntitas: "PT PPJK"},
+		},
+	}
+
+	jg := NewJsonGenerator()
+	data := &models.ResponseData{
+		KodeKantor: "050100",
+		Entitas: []models.Entitas{
+			{KodeEntitas: "1", NamaEntitas: "PT OTHER", SeriEntitas: 1},
+		},
+	}
+	jg.PrefillTenantDefaults(data, defaults)
+
+	if data.KodeKantor != "050100" {
+		t.Errorf("Expected declared kodeKantor to be kept, got %s", data.KodeKantor)
+	}
+	if data.IdPengguna != "ACME01" {
+		t.Errorf("Expected idPengguna to be prefilled, got %s", data.IdPengguna)
+	}
+	if len(data.Entitas) != 2 || data.Entitas[0].NamaEntitas != "PT OTHER" || data.Entitas[1].KodeEntitas != "4" || data.Entitas[1].SeriEntitas != 2 {
+		t.Errorf("Unexpected entitas %+v", data.Entitas)
+	}
+
+	sample := jg.GenerateSampleDataForTenant(defaults)
+	if sample.KodeKantor != "040300" || sample.IdPengguna != "ACME01" {
+		t.Errorf("Expected sample to carry tenant defaults, got %s/%s", sample.KodeKantor, sample.IdPengguna)
+	}
+	if sample.Entitas[0].NamaEntitas != "PT ACME" || sample.Entitas[0].SeriEntitas != 1 {
+		t.Errorf("Expected sample importer to be the tenant, got %+v", sample.Entitas[0])
+	}
+}
+
+func TestTenantSessionsAreSharedAndPinned(t *testing.T) {
+	service := NewOAuthService()
+	tenant := &models.Tenant{
+		ID:    "acme",
+		OAuth: &models.OAuth2Config{TokenURL: "https://example.test/token", Username: "acme", Password: "secret"},
+	}
+
+	first := service.TenantSession(tenant)
+	second := service.TenantSession(tenant)
+	if first != second {
+		t.Error("Expected one shared session per tenant")
+	}
+	if first.TenantID() != "acme" {
+		t.Errorf("Expected tenant id acme, got %q", first.TenantID())
+	}
+	if config := first.GetConfig(); config == nil || config.Username != "acme" {
+		t.Errorf("Expected tenant OAuth config to seed the session, got %+v", config)
+	}
+
+	service.sessionTTL = 1
+	if _, ok := service.GetSession(first.ID); !ok {
+		t.Error("Expected tenant sessions not to expire")
+	}
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// TokenRefresher renews OAuth tokens in the background before they expire,
+// so that submissions never wait on a refresh
+type TokenRefresher struct {
+	oauthService *OAuthService
+	fraction     float64
+	interval     time.Duration
+	stop         chan struct{}
+	done         chan struct{}
+	once         sync.Once
+}
+
+// NewTokenRefresher creates a refresher that renews tokens once fraction of
+// their lifetime has elapsed, checking every interval
+func NewTokenRefresher(oauthService *OAuthService, fraction float64, interval time.Duration) *TokenRefresher {
+	if fraction <= 0 || fraction >= 1 {
+		fraction = 0.75
+	}
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+
+	return &TokenRefresher{
+		oauthService: oauthService,
+		fraction:     fraction,
+		interval:     interval,
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+}
+
+// Start launches the background refresh loop
+func (tr *TokenRefresher) Start() {
+	go tr.run()
+}
+
+// Stop signals the refresh loop to exit and waits for it until ctx is done
+func (tr *TokenRefresher) Stop(ctx context.Context) error {
+	tr.once.Do(func() { close(tr.stop) })
+
+	select {
+	case <-tr.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (tr *TokenRefresher) run() {
+	defer close(tr.done)
+
+	ticker := time.NewTicker(tr.interval)
+	defer ticker.Stop()
+
+	logrus.WithFields(logrus.Fields{
+		"fraction": tr.fraction,
+		"interval": tr.interval.String(),
+	}).Info("OAuth token refresher started")
+
+	for {
+		select {
+		case <-tr.stop:
+			logrus.Info("OAuth token refresher stopped")
+			return
+		case now := <-ticker.C:
+			tr.refreshDue(now)
+		}
+	}
+}
+
+// refreshDue renews every session whose token has reached its refresh point
+func (tr *TokenRefresher) refreshDue(now time.Time) {
+	for _, session := range tr.oauthService.Sessions() {
+		select {
+		case <-tr.stop:
+			return
+		default:
+		}
+
+		if !tr.isDue(session, now) || !session.canRenew() {
+			continue
+		}
+
+		if _, err := session.RefreshToken(); err != nil {
+			logrus.WithError(err).WithField("session", session.logID()).Warn("Background token refresh failed")
+		}
+	}
+}
+
+// isDue reports whether the session token has passed the configured fraction of its lifetime
+func (tr *TokenRefresher) isDue(session *OAuthSession, now time.Time) bool {
+	token := session.GetTokenInfo()
+	if token == nil || token.IssuedAt.IsZero() {
+		return false
+	}
+
+	lifetime := token.ExpiresAt.Sub(token.IssuedAt)
+	refreshAt := token.IssuedAt.Add(time.Duration(float64(lifetime) * tr.fraction))
+
+	return !now.Before(refreshAt)
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"json-response-generator/internal/models"
+)
+
+// newCeisaStub returns a server answering CEISA login and refresh calls and
+// counting how often each endpoint was hit
+func newCeisaStub(t *testing.T, logins, refreshes *int32) *httptest.Server {
+	t.Helper()
+
+	respond := func(w http.ResponseWriter, token string) {
+		var resp models.OAuthTokenResponse
+		resp.Status = "success"
+		resp.Item.AccessToken = token
+		resp.Item.RefreshToken = "refresh-" + token
+		resp.Item.ExpiresIn = 300
+		resp.Item.RefreshExpiresIn = 1800
+		json.NewEncoder(w).Encode(resp)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(logins, 1)
+		respond(w, "login-token")
+	})
+	mux.HandleFunc("/refresh", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(refreshes, 1)
+		time.Sleep(20 * time.Millisecond)
+		respond(w, "refreshed-token")
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRefreshTokenIsSingleFlight(t *testing.T) {
+	var logins, refreshes int32
+	server := newCeisaStub(t, &logins, &refreshes)
+
+	service := NewOAuthService()
+	session, _ := service.NewSession()
+	session.SetConfig(&models.OAuth2Config{TokenURL: server.URL + "/login", RefreshURL: server.URL + "/refresh"})
+	session.tokenInfo = &models.OAuthTokenInfo{
+		AccessToken:  "old",
+		RefreshToken: "refresh-old",
+		IssuedAt:     time.Now().Add(-5 * time.Minute),
+		ExpiresAt:    time.Now().Add(30 * time.Second),
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := session.GetValidToken()
+			if err != nil || token != "refreshed-token" {
+				t.Errorf("GetValidToken() = %q, %v", token, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if refreshes != 1 {
+		t.Errorf("Expected a single refresh call, got %d", refreshes)
+	}
+}
+
+func TestRefreshSurvivesCancelledCaller(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		var resp models.OAuthTokenResponse
+		resp.Status = "success"
+		resp.Item.AccessToken = "refreshed-token"
+		resp.Item.RefreshToken = "refresh-refreshed-token"
+		resp.Item.ExpiresIn = 300
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	service := NewOAuthService()
+	session, _ := service.NewSession()
+	session.SetConfig(&models.OAuth2Config{TokenURL: server.URL, RefreshURL: server.URL})
+	session.tokenInfo = &models.OAuthTokenInfo{
+		AccessToken:  "old",
+		RefreshToken: "refresh-old",
+		IssuedAt:     time.Now().Add(-5 * time.Minute),
+		ExpiresAt:    time.Now().Add(30 * time.Second),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := session.RefreshTokenContext(ctx)
+		first <- err
+	}()
+
+	// Wait for the first caller to start the shared refresh before joining it
+	for {
+		session.refreshMutex.Lock()
+		started := session.refreshCall != nil
+		session.refreshMutex.Unlock()
+		if started {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	second := make(chan *models.OAuthTokenInfo, 1)
+	go func() {
+		token, err := session.RefreshTokenContext(context.Background())
+		if err != nil {
+			t.Errorf("waiting caller failed: %v", err)
+		}
+		second <- token
+	}()
+
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled caller error = %v, want context.Canceled", err)
+	}
+
+	close(release)
+	if token := <-second; token == nil || token.AccessToken != "refreshed-token" {
+		t.Errorf("waiting caller got %+v, want the refreshed token", token)
+	}
+	if session.GetTokenInfo().AccessToken != "refreshed-token" {
+		t.Error("the refresh should complete despite the cancelled caller")
+	}
+}
+
+func TestRefreshFallsBackToLoginWhenRefreshTokenExpired(t *testing.T) {
+	var logins, refreshes int32
+	server := newCeisaStub(t, &logins, &refreshes)
+
+	service := NewOAuthService()
+	session, _ := service.NewSession()
+	session.SetConfig(&models.OAuth2Config{
+		TokenURL:   server.URL + "/login",
+		RefreshURL: server.URL + "/refresh",
+		Username:   "operator",
+		Password:   "secret",
+	})
+	session.tokenInfo = &models.OAuthTokenInfo{
+		AccessToken:      "old",
+		RefreshToken:     "refresh-old",
+		IssuedAt:         time.Now().Add(-time.Hour),
+		ExpiresAt:        time.Now().Add(-time.Minute),
+		RefreshExpiresAt: time.Now().Add(-time.Second),
+	}
+
+	token, err := session.RefreshToken()
+	if err != nil {
+		t.Fatalf("RefreshToken() error = %v", err)
+	}
+	if token.AccessToken != "login-token" || logins != 1 || refreshes != 0 {
+		t.Errorf("Expected re-login, got token %q with %d logins and %d refreshes", token.AccessToken, logins, refreshes)
+	}
+}
+
+func TestTokenRefresherRenewsDueTokens(t *testing.T) {
+	var logins, refreshes int32
+	server := newCeisaStub(t, &logins, &refreshes)
+
+	service := NewOAuthService()
+	session, _ := service.NewSession()
+	session.SetConfig(&models.OAuth2Config{TokenURL: server.URL + "/login", RefreshURL: server.URL + "/refresh"})
+	session.tokenInfo = &models.OAuthTokenInfo{
+		AccessToken:  "old",
+		RefreshToken: "refresh-old",
+		IssuedAt:     time.Now().Add(-4 * time.Minute),
+		ExpiresAt:    time.Now().Add(1 * time.Minute),
+	}
+
+	refresher := NewTokenRefresher(service, 0.75, 10*time.Millisecond)
+	refresher.Start()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&refreshes) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := refresher.Stop(ctx); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	if session.GetTokenInfo().AccessToken != "refreshed-token" {
+		t.Errorf("Expected background refresh, got token %q", session.GetTokenInfo().AccessToken)
+	}
+}
+
+func TestTokenRefresherSkipsFreshTokens(t *testing.T) {
+	refresher := NewTokenRefresher(NewOAuthService(), 0.75, time.Second)
+	session := refresher.oauthService.defaultSession()
+	session.tokenInfo = &models.OAuthTokenInfo{
+		IssuedAt:  time.Now(),
+		ExpiresAt: time.Now().Add(5 * time.Minute),
+	}
+
+	if refresher.isDue(session, time.Now()) {
+		t.Error("Fresh token should not be due for refresh")
+	}
+	if !refresher.isDue(session, time.Now().Add(4*time.Minute)) {
+		t.Error("Token past 75% of its lifetime should be due for refresh")
+	}
+}
@@ -0,0 +1,320 @@
+package services
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"json-response-generator/internal/models"
+)
+
+// SessionRecord is the persisted form of an OAuth session
+type SessionRecord struct {
+	ID        string                 `json:"id"`
+	CreatedAt time.Time              `json:"created_at"`
+	LastUsed  time.Time              `json:"last_used"`
+	Principal *models.Principal      `json:"principal,omitempty"`
+	Config    *models.OAuth2Config   `json:"config,omitempty"`
+	Token     *models.OAuthTokenInfo `json:"token,omitempty"`
+}
+
+// TokenStore persists OAuth sessions, including CEISA credentials, across restarts
+type TokenStore interface {
+	Save(record *SessionRecord) error
+	Delete(id string) error
+	LoadAll() ([]*SessionRecord, error)
+}
+
+// Keyring holds the AES-256 keys used to encrypt stored sessions. The primary
+// key encrypts new data; previous keys are kept to decrypt data written before
+// a key rotation.
+type Keyring struct {
+	primary  []byte
+	previous [][]byte
+}
+
+// NewKeyring creates a keyring from a primary key and optional previous keys
+func NewKeyring(primary []byte, previous ...[]byte) (*Keyring, error) {
+	for _, key := range append([][]byte{primary}, previous...) {
+		if len(key) != 32 {
+			return nil, fmt.Errorf("encryption keys must be 32 bytes, got %d", len(key))
+		}
+	}
+
+	return &Keyring{
+		primary:  primary,
+		previous: previous,
+	}, nil
+}
+
+// LoadKeyring builds a keyring from base64 keys. Keys can be passed directly as
+// a comma separated list or read from a key file with one key per line; the
+// first key is the primary one.
+func LoadKeyring(keys string, keyFile string) (*Keyring, error) {
+	var encoded []string
+
+	if keys != "" {
+		for _, key := range strings.Split(keys, ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				encoded = append(encoded, key)
+			}
+		}
+	}
+
+	if keyFile != "" {
+		f, err := os.Open(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open key file %s: %w", keyFile, err)
+		}
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line != "" && !strings.HasPrefix(line, "#") {
+				encoded = append(encoded, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read key file %s: %w", keyFile, err)
+		}
+	}
+
+	if len(encoded) == 0 {
+		return nil, fmt.Errorf("no encryption key configured")
+	}
+
+	decoded := make([][]byte, 0, len(encoded))
+	for i, key := range encoded {
+		raw, err := base64.StdEncoding.DecodeString(key)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %d is not valid base64: %w", i+1, err)
+		}
+		decoded = append(decoded, raw)
+	}
+
+	return NewKeyring(decoded[0], decoded[1:]...)
+}
+
+// keyID returns a short non-secret identifier for a key
+func keyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:4])
+}
+
+// encryptedEnvelope is the on-disk format of an encrypted payload
+type encryptedEnvelope struct {
+	KeyID      string `json:"key_id"`
+	Nonce      string `json:"nonce"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+// Encrypt seals plaintext with the primary key using AES-GCM
+func (k *Keyring) Encrypt(plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(k.primary)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	envelope := encryptedEnvelope{
+		KeyID:      keyID(k.primary),
+		Nonce:      base64.StdEncoding.EncodeToString(nonce),
+		Ciphertext: base64.StdEncoding.EncodeToString(gcm.Seal(nil, nonce, plaintext, nil)),
+	}
+
+	return json.Marshal(envelope)
+}
+
+// Decrypt opens data produced by Encrypt. It reports whether the data was
+// sealed with a previous key and should be re-encrypted.
+func (k *Keyring) Decrypt(data []byte) ([]byte, bool, error) {
+	var envelope encryptedEnvelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, false, fmt.Errorf("failed to parse encrypted data: %w", err)
+	}
+
+	nonce, err := base64.StdEncoding.DecodeString(envelope.Nonce)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid nonce: %w", err)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(envelope.Ciphertext)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid ciphertext: %w", err)
+	}
+
+	for i, key := range append([][]byte{k.primary}, k.previous...) {
+		if keyID(key) != envelope.KeyID {
+			continue
+		}
+
+		gcm, err := newGCM(key)
+		if err != nil {
+			return nil, false, err
+		}
+
+		plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to decrypt data: %w", err)
+		}
+
+		return plaintext, i > 0, nil
+	}
+
+	return nil, false, fmt.Errorf("no key available for key id %s", envelope.KeyID)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+
+	return gcm, nil
+}
+
+// FileTokenStore keeps all sessions in a single AES-GCM encrypted file
+type FileTokenStore struct {
+	path    string
+	keyring *Keyring
+	records map[string]*SessionRecord
+	mutex   sync.Mutex
+}
+
+// NewFileTokenStore opens the store at path, decrypting any existing data.
+// Data encrypted with a previous key is re-encrypted with the primary key.
+func NewFileTokenStore(path string, keyring *Keyring) (*FileTokenStore, error) {
+	store := &FileTokenStore{
+		path:    path,
+		keyring: keyring,
+		records: make(map[string]*SessionRecord),
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token store %s: %w", path, err)
+	}
+
+	plaintext, rotated, err := keyring.Decrypt(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt token store %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(plaintext, &store.records); err != nil {
+		return nil, fmt.Errorf("failed to parse token store %s: %w", path, err)
+	}
+
+	if rotated {
+		logrus.WithField("path", path).Info("Re-encrypting token store with the primary key")
+		if err := store.writeLocked(); err != nil {
+			return nil, err
+		}
+	}
+
+	return store, nil
+}
+
+// Save stores or replaces a session record
+func (fs *FileTokenStore) Save(record *SessionRecord) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	fs.records[record.ID] = record
+	return fs.writeLocked()
+}
+
+// Delete removes a session record
+func (fs *FileTokenStore) Delete(id string) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if _, exists := fs.records[id]; !exists {
+		return nil
+	}
+
+	delete(fs.records, id)
+	return fs.writeLocked()
+}
+
+// LoadAll returns all stored session records
+func (fs *FileTokenStore) LoadAll() ([]*SessionRecord, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	records := make([]*SessionRecord, 0, len(fs.records))
+	for _, record := range fs.records {
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// writeLocked encrypts all records and atomically replaces the store file
+func (fs *FileTokenStore) writeLocked() error {
+	plaintext, err := json.Marshal(fs.records)
+	if err != nil {
+		return fmt.Errorf("failed to marshal token store: %w", err)
+	}
+
+	data, err := fs.keyring.Encrypt(plaintext)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt token store: %w", err)
+	}
+
+	return writeFileAtomic(fs.path, data)
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it
+// into place, so readers never see a partially written file
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+
+	tempFile, err := os.CreateTemp(dir, "."+filepath.Base(path)+"_*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tempFile.Name())
+
+	if _, err := tempFile.Write(data); err != nil {
+		tempFile.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tempFile.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	if err := os.Rename(tempFile.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+
+	return nil
+}
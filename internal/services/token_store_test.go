@@ -0,0 +1,164 @@
+package services
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"json-response-generator/internal/models"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+func TestKeyringRejectsShortKeys(t *testing.T) {
+	if _, err := NewKeyring([]byte("too short")); err == nil {
+		t.Error("Expected error for a key that is not 32 bytes")
+	}
+}
+
+func TestFileTokenStoreRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.enc")
+	keyring, _ := NewKeyring(testKey(1))
+
+	store, err := NewFileTokenStore(path, keyring)
+	if err != nil {
+		t.Fatalf("NewFileTokenStore() error = %v", err)
+	}
+
+	record := &SessionRecord{
+		ID:       "session-1",
+		LastUsed: time.Now(),
+		Config:   &models.OAuth2Config{Username: "operator", Password: "s3cret-password"},
+		Token:    &models.OAuthTokenInfo{AccessToken: "access", ExpiresAt: time.Now().Add(time.Hour)},
+	}
+	if err := store.Save(record); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	raw, _ := os.ReadFile(path)
+	if bytes.Contains(raw, []byte("s3cret-password")) {
+		t.Error("Password should not be stored in plain text")
+	}
+
+	reopened, err := NewFileTokenStore(path, keyring)
+	if err != nil {
+		t.Fatalf("Reopening store failed: %v", err)
+	}
+
+	records, _ := reopened.LoadAll()
+	if len(records) != 1 || records[0].Config.Password != "s3cret-password" {
+		t.Fatalf("Unexpected records after reopening: %+v", records)
+	}
+
+	if err := reopened.Delete("session-1"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	records, _ = reopened.LoadAll()
+	if len(records) != 0 {
+		t.Errorf("Expected no records after delete, got %d", len(records))
+	}
+}
+
+func TestFileTokenStoreKeyRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.enc")
+	oldKeyring, _ := NewKeyring(testKey(1))
+
+	store, _ := NewFileTokenStore(path, oldKeyring)
+	store.Save(&SessionRecord{ID: "session-1"})
+
+	rotatedKeyring, _ := NewKeyring(testKey(2), testKey(1))
+	if _, err := NewFileTokenStore(path, rotatedKeyring); err != nil {
+		t.Fatalf("Opening with rotated keyring failed: %v", err)
+	}
+
+	// After rotation the old key alone can no longer read the store
+	if _, err := NewFileTokenStore(path, oldKeyring); err == nil {
+		t.Error("Expected store to be re-encrypted with the new primary key")
+	}
+
+	newOnly, _ := NewKeyring(testKey(2))
+	if _, err := NewFileTokenStore(path, newOnly); err != nil {
+		t.Errorf("New key should read the rotated store: %v", err)
+	}
+}
+
+func TestOAuthServiceRestoresAndWipesSessions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.enc")
+	keyring, _ := NewKeyring(testKey(1))
+	store, _ := NewFileTokenStore(path, keyring)
+
+	service := NewOAuthService()
+	service.SetTokenStore(store)
+
+	session, _ := service.NewSession()
+	session.SetConfig(&models.OAuth2Config{Username: "operator"})
+
+	// Simulate a restart
+	restarted := NewOAuthService()
+	reopened, _ := NewFileTokenStore(path, keyring)
+	restarted.SetTokenStore(reopened)
+
+	restored, err := restarted.RestoreSessions()
+	if err != nil || restored != 1 {
+		t.Fatalf("RestoreSessions() = %d, %v", restored, err)
+	}
+
+	restoredSession, ok := restarted.GetSession(session.ID)
+	if !ok || restoredSession.GetConfig().Username != "operator" {
+		t.Fatal("Expected session to be restored with its configuration")
+	}
+
+	restarted.DeleteSession(session.ID)
+	records, _ := reopened.LoadAll()
+	if len(records) != 0 {
+		t.Errorf("Expected logout to wipe the stored session, got %d records", len(records))
+	}
+}
+
+func TestActiveSessionsSurviveRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.enc")
+	keyring, _ := NewKeyring(testKey(1))
+	store, _ := NewFileTokenStore(path, keyring)
+
+	service := NewOAuthService()
+	service.sessionTTL = time.Hour
+	service.SetTokenStore(store)
+
+	session, _ := service.NewSession()
+	session.SetConfig(&models.OAuth2Config{Username: "operator"})
+
+	// The stored copy dates from before the TTL, but the session was used since
+	session.mutex.Lock()
+	session.lastUsed = time.Now().Add(-90 * time.Minute)
+	session.mutex.Unlock()
+	session.persist()
+	session.mutex.Lock()
+	session.lastUsed = time.Now().Add(-30 * time.Minute)
+	session.mutex.Unlock()
+
+	if _, ok := service.GetSession(session.ID); !ok {
+		t.Fatal("Expected the session to be active")
+	}
+
+	restarted := NewOAuthService()
+	restarted.sessionTTL = time.Hour
+	reopened, _ := NewFileTokenStore(path, keyring)
+	restarted.SetTokenStore(reopened)
+
+	if restored, err := restarted.RestoreSessions(); err != nil || restored != 1 {
+		t.Fatalf("RestoreSessions() = %d, %v, want the active session restored", restored, err)
+	}
+
+	// Uses within the persist interval are not written again
+	stale := time.Now().Add(-10 * time.Second)
+	store.Save(&SessionRecord{ID: session.ID, LastUsed: stale, Config: session.GetConfig()})
+	service.GetSession(session.ID)
+	records, _ := store.LoadAll()
+	if len(records) != 1 || !records[0].LastUsed.Equal(stale) {
+		t.Errorf("Expected the last use to be persisted at most once per %s", lastUsedPersistInterval)
+	}
+}
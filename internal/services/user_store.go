@@ -0,0 +1,166 @@
+package services
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"json-response-generator/internal/models"
+)
+
+// UserAccount is a local user of this service
+type UserAccount struct {
+	Username     string `json:"username"`
+	PasswordHash string `json:"password_hash"` // bcrypt
+	Role         string `json:"role"`
+	Disabled     bool   `json:"disabled,omitempty"`
+}
+
+// APIKey is a static key for machine-to-machine access
+type APIKey struct {
+	Name    string `json:"name"`
+	KeyHash string `json:"key_sha256"` // hex encoded SHA-256 of the key
+	Role    string `json:"role"`
+}
+
+// usersFile is the on-disk format of the user store
+type usersFile struct {
+	Users   []UserAccount `json:"users"`
+	APIKeys []APIKey      `json:"api_keys"`
+}
+
+var (
+	dummyHash     []byte
+	dummyHashOnce sync.Once
+)
+
+func dummyPasswordHash() []byte {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+	})
+	return dummyHash
+}
+
+// UserStore authenticates users and API keys loaded from a local file
+type UserStore struct {
+	users   map[string]UserAccount
+	apiKeys []APIKey
+}
+
+// LoadUserStore reads users and API keys from a JSON file
+func LoadUserStore(path string) (*UserStore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read users file %s: %w", path, err)
+	}
+
+	var file usersFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse users file %s: %w", path, err)
+	}
+
+	return NewUserStore(file.Users, file.APIKeys)
+}
+
+// NewUserStore creates a user store from users and API keys
+func NewUserStore(users []UserAccount, apiKeys []APIKey) (*UserStore, error) {
+	store := &UserStore{
+		users: make(map[string]UserAccount),
+	}
+
+	for _, user := range users {
+		if user.Username == "" {
+			return nil, fmt.Errorf("user without username")
+		}
+		if !models.IsValidRole(user.Role) {
+			return nil, fmt.Errorf("user %s has unknown role %q", user.Username, user.Role)
+		}
+		if _, err := bcrypt.Cost([]byte(user.PasswordHash)); err != nil {
+			return nil, fmt.Errorf("user %s has an invalid bcrypt password hash", user.Username)
+		}
+		if _, exists := store.users[user.Username]; exists {
+			return nil, fmt.Errorf("duplicate user %s", user.Username)
+		}
+		store.users[user.Username] = user
+	}
+
+	for _, key := range apiKeys {
+		if !models.IsValidRole(key.Role) {
+			return nil, fmt.Errorf("API key %s has unknown role %q", key.Name, key.Role)
+		}
+		if len(key.KeyHash) != sha256.Size*2 {
+			return nil, fmt.Errorf("API key %s must have a hex encoded SHA-256 key_sha256", key.Name)
+		}
+		store.apiKeys = append(store.apiKeys, key)
+	}
+
+	return store, nil
+}
+
+// Authenticate verifies a username and password
+func (us *UserStore) Authenticate(username, password string) (*models.Principal, error) {
+	user, exists := us.users[username]
+	if !exists || user.Disabled {
+		// Compare against a dummy hash so unknown users take as long as known ones
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+		return nil, fmt.Errorf("invalid username or password")
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, fmt.Errorf("invalid username or password")
+	}
+
+	return &models.Principal{
+		Username: user.Username,
+		Role:     user.Role,
+		Method:   "password",
+	}, nil
+}
+
+// Lookup returns the current principal of an enabled user, for sessions
+// issued to them earlier
+func (us *UserStore) Lookup(username string) (*models.Principal, bool) {
+	user, exists := us.users[username]
+	if !exists || user.Disabled {
+		return nil, false
+	}
+
+	return &models.Principal{
+		Username: user.Username,
+		Role:     user.Role,
+		Method:   "password",
+	}, true
+}
+
+// AuthenticateAPIKey resolves a static API key
+func (us *UserStore) AuthenticateAPIKey(key string) (*models.Principal, bool) {
+	sum := sha256.Sum256([]byte(key))
+	hash := hex.EncodeToString(sum[:])
+
+	for _, apiKey := range us.apiKeys {
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(apiKey.KeyHash)) == 1 {
+			return &models.Principal{
+				Username: "apikey:" + apiKey.Name,
+				Role:     apiKey.Role,
+				Method:   "api_key",
+			}, true
+		}
+	}
+
+	return nil, false
+}
+
+// HashPassword returns a bcrypt hash suitable for the users file
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"json-response-generator/internal/models"
+)
+
+func TestUserStoreAuthenticate(t *testing.T) {
+	hash, err := HashPassword("s3cret")
+	if err != nil {
+		t.Fatalf("HashPassword failed: %v", err)
+	}
+
+	store, err := NewUserStore([]UserAccount{
+		{Username: "alice", PasswordHash: hash, Role: models.RolePreparer},
+		{Username: "bob", PasswordHash: hash, Role: models.RoleApprover, Disabled: true},
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewUserStore failed: %v", err)
+	}
+
+	principal, err := store.Authenticate("alice", "s3cret")
+	if err != nil {
+		t.Fatalf("Expected valid login, got %v", err)
+	}
+	if principal.Username != "alice" || principal.Role != models.RolePreparer || principal.Method != "password" {
+		t.Errorf("Unexpected principal %+v", principal)
+	}
+
+	if _, err := store.Authenticate("alice", "wrong"); err == nil {
+		t.Error("Expected wrong password to be rejected")
+	}
+	if _, err := store.Authenticate("bob", "s3cret"); err == nil {
+		t.Error("Expected disabled user to be rejected")
+	}
+	if _, err := store.Authenticate("mallory", "s3cret"); err == nil {
+		t.Error("Expected unknown user to be rejected")
+	}
+
+	if principal, ok := store.Lookup("alice"); !ok || principal.Role != models.RolePreparer {
+		t.Errorf("Expected alice to be looked up, got %+v", principal)
+	}
+	if _, ok := store.Lookup("bob"); ok {
+		t.Error("Expected disabled user not to be looked up")
+	}
+	if _, ok := store.Lookup("mallory"); ok {
+		t.Error("Expected unknown user not to be looked up")
+	}
+}
+
+func TestUserStoreAPIKey(t *testing.T) {
+	sum := sha256.Sum256([]byte("key-123"))
+
+	store, err := NewUserStore(nil, []APIKey{
+		{Name: "erp", KeyHash: hex.EncodeToString(sum[:]), Role: models.RoleViewer},
+	})
+	if err != nil {
+		t.Fatalf("NewUserStore failed: %v", err)
+	}
+
+	principal, ok := store.AuthenticateAPIKey("key-123")
+	if !ok {
+		t.Fatal("Expected API key to be accepted")
+	}
+	if principal.Username != "apikey:erp" || principal.Role != models.RoleViewer {
+		t.Errorf("Unexpected principal %+v", principal)
+	}
+
+	if _, ok := store.AuthenticateAPIKey("key-456"); ok {
+		t.Error("Expected unknown API key to be rejected")
+	}
+}
+
+func TestLoadUserStoreRejectsInvalidEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+
+	cases := map[string]string{
+		"unknown role": `{"users": [{"username": "alice", "password_hash": "$2a$10$abcdefghijklmnopqrstuuQ2n8P3m6VZl7H1bqW9kqv0p6fQ7xJ1a", "role": "root"}]}`,
+		"invalid hash": `{"users": [{"username": "alice", "password_hash": "plaintext", "role": "viewer"}]}`,
+		"short key":    `{"api_keys": [{"name": "erp", "key_sha256": "abc", "role": "viewer"}]}`,
+		"invalid json": `{"users": [`,
+	}
+
+	for name, content := range cases {
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadUserStore(path); err == nil {
+			t.Errorf("%s: expected LoadUserStore to fail", name)
+		}
+	}
+}
+
+func TestPrincipalHasRole(t *testing.T) {
+	approver := &models.Principal{Username: "carol", Role: models.RoleApprover}
+
+	if !approver.HasRole(models.RolePreparer) || !approver.HasRole(models.RoleApprover) {
+		t.Error("Expected approver to hold preparer and approver roles")
+	}
+	if approver.HasRole(models.RoleAdmin) {
+		t.Error("Expected approver not to hold admin role")
+	}
+
+	var anonymous *models.Principal
+	if anonymous.HasRole(models.RoleViewer) {
+		t.Error("Expected nil principal to hold no role")
+	}
+}
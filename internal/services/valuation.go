@@ -0,0 +1,318 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"json-response-generator/internal/models"
+)
+
+// Insurers by kodeAsuransi
+const (
+	KodeAsuransiLuarNegeri  = "LN" // insured abroad
+	KodeAsuransiDalamNegeri = "DN" // insured in Indonesia
+)
+
+// TarifAsuransiEstimasi is the insurance, in percent of the FOB value, added
+// to the customs value when none is declared
+const TarifAsuransiEstimasi = 0.5
+
+// Ways header freight and insurance are spread over the goods
+const (
+	ProrateByValue  = "value"
+	ProrateByWeight = "weight" // gross weight (bruto)
+)
+
+// incotermCosts tells for each incoterm whether its price includes the freight
+// and the insurance to the port of import
+var incotermCosts = map[string]struct{ freight, insurance bool }{
+	"EXW": {},
+	"FCA": {},
+	"FAS": {},
+	"FOB": {},
+	"CFR": {freight: true},
+	"CNF": {freight: true},
+	"CPT": {freight: true},
+	"CIF": {freight: true, insurance: true},
+	"CIP": {freight: true, insurance: true},
+	"DAP": {freight: true, insurance: true},
+	"DAT": {freight: true, insurance: true},
+	"DPU": {freight: true, insurance: true},
+	"DDP": {freight: true, insurance: true},
+	"DDU": {freight: true, insurance: true},
+}
+
+// ValuationInput is what the customs value of a declaration is computed from.
+// Amounts are in the invoice currency.
+type ValuationInput struct {
+	KodeIncoterm  string
+	NilaiIncoterm float64 // invoice total, checked against the goods when set
+	Freight       float64
+	Asuransi      float64
+	KodeAsuransi  string
+	// BiayaTambahan are costs borne by the buyer outside the price, e.g.
+	// commissions or transport to the port of loading of an EXW purchase;
+	// BiayaPengurang are costs within the price that are not part of the
+	// customs value
+	BiayaTambahan  float64
+	BiayaPengurang float64
+	Ndpbm          float64 // of goods without their own
+	Basis          string  // ProrateByValue when empty
+	Barang         []ValuationItemInput
+}
+
+// ValuationItemInput is what the customs value of one item is computed from
+type ValuationItemInput struct {
+	SeriBarang int
+	Nilai      float64 // invoice value at the incoterm
+	Diskon     float64
+	Bruto      float64
+	Ndpbm      float64
+}
+
+// Valuate converts the invoice value of the goods to their customs (CIF)
+// value. Discounts are taken off each item, costs added or deducted are
+// spread over the goods by value, and header freight and declared insurance
+// by the basis. The freight and insurance an incoterm's price includes are
+// split off to find the FOB value; insurance an FOB, EXW or CFR purchase
+// declares none of (kodeAsuransi neither LN nor DN) is estimated at
+// TarifAsuransiEstimasi percent of FOB.
+//
+// Item amounts are kept to the sen, amounts spread over the goods add up to
+// the header amount.
+func Valuate(input ValuationInput) (*models.Valuation, error) {
+	incoterm := strings.ToUpper(strings.TrimSpace(input.KodeIncoterm))
+	costs, known := incotermCosts[incoterm]
+	basis := input.Basis
+	if basis == "" {
+		basis = ProrateByValue
+	}
+
+	var problems []models.FieldError
+	if !known {
+		problems = append(problems, models.FieldError{Field: "kodeIncoterm", Code: models.FieldInvalidValue,
+			Message: fmt.Sprintf("unknown incoterm %q", input.KodeIncoterm)})
+	}
+	if basis != ProrateByValue && basis != ProrateByWeight {
+		problems = append(problems, models.FieldError{Field: "basis", Code: models.FieldInvalidValue,
+			Message: fmt.Sprintf("basis must be %s or %s", ProrateByValue, ProrateByWeight)})
+	}
+	if len(input.Barang) == 0 {
+		problems = append(problems, models.FieldError{Field: "barang", Code: models.FieldRequired})
+	}
+
+	valuation := &models.Valuation{
+		KodeIncoterm:   incoterm,
+		BiayaTambahan:  input.BiayaTambahan,
+		BiayaPengurang: input.BiayaPengurang,
+		Freight:        input.Freight,
+		Basis:          basis,
+		Barang:         make([]models.ValuationItem, 0, len(input.Barang)),
+	}
+
+	values := make([]float64, len(input.Barang))
+	weights := make([]float64, len(input.Barang))
+	for i, item := range input.Barang {
+		values[i] = roundSen(item.Nilai - item.Diskon)
+		weights[i] = item.Bruto
+		if values[i] <= 0 {
+			problems = append(problems, models.FieldError{Field: fmt.Sprintf("barang[%d].nilaiBarang", i), Code: models.FieldRequired})
+		}
+		if basis == ProrateByWeight && item.Bruto <= 0 {
+			problems = append(problems, models.FieldError{Field: fmt.Sprintf("barang[%d].bruto", i), Code: models.FieldRequired})
+		}
+		valuation.NilaiIncoterm = roundSen(valuation.NilaiIncoterm + item.Nilai)
+		valuation.Diskon = roundSen(valuation.Diskon + item.Diskon)
+	}
+	if input.NilaiIncoterm != 0 && math.Abs(input.NilaiIncoterm-valuation.NilaiIncoterm) > amountTolerance {
+		problems = append(problems, models.FieldError{Field: "nilaiIncoterm", Code: models.FieldMismatch,
+			Message: fmt.Sprintf("nilaiIncoterm %.2f differs from the %.2f of the goods", input.NilaiIncoterm, valuation.NilaiIncoterm)})
+	}
+	if len(problems) > 0 {
+		return nil, models.NewError(models.ErrCodeValidationFailed, "the customs value cannot be computed", nil).WithFields(problems...)
+	}
+
+	if basis == ProrateByValue {
+		weights = values
+	}
+	adjustments := prorate(input.BiayaTambahan-input.BiayaPengurang, values)
+	freight := prorate(input.Freight, weights)
+
+	estimated := !costs.insurance && !insuranceDeclared(input.KodeAsuransi)
+	asuransi := prorate(input.Asuransi, weights)
+	valuation.AsuransiEstimated = estimated
+
+	for i, item := range input.Barang {
+		result := models.ValuationItem{
+			SeriBarang: item.SeriBarang,
+			Nilai:      values[i],
+			Freight:    freight[i],
+			Asuransi:   asuransi[i],
+		}
+
+		fob := values[i] + adjustments[i]
+		if costs.freight {
+			fob -= freight[i]
+		}
+		if costs.insurance {
+			fob -= asuransi[i]
+		}
+		result.Fob = roundSen(fob)
+		if result.Fob < 0 {
+			problems = append(problems, models.FieldError{Field: fmt.Sprintf("barang[%d].fob", i), Code: models.FieldInvalidValue,
+				Message: "freight and insurance exceed the value of the item"})
+		}
+		if estimated {
+			result.Asuransi = roundSen(result.Fob * TarifAsuransiEstimasi / 100)
+		}
+		result.Cif = roundSen(result.Fob + result.Freight + result.Asuransi)
+
+		ndpbm := item.Ndpbm
+		if ndpbm <= 0 {
+			ndpbm = input.Ndpbm
+		}
+		result.CifRupiah = roundSen(result.Cif * ndpbm)
+
+		valuation.Fob = roundSen(valuation.Fob + result.Fob)
+		valuation.Asuransi = roundSen(valuation.Asuransi + result.Asuransi)
+		valuation.Cif = roundSen(valuation.Cif + result.Cif)
+		valuation.CifRupiah = roundSen(valuation.CifRupiah + result.CifRupiah)
+		valuation.Barang = append(valuation.Barang, result)
+	}
+	if len(problems) > 0 {
+		return nil, models.NewError(models.ErrCodeValidationFailed, "the customs value cannot be computed", nil).WithFields(problems...)
+	}
+	return valuation, nil
+}
+
+// ApplyValuation computes the customs value of the goods of a declaration and
+// fills the fob, freight, asuransi, cif and cifRupiah of the header and each
+// item. The invoice value of an item is its nilaiBarang, or hargaSatuan times
+// jumlahSatuan.
+func ApplyValuation(declaration models.Declaration, basis string) (*models.Valuation, error) {
+	valued, err := valuedDeclarationOf(declaration)
+	if err != nil {
+		return nil, err
+	}
+
+	input := ValuationInput{
+		KodeIncoterm:   *valued.kodeIncoterm,
+		Freight:        *valued.freight,
+		Asuransi:       *valued.asuransi,
+		KodeAsuransi:   *valued.kodeAsuransi,
+		BiayaTambahan:  *valued.biayaTambahan,
+		BiayaPengurang: *valued.biayaPengurang,
+		Ndpbm:          *valued.ndpbm,
+		Basis:          basis,
+	}
+	if valued.nilaiIncoterm != nil {
+		input.NilaiIncoterm = *valued.nilaiIncoterm
+	}
+	barang := *valued.barang
+	for _, b := range barang {
+		nilai := b.NilaiBarang
+		if nilai == 0 {
+			nilai = roundSen(b.HargaSatuan * b.JumlahSatuan)
+		}
+		input.Barang = append(input.Barang, ValuationItemInput{
+			SeriBarang: b.SeriBarang,
+			Nilai:      nilai,
+			Diskon:     b.Diskon,
+			Bruto:      b.Bruto,
+			Ndpbm:      b.Ndpbm,
+		})
+	}
+
+	valuation, err := Valuate(input)
+	if err != nil {
+		return nil, err
+	}
+
+	*valued.fob = valuation.Fob
+	*valued.asuransi = valuation.Asuransi
+	*valued.cif = valuation.Cif
+	if valued.nilaiIncoterm != nil {
+		*valued.nilaiIncoterm = valuation.NilaiIncoterm
+	}
+	for i, item := range valuation.Barang {
+		barang[i].Fob = item.Fob
+		barang[i].Freight = item.Freight
+		barang[i].Asuransi = item.Asuransi
+		barang[i].Cif = item.Cif
+		if item.CifRupiah > 0 {
+			barang[i].CifRupiah = item.CifRupiah
+		}
+	}
+	return valuation, nil
+}
+
+// valuedDeclaration points at the fields of a declaration its customs value
+// is computed from and written to
+type valuedDeclaration struct {
+	kodeIncoterm, kodeAsuransi           *string
+	nilaiIncoterm                        *float64 // nil when not declared
+	fob, freight, asuransi, cif          *float64
+	biayaTambahan, biayaPengurang, ndpbm *float64
+	barang                               *[]models.Barang
+}
+
+// valuedDeclarationOf returns the valuation fields of an import declaration
+func valuedDeclarationOf(declaration models.Declaration) (*valuedDeclaration, error) {
+	switch d := declaration.(type) {
+	case *models.ResponseData:
+		return &valuedDeclaration{
+			kodeIncoterm: &d.KodeIncoterm, kodeAsuransi: &d.KodeAsuransi, nilaiIncoterm: &d.NilaiIncoterm,
+			fob: &d.Fob, freight: &d.Freight, asuransi: &d.Asuransi, cif: &d.Cif,
+			biayaTambahan: &d.BiayaTambahan, biayaPengurang: &d.BiayaPengurang, ndpbm: &d.Ndpbm,
+			barang: &d.Barang,
+		}, nil
+	case *models.BondedImportDeclaration:
+		return &valuedDeclaration{
+			kodeIncoterm: &d.KodeIncoterm, kodeAsuransi: &d.KodeAsuransi,
+			fob: &d.Fob, freight: &d.Freight, asuransi: &d.Asuransi, cif: &d.Cif,
+			biayaTambahan: &d.BiayaTambahan, biayaPengurang: &d.BiayaPengurang, ndpbm: &d.Ndpbm,
+			barang: &d.Barang,
+		}, nil
+	}
+
+	dt, err := DocumentTypeOf(declaration)
+	if err != nil {
+		return nil, err
+	}
+	return nil, models.Errorf(models.ErrCodeValidationFailed, "%s declarations are not valued by incoterm", dt.Name)
+}
+
+// insuranceDeclared reports whether a kodeAsuransi names who insured the goods
+func insuranceDeclared(kodeAsuransi string) bool {
+	code := strings.ToUpper(strings.TrimSpace(kodeAsuransi))
+	return code == KodeAsuransiLuarNegeri || code == KodeAsuransiDalamNegeri
+}
+
+// prorate spreads an amount over shares in proportion to their weights,
+// rounded to the sen. The rounding difference goes to the last share with a
+// weight, so the shares add up to the amount.
+func prorate(amount float64, weights []float64) []float64 {
+	shares := make([]float64, len(weights))
+	total, last := 0.0, -1
+	for i, weight := range weights {
+		if weight > 0 {
+			total += weight
+			last = i
+		}
+	}
+	if amount == 0 || last < 0 {
+		return shares
+	}
+
+	allocated := 0.0
+	for i, weight := range weights {
+		if weight <= 0 {
+			continue
+		}
+		shares[i] = roundSen(amount * weight / total)
+		allocated += shares[i]
+	}
+	shares[last] = roundSen(shares[last] + amount - allocated)
+	return shares
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"json-response-generator/internal/models"
+)
+
+func TestValuate(t *testing.T) {
+	items := []ValuationItemInput{
+		{SeriBarang: 1, Nilai: 1000, Diskon: 100, Bruto: 10, Ndpbm: 15000},
+		{SeriBarang: 2, Nilai: 300, Bruto: 30},
+	}
+
+	// FOB without declared insurance: 0.5% of FOB is added
+	valuation, err := Valuate(ValuationInput{
+		KodeIncoterm:   "fob",
+		Freight:        100,
+		BiayaTambahan:  60,
+		BiayaPengurang: 20,
+		Ndpbm:          16000,
+		Barang:         items,
+	})
+	if err != nil {
+		t.Fatalf("Failed to value: %v", err)
+	}
+	// 900 and 300 after discount, the 40 net costs spread 30 and 10
+	first, second := valuation.Barang[0], valuation.Barang[1]
+	if first.Fob != 930 || second.Fob != 310 {
+		t.Errorf("Expected FOB values of 930 and 310, got %.2f and %.2f", first.Fob, second.Fob)
+	}
+	if first.Freight != 75 || second.Freight != 25 {
+		t.Errorf("Expected freight spread by value as 75 and 25, got %.2f and %.2f", first.Freight, second.Freight)
+	}
+	if !valuation.AsuransiEstimated || first.Asuransi != 4.65 || second.Asuransi != 1.55 {
+		t.Errorf("Expected estimated insurance of 4.65 and 1.55, got %+v", valuation)
+	}
+	if first.Cif != 1009.65 || first.CifRupiah != 15144750 || second.CifRupiah != 5384800 {
+		t.Errorf("Expected the CIF of the first item at its own NDPBM and the second at the header's, got %+v", valuation.Barang)
+	}
+	if valuation.Cif != 1346.2 || valuation.NilaiIncoterm != 1300 || valuation.Diskon != 100 {
+		t.Errorf("Expected header totals of the goods, got %+v", valuation)
+	}
+
+	// CFR with declared insurance, spread by weight
+	valuation, err = Valuate(ValuationInput{
+		KodeIncoterm:  "CFR",
+		NilaiIncoterm: 1300,
+		Freight:       100,
+		Asuransi:      10,
+		KodeAsuransi:  KodeAsuransiLuarNegeri,
+		Basis:         ProrateByWeight,
+		Barang:        items,
+	})
+	if err != nil {
+		t.Fatalf("Failed to value: %v", err)
+	}
+	first, second = valuation.Barang[0], valuation.Barang[1]
+	if first.Freight != 25 || second.Freight != 75 || first.Asuransi != 2.5 || second.Asuransi != 7.5 {
+		t.Errorf("Expected freight and insurance spread by weight, got %+v", valuation.Barang)
+	}
+	if first.Fob != 875 || first.Cif != 902.5 || second.Fob != 225 || second.Cif != 307.5 {
+		t.Errorf("Expected freight split off the CFR price, got %+v", valuation.Barang)
+	}
+
+	// CIF prices include both
+	valuation, err = Valuate(ValuationInput{KodeIncoterm: "CIF", Freight: 100, Asuransi: 10, Barang: items})
+	if err != nil {
+		t.Fatalf("Failed to value: %v", err)
+	}
+	if valuation.Cif != 1200 || valuation.Fob != 1090 || valuation.AsuransiEstimated {
+		t.Errorf("Expected a CIF of 1200 and FOB of 1090, got %+v", valuation)
+	}
+
+	// Spread amounts add up despite rounding
+	if shares := prorate(100, []float64{1, 1, 1}); shares[0]+shares[1]+shares[2] != 100 || shares[2] != 33.34 {
+		t.Errorf("Expected shares adding up to 100, got %v", shares)
+	}
+}
+
+func TestValuateRejectsInconsistentInput(t *testing.T) {
+	_, err := Valuate(ValuationInput{
+		KodeIncoterm:  "XYZ",
+		NilaiIncoterm: 999,
+		Basis:         ProrateByWeight,
+		Barang:        []ValuationItemInput{{Nilai: 1000}},
+	})
+
+	var appErr *models.AppError
+	if !errors.As(err, &appErr) {
+		t.Fatalf("Expected a validation error, got %v", err)
+	}
+	fields := make(map[string]string)
+	for _, field := range appErr.Fields {
+		fields[field.Field] = field.Code
+	}
+	expected := map[string]string{
+		"kodeIncoterm":    models.FieldInvalidValue,
+		"nilaiIncoterm":   models.FieldMismatch,
+		"barang[0].bruto": models.FieldRequired,
+	}
+	for field, code := range expected {
+		if fields[field] != code {
+			t.Errorf("Expected %s to be %s, got %+v", field, code, appErr.Fields)
+		}
+	}
+}
+
+func TestApplyValuation(t *testing.T) {
+	sample := NewJsonGenerator().GenerateSampleData()
+	sample.KodeIncoterm = "FOB"
+	sample.KodeAsuransi = ""
+	sample.Freight = 50
+
+	valuation, err := ApplyValuation(sample, "")
+	if err != nil {
+		t.Fatalf("Failed to value the sample: %v", err)
+	}
+	if sample.Cif != valuation.Cif || sample.Asuransi != valuation.Asuransi || sample.Fob != valuation.Fob {
+		t.Errorf("Expected the header to carry the valuation, got %+v", valuation)
+	}
+	for i, item := range valuation.Barang {
+		if sample.Barang[i].Cif != item.Cif || sample.Barang[i].Freight != item.Freight {
+			t.Errorf("Expected barang[%d] to carry its valuation %+v, got %+v", i, item, sample.Barang[i])
+		}
+	}
+
+	local := NewJsonGenerator().GenerateLocalEntrySampleData()
+	if _, err := ApplyValuation(local, ""); err == nil {
+		t.Error("Expected BC 4.0 declarations not to be valued by incoterm")
+	}
+}
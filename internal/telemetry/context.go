@@ -0,0 +1,82 @@
+package telemetry
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RequestIDHeaderName is the header carrying the request ID, inbound and outbound
+const RequestIDHeaderName = "X-Request-ID"
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	operationKey
+)
+
+// Operations labelling outbound CEISA calls. Metrics are labelled by
+// operation rather than URL so their cardinality stays bounded.
+const (
+	OperationLogin          = "login"
+	OperationRefresh        = "refresh"
+	OperationSubmit         = "submit"
+	OperationPing           = "ping"
+	OperationConnectionTest = "connection_test"
+	OperationOther          = "other"
+)
+
+// WithRequestID returns a context carrying the request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID returns the request ID carried by ctx
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// WithOperation returns a context labelling outbound calls with operation
+func WithOperation(ctx context.Context, operation string) context.Context {
+	return context.WithValue(ctx, operationKey, operation)
+}
+
+// Operation returns the operation carried by ctx, or OperationOther
+func Operation(ctx context.Context) string {
+	if ctx != nil {
+		if operation, _ := ctx.Value(operationKey).(string); operation != "" {
+			return operation
+		}
+	}
+	return OperationOther
+}
+
+// ContextHook adds the request and trace IDs of the entry's context to log
+// lines written with logrus.WithContext
+type ContextHook struct{}
+
+// Levels returns the levels the hook fires for
+func (ContextHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire adds the IDs to the entry
+func (ContextHook) Fire(entry *logrus.Entry) error {
+	if entry.Context == nil {
+		return nil
+	}
+	if id := RequestID(entry.Context); id != "" {
+		if _, exists := entry.Data["request_id"]; !exists {
+			entry.Data["request_id"] = id
+		}
+	}
+	if traceID := TraceID(entry.Context); traceID != "" {
+		entry.Data["trace_id"] = traceID
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package telemetry
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Histogram buckets for latencies in seconds and for row counts
+var (
+	LatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+	RowBuckets     = []float64{1, 5, 10, 50, 100, 500, 1000, 5000}
+)
+
+// Metrics exposed at /metrics
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by route and status.",
+	}, []string{"method", "route", "status"})
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by route.",
+		Buckets: LatencyBuckets,
+	}, []string{"method", "route"})
+
+	ExcelParseDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "excel_parse_duration_seconds",
+		Help:    "Time to parse an uploaded Excel file.",
+		Buckets: LatencyBuckets,
+	}, []string{"outcome"})
+	ExcelSheetRows = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "excel_sheet_rows",
+		Help:    "Data rows per parsed Excel sheet.",
+		Buckets: RowBuckets,
+	}, []string{"sheet"})
+
+	CEISARequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ceisa_requests_total",
+		Help: "Outbound CEISA requests by operation and status.",
+	}, []string{"operation", "status"})
+	CEISARequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ceisa_request_duration_seconds",
+		Help:    "Outbound CEISA request latency by operation.",
+		Buckets: LatencyBuckets,
+	}, []string{"operation", "status"})
+
+	OAuthLogins = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "oauth_logins_total",
+		Help: "CEISA OAuth logins by outcome.",
+	}, []string{"outcome"})
+	OAuthRefreshes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "oauth_refreshes_total",
+		Help: "CEISA OAuth token refreshes by outcome.",
+	}, []string{"outcome"})
+)
+
+// Outcome label values
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// Outcome returns the outcome label for err
+func Outcome(err error) string {
+	if err != nil {
+		return OutcomeFailure
+	}
+	return OutcomeSuccess
+}
+
+// MetricsHandler serves the metrics of the default registry, including the Go
+// runtime and process metrics, for Prometheus to scrape
+func MetricsHandler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,159 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMetricsPassLint(t *testing.T) {
+	HTTPRequests.WithLabelValues("GET", "/health", "200").Inc()
+	HTTPRequestDuration.WithLabelValues("GET", "/health").Observe(0.01)
+	ExcelParseDuration.WithLabelValues(OutcomeSuccess).Observe(0.2)
+	ExcelSheetRows.WithLabelValues("HEADER").Observe(1)
+	CEISARequests.WithLabelValues(OperationLogin, "200").Inc()
+	CEISARequestDuration.WithLabelValues(OperationLogin, "200").Observe(0.3)
+	OAuthLogins.WithLabelValues(OutcomeSuccess).Inc()
+	OAuthRefreshes.WithLabelValues(OutcomeFailure).Inc()
+
+	names := []string{
+		"http_requests_total", "http_request_duration_seconds",
+		"excel_parse_duration_seconds", "excel_sheet_rows",
+		"ceisa_requests_total", "ceisa_request_duration_seconds",
+		"oauth_logins_total", "oauth_refreshes_total",
+	}
+
+	// The same checks as promtool check metrics
+	problems, err := testutil.GatherAndLint(prometheus.DefaultGatherer, names...)
+	if err != nil {
+		t.Fatalf("GatherAndLint failed: %v", err)
+	}
+	for _, problem := range problems {
+		t.Errorf("%s: %s", problem.Metric, problem.Text)
+	}
+
+	recorder := httptest.NewRecorder()
+	MetricsHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(recorder.Body)
+	if err != nil {
+		t.Fatalf("/metrics is not in the text exposition format: %v", err)
+	}
+	for _, name := range names {
+		if _, exists := families[name]; !exists {
+			t.Errorf("/metrics is missing %s", name)
+		}
+	}
+}
+
+func TestStartServerSpanContinuesTrace(t *testing.T) {
+	header := http.Header{}
+	header.Set(TraceparentHeaderName, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, span := StartServerSpan(context.Background(), "test", header)
+	span.End()
+	if got := TraceID(ctx); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("TraceID = %q, want the inbound trace ID", got)
+	}
+
+	for _, traceparent := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-zzzzzzzzzzzzzzzz-01",
+	} {
+		header := http.Header{}
+		header.Set(TraceparentHeaderName, traceparent)
+		ctx, span := StartServerSpan(context.Background(), "test", header)
+		span.End()
+		if got := TraceID(ctx); got == "" || got == "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("traceparent %q: TraceID = %q, want a new trace", traceparent, got)
+		}
+	}
+}
+
+func TestTransportPropagatesIDsAndRecordsMetrics(t *testing.T) {
+	var requestID, traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID = r.Header.Get(RequestIDHeaderName)
+		traceparent = r.Header.Get(TraceparentHeaderName)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	ctx := WithRequestID(context.Background(), "req-123")
+	header := http.Header{}
+	header.Set(TraceparentHeaderName, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, span := StartServerSpan(ctx, "test", header)
+	defer span.End()
+
+	req, _ := http.NewRequestWithContext(WithOperation(ctx, OperationSubmit), http.MethodPost, server.URL+"/v1/declarations", nil)
+	resp, err := NewHTTPClient(5 * time.Second).Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if requestID != "req-123" {
+		t.Errorf("X-Request-ID = %q, want req-123", requestID)
+	}
+	if !strings.HasPrefix(traceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-") {
+		t.Errorf("traceparent = %q, want the inbound trace ID", traceparent)
+	}
+	if req.Header.Get(RequestIDHeaderName) != "" {
+		t.Error("transport must not modify the caller's request")
+	}
+
+	if got := testutil.ToFloat64(CEISARequests.WithLabelValues(OperationSubmit, "202")); got != 1 {
+		t.Errorf("ceisa_requests_total = %v, want 1", got)
+	}
+	var duration dto.Metric
+	CEISARequestDuration.WithLabelValues(OperationSubmit, "202").(prometheus.Metric).Write(&duration)
+	if got := duration.GetHistogram().GetSampleCount(); got != 1 {
+		t.Errorf("ceisa_request_duration_seconds count = %v, want 1", got)
+	}
+}
+
+func TestExporterSendsSpans(t *testing.T) {
+	received := make(chan string, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		buf.ReadFrom(r.Body)
+		received <- r.URL.Path + " " + buf.String()
+	}))
+	defer collector.Close()
+
+	exporter, err := StartExporter(collector.URL, "test-service")
+	if err != nil {
+		t.Fatalf("StartExporter failed: %v", err)
+	}
+	ctx, span := StartSpan(context.Background(), "operation", trace.SpanKindServer)
+	span.End()
+	traceID := trace.SpanContextFromContext(ctx).TraceID()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := exporter.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+
+	select {
+	case body := <-received:
+		// OTLP/HTTP sends protobuf, which carries the trace ID as raw bytes
+		if !strings.HasPrefix(body, "/v1/traces ") || !strings.Contains(body, string(traceID[:])) || !strings.Contains(body, "test-service") {
+			t.Errorf("unexpected export: %s", body)
+		}
+	default:
+		t.Fatal("no spans were exported")
+	}
+}
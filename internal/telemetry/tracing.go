@@ -0,0 +1,95 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TraceparentHeaderName is the W3C trace context header
+const TraceparentHeaderName = "traceparent"
+
+// instrumentationName names the tracer creating the spans of the service
+const instrumentationName = "json-response-generator"
+
+// propagator reads and writes W3C trace context headers
+var propagator = propagation.TraceContext{}
+
+// Spans are always recorded so trace IDs reach logs and outbound headers;
+// they are only exported while an Exporter runs
+var (
+	providerMutex sync.RWMutex
+	provider      *sdktrace.TracerProvider = sdktrace.NewTracerProvider()
+)
+
+// StartSpan starts a span as a child of the span in ctx, or a new trace
+func StartSpan(ctx context.Context, name string, kind trace.SpanKind) (context.Context, trace.Span) {
+	providerMutex.RLock()
+	tracer := provider.Tracer(instrumentationName)
+	providerMutex.RUnlock()
+
+	return tracer.Start(ctx, name, trace.WithSpanKind(kind))
+}
+
+// StartServerSpan starts the span of an inbound request, continuing the trace
+// of a valid traceparent header
+func StartServerSpan(ctx context.Context, name string, header http.Header) (context.Context, trace.Span) {
+	ctx = propagator.Extract(ctx, propagation.HeaderCarrier(header))
+	return StartSpan(ctx, name, trace.SpanKindServer)
+}
+
+// TraceID returns the trace ID of the span in ctx
+func TraceID(ctx context.Context) string {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.HasTraceID() {
+		return ""
+	}
+	return spanContext.TraceID().String()
+}
+
+// Exporter sends finished spans in batches to an OpenTelemetry collector
+// using OTLP/HTTP
+type Exporter struct {
+	provider *sdktrace.TracerProvider
+}
+
+// StartExporter starts exporting spans to the collector at endpoint, e.g.
+// http://localhost:4318
+func StartExporter(endpoint, serviceName string) (*Exporter, error) {
+	client, err := otlptracehttp.New(context.Background(),
+		otlptracehttp.WithEndpointURL(strings.TrimRight(endpoint, "/")+"/v1/traces"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+
+	exp := &Exporter{provider: sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(client),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName))),
+	)}
+
+	providerMutex.Lock()
+	provider = exp.provider
+	providerMutex.Unlock()
+
+	return exp, nil
+}
+
+// Shutdown stops the exporter after sending the queued spans, or when ctx ends
+func (exp *Exporter) Shutdown(ctx context.Context) error {
+	providerMutex.Lock()
+	if provider == exp.provider {
+		provider = sdktrace.NewTracerProvider()
+	}
+	providerMutex.Unlock()
+
+	return exp.provider.Shutdown(ctx)
+}
@@ -0,0 +1,71 @@
+package telemetry
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Transport instruments outbound CEISA calls: it propagates the request ID and
+// trace context, records latency and status by operation and traces the call
+type Transport struct {
+	Base http.RoundTripper
+}
+
+// NewHTTPClient returns an HTTP client using the instrumented transport
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &Transport{Base: http.DefaultTransport},
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	operation := Operation(req.Context())
+
+	ctx, span := StartSpan(req.Context(), req.Method+" "+operation, trace.SpanKindClient)
+	span.SetAttributes(
+		attribute.String("http.method", req.Method),
+		attribute.String("http.url", req.URL.Scheme+"://"+req.URL.Host+req.URL.Path),
+	)
+
+	// A RoundTripper must not modify the caller's request
+	outbound := req.Clone(ctx)
+	if id := RequestID(ctx); id != "" && outbound.Header.Get(RequestIDHeaderName) == "" {
+		outbound.Header.Set(RequestIDHeaderName, id)
+	}
+	propagator.Inject(ctx, propagation.HeaderCarrier(outbound.Header))
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	start := time.Now()
+	resp, err := base.RoundTrip(outbound)
+	elapsed := time.Since(start).Seconds()
+
+	status := "error"
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else {
+		status = strconv.Itoa(resp.StatusCode)
+		span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+		if resp.StatusCode >= 500 {
+			span.SetStatus(codes.Error, resp.Status)
+		}
+	}
+	span.End()
+
+	CEISARequests.WithLabelValues(operation, status).Inc()
+	CEISARequestDuration.WithLabelValues(operation, status).Observe(elapsed)
+
+	return resp, err
+}
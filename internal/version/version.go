@@ -0,0 +1,10 @@
+// Package version holds build information injected at link time, e.g.
+//
+//	go build -ldflags "-X json-response-generator/internal/version.Version=2.1.0 -X json-response-generator/internal/version.Commit=$(git rev-parse --short HEAD)"
+package version
+
+// Version is the release version of the service
+var Version = "2.0.0"
+
+// Commit is the git commit the service was built from
+var Commit = "unknown"
@@ -1,9 +1,17 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -14,28 +22,119 @@ import (
 	"json-response-generator/internal/handlers"
 	"json-response-generator/internal/middleware"
 	"json-response-generator/internal/services"
+	"json-response-generator/internal/telemetry"
 )
 
 func main() {
+	// Command line utilities
+	if len(os.Args) > 1 {
+		runCommand(os.Args[1:])
+		return
+	}
+
 	// Load environment variables
 	if err := godotenv.Load(); err != nil {
 		logrus.Warn("No .env file found, using environment variables")
 	}
 
 	// Initialize configuration
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	var current atomic.Pointer[config.Config]
+	current.Store(cfg)
 
 	// Setup logging
 	setupLogging(cfg.Debug)
 
+	// Export traces to an OpenTelemetry collector
+	var exporter *telemetry.Exporter
+	if cfg.OTLPEndpoint != "" {
+		var err error
+		exporter, err = telemetry.StartExporter(cfg.OTLPEndpoint, cfg.ServiceName)
+		if err != nil {
+			log.Fatal("Failed to start trace exporter:", err)
+		}
+		logrus.Infof("📈 Exporting traces to %s", cfg.OTLPEndpoint)
+	}
+
 	// Initialize services
 	jsonGenerator := services.NewJsonGenerator()
 	excelHandler := services.NewExcelHandler()
 	oauthService := services.NewOAuthService()
+	oauthService.SetDefaultURLs(cfg.OAuthTokenURL, cfg.OAuthRefreshURL)
+	if cfg.OAuthJWKSFile != "" {
+		verifier, err := services.LoadJWKSVerifier(cfg.OAuthJWKSFile)
+		if err != nil {
+			log.Fatal("Failed to load JWKS file:", err)
+		}
+		oauthService.SetJWKSVerifier(verifier)
+	}
+	if cfg.TokenStorePath != "" {
+		setupTokenStore(cfg, oauthService)
+	}
 	apiClient := services.NewApiClientWithOAuth(oauthService)
 
+	// Renew OAuth tokens in the background
+	tokenRefresher := services.NewTokenRefresher(
+		oauthService,
+		cfg.OAuthRefreshFraction,
+		seconds(cfg.OAuthRefreshInterval),
+	)
+	tokenRefresher.Start()
+
+	// Load users of this service, authentication is disabled without them
+	var userStore *services.UserStore
+	if cfg.AuthUsersFile != "" {
+		userStore, err = services.LoadUserStore(cfg.AuthUsersFile)
+		if err != nil {
+			log.Fatal("Failed to load users:", err)
+		}
+	} else {
+		logrus.Warn("⚠️  AUTH_USERS_FILE not set, API authentication is DISABLED")
+	}
+	if !cfg.RequireApproval {
+		logrus.Warn("⚠️  REQUIRE_APPROVAL is off, declarations can be sent to CEISA without approval")
+	}
+
+	// Declarations under the approval workflow
+	documents, err := services.NewDocumentStore(cfg.DocumentStorePath)
+	if err != nil {
+		log.Fatal("Failed to open document store:", err)
+	}
+
+	// Importers served in multi-tenant mode
+	var tenants *services.TenantStore
+	if cfg.TenantsFile != "" {
+		tenants, err = services.LoadTenantStore(cfg.TenantsFile)
+		if err != nil {
+			log.Fatal("Failed to load tenants:", err)
+		}
+		logrus.Infof("🏢 Multi-tenant mode with %d tenant(s)", tenants.Count())
+	}
+
+	// Exchange rates and customs reference tables
+	kurs, err := services.NewKursTable(cfg.KursStorePath)
+	if err != nil {
+		log.Fatal("Failed to open exchange rates:", err)
+	}
+	references, err := services.LoadReferenceData(cfg.ReferencesDir)
+	if err != nil {
+		log.Fatal("Failed to load reference tables:", err)
+	}
+
 	// Initialize handlers
-	h := handlers.New(jsonGenerator, excelHandler, apiClient, oauthService)
+	h := handlers.New(cfg, jsonGenerator, excelHandler, apiClient, oauthService, userStore, documents, tenants, kurs, references)
+	if cfg.AuditLogPath != "" {
+		auditLog, err := services.OpenAuditLog(cfg.AuditLogPath)
+		if err != nil {
+			log.Fatal("Failed to open audit log:", err)
+		}
+		h.SetAuditLog(auditLog)
+	} else {
+		logrus.Warn("⚠️  AUDIT_LOG_PATH not set, actions are not audited")
+	}
 
 	// Setup Gin router
 	if !cfg.Debug {
@@ -45,76 +144,223 @@ func main() {
 	router := gin.New()
 
 	// Middleware
-	router.Use(gin.Logger())
+	router.Use(middleware.RequestID())
+	router.Use(middleware.Telemetry())
+	router.Use(middleware.AccessLog())
 	router.Use(gin.Recovery())
 	router.Use(middleware.ErrorHandler())
 
 	// CORS configuration
 	corsConfig := cors.DefaultConfig()
-	corsConfig.AllowOrigins = []string{
-		"http://localhost:3000",
-		"http://127.0.0.1:3000",
-		cfg.FrontendURL,
+	corsConfig.AllowOriginFunc = func(origin string) bool {
+		cfg := current.Load()
+		if origin == cfg.FrontendURL {
+			return true
+		}
+		for _, allowed := range cfg.CORSOrigins {
+			if origin == allowed {
+				return true
+			}
+		}
+		return false
 	}
 	corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization"}
+	corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", "Idempotency-Key", "X-Session-Token", "X-API-Key", "X-Tenant-ID", "X-Request-ID", "traceparent"}
+	corsConfig.ExposeHeaders = []string{"X-Request-ID"}
 	corsConfig.AllowCredentials = true
 	router.Use(cors.New(corsConfig))
 
-	// API routes
-	api := router.Group("/api")
-	{
-		// Health check
-		api.GET("/health", h.HealthCheck)
+	// API routes, under /api/v1 and the unversioned /api alias
+	h.RegisterRoutes(router.Group(handlers.APIBasePath))
+	h.RegisterRoutes(router.Group("/api"))
 
-		// Configuration
-		api.GET("/config", h.GetConfig)
+	// Prometheus metrics
+	router.GET("/metrics", gin.WrapH(telemetry.MetricsHandler()))
 
-		// Excel operations
-		api.POST("/upload-excel", h.UploadExcel)
-		api.GET("/download-template", h.DownloadTemplate)
-
-		// JSON generation
-		api.POST("/generate-json", h.GenerateJson)
-
-		// API operations
-		api.POST("/test-connection", h.TestConnection)
-		api.POST("/send-to-api", h.SendToApi)
-
-		// Sample data
-		api.GET("/sample-data", h.GetSampleData)
-
-		// OAuth 2.0 endpoints
-		oauth := api.Group("/oauth")
-		{
-			oauth.POST("/login", h.OAuthLogin)
-			oauth.POST("/refresh", h.OAuthRefresh)
-			oauth.GET("/status", h.OAuthStatus)
-			oauth.Any("/config", h.OAuthConfig)
-			oauth.POST("/logout", h.OAuthLogout)
-		}
-	}
+	// Apply configuration changes on SIGHUP
+	go reloadOnHangup(&current, h, oauthService)
 
 	// Start server
 	addr := fmt.Sprintf("%s:%s", cfg.Host, cfg.Port)
 	logrus.Infof("🚀 Starting JSON Response Generator API Server...")
 	logrus.Infof("📡 API available at: http://%s", addr)
 	logrus.Infof("🔧 Debug mode: %v", cfg.Debug)
-	logrus.Infof("🌐 CORS enabled for: %s", cfg.FrontendURL)
+	logrus.Infof("🌐 CORS enabled for: %s", strings.Join(append([]string{cfg.FrontendURL}, cfg.CORSOrigins...), ", "))
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           router,
+		ReadTimeout:       seconds(cfg.ReadTimeout),
+		ReadHeaderTimeout: seconds(cfg.ReadTimeout),
+		WriteTimeout:      seconds(cfg.WriteTimeout),
+		IdleTimeout:       seconds(cfg.IdleTimeout),
+	}
 
-	if err := router.Run(addr); err != nil {
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case err := <-serverErr:
 		log.Fatal("Failed to start server:", err)
+	case sig := <-quit:
+		logrus.Infof("🛑 Received %s, shutting down", sig)
 	}
+
+	shutdown(cfg, server, h, tokenRefresher, exporter)
+}
+
+// shutdown stops the server gracefully: it reports not ready, waits for load
+// balancers to notice, drains in-flight requests and stops background workers,
+// all within the configured deadline
+func shutdown(cfg *config.Config, server *http.Server, h *handlers.Handlers, tokenRefresher *services.TokenRefresher, exporter *telemetry.Exporter) {
+	h.StartDraining()
+
+	ctx, cancel := context.WithTimeout(context.Background(), seconds(cfg.ShutdownDelay+cfg.ShutdownTimeout))
+	defer cancel()
+
+	select {
+	case <-time.After(seconds(cfg.ShutdownDelay)):
+	case <-ctx.Done():
+	}
+
+	if err := server.Shutdown(ctx); err != nil {
+		logrus.WithError(err).Warn("In-flight requests did not finish in time, closing connections")
+		server.Close()
+	}
+
+	if err := tokenRefresher.Stop(ctx); err != nil {
+		logrus.WithError(err).Warn("OAuth token refresher did not stop in time")
+	}
+	if exporter != nil {
+		if err := exporter.Shutdown(ctx); err != nil {
+			logrus.WithError(err).Warn("Trace exporter did not flush in time")
+		}
+	}
+
+	logrus.Info("👋 Server stopped")
+}
+
+// runCommand runs a command line utility instead of the server
+func runCommand(args []string) {
+	switch args[0] {
+	case "hash-password":
+		// Reads the password from stdin and prints a bcrypt hash for the users file
+		reader := bufio.NewReader(os.Stdin)
+		password, err := reader.ReadString('\n')
+		if err != nil && password == "" {
+			log.Fatal("Failed to read password:", err)
+		}
+
+		hash, err := services.HashPassword(strings.TrimRight(password, "\r\n"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(hash)
+	case "verify-audit":
+		// Verifies the hash chain of the audit log given as argument or configured
+		path := ""
+		if len(args) > 1 {
+			path = args[1]
+		} else {
+			godotenv.Load()
+			cfg, err := config.Load()
+			if err != nil {
+				log.Fatal(err)
+			}
+			path = cfg.AuditLogPath
+		}
+		if path == "" {
+			log.Fatal("No audit log given and AUDIT_LOG_PATH not set")
+		}
+
+		count, lastHash, err := services.VerifyAuditLog(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Audit log %s is INVALID: %v\n", path, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Audit log %s is intact: %d entries, head hash %s\n", path, count, lastHash)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q. Available commands: hash-password, verify-audit\n", args[0])
+		os.Exit(2)
+	}
+}
+
+func setupTokenStore(cfg *config.Config, oauthService *services.OAuthService) {
+	keyring, err := services.LoadKeyring(cfg.TokenStoreKeys, cfg.TokenStoreKeyFile)
+	if err != nil {
+		log.Fatal("Failed to load token store keys:", err)
+	}
+
+	store, err := services.NewFileTokenStore(cfg.TokenStorePath, keyring)
+	if err != nil {
+		log.Fatal("Failed to open token store:", err)
+	}
+
+	oauthService.SetTokenStore(store)
+
+	restored, err := oauthService.RestoreSessions()
+	if err != nil {
+		log.Fatal("Failed to restore OAuth sessions:", err)
+	}
+
+	logrus.Infof("🔐 Restored %d OAuth session(s) from %s", restored, cfg.TokenStorePath)
+}
+
+// reloadOnHangup reloads the configuration on SIGHUP. Settings that need a
+// restart keep their current values; an invalid configuration is rejected as
+// a whole.
+func reloadOnHangup(current *atomic.Pointer[config.Config], h *handlers.Handlers, oauthService *services.OAuthService) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	for range hangup {
+		next, err := config.Load()
+		if err != nil {
+			logrus.WithError(err).Error("Configuration reload failed, keeping the current configuration")
+			continue
+		}
+
+		cfg, ignored := current.Load().Reloaded(next)
+		if cfg.RequireApproval && cfg.AuthUsersFile == "" {
+			logrus.Error("Configuration reload failed, approval needs authentication enabled at startup, keeping the current configuration")
+			continue
+		}
+		if len(ignored) > 0 {
+			logrus.WithField("settings", ignored).Warn("Changed settings need a restart to apply")
+		}
+
+		current.Store(cfg)
+		h.SetConfig(cfg)
+		oauthService.SetDefaultURLs(cfg.OAuthTokenURL, cfg.OAuthRefreshURL)
+		setLogLevel(cfg.Debug)
+
+		logrus.Info("🔄 Configuration reloaded")
+	}
+}
+
+// seconds converts a configured number of seconds to a duration
+func seconds(n int) time.Duration {
+	return time.Duration(n) * time.Second
 }
 
 func setupLogging(debug bool) {
 	logrus.SetFormatter(&logrus.JSONFormatter{})
+	setLogLevel(debug)
+	logrus.SetOutput(os.Stdout)
+
+	// Add request and trace IDs to log lines of a request
+	logrus.AddHook(telemetry.ContextHook{})
+}
 
+func setLogLevel(debug bool) {
 	if debug {
 		logrus.SetLevel(logrus.DebugLevel)
 	} else {
 		logrus.SetLevel(logrus.InfoLevel)
 	}
-
-	logrus.SetOutput(os.Stdout)
 }